@@ -7,14 +7,22 @@ import (
 
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
 )
 
 func runScript() {
+	runToLine := takeRunToLine()
 	tab := getCurrentTab()
 	if tab == nil || translationLayer == nil {
 		return
 	}
 
+	// F5 continues when stopped at a breakpoint
+	if isDebugStopped() {
+		debugStep(translation.DebugContinue)
+		return
+	}
+
 	// Check if file needs to be saved
 	if tab.modified {
 		dialog := gtk.MessageDialogNew(
@@ -59,22 +67,57 @@ func runScript() {
 		return
 	}
 
+	runConfig := activeRunConfiguration(tab.filename)
+	params := scriptParametersForTab(tab)
+	if missing := missingMandatoryParameters(params, runConfig); len(missing) > 0 {
+		showInfoDialog(mainWindow, "Run Configuration",
+			fmt.Sprintf("The script requires %s. Please set a value in the run configuration.",
+				strings.Join(missing, ", ")))
+		showRunConfigurationsDialog(tab)
+		refreshRunConfigCombo()
+		return
+	}
+	options := runConfig.Options(params)
+
+	filename := tab.filename
+	configName := runConfig.Name
+	runOrQueue("Run "+getBaseName(filename), func() {
+		startScriptRun(filename, configName, options, runToLine)
+	})
+}
+
+// startScriptRun runs a script file in the background
+func startScriptRun(filename, configName string, options translation.RunOptions, runToLine int) {
 	setExecuting(true)
-	statusLabel.SetText("Running script. Press Ctrl+Break to stop.")
+	statusLabel.SetText(fmt.Sprintf("Running script (%s). Press Ctrl+Break to stop.", configName))
+	beginConsoleBlock(getBaseName(filename))
 
+	tl := translationLayer
+	updateBreakpointSnapshot()
 	go func() {
-		output, err := translationLayer.ExecuteScript(tab.filename)
+		syncBreakpoints(tl)
+		if runToLine > 0 {
+			tl.PrepareRunToLine(filename, runToLine)
+		}
+		_, err := tl.ExecuteScriptWithOptions(filename, options)
+		objects := collectObjectCapture(tl)
+		errors := collectErrorRecords(tl)
+		stop, frames := probeDebugger(tl, runToLine > 0)
+		watches := evaluateWatches(tl)
 
 		glib.IdleAdd(func() bool {
+			flushStreamedOutput()
 			if err != nil {
 				displayOutput(fmt.Sprintf("\nError: %v\n", err))
-			} else {
-				// Display the script output
-				displayOutput(output)
 			}
+			displayObjectCapture(objects)
+			displayErrorRecords(errors)
+			setRunErrors(errors)
 			displayPrompt()
 			setExecuting(false)
 			statusLabel.SetText("Ready")
+			updateDebugUI(stop, frames)
+			updateWatchResults(watches)
 			return false
 		})
 	}()
@@ -98,27 +141,51 @@ func runSelection() {
 		return
 	}
 
-	selection, _ := tab.buffer.GetText(start, end, false)
+	selection, _ := tab.buffer.GetText(start, end, true)
 	if selection == "" {
 		return
 	}
 
+	// Run the selection at its real position so errors point back here
+	sourceName := tabSourceName(tab)
+	line := start.GetLine() + 1
+	column := start.GetLineOffset() + 1
+
+	label := fmt.Sprintf("Run selection (%s, line %d)", getBaseName(sourceName), line)
+	runOrQueue(label, func() {
+		startSelectionRun(selection, sourceName, line, column)
+	})
+}
+
+// startSelectionRun runs selected code in the background at its editor position
+func startSelectionRun(selection, sourceName string, line, column int) {
 	setExecuting(true)
 	statusLabel.SetText("Running selection. Press Ctrl+Break to stop.")
+	beginConsoleBlock(selection)
 
+	tl := translationLayer
+	updateBreakpointSnapshot()
 	go func() {
-		output, err := translationLayer.ExecuteSelection(strings.TrimSpace(selection))
+		syncBreakpoints(tl)
+		_, err := tl.ExecuteSelectionAt(selection, sourceName, line, column)
+		objects := collectObjectCapture(tl)
+		errors := collectErrorRecords(tl)
+		stop, frames := probeDebugger(tl, false)
+		watches := evaluateWatches(tl)
 
 		glib.IdleAdd(func() bool {
+			flushStreamedOutput()
 			if err != nil {
 				displayOutput(fmt.Sprintf("\nError: %v\n", err))
-			} else {
-				// Display the selection output
-				displayOutput(output)
 			}
+			displayObjectCapture(objects)
+			displayErrorRecords(errors)
+			setRunErrors(errors)
 			displayPrompt()
 			setExecuting(false)
 			statusLabel.SetText("Ready")
+			updateDebugUI(stop, frames)
+			updateWatchResults(watches)
 			return false
 		})
 	}()
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+	"github.com/laurie/ps-ide-go/internal/psanalyzer"
+)
+
+var (
+	scriptAnalyzer         = psanalyzer.New() // Replaced, never modified, as checks read it off the main thread
+	analyzerData           translation.AnalyzerData
+	analyzerDataExecutable string // PowerShell the verbs and aliases were requested from
+)
+
+// loadAnalyzerData reads the verbs and aliases of a PowerShell in the
+// background, then checks the open tabs again
+func loadAnalyzerData(executable string) {
+	if analyzerDataExecutable == executable {
+		return
+	}
+	analyzerDataExecutable = executable
+	go func() {
+		data, err := translation.LoadAnalyzerData(executable)
+		glib.IdleAdd(func() bool {
+			if err != nil {
+				translation.DebugLog("Script analyzer keeps its built-in verbs and aliases: %v", err)
+				return false
+			}
+			if executable != analyzerDataExecutable {
+				return false
+			}
+			analyzerData = data
+			configureScriptAnalyzer()
+			return false
+		})
+	}()
+}
+
+// configureScriptAnalyzer applies the verbs, aliases and disabled rules,
+// then checks the open tabs again
+func configureScriptAnalyzer() {
+	analyzer := psanalyzer.New()
+	if len(analyzerData.Verbs) > 0 {
+		analyzer.ApprovedVerbs = make(map[string]bool, len(analyzerData.Verbs))
+		for _, verb := range analyzerData.Verbs {
+			analyzer.ApprovedVerbs[strings.ToLower(verb)] = true
+		}
+	}
+	if len(analyzerData.Aliases) > 0 {
+		analyzer.Aliases = make(map[string]string, len(analyzerData.Aliases))
+		for alias, command := range analyzerData.Aliases {
+			analyzer.Aliases[strings.ToLower(alias)] = command
+		}
+	}
+	if appConfig != nil {
+		for _, id := range appConfig.DisabledAnalyzerRules {
+			analyzer.Disabled[id] = true
+		}
+	}
+	scriptAnalyzer = analyzer
+
+	for _, tab := range openTabs {
+		scheduleDiagnostics(tab)
+	}
+}
+
+// analyzeScript runs the analyzer and converts its findings to warnings.
+// It is safe to call off the main thread.
+func analyzeScript(analyzer *psanalyzer.Analyzer, text string) []Diagnostic {
+	findings := analyzer.Analyze(text)
+	if len(findings) == 0 {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	column := func(line, offset int) int {
+		if line >= len(lines) || offset > len(lines[line]) {
+			return offset
+		}
+		return utf8.RuneCountInString(lines[line][:offset])
+	}
+
+	diagnostics := make([]Diagnostic, 0, len(findings))
+	for _, f := range findings {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity:  SeverityWarning,
+			Line:      f.Line,
+			Column:    column(f.Line, f.Start),
+			EndLine:   f.EndLine,
+			EndColumn: column(f.EndLine, f.End),
+			Message:   f.Message,
+			Rule:      f.RuleID,
+			Fix:       f.Fix,
+		})
+	}
+	return diagnostics
+}
+
+// applyQuickFix applies a fix as one undo step. It does nothing if the
+// text it replaces has changed since the script was analyzed.
+func applyQuickFix(tab *ScriptTab, fix *psanalyzer.Fix) bool {
+	if fix == nil || len(fix.Edits) == 0 {
+		return false
+	}
+	for _, edit := range fix.Edits {
+		if edit.Line >= tab.buffer.GetLineCount() {
+			return false
+		}
+		lineEnd := tab.buffer.GetIterAtLine(edit.Line)
+		if !lineEnd.EndsLine() {
+			lineEnd.ForwardToLineEnd()
+		}
+		if edit.End > lineEnd.GetLineIndex() {
+			return false
+		}
+		start := tab.buffer.GetIterAtLineIndex(edit.Line, edit.Start)
+		end := tab.buffer.GetIterAtLineIndex(edit.Line, edit.End)
+		if text, _ := tab.buffer.GetText(start, end, true); text != edit.Old {
+			return false
+		}
+	}
+
+	// Later edits first, so earlier offsets stay valid
+	edits := append([]psanalyzer.Edit(nil), fix.Edits...)
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].Line != edits[j].Line {
+			return edits[i].Line > edits[j].Line
+		}
+		return edits[i].Start > edits[j].Start
+	})
+
+	tab.buffer.BeginUserAction()
+	for _, edit := range edits {
+		start := tab.buffer.GetIterAtLineIndex(edit.Line, edit.Start)
+		end := tab.buffer.GetIterAtLineIndex(edit.Line, edit.End)
+		tab.buffer.Delete(start, end)
+		start = tab.buffer.GetIterAtLineIndex(edit.Line, edit.Start)
+		tab.buffer.Insert(start, edit.New)
+	}
+	tab.buffer.EndUserAction()
+
+	statusLabel.SetText(fmt.Sprintf("Quick fix: %s", fix.Description))
+	return true
+}
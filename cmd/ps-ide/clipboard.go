@@ -70,6 +70,7 @@ func undoText() {
 
 	if tab.undoStack.CanUndo() {
 		tab.undoStack.Undo()
+		tab.textView.ScrollMarkOnscreen(tab.buffer.GetInsert())
 		statusLabel.SetText("Undo")
 		updateToolbarButtons()
 	}
@@ -83,6 +84,7 @@ func redoText() {
 
 	if tab.undoStack.CanRedo() {
 		tab.undoStack.Redo()
+		tab.textView.ScrollMarkOnscreen(tab.buffer.GetInsert())
 		statusLabel.SetText("Redo")
 		updateToolbarButtons()
 	}
@@ -923,7 +923,9 @@ func (addon *CommandAddOn) onInsertCommand() {
 	// Insert at cursor in active script
 	tab := getCurrentTab()
 	if tab != nil {
+		tab.buffer.BeginUserAction()
 		tab.buffer.InsertAtCursor(cmd)
+		tab.buffer.EndUserAction()
 	}
 }
 
@@ -953,13 +955,7 @@ func (addon *CommandAddOn) onShowHelp() {
 
 // executePowerShellCommand executes a command in the PowerShell console
 func executePowerShellCommand(cmd string) {
-	// Add command to console and execute
-	if consoleTextView != nil {
-		buffer, _ := consoleTextView.GetBuffer()
-		endIter := buffer.GetEndIter()
-		buffer.Insert(endIter, "\n"+cmd+"\n")
-	}
-
-	// Execute via console's executeCommand
-	go executeCommand(cmd)
+	// Echo the command at the prompt and execute it, or queue it behind
+	// the running command
+	submitConsoleCommand(cmd, true)
 }
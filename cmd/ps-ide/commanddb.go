@@ -109,7 +109,7 @@ func (db *CommandDatabase) LoadFromPowerShell() error {
 	defer db.mutex.Unlock()
 
 	// Execute Get-Command and convert to JSON - select only string fields
-	cmd := exec.Command("pwsh", "-NoProfile", "-Command",
+	cmd := exec.Command(powerShellPath(), "-NoProfile", "-Command",
 		`Get-Command | Select-Object @{N='Name';E={$_.Name}}, @{N='CommandType';E={$_.CommandType.ToString()}}, @{N='ModuleName';E={$_.ModuleName}} | ConvertTo-Json -Compress`)
 
 	output, err := cmd.Output()
@@ -288,7 +288,7 @@ if ($cmd.Parameters) {
 $result | ConvertTo-Json -Depth 5 -Compress
 `, cmdName)
 
-	cmd := exec.Command("pwsh", "-NoProfile", "-Command", psScript)
+	cmd := exec.Command(powerShellPath(), "-NoProfile", "-Command", psScript)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		log.Printf("PowerShell error for %s: %v, output: %s", cmdName, err, string(output))
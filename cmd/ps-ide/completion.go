@@ -0,0 +1,791 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+	"github.com/laurie/ps-ide-go/internal/psanalyzer"
+)
+
+const (
+	completionLimit     = 200 // Most rows the completion list shows
+	completionHelpDelay = 250 // Milliseconds a command stays selected before its help is fetched
+)
+
+var (
+	completionPopover   *gtk.Popover
+	completionStore     *gtk.ListStore
+	completionView      *gtk.TreeView
+	completionDetail    *gtk.Label
+	completionTab       *ScriptTab
+	completionContext   psanalyzer.Completion
+	completionLine      int                          // 0-based line being completed
+	completionScope     string                       // Key of the context the items were gathered for
+	completionItems     []translation.CompletionItem // Candidates for the context, before filtering
+	completionShown     []int                        // Item indexes of the rows
+	completionVersion   int                          // Bumped whenever the items are gathered; stale results are dropped
+	completionLoading   bool                         // Items are being fetched in the background
+	completionPending   bool                         // A refresh is queued
+	completionInserting bool                         // Set while an item is inserted, so it triggers nothing
+
+	// Bumped whenever the selection changes; stale help lookups are dropped
+	completionDetailVersion int
+
+	// Callbacks waiting for the help of commands being fetched, by name
+	completionHelpWaiters = make(map[string][]func(*CommandHelp))
+)
+
+var completionIcons = map[translation.CompletionType]string{
+	translation.CommandCompletion:   "system-run",
+	translation.ParameterCompletion: "document-properties",
+	translation.VariableCompletion:  "insert-text",
+	translation.PathCompletion:      "text-x-generic",
+	translation.MemberCompletion:    "media-record",
+	translation.TypeCompletion:      "package-x-generic",
+}
+
+// commonParameters are the parameters every cmdlet and advanced function
+// takes. Get-Help leaves them out.
+var commonParameters = []string{
+	"Debug", "ErrorAction", "ErrorVariable", "InformationAction", "InformationVariable",
+	"OutBuffer", "OutVariable", "PipelineVariable", "ProgressAction", "Verbose",
+	"WarningAction", "WarningVariable",
+}
+
+// completionTriggers are the kinds of completion typing a character opens
+// the list for
+var completionTriggers = map[string][]psanalyzer.CompletionKind{
+	"-": {psanalyzer.CompleteParameter, psanalyzer.CompleteCommand},
+	"$": {psanalyzer.CompleteVariable},
+	".": {psanalyzer.CompleteMember, psanalyzer.CompleteType},
+	":": {psanalyzer.CompleteMember, psanalyzer.CompleteVariable},
+	`\`: {psanalyzer.CompletePath},
+	"/": {psanalyzer.CompletePath},
+}
+
+// createCompletionPopover creates the completion list with its detail pane
+func createCompletionPopover() {
+	completionStore, _ = gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING)
+	completionView, _ = gtk.TreeViewNew()
+	completionView.SetModel(completionStore)
+	completionView.SetHeadersVisible(false)
+	completionView.SetEnableSearch(false)
+	completionView.SetActivateOnSingleClick(true)
+	completionView.SetCanFocus(false) // The editor keeps the focus
+
+	column, _ := gtk.TreeViewColumnNew()
+	iconRenderer, _ := gtk.CellRendererPixbufNew()
+	column.PackStart(iconRenderer, false)
+	column.AddAttribute(iconRenderer, "icon-name", 0)
+	textRenderer, _ := gtk.CellRendererTextNew()
+	column.PackStart(textRenderer, true)
+	column.AddAttribute(textRenderer, "text", 1)
+	completionView.AppendColumn(column)
+
+	selection, _ := completionView.GetSelection()
+	selection.Connect("changed", func() { showCompletionDetail() })
+	completionView.Connect("row-activated", func() { acceptCompletion() })
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scroll.SetSizeRequest(380, 200)
+	scroll.Add(completionView)
+
+	completionDetail, _ = gtk.LabelNew("")
+	completionDetail.SetLineWrap(true)
+	completionDetail.SetMaxWidthChars(50)
+	completionDetail.SetXAlign(0)
+	completionDetail.SetYAlign(0)
+	completionDetail.SetMarginStart(6)
+	completionDetail.SetMarginEnd(6)
+	completionDetail.SetMarginTop(4)
+	completionDetail.SetMarginBottom(4)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	box.PackStart(scroll, true, true, 0)
+	separator, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+	box.PackStart(separator, false, false, 0)
+	box.PackStart(completionDetail, false, false, 0)
+	box.ShowAll()
+
+	completionPopover, _ = gtk.PopoverNew(nil)
+	completionPopover.SetModal(false)
+	completionPopover.SetPosition(gtk.POS_BOTTOM)
+	completionPopover.Add(box)
+}
+
+// setupCompletion opens the completion list as trigger characters are
+// typed and keeps it in step with typing and cursor moves
+func setupCompletion(tab *ScriptTab) {
+	tab.buffer.ConnectAfter("insert-text", func(_ *gtk.TextBuffer, _ *gtk.TextIter, text string, _ int) {
+		kinds, ok := completionTriggers[text]
+		if !ok || completionInserting || !isScriptTab(tab) || !tab.textView.HasFocus() {
+			return
+		}
+		glib.IdleAdd(func() bool {
+			if isTabOpen(tab) && tab == getCurrentTab() {
+				startCompletion(tab, kinds...)
+			}
+			return false
+		})
+	})
+	tab.buffer.Connect("notify::cursor-position", func() {
+		if tab != completionTab || !completionVisible() || completionPending {
+			return
+		}
+		completionPending = true
+		glib.IdleAdd(func() bool {
+			completionPending = false
+			refreshCompletion()
+			return false
+		})
+	})
+	tab.textView.Connect("focus-out-event", func() {
+		glib.IdleAdd(func() bool {
+			if tab == completionTab && !tab.textView.HasFocus() {
+				hideCompletion()
+			}
+			return false
+		})
+	})
+	tab.textView.Connect("button-press-event", func() {
+		if tab == completionTab {
+			hideCompletion()
+		}
+	})
+}
+
+// completionVisible reports whether the list is open. It counts as closed
+// once hidden, while the popover may still be fading out.
+func completionVisible() bool {
+	return completionTab != nil && completionPopover != nil && completionPopover.IsVisible()
+}
+
+// triggerCompletion opens the completion list at the cursor of the current
+// tab, whatever can be completed there (Ctrl+Space)
+func triggerCompletion() {
+	tab := getCurrentTab()
+	if tab == nil || !isScriptTab(tab) {
+		return
+	}
+	tab.textView.GrabFocus()
+	startCompletion(tab)
+}
+
+// completionAt returns what can be completed at the cursor, with the
+// cursor's line and the tab's text
+func completionAt(tab *ScriptTab) (psanalyzer.Completion, int, string) {
+	start, end := tab.buffer.GetBounds()
+	text, _ := tab.buffer.GetText(start, end, true)
+	cursor := tab.buffer.GetIterAtMark(tab.buffer.GetInsert())
+	lineStart := tab.buffer.GetIterAtLine(cursor.GetLine())
+	before, _ := tab.buffer.GetText(lineStart, cursor, true)
+	return psanalyzer.CompletionAt(text, cursor.GetLine(), len(before)), cursor.GetLine(), text
+}
+
+// completionScopeOf tells contexts apart whose candidates differ: another
+// kind, position or command, a path's directory, a type's namespace
+func completionScopeOf(c psanalyzer.Completion, line int) string {
+	scope := ""
+	switch c.Kind {
+	case psanalyzer.CompletePath:
+		scope = c.Prefix[:strings.LastIndexAny(c.Prefix, `\/`)+1]
+	case psanalyzer.CompleteType:
+		scope = c.Prefix[:strings.LastIndex(c.Prefix, ".")+1]
+	case psanalyzer.CompleteVariable:
+		scope = fmt.Sprint(isEnvPrefix(c.Prefix))
+	}
+	return fmt.Sprintf("%d %d %d %s %t %s", c.Kind, line, c.Start, strings.ToLower(c.Command), c.Static, scope)
+}
+
+// startCompletion opens the completion list at the cursor if what is there
+// can be completed and is one of kinds, or of any kind if none are given
+func startCompletion(tab *ScriptTab, kinds ...psanalyzer.CompletionKind) {
+	c, line, text := completionAt(tab)
+	if c.Kind == psanalyzer.CompleteNone {
+		hideCompletion()
+		return
+	}
+	if len(kinds) > 0 {
+		wanted := false
+		for _, kind := range kinds {
+			wanted = wanted || c.Kind == kind
+		}
+		if !wanted {
+			return
+		}
+	}
+	loadCompletion(tab, c, line, text)
+}
+
+// refreshCompletion follows typing and cursor moves while the list is
+// open: the items are filtered again, gathered again if the context moved
+// on, as into another directory, or the list is closed if it ended
+func refreshCompletion() {
+	tab := completionTab
+	if tab == nil || !completionVisible() || tab != getCurrentTab() {
+		hideCompletion()
+		return
+	}
+	c, line, text := completionAt(tab)
+	switch {
+	case c.Kind != completionContext.Kind || line != completionLine:
+		hideCompletion()
+	case completionScopeOf(c, line) != completionScope:
+		loadCompletion(tab, c, line, text)
+	default:
+		completionContext = c
+		filterCompletion()
+	}
+}
+
+// loadCompletion gathers the candidates for a context and shows them.
+// Commands, variables, paths and script functions are at hand; parameters
+// may need the command's help, and types and members come from the
+// syntax checker's PowerShell, both fetched in the background.
+func loadCompletion(tab *ScriptTab, c psanalyzer.Completion, line int, text string) {
+	completionVersion++
+	version := completionVersion
+	completionTab = tab
+	completionContext = c
+	completionLine = line
+	completionScope = completionScopeOf(c, line)
+	completionItems = nil
+	completionLoading = false
+
+	update := func(items []translation.CompletionItem) {
+		glib.IdleAdd(func() bool {
+			if version == completionVersion && tab == completionTab {
+				completionItems = items
+				completionLoading = false
+				filterCompletion()
+			}
+			return false
+		})
+	}
+
+	switch c.Kind {
+	case psanalyzer.CompleteCommand:
+		completionItems = commandCompletions(c)
+	case psanalyzer.CompleteVariable:
+		completionItems = variableCompletions(c)
+	case psanalyzer.CompletePath:
+		completionItems = pathCompletions(tab, c, line)
+	case psanalyzer.CompleteParameter:
+		items, command := parameterCompletions(c)
+		completionItems = items
+		if command != nil && len(command.Parameters) == 0 {
+			completionLoading = true
+			requestCompletionHelp(command.Name, func(*CommandHelp) {
+				if version == completionVersion && tab == completionTab {
+					items, _ := parameterCompletions(c)
+					update(items)
+				}
+			})
+		}
+	case psanalyzer.CompleteType, psanalyzer.CompleteMember:
+		// Ask for everything in the scope; what follows is typed filter
+		cursor := tab.buffer.GetIterAtMark(tab.buffer.GetInsert())
+		lineStart := tab.buffer.GetIterAtLine(cursor.GetLine())
+		before, _ := tab.buffer.GetText(tab.buffer.GetStartIter(), lineStart, true)
+		typed := len(c.Prefix)
+		if c.Kind == psanalyzer.CompleteType {
+			typed -= strings.LastIndex(c.Prefix, ".") + 1
+		}
+		offset := len(before) + c.Start + len(c.Prefix) - typed
+		script := text[:offset] + text[offset+typed:]
+		checker := getSyntaxChecker()
+		completionLoading = true
+		go func() {
+			items, err := checker.Complete(script, offset)
+			if err != nil {
+				log.Printf("Completion failed: %v", err)
+			}
+			for i := range items {
+				if c.Kind == psanalyzer.CompleteType {
+					items[i].Text = strings.TrimPrefix(items[i].Text, "[")
+				}
+			}
+			update(items)
+		}()
+	}
+	filterCompletion()
+}
+
+// requestCompletionHelp fetches a command's help in the background and
+// calls done with it on the main thread, with nil if it failed. Requests
+// for a command whose help is already being fetched wait for that lookup
+// rather than starting another PowerShell.
+func requestCompletionHelp(name string, done func(*CommandHelp)) {
+	waiters, fetching := completionHelpWaiters[name]
+	completionHelpWaiters[name] = append(waiters, done)
+	if fetching {
+		return
+	}
+	go func() {
+		help, err := commandDatabase.GetCommandHelp(name)
+		if err != nil {
+			log.Printf("Error getting help for %s: %v", name, err)
+			help = nil
+		}
+		glib.IdleAdd(func() bool {
+			waiters := completionHelpWaiters[name]
+			delete(completionHelpWaiters, name)
+			for _, done := range waiters {
+				done(help)
+			}
+			return false
+		})
+	}()
+}
+
+// findCommand looks a command up in the command database, ignoring case
+func findCommand(name string) *CommandHelp {
+	if command := commandDatabase.GetCommand(name); command != nil {
+		return command
+	}
+	for _, command := range commandDatabase.Search(name, "") {
+		if strings.EqualFold(command.Name, name) {
+			return command
+		}
+	}
+	return nil
+}
+
+// commandCompletions lists the known commands and the script's functions
+func commandCompletions(c psanalyzer.Completion) []translation.CompletionItem {
+	var items []translation.CompletionItem
+	seen := make(map[string]bool)
+	for _, f := range c.Functions {
+		if lower := strings.ToLower(f.Name); !seen[lower] {
+			seen[lower] = true
+			items = append(items, translation.CompletionItem{
+				Text:        f.Name,
+				Type:        translation.CommandCompletion,
+				Description: functionDescription(f),
+			})
+		}
+	}
+	for _, command := range commandDatabase.Search("", "") {
+		if lower := strings.ToLower(command.Name); !seen[lower] {
+			seen[lower] = true
+			items = append(items, translation.CompletionItem{
+				Text:        command.Name,
+				Type:        translation.CommandCompletion,
+				Description: command.Synopsis,
+			})
+		}
+	}
+	return items
+}
+
+// functionDescription describes a function of the script
+func functionDescription(f psanalyzer.ScriptFunction) string {
+	if len(f.Parameters) == 0 {
+		return "Function in this script"
+	}
+	return "Function in this script: -" + strings.Join(f.Parameters, ", -")
+}
+
+// parameterCompletions lists the parameters of the command being
+// completed, a function of the script or a known command, whose aliases
+// are followed. It also returns the known command, if that is what it is.
+func parameterCompletions(c psanalyzer.Completion) ([]translation.CompletionItem, *CommandHelp) {
+	name := c.Command
+	if command, ok := scriptAnalyzer.Aliases[strings.ToLower(name)]; ok {
+		name = command
+	}
+	var items []translation.CompletionItem
+	for _, f := range c.Functions {
+		if strings.EqualFold(f.Name, name) {
+			for _, param := range f.Parameters {
+				items = append(items, translation.CompletionItem{Text: "-" + param, Type: translation.ParameterCompletion})
+			}
+			return items, nil
+		}
+	}
+
+	command := findCommand(name)
+	if command == nil {
+		return nil, nil
+	}
+	seen := make(map[string]bool)
+	for _, param := range command.Parameters {
+		if lower := strings.ToLower(param.Name); !seen[lower] {
+			seen[lower] = true
+			items = append(items, translation.CompletionItem{
+				Text:        "-" + param.Name,
+				Type:        translation.ParameterCompletion,
+				Description: parameterDescription(param),
+			})
+		}
+	}
+	if len(command.Parameters) > 0 {
+		for _, param := range commonParameters {
+			if !seen[strings.ToLower(param)] {
+				items = append(items, translation.CompletionItem{
+					Text:        "-" + param,
+					Type:        translation.ParameterCompletion,
+					Description: "Common parameter",
+				})
+			}
+		}
+	}
+	return items, command
+}
+
+// parameterDescription shows a parameter's type, whether it is required,
+// and its help
+func parameterDescription(param Parameter) string {
+	var parts []string
+	if param.Type != "" {
+		parts = append(parts, "["+param.Type+"]")
+	}
+	if param.Required {
+		parts = append(parts, "Required.")
+	}
+	if param.Description != "" {
+		parts = append(parts, param.Description)
+	}
+	return strings.Join(parts, " ")
+}
+
+// isEnvPrefix reports whether a variable being typed is in env:
+func isEnvPrefix(prefix string) bool {
+	return len(prefix) >= 5 && strings.EqualFold(prefix[1:5], "env:")
+}
+
+// variableCompletions lists the variables in scope, or the environment
+// variables after $env:
+func variableCompletions(c psanalyzer.Completion) []translation.CompletionItem {
+	sigil := "$"
+	if strings.HasPrefix(c.Prefix, "@") {
+		sigil = "@" // Splatting
+	}
+	var items []translation.CompletionItem
+	if isEnvPrefix(c.Prefix) {
+		scope := c.Prefix[:5]
+		for _, variable := range os.Environ() {
+			if name, _, ok := strings.Cut(variable, "="); ok && name != "" {
+				items = append(items, translation.CompletionItem{
+					Text:        scope + name,
+					Type:        translation.VariableCompletion,
+					Description: "Environment variable",
+				})
+			}
+		}
+		return items
+	}
+	for _, name := range c.Variables {
+		items = append(items, translation.CompletionItem{Text: sigil + name, Type: translation.VariableCompletion})
+	}
+	return items
+}
+
+// pathCompletions lists the files and folders in the directory typed so
+// far. Relative paths are taken from the script's folder, or the working
+// folder for unsaved scripts. Names with spaces are quoted unless the path
+// is already in a string.
+func pathCompletions(tab *ScriptTab, c psanalyzer.Completion, line int) []translation.CompletionItem {
+	typedDir := c.Prefix[:strings.LastIndexAny(c.Prefix, `\/`)+1]
+	dir := strings.ReplaceAll(typedDir, `\`, string(filepath.Separator))
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(dir, "~") {
+		dir = home + dir[1:]
+	}
+	if !filepath.IsAbs(dir) && !(len(dir) >= 2 && dir[1] == ':') {
+		base, _ := os.Getwd()
+		if tab.filename != "" {
+			base = filepath.Dir(tab.filename)
+		}
+		dir = filepath.Join(base, dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	separator := string(filepath.Separator)
+	if i := strings.LastIndexAny(typedDir, `\/`); i >= 0 {
+		separator = typedDir[i : i+1]
+	}
+	lineText := completionLineText(tab, line)
+	inString := c.Start > 0 && c.Start <= len(lineText) && strings.ContainsAny(lineText[c.Start-1:c.Start], `'"`)
+	hidden := strings.HasPrefix(c.Prefix[len(typedDir):], ".")
+
+	var items []translation.CompletionItem
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !hidden {
+			continue
+		}
+		text, description := typedDir+name, "File"
+		if entry.IsDir() {
+			description = "Folder"
+		}
+		switch {
+		case strings.Contains(name, " ") && !inString:
+			text = "'" + strings.ReplaceAll(text, "'", "''") + "'"
+		case entry.IsDir():
+			text += separator
+		}
+		items = append(items, translation.CompletionItem{Text: text, Type: translation.PathCompletion, Description: description})
+	}
+	return items
+}
+
+// completionFilterKey is the part of a candidate or of the typed text that
+// is matched: without a sigil, env: or dash, and only the last part of a
+// path or type name
+func completionFilterKey(text string, kind psanalyzer.CompletionKind) string {
+	text = strings.TrimLeft(text, "$@-'")
+	if isEnvPrefix("$" + text) {
+		text = text[4:]
+	}
+	switch kind {
+	case psanalyzer.CompletePath:
+		text = strings.TrimRight(text, `\/'`)
+		text = text[strings.LastIndexAny(text, `\/`)+1:]
+	case psanalyzer.CompleteType:
+		text = text[strings.LastIndex(text, ".")+1:]
+	}
+	return text
+}
+
+// completionLineText returns the text of a 0-based line of a tab
+func completionLineText(tab *ScriptTab, line int) string {
+	start := tab.buffer.GetIterAtLine(line)
+	end := tab.buffer.GetIterAtLine(line)
+	if !end.EndsLine() {
+		end.ForwardToLineEnd()
+	}
+	text, _ := tab.buffer.GetText(start, end, true)
+	return text
+}
+
+// filterCompletion shows the items matching what has been typed, best
+// matches first, and closes the list if none do. While items are being
+// fetched, an open list is emptied rather than closed.
+func filterCompletion() {
+	tab := completionTab
+	if tab == nil {
+		return
+	}
+	c := completionContext
+	query := completionFilterKey(c.Prefix, c.Kind)
+	type match struct{ index, score int }
+	var matches []match
+	for i, item := range completionItems {
+		if score := fuzzyScore(query, completionFilterKey(item.Text, c.Kind)); score >= 0 {
+			matches = append(matches, match{i, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return strings.ToLower(completionItems[matches[i].index].Text) < strings.ToLower(completionItems[matches[j].index].Text)
+	})
+	if len(matches) > completionLimit {
+		matches = matches[:completionLimit]
+	}
+	completionStore.Clear()
+	completionShown = completionShown[:0]
+	if len(matches) == 0 {
+		if !completionLoading {
+			hideCompletion()
+		}
+		return
+	}
+
+	for _, m := range matches {
+		item := completionItems[m.index]
+		icon := completionIcons[item.Type]
+		if item.Type == translation.PathCompletion && item.Description == "Folder" {
+			icon = "folder"
+		}
+		completionStore.Set(completionStore.Append(), []int{0, 1}, []interface{}{icon, item.Text})
+		completionShown = append(completionShown, m.index)
+	}
+	selectCompletionRow(0)
+	showCompletionPopover(tab)
+}
+
+// showCompletionPopover points the list at the start of the text being
+// completed
+func showCompletionPopover(tab *ScriptTab) {
+	iter := tab.buffer.GetIterAtLine(completionLine)
+	if lineText := completionLineText(tab, completionLine); completionContext.Start <= len(lineText) {
+		iter.SetLineOffset(utf8.RuneCountInString(lineText[:completionContext.Start]))
+	}
+	location := tab.textView.GetIterLocation(iter)
+	x, y := tab.textView.BufferToWindowCoords(gtk.TEXT_WINDOW_WIDGET, location.GetX(), location.GetY())
+
+	completionPopover.SetRelativeTo(tab.textView)
+	completionPopover.SetPointingTo(*gdk.RectangleNew(x, y, 1, location.GetHeight()))
+	if !completionVisible() {
+		completionPopover.Popup()
+		tab.textView.GrabFocus()
+	}
+}
+
+func hideCompletion() {
+	completionVersion++
+	completionTab = nil
+	completionItems = nil
+	completionShown = completionShown[:0]
+	completionLoading = false
+	if completionVisible() {
+		completionPopover.Popdown()
+	}
+}
+
+func selectCompletionRow(row int) {
+	if path, err := gtk.TreePathNewFromString(fmt.Sprint(row)); err == nil {
+		selection, _ := completionView.GetSelection()
+		selection.SelectPath(path)
+		completionView.ScrollToCell(path, nil, false, 0, 0)
+	}
+}
+
+// selectedCompletion returns the item of the selected row
+func selectedCompletion() (translation.CompletionItem, bool) {
+	selection, _ := completionView.GetSelection()
+	_, iter, ok := selection.GetSelected()
+	if !ok {
+		return translation.CompletionItem{}, false
+	}
+	path, err := completionStore.GetPath(iter)
+	if err != nil {
+		return translation.CompletionItem{}, false
+	}
+	row := path.GetIndices()[0]
+	if row < 0 || row >= len(completionShown) {
+		return translation.CompletionItem{}, false
+	}
+	return completionItems[completionShown[row]], true
+}
+
+// showCompletionDetail shows what the selected item is. A command's
+// synopsis is looked up in the background if it is not known yet, once
+// the selection has rested on it for completionHelpDelay.
+func showCompletionDetail() {
+	completionDetailVersion++
+	item, ok := selectedCompletion()
+	if !ok {
+		completionDetail.Hide()
+		return
+	}
+	setDetail := func(description string) {
+		markup := "<b>" + glib.MarkupEscapeText(item.Text) + "</b>"
+		if description != "" {
+			markup += "\n" + glib.MarkupEscapeText(description)
+		}
+		completionDetail.SetMarkup(markup)
+		completionDetail.Show()
+	}
+	setDetail(item.Description)
+
+	if item.Type != translation.CommandCompletion || item.Description != "" || findCommand(item.Text) == nil {
+		return
+	}
+	version, detailVersion := completionVersion, completionDetailVersion
+	glib.TimeoutAdd(completionHelpDelay, func() bool {
+		if version != completionVersion || detailVersion != completionDetailVersion {
+			return false
+		}
+		requestCompletionHelp(item.Text, func(help *CommandHelp) {
+			if help == nil || help.Synopsis == "" || version != completionVersion {
+				return
+			}
+			for i := range completionItems {
+				if completionItems[i].Text == item.Text && completionItems[i].Type == translation.CommandCompletion {
+					completionItems[i].Description = help.Synopsis
+				}
+			}
+			if selected, ok := selectedCompletion(); ok && selected.Text == item.Text {
+				setDetail(help.Synopsis)
+			}
+		})
+		return false
+	})
+}
+
+// acceptCompletion replaces the text being completed with the selected
+// item, as one undo step. Completing a folder opens the list on its
+// contents.
+func acceptCompletion() {
+	tab := completionTab
+	item, ok := selectedCompletion()
+	if tab == nil || !ok {
+		hideCompletion()
+		return
+	}
+	hideCompletion()
+
+	cursor := tab.buffer.GetIterAtMark(tab.buffer.GetInsert())
+	start := tab.buffer.GetIterAtLine(cursor.GetLine())
+	before, _ := tab.buffer.GetText(start, cursor, true)
+	if completionContext.Start <= len(before) {
+		start.SetLineOffset(utf8.RuneCountInString(before[:completionContext.Start]))
+	}
+
+	completionInserting = true
+	tab.buffer.BeginUserAction()
+	tab.buffer.Delete(start, cursor)
+	tab.buffer.Insert(start, item.Text)
+	tab.buffer.EndUserAction()
+	completionInserting = false
+	tab.textView.ScrollMarkOnscreen(tab.buffer.GetInsert())
+
+	if item.Type == translation.PathCompletion && (strings.HasSuffix(item.Text, `\`) || strings.HasSuffix(item.Text, "/")) {
+		startCompletion(tab, psanalyzer.CompletePath)
+	}
+}
+
+// handleCompletionKey moves through, accepts or closes the open completion
+// list. It returns whether the key was used.
+func handleCompletionKey(keyval, state uint) bool {
+	if !completionVisible() || state&(uint(gdk.CONTROL_MASK)|uint(gdk.MOD1_MASK)) != 0 {
+		return false
+	}
+	switch keyval {
+	case gdk.KEY_Escape:
+		hideCompletion()
+		return true
+	case gdk.KEY_Return, gdk.KEY_KP_Enter, gdk.KEY_Tab:
+		acceptCompletion()
+		return true
+	case gdk.KEY_Up, gdk.KEY_Down, gdk.KEY_Page_Up, gdk.KEY_Page_Down:
+	default:
+		return false
+	}
+
+	row := 0
+	selection, _ := completionView.GetSelection()
+	if _, iter, ok := selection.GetSelected(); ok {
+		if path, err := completionStore.GetPath(iter); err == nil {
+			row = path.GetIndices()[0]
+		}
+	}
+	switch keyval {
+	case gdk.KEY_Up:
+		row--
+	case gdk.KEY_Down:
+		row++
+	case gdk.KEY_Page_Up:
+		row -= 8
+	case gdk.KEY_Page_Down:
+		row += 8
+	}
+	selectCompletionRow(max(0, min(row, len(completionShown)-1)))
+	return true
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
@@ -16,31 +17,34 @@ var (
 	consoleTextBuffer *gtk.TextBuffer
 	promptMark        *gtk.TextMark
 	consoleTags       map[string]*gtk.TextTag
+
+	// Output of the running command, parsed as it is read and waiting to
+	// be queued for the console by the main loop
+	streamedOutput          []translation.PSOutput
+	streamedOutputMu        sync.Mutex
+	streamedOutputScheduled bool
 )
 
 func initTranslationLayer() error {
-	tl, err := translation.New()
+	path := tabPowerShellPath(getCurrentTab())
+	tl, err := layerForExecutable(path)
 	if err != nil {
 		return fmt.Errorf("failed to create translation layer: %w", err)
 	}
 
 	translationLayer = tl
+	setActivePowerShellPath(path)
 
 	// Display initial prompt after a short delay
 	glib.TimeoutAdd(500, func() bool {
 		displayPrompt()
+		refreshPreferenceChecks()
 		return false
 	})
 
 	return nil
 }
 
-func shutdownTranslationLayer() {
-	if translationLayer != nil {
-		translationLayer.Shutdown()
-	}
-}
-
 func createConsoleUI() (*gtk.ScrolledWindow, error) {
 	textView, _ := gtk.TextViewNew()
 	textView.SetEditable(true)
@@ -70,7 +74,7 @@ func createConsoleUI() (*gtk.ScrolledWindow, error) {
 
 	textView.Connect("key-press-event", onConsoleKeyPress)
 
-	textView.AddEvents(int(gdk.BUTTON_PRESS_MASK))
+	textView.AddEvents(int(gdk.BUTTON_PRESS_MASK | gdk.BUTTON_RELEASE_MASK | gdk.POINTER_MOTION_MASK))
 	textView.Connect("button-press-event", func(_ interface{}, event *gdk.Event) bool {
 		if gdk.EventButtonNewFromEvent(event).Button() == 3 {
 			showConsoleContextMenu(event)
@@ -79,62 +83,46 @@ func createConsoleUI() (*gtk.ScrolledWindow, error) {
 		return false
 	})
 
+	// Clickable script locations in error output
+	textView.Connect("button-release-event", func(_ interface{}, event *gdk.Event) bool {
+		return onConsoleLinkClick(event)
+	})
+	textView.Connect("motion-notify-event", func(_ interface{}, event *gdk.Event) bool {
+		return onConsoleLinkMotion(event)
+	})
+
 	return scroll, nil
 }
 
-// createConsoleTags creates text tags for styling different output streams
+// consoleTagStyles are the console's stream and prompt tags.
 // Tags control text colors (override CSS), so all colors must be bright
+var consoleTagStyles = []struct {
+	name       string
+	foreground string
+	weight     int
+}{
+	{"error", "#FF6B6B", 700},       // Error stream - bright red
+	{"warning", "#FFFF00", 500},     // Warning stream - bright yellow
+	{"verbose", "#00FF00", 500},     // Verbose stream - bright green
+	{"debug", "#FF00FF", 500},       // Debug stream - bright magenta
+	{"information", "#00FFFF", 500}, // Information stream - bright cyan
+	{"output", "#FFFFFF", 500},      // Default output - BRIGHT WHITE (most important!)
+	{"prompt", "#00FFFF", 500},      // Prompt - bright cyan (like PowerShell PS>)
+}
+
+// createConsoleTags creates text tags for styling different output streams
 func createConsoleTags(buffer *gtk.TextBuffer) {
 	consoleTags = make(map[string]*gtk.TextTag)
 
-	// Error stream - bright red
-	errorTag := buffer.CreateTag("error", map[string]interface{}{
-		"foreground": "#FF6B6B",
-		"weight":     700,
-	})
-	consoleTags["error"] = errorTag
-
-	// Warning stream - bright yellow
-	warningTag := buffer.CreateTag("warning", map[string]interface{}{
-		"foreground": "#FFFF00",
-		"weight":     500,
-	})
-	consoleTags["warning"] = warningTag
-
-	// Verbose stream - bright green
-	verboseTag := buffer.CreateTag("verbose", map[string]interface{}{
-		"foreground": "#00FF00",
-		"weight":     500,
-	})
-	consoleTags["verbose"] = verboseTag
-
-	// Debug stream - bright magenta
-	debugTag := buffer.CreateTag("debug", map[string]interface{}{
-		"foreground": "#FF00FF",
-		"weight":     500,
-	})
-	consoleTags["debug"] = debugTag
-
-	// Information stream - bright cyan
-	infoTag := buffer.CreateTag("information", map[string]interface{}{
-		"foreground": "#00FFFF",
-		"weight":     500,
-	})
-	consoleTags["information"] = infoTag
-
-	// Default output - BRIGHT WHITE (most important!)
-	outputTag := buffer.CreateTag("output", map[string]interface{}{
-		"foreground": "#FFFFFF",
-		"weight":     500,
-	})
-	consoleTags["output"] = outputTag
+	for _, style := range consoleTagStyles {
+		consoleTags[style.name] = buffer.CreateTag(style.name, map[string]interface{}{
+			"foreground": style.foreground,
+			"weight":     style.weight,
+		})
+	}
 
-	// Prompt - bright cyan (like PowerShell PS>)
-	promptTag := buffer.CreateTag("prompt", map[string]interface{}{
-		"foreground": "#00FFFF",
-		"weight":     500,
-	})
-	consoleTags["prompt"] = promptTag
+	createConsoleLinkTag(buffer)
+	createConsoleSearchTags(buffer)
 }
 
 func displayPrompt() {
@@ -142,6 +130,14 @@ func displayPrompt() {
 		return
 	}
 
+	// Show the prompt after queued output has been rendered
+	if len(renderQueue) > 0 {
+		promptPending = true
+		scheduleConsoleRender()
+		return
+	}
+	endConsoleBlock()
+
 	prompt := translationLayer.GetPrompt()
 
 	endIter := consoleTextBuffer.GetEndIter()
@@ -162,7 +158,9 @@ func displayPrompt() {
 	promptMark = consoleTextBuffer.CreateMark("prompt", endIter, true)
 
 	consoleTextBuffer.PlaceCursor(endIter)
-	consoleTextView.ScrollToIter(endIter, 0.0, false, 0.0, 0.0)
+	restoreTypeAhead()
+	refreshConsoleSearch()
+	consoleTextView.ScrollToIter(consoleTextBuffer.GetEndIter(), 0.0, false, 0.0, 0.0)
 }
 
 func displayOutput(text string) {
@@ -178,28 +176,56 @@ func displayOutput(text string) {
 	// Parse output using the translation layer's parser
 	parsedOutput, err := translationLayer.ParseOutput(text)
 	if err != nil {
-		// If parsing fails, display as plain text with output tag
-		endIter := consoleTextBuffer.GetEndIter()
-		startOffset := endIter.GetOffset()
-		consoleTextBuffer.Insert(endIter, text+"\n")
-
-		// Apply output tag for bright white text
-		if outputTag, ok := consoleTags["output"]; ok {
-			startIter := consoleTextBuffer.GetIterAtOffset(startOffset)
-			endIter = consoleTextBuffer.GetEndIter()
-			consoleTextBuffer.ApplyTag(outputTag, startIter, endIter)
-		}
-
-		consoleTextView.ScrollToIter(consoleTextBuffer.GetEndIter(), 0.0, false, 0.0, 0.0)
+		// If parsing fails, display as plain text with output tag for bright white text
+		queueConsoleText(text+"\n", true, consoleTags["output"])
 		return
 	}
 
-	// Display each parsed output with appropriate formatting
+	// Queue each parsed output with appropriate formatting; it is inserted
+	// in batches by renderConsoleFrame
 	for _, output := range parsedOutput {
 		displayParsedOutput(output)
 	}
+}
 
-	consoleTextView.ScrollToIter(consoleTextBuffer.GetEndIter(), 0.0, false, 0.0, 0.0)
+// streamOutput is the output handler of every translation layer. It runs
+// on the goroutine executing the command, so parsing stays off the main
+// loop, and hands the parsed output over in batches.
+func streamOutput(tl *translation.TranslationLayer, text string) {
+	parsed, err := tl.ParseOutput(text)
+	if err != nil {
+		parsed = []translation.PSOutput{{Stream: translation.OutputStream, Content: text}}
+	}
+	if len(parsed) == 0 {
+		return
+	}
+
+	streamedOutputMu.Lock()
+	streamedOutput = append(streamedOutput, parsed...)
+	schedule := !streamedOutputScheduled
+	streamedOutputScheduled = true
+	streamedOutputMu.Unlock()
+
+	if schedule {
+		glib.IdleAdd(func() bool {
+			flushStreamedOutput()
+			return false
+		})
+	}
+}
+
+// flushStreamedOutput queues the output streamed so far for the console.
+// Commands call it when they finish, before showing what follows.
+func flushStreamedOutput() {
+	streamedOutputMu.Lock()
+	outputs := streamedOutput
+	streamedOutput = nil
+	streamedOutputScheduled = false
+	streamedOutputMu.Unlock()
+
+	for _, output := range outputs {
+		displayParsedOutput(output)
+	}
 }
 
 func displayParsedOutput(output translation.PSOutput) {
@@ -207,6 +233,13 @@ func displayParsedOutput(output translation.PSOutput) {
 		return
 	}
 
+	// Keep the stream tag so stream filters also hide colored output.
+	// ANSI tags are created later, so their colors take priority.
+	var streamTag *gtk.TextTag
+	if output.Stream != translation.OutputStream {
+		streamTag = consoleTags[streamTagName(output.Stream)]
+	}
+
 	// If output has ANSI segments, display each segment with its color
 	if output.IsFormatted && len(output.ANSISegments) > 0 {
 		for _, segment := range output.ANSISegments {
@@ -214,11 +247,6 @@ func displayParsedOutput(output translation.PSOutput) {
 				continue
 			}
 
-			endIter := consoleTextBuffer.GetEndIter()
-			startOffset := endIter.GetOffset()
-
-			consoleTextBuffer.Insert(endIter, segment.Text)
-
 			// Apply color based on ANSI foreground color
 			color := getColorFromANSI(segment.FGColor)
 
@@ -233,31 +261,15 @@ func displayParsedOutput(output translation.PSOutput) {
 				consoleTags[tagName] = tag
 			}
 
-			startIter := consoleTextBuffer.GetIterAtOffset(startOffset)
-			endIter = consoleTextBuffer.GetEndIter()
-			consoleTextBuffer.ApplyTag(tag, startIter, endIter)
+			queueConsoleText(segment.Text, true, tag, streamTag)
 		}
 		// Add newline after all segments
-		consoleTextBuffer.Insert(consoleTextBuffer.GetEndIter(), "\n")
+		queueConsoleText("\n", true, streamTag)
 		return
 	}
 
 	// No ANSI codes - use stream-based coloring
-	var tag *gtk.TextTag
-	switch output.Stream {
-	case translation.ErrorStream:
-		tag = consoleTags["error"]
-	case translation.WarningStream:
-		tag = consoleTags["warning"]
-	case translation.VerboseStream:
-		tag = consoleTags["verbose"]
-	case translation.DebugStream:
-		tag = consoleTags["debug"]
-	case translation.InformationStream:
-		tag = consoleTags["information"]
-	default:
-		tag = consoleTags["output"]
-	}
+	tag := consoleTags[streamTagName(output.Stream)]
 
 	// Format the output
 	formattedText := output.Content
@@ -267,17 +279,8 @@ func displayParsedOutput(output translation.PSOutput) {
 		formattedText += "\n"
 	}
 
-	// Insert with appropriate tag
-	endIter := consoleTextBuffer.GetEndIter()
-	startOffset := endIter.GetOffset()
-
-	consoleTextBuffer.Insert(endIter, formattedText)
-
-	if tag != nil {
-		startIter := consoleTextBuffer.GetIterAtOffset(startOffset)
-		endIter = consoleTextBuffer.GetEndIter()
-		consoleTextBuffer.ApplyTag(tag, startIter, endIter)
-	}
+	// Queue with appropriate tag
+	queueConsoleText(formattedText, true, tag)
 }
 
 // getColorFromANSI converts ANSI color codes to hex colors
@@ -336,35 +339,7 @@ func displayRawOutput(text string, streamType translation.StreamType) {
 		return
 	}
 
-	// Get the appropriate tag
-	var tag *gtk.TextTag
-	switch streamType {
-	case translation.ErrorStream:
-		tag = consoleTags["error"]
-	case translation.WarningStream:
-		tag = consoleTags["warning"]
-	case translation.VerboseStream:
-		tag = consoleTags["verbose"]
-	case translation.DebugStream:
-		tag = consoleTags["debug"]
-	case translation.InformationStream:
-		tag = consoleTags["information"]
-	default:
-		tag = consoleTags["output"]
-	}
-
-	endIter := consoleTextBuffer.GetEndIter()
-	startOffset := endIter.GetOffset()
-
-	consoleTextBuffer.Insert(endIter, text)
-
-	if tag != nil {
-		startIter := consoleTextBuffer.GetIterAtOffset(startOffset)
-		endIter = consoleTextBuffer.GetEndIter()
-		consoleTextBuffer.ApplyTag(tag, startIter, endIter)
-	}
-
-	consoleTextView.ScrollToIter(consoleTextBuffer.GetEndIter(), 0.0, false, 0.0, 0.0)
+	queueConsoleText(text, false, consoleTags[streamTagName(streamType)])
 }
 
 func getUserInput() string {
@@ -397,7 +372,7 @@ func onConsoleKeyPress(_ interface{}, event *gdk.Event) bool {
 	keyval := keyEvent.KeyVal()
 	state := keyEvent.State()
 
-	if translationLayer.IsExecuting() {
+	if isBusy() {
 		if keyval == gdk.KEY_c && (state&uint(gdk.CONTROL_MASK)) != 0 {
 			translationLayer.StopExecution()
 			displayRawOutput("\n^C\n", translation.WarningStream)
@@ -407,7 +382,16 @@ func onConsoleKeyPress(_ interface{}, event *gdk.Event) bool {
 			})
 			return true
 		}
-		return true
+
+		// Type-ahead: Enter queues the line behind the running command
+		if keyval == gdk.KEY_Return || keyval == gdk.KEY_KP_Enter {
+			input := getUserInput()
+			if strings.TrimSpace(input) != "" {
+				clearUserInput()
+				submitConsoleCommand(input, true)
+			}
+			return true
+		}
 	}
 
 	if keyval == gdk.KEY_Up {
@@ -440,7 +424,7 @@ func onConsoleKeyPress(_ interface{}, event *gdk.Event) bool {
 		input := getUserInput()
 		consoleTextBuffer.Insert(consoleTextBuffer.GetEndIter(), "\n")
 
-		go executeCommand(input)
+		submitConsoleCommand(input, false)
 
 		return true
 	}
@@ -462,6 +446,7 @@ func executeCommand(cmd string) {
 	if cmd == "" {
 		glib.IdleAdd(func() bool {
 			displayPrompt()
+			setExecuting(false)
 			return false
 		})
 		return
@@ -470,22 +455,33 @@ func executeCommand(cmd string) {
 	if cmd == "clear" || cmd == "cls" {
 		glib.IdleAdd(func() bool {
 			clearConsole()
+			setExecuting(false)
 			return false
 		})
 		return
 	}
 
 	// Execute command and get output
-	output, err := translationLayer.ExecuteCommand(cmd)
+	tl := translationLayer
+	syncBreakpoints(tl)
+	_, err := tl.ExecuteCommand(cmd)
+	objects := collectObjectCapture(tl)
+	errors := collectErrorRecords(tl)
+	stop, frames := probeDebugger(tl, false)
+	watches := evaluateWatches(tl)
 
 	glib.IdleAdd(func() bool {
+		// The output was streamed while the command ran
+		flushStreamedOutput()
 		if err != nil {
 			displayRawOutput(fmt.Sprintf("Error: %v\n", err), translation.ErrorStream)
-		} else {
-			// Display the output
-			displayOutput(output)
 		}
+		displayObjectCapture(objects)
+		displayErrorRecords(errors)
 		displayPrompt()
+		setExecuting(false)
+		updateDebugUI(stop, frames)
+		updateWatchResults(watches)
 		return false
 	})
 }
@@ -495,9 +491,13 @@ func clearConsole() {
 		return
 	}
 
+	stashTypeAhead()
+	clearRenderQueue()
+	clearConsoleBlocks()
 	consoleTextBuffer.Delete(
 		consoleTextBuffer.GetStartIter(),
 		consoleTextBuffer.GetEndIter())
+	pruneConsoleWidgets()
 	promptMark = nil
 	displayPrompt()
 }
@@ -530,6 +530,17 @@ func applyConsoleColors(textView *gtk.TextView) {
 func showConsoleContextMenu(event *gdk.Event) {
 	menu, _ := gtk.MenuNew()
 
+	// Output of the selected command block, else the one clicked, else the last
+	button := gdk.EventButtonNewFromEvent(event)
+	offset := consoleIterAtPointer(button.X(), button.Y()).GetOffset()
+	if start, _, hasSelection := consoleTextBuffer.GetSelectionBounds(); hasSelection {
+		offset = start.GetOffset()
+	}
+	block := consoleBlockAt(offset)
+	if block == nil {
+		block = lastConsoleBlock()
+	}
+
 	copyItem, _ := gtk.MenuItemNewWithLabel("Copy")
 	copyItem.Connect("activate", func() { copyConsoleSelection() })
 	menu.Append(copyItem)
@@ -542,6 +553,31 @@ func showConsoleContextMenu(event *gdk.Event) {
 	clearItem.Connect("activate", func() { clearConsole() })
 	menu.Append(clearItem)
 
+	openOutputItem, _ := gtk.MenuItemNewWithLabel("Open Output in New Tab")
+	openOutputItem.SetSensitive(block != nil)
+	openOutputItem.Connect("activate", func() { openConsoleBlockInNewTab(block) })
+	menu.Append(openOutputItem)
+
+	sep, _ := gtk.SeparatorMenuItemNew()
+	menu.Append(sep)
+
+	copyHTMLItem, _ := gtk.MenuItemNewWithLabel("Copy as HTML")
+	copyHTMLItem.Connect("activate", func() { copyConsoleAs("html") })
+	menu.Append(copyHTMLItem)
+
+	copyRTFItem, _ := gtk.MenuItemNewWithLabel("Copy as RTF")
+	copyRTFItem.Connect("activate", func() { copyConsoleAs("rtf") })
+	menu.Append(copyRTFItem)
+
+	saveItem, _ := gtk.MenuItemNewWithLabel("Save Console As...")
+	saveItem.Connect("activate", func() { showSaveConsoleDialog() })
+	menu.Append(saveItem)
+
+	includePromptsItem, _ := gtk.CheckMenuItemNewWithLabel("Include Prompts and Commands")
+	includePromptsItem.SetActive(exportIncludePrompts)
+	includePromptsItem.Connect("toggled", func() { exportIncludePrompts = includePromptsItem.GetActive() })
+	menu.Append(includePromptsItem)
+
 	menu.ShowAll()
 	menu.PopupAtPointer(event)
 }
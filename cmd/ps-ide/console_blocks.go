@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// maxConsoleBlocks limits how many command blocks are remembered
+const maxConsoleBlocks = 500
+
+// consoleBlock is the output of one command in the console
+type consoleBlock struct {
+	command string
+	start   *gtk.TextMark
+	end     *gtk.TextMark // nil while the command is running
+}
+
+var (
+	consoleBlocks       []*consoleBlock
+	consoleBlockCounter int // Keeps block mark names unique
+)
+
+// beginConsoleBlock starts recording the output of command, which begins
+// at the end of the console
+func beginConsoleBlock(command string) {
+	if consoleTextBuffer == nil {
+		return
+	}
+	endConsoleBlock()
+
+	consoleBlockCounter++
+	start := consoleTextBuffer.CreateMark(fmt.Sprintf("block-%d-start", consoleBlockCounter), consoleTextBuffer.GetEndIter(), true)
+	consoleBlocks = append(consoleBlocks, &consoleBlock{command: command, start: start})
+}
+
+// endConsoleBlock ends the running block at the end of the console. Called
+// before the next prompt is shown; blocks without output are dropped.
+func endConsoleBlock() {
+	n := len(consoleBlocks)
+	if n == 0 || consoleBlocks[n-1].end != nil {
+		return
+	}
+	block := consoleBlocks[n-1]
+	endIter := consoleTextBuffer.GetEndIter()
+	if consoleTextBuffer.GetIterAtMark(block.start).GetOffset() == endIter.GetOffset() {
+		consoleTextBuffer.DeleteMark(block.start)
+		consoleBlocks = consoleBlocks[:n-1]
+		return
+	}
+	block.end = consoleTextBuffer.CreateMark(fmt.Sprintf("block-%d-end", consoleBlockCounter), endIter, true)
+
+	for len(consoleBlocks) > maxConsoleBlocks {
+		dropConsoleBlock(0)
+	}
+}
+
+func dropConsoleBlock(index int) {
+	block := consoleBlocks[index]
+	consoleTextBuffer.DeleteMark(block.start)
+	if block.end != nil {
+		consoleTextBuffer.DeleteMark(block.end)
+	}
+	consoleBlocks = append(consoleBlocks[:index], consoleBlocks[index+1:]...)
+}
+
+// pruneConsoleBlocks drops blocks whose output was trimmed from the console
+func pruneConsoleBlocks() {
+	for len(consoleBlocks) > 0 {
+		block := consoleBlocks[0]
+		if block.end == nil || consoleTextBuffer.GetIterAtMark(block.end).GetOffset() > 0 {
+			return
+		}
+		dropConsoleBlock(0)
+	}
+}
+
+// clearConsoleBlocks forgets all blocks, e.g. when the console is cleared
+func clearConsoleBlocks() {
+	for len(consoleBlocks) > 0 {
+		dropConsoleBlock(0)
+	}
+}
+
+// consoleBlockAt returns the finished block containing a buffer offset
+func consoleBlockAt(offset int) *consoleBlock {
+	for i := len(consoleBlocks) - 1; i >= 0; i-- {
+		block := consoleBlocks[i]
+		if block.end == nil {
+			continue
+		}
+		start := consoleTextBuffer.GetIterAtMark(block.start).GetOffset()
+		end := consoleTextBuffer.GetIterAtMark(block.end).GetOffset()
+		if offset >= start && offset <= end {
+			return block
+		}
+	}
+	return nil
+}
+
+// lastConsoleBlock returns the most recent finished block
+func lastConsoleBlock() *consoleBlock {
+	for i := len(consoleBlocks) - 1; i >= 0; i-- {
+		if consoleBlocks[i].end != nil {
+			return consoleBlocks[i]
+		}
+	}
+	return nil
+}
+
+// openConsoleBlockInNewTab opens a block's output, without ANSI escape
+// codes, in a new editor tab named after its command
+func openConsoleBlockInNewTab(block *consoleBlock) {
+	if block == nil {
+		return
+	}
+	start := consoleTextBuffer.GetIterAtMark(block.start)
+	end := consoleTextBuffer.GetIterAtMark(block.end)
+	text, _ := consoleTextBuffer.GetText(start, end, false)
+	if translationLayer != nil {
+		text = translationLayer.GetParser().StripANSI(text)
+	}
+
+	tab := createNewTab()
+	tab.title = outputTabTitle(block.command)
+	tab.buffer.SetText(strings.TrimRight(text, "\n") + "\n")
+	tab.undoStack.Clear()
+	tab.modified = false
+	updateTabTitle(tab)
+	statusLabel.SetText("Opened output of " + tab.title)
+}
+
+// outputTabTitle names an output tab after the first line of its command
+func outputTabTitle(command string) string {
+	title := strings.TrimSpace(command)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = strings.TrimSpace(title[:i]) + " ..."
+	}
+	if runes := []rune(title); len(runes) > 40 {
+		title = string(runes[:40]) + "..."
+	}
+	if title == "" {
+		title = "Output"
+	}
+	return title + " (output)"
+}
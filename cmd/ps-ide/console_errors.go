@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+)
+
+// Colours of error record blocks in the console
+const (
+	errorRecordTitleColor = "#FF6B6B"
+	errorRecordNameColor  = "#A0B4D0"
+	errorRecordValueColor = "#FFFFFF"
+)
+
+// collectErrorRecords returns the errors raised by the last command. Runs
+// on worker goroutines.
+func collectErrorRecords(tl *translation.TranslationLayer) []translation.ErrorRecordInfo {
+	records, err := tl.GetNewErrors()
+	if err != nil {
+		return nil
+	}
+	return records
+}
+
+// displayErrorRecords queues a collapsible block for each error record,
+// after the output already queued
+func displayErrorRecords(records []translation.ErrorRecordInfo) {
+	for _, record := range records {
+		rec := record
+		queueConsoleWidget(func() gtk.IWidget {
+			return createErrorRecordWidget(rec)
+		}, nil, consoleTags["error"])
+	}
+}
+
+// createErrorRecordWidget builds the collapsible block for an error record
+func createErrorRecordWidget(rec translation.ErrorRecordInfo) gtk.IWidget {
+	message := rec.Message
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		message = message[:i] + " ..."
+	}
+	title, _ := gtk.LabelNew("")
+	title.SetMarkup(fmt.Sprintf(`<span foreground="%s" weight="bold">%s</span>`,
+		errorRecordTitleColor, glib.MarkupEscapeText(message)))
+	title.SetEllipsize(3) // PANGO_ELLIPSIZE_END
+	title.SetMaxWidthChars(120)
+
+	expander, _ := gtk.ExpanderNew("")
+	expander.SetLabelWidget(title)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 4)
+	box.SetMarginStart(16)
+	box.SetMarginTop(4)
+	box.SetMarginBottom(4)
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(2)
+	grid.SetColumnSpacing(12)
+	row := 0
+	addField := func(name, value string) {
+		if value == "" {
+			return
+		}
+		grid.Attach(errorRecordLabel(name, errorRecordNameColor, false), 0, row, 1, 1)
+		grid.Attach(errorRecordLabel(value, errorRecordValueColor, false), 1, row, 1, 1)
+		row++
+	}
+	addField("Message", rec.Message)
+	addField("FullyQualifiedErrorId", rec.FullyQualifiedErrorID)
+	addField("CategoryInfo", rec.Category)
+	addField("TargetObject", rec.TargetObject)
+	addField("Exception", rec.ExceptionType)
+	if rec.Line > 0 {
+		location := rec.ScriptName
+		if location == "" {
+			location = "<prompt>"
+		}
+		addField("Position", fmt.Sprintf("%s:%d char:%d", location, rec.Line, rec.Column))
+	}
+	box.PackStart(grid, false, false, 0)
+
+	// The offending line with a caret under the error position
+	if rec.LineText != "" {
+		code := strings.TrimRight(rec.LineText, "\r\n")
+		if caret := rec.CaretLine(); caret != "" {
+			code += "\n" + caret
+		}
+		box.PackStart(errorRecordLabel(code, errorRecordValueColor, true), false, false, 0)
+	}
+
+	if rec.ScriptStackTrace != "" {
+		box.PackStart(errorRecordLabel("ScriptStackTrace:", errorRecordNameColor, false), false, false, 0)
+		box.PackStart(errorRecordLabel(rec.ScriptStackTrace, errorRecordValueColor, true), false, false, 0)
+	}
+
+	buttons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	copyButton, _ := gtk.ButtonNewWithLabel("Copy details")
+	copyButton.Connect("clicked", func() {
+		clipboard, _ := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+		clipboard.SetText(rec.Details())
+		statusLabel.SetText("Copied error details")
+	})
+	buttons.PackStart(copyButton, false, false, 0)
+
+	loc := SourceLocation{File: rec.ScriptName, Line: rec.Line, Column: rec.Column}
+	if rec.ScriptName != "" && rec.Line > 0 && sourceLocationExists(loc) {
+		sourceButton, _ := gtk.ButtonNewWithLabel(fmt.Sprintf("Go to %s:%d", getBaseName(rec.ScriptName), rec.Line))
+		sourceButton.SetTooltipText(rec.ScriptName)
+		sourceButton.Connect("clicked", func() { navigateToSource(loc) })
+		buttons.PackStart(sourceButton, false, false, 0)
+	}
+	box.PackStart(buttons, false, false, 0)
+
+	expander.Add(box)
+	expander.ShowAll()
+	return expander
+}
+
+func errorRecordLabel(text, color string, monospace bool) *gtk.Label {
+	markup := fmt.Sprintf(`<span foreground="%s">%s</span>`, color, glib.MarkupEscapeText(text))
+	if monospace {
+		markup = "<tt>" + markup + "</tt>"
+	}
+	label, _ := gtk.LabelNew("")
+	label.SetMarkup(markup)
+	label.SetXAlign(0)
+	label.SetSelectable(true)
+	label.SetLineWrap(true)
+	label.SetMaxWidthChars(120)
+	return label
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Console colours used in exports, matching applyConsoleColors
+const (
+	consoleBackground = "#012456"
+	consoleForeground = "#FFFFFF"
+)
+
+// exportIncludePrompts includes prompts and typed commands in exports
+var exportIncludePrompts = true
+
+// consoleRun is a stretch of console text with one style
+type consoleRun struct {
+	text       string
+	foreground string
+	bold       bool
+}
+
+// consoleTagStyle returns the colour and weight of a console tag
+func consoleTagStyle(name string) (string, int, bool) {
+	if code, ok := strings.CutPrefix(name, "ansi-"); ok {
+		n, err := strconv.Atoi(code)
+		if err != nil {
+			return "", 0, false
+		}
+		return getColorFromANSI(n), 500, true
+	}
+	for _, style := range consoleTagStyles {
+		if style.name == name {
+			return style.foreground, style.weight, true
+		}
+	}
+	return "", 0, false
+}
+
+// consoleRuns splits console text between two offsets into styled runs.
+// Output hidden by stream filters is left out, as are prompts and typed
+// commands unless includePrompts is set.
+func consoleRuns(startOffset, endOffset int, includePrompts bool) []consoleRun {
+	hidden := make(map[string]bool)
+	for _, name := range hiddenStreams() {
+		for _, fs := range filterStreams {
+			if fs.stream.String() == name {
+				hidden[fs.tag] = true
+			}
+		}
+	}
+
+	var runs []consoleRun
+	for offset := startOffset; offset < endOffset; {
+		start := consoleTextBuffer.GetIterAtOffset(offset)
+		end := consoleTextBuffer.GetIterAtOffset(offset)
+		end.ForwardToTagToggle(nil)
+		next := end.GetOffset()
+		if next > endOffset {
+			next = endOffset
+			end = consoleTextBuffer.GetIterAtOffset(next)
+		}
+		if next <= offset {
+			break
+		}
+
+		// ANSI tags take priority over stream tags, as in the console
+		foreground, weight, styled, skip := "", 0, false, false
+		isPrompt := false
+		for name, tag := range consoleTags {
+			if !start.HasTag(tag) {
+				continue
+			}
+			if hidden[name] {
+				skip = true
+			}
+			if name == "prompt" {
+				isPrompt = true
+			}
+			if fg, w, ok := consoleTagStyle(name); ok && (!styled || strings.HasPrefix(name, "ansi-")) {
+				foreground, weight, styled = fg, w, true
+			}
+		}
+		// Untagged text is what was typed at the prompt
+		if !styled || isPrompt {
+			skip = skip || !includePrompts
+		}
+
+		if !skip {
+			text, _ := consoleTextBuffer.GetText(start, end, false)
+			if foreground == "" {
+				foreground = consoleForeground
+			}
+			bold := weight >= 700
+			if n := len(runs); n > 0 && runs[n-1].foreground == foreground && runs[n-1].bold == bold {
+				runs[n-1].text += text
+			} else {
+				runs = append(runs, consoleRun{text: text, foreground: foreground, bold: bold})
+			}
+		}
+		offset = next
+	}
+	return runs
+}
+
+// consoleExportRange returns the selected console range, or the whole console
+func consoleExportRange() (int, int) {
+	if start, end, ok := consoleTextBuffer.GetSelectionBounds(); ok {
+		return start.GetOffset(), end.GetOffset()
+	}
+	return 0, consoleTextBuffer.GetEndIter().GetOffset()
+}
+
+// consoleRunsToHTML renders runs as a coloured <pre> block
+func consoleRunsToHTML(runs []consoleRun) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(`<pre style="background-color:%s;color:%s;font-family:Consolas,'Liberation Mono','Courier New',monospace;font-size:11pt;padding:8px;">`,
+		consoleBackground, consoleForeground))
+	for _, run := range runs {
+		style := "color:" + run.foreground
+		if run.bold {
+			style += ";font-weight:bold"
+		}
+		b.WriteString(`<span style="` + style + `">` + html.EscapeString(run.text) + `</span>`)
+	}
+	b.WriteString("</pre>")
+	return b.String()
+}
+
+// consoleHTMLDocument wraps exported HTML in a standalone page
+func consoleHTMLDocument(runs []consoleRun) string {
+	return "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>PowerShell Console</title>\n</head>\n<body style=\"background-color:" +
+		consoleBackground + ";margin:0;\">\n" + consoleRunsToHTML(runs) + "\n</body>\n</html>\n"
+}
+
+// consoleRunsToRTF renders runs as an RTF document
+func consoleRunsToRTF(runs []consoleRun) string {
+	// Colour table: 1 is the background, the rest are run colours
+	colors := []string{consoleBackground}
+	colorIndex := map[string]int{consoleBackground: 1}
+	for _, run := range runs {
+		if _, ok := colorIndex[run.foreground]; !ok {
+			colors = append(colors, run.foreground)
+			colorIndex[run.foreground] = len(colors)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`{\rtf1\ansi\deff0{\fonttbl{\f0\fmodern Consolas;}}{\colortbl;`)
+	for _, c := range colors {
+		var r, g, bl int
+		fmt.Sscanf(c, "#%02x%02x%02x", &r, &g, &bl)
+		b.WriteString(fmt.Sprintf(`\red%d\green%d\blue%d;`, r, g, bl))
+	}
+	b.WriteString("}\n")
+	b.WriteString(`\f0\fs22\cb1\chshdng0\chcbpat1 `)
+	for _, run := range runs {
+		b.WriteString(fmt.Sprintf(`{\cf%d`, colorIndex[run.foreground]))
+		if run.bold {
+			b.WriteString(`\b`)
+		}
+		b.WriteString(" " + escapeRTF(run.text) + "}")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// escapeRTF escapes text for RTF, writing non-ASCII as \u escapes
+func escapeRTF(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '{' || r == '}':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\line `)
+		case r == '\t':
+			b.WriteString(`\tab `)
+		case r < 0x80:
+			b.WriteRune(r)
+		case r > 0xFFFF:
+			// RTF \u takes a signed 16-bit value; use a surrogate pair
+			r -= 0x10000
+			b.WriteString(fmt.Sprintf(`\u%d?\u%d?`, int16(0xD800+(r>>10)), int16(0xDC00+(r&0x3FF))))
+		default:
+			b.WriteString(fmt.Sprintf(`\u%d?`, int16(r)))
+		}
+	}
+	return b.String()
+}
+
+// copyConsoleAs copies the console selection (or everything) as HTML or
+// RTF. GTK only offers plain text here, so the desktop's clipboard tool is
+// used for the rich format when available.
+func copyConsoleAs(format string) {
+	if consoleTextBuffer == nil {
+		return
+	}
+	start, end := consoleExportRange()
+	runs := consoleRuns(start, end, exportIncludePrompts)
+
+	var data, mime string
+	if format == "rtf" {
+		data, mime = consoleRunsToRTF(runs), "text/rtf"
+	} else {
+		data, mime = consoleRunsToHTML(runs), "text/html"
+	}
+
+	if err := copyRichText(data, mime); err != nil {
+		clipboard, _ := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+		clipboard.SetText(data)
+		statusLabel.SetText(fmt.Sprintf("Copied %s source as text (%v)", strings.ToUpper(format), err))
+		return
+	}
+	statusLabel.SetText("Copied console as " + strings.ToUpper(format))
+}
+
+// copyRichText places data on the clipboard with the given MIME type using
+// wl-copy or xclip
+func copyRichText(data, mime string) error {
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("wl-copy"); err == nil && os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmd = exec.Command("wl-copy", "--type", mime)
+	} else if _, err := exec.LookPath("xclip"); err == nil {
+		cmd = exec.Command("xclip", "-selection", "clipboard", "-t", mime)
+	} else {
+		return fmt.Errorf("install wl-copy or xclip to copy rich text")
+	}
+	cmd.Stdin = strings.NewReader(data)
+	return cmd.Run()
+}
+
+// showSaveConsoleDialog saves the console as HTML, RTF or plain text,
+// chosen by the file extension
+func showSaveConsoleDialog() {
+	if consoleTextBuffer == nil {
+		return
+	}
+
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons(
+		"Save Console As",
+		mainWindow,
+		gtk.FILE_CHOOSER_ACTION_SAVE,
+		"Cancel", gtk.RESPONSE_CANCEL,
+		"Save", gtk.RESPONSE_ACCEPT)
+	dialog.SetDoOverwriteConfirmation(true)
+
+	for _, f := range []struct{ name, pattern string }{
+		{"HTML (*.html)", "*.html"},
+		{"Rich Text (*.rtf)", "*.rtf"},
+		{"Plain Text (*.txt)", "*.txt"},
+	} {
+		filter, _ := gtk.FileFilterNew()
+		filter.SetName(f.name)
+		filter.AddPattern(f.pattern)
+		dialog.AddFilter(filter)
+	}
+
+	includeCheck, _ := gtk.CheckButtonNewWithLabel("Include prompts and commands")
+	includeCheck.SetActive(exportIncludePrompts)
+	dialog.SetExtraWidget(includeCheck)
+
+	if lastOpenDirectory != "" {
+		dialog.SetCurrentFolder(lastOpenDirectory)
+	}
+	dialog.SetCurrentName("console.html")
+
+	if dialog.Run() == gtk.RESPONSE_ACCEPT {
+		filename := dialog.GetFilename()
+		exportIncludePrompts = includeCheck.GetActive()
+		runs := consoleRuns(0, consoleTextBuffer.GetEndIter().GetOffset(), exportIncludePrompts)
+
+		var data string
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".rtf":
+			data = consoleRunsToRTF(runs)
+		case ".txt":
+			var b strings.Builder
+			for _, run := range runs {
+				b.WriteString(run.text)
+			}
+			data = b.String()
+		default:
+			data = consoleHTMLDocument(runs)
+		}
+
+		if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+			showErrorDialog(mainWindow, "Save Console As", fmt.Sprintf("Could not save console: %v", err))
+		} else {
+			lastOpenDirectory = filepath.Dir(filename)
+			statusLabel.SetText("Saved console: " + filename)
+		}
+	}
+	dialog.Destroy()
+}
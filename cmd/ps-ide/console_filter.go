@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+)
+
+// filterStreams are the streams that can be hidden in the console, with
+// the console tag that marks their output
+var filterStreams = []struct {
+	stream translation.StreamType
+	tag    string
+	label  string
+}{
+	{translation.ErrorStream, "error", "Errors"},
+	{translation.WarningStream, "warning", "Warnings"},
+	{translation.VerboseStream, "verbose", "Verbose"},
+	{translation.DebugStream, "debug", "Debug"},
+	{translation.InformationStream, "information", "Information"},
+}
+
+// preferenceStreams are the streams with a session preference toggle
+var preferenceStreams = []struct {
+	stream translation.StreamType
+	label  string
+}{
+	{translation.VerboseStream, "$VerbosePreference"},
+	{translation.DebugStream, "$DebugPreference"},
+	{translation.InformationStream, "$InformationPreference"},
+}
+
+var (
+	streamFilterButtons = make(map[translation.StreamType]*gtk.ToggleButton)
+	preferenceChecks    = make(map[translation.StreamType]*gtk.CheckButton)
+
+	// Streams hidden when the session was saved, applied as the bar is created
+	savedHiddenStreams []string
+
+	// Set while the preference checks are updated from the session
+	updatingPreferenceChecks bool
+)
+
+// streamTagName returns the console tag used for a stream's output
+func streamTagName(stream translation.StreamType) string {
+	for _, fs := range filterStreams {
+		if fs.stream == stream {
+			return fs.tag
+		}
+	}
+	return "output"
+}
+
+// createConsoleFilterBar creates the toolbar above the console with stream
+// filters and session preference toggles
+func createConsoleFilterBar() *gtk.Box {
+	bar, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 4)
+	bar.SetMarginStart(4)
+	bar.SetMarginEnd(4)
+	bar.SetMarginTop(2)
+	bar.SetMarginBottom(2)
+
+	showLabel, _ := gtk.LabelNew("Show:")
+	bar.PackStart(showLabel, false, false, 0)
+
+	for _, fs := range filterStreams {
+		tagName := fs.tag
+		button, _ := gtk.ToggleButtonNewWithLabel(fs.label)
+		button.SetTooltipText(fmt.Sprintf("Show %s output in the console", fs.stream))
+		button.Connect("toggled", func() {
+			setStreamVisible(tagName, button.GetActive())
+		})
+		button.SetActive(!containsString(savedHiddenStreams, fs.stream.String()))
+		streamFilterButtons[fs.stream] = button
+		bar.PackStart(button, false, false, 0)
+	}
+
+	sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_VERTICAL)
+	bar.PackStart(sep, false, false, 6)
+
+	sessionLabel, _ := gtk.LabelNew("Write:")
+	bar.PackStart(sessionLabel, false, false, 0)
+
+	for _, ps := range preferenceStreams {
+		stream := ps.stream
+		check, _ := gtk.CheckButtonNewWithLabel(ps.label)
+		check.SetTooltipText(fmt.Sprintf("Set %s to Continue (checked) or SilentlyContinue in the session", ps.label))
+		check.Connect("toggled", func() {
+			if !updatingPreferenceChecks {
+				setStreamPreference(stream, check.GetActive())
+			}
+		})
+		preferenceChecks[stream] = check
+		bar.PackStart(check, false, false, 0)
+	}
+
+	return bar
+}
+
+// setStreamVisible hides or shows all console output with the stream's tag,
+// including output already in the console
+func setStreamVisible(tagName string, visible bool) {
+	if tag, ok := consoleTags[tagName]; ok {
+		tag.SetProperty("invisible", !visible)
+	}
+}
+
+// hiddenStreams returns the names of the streams hidden in the console
+func hiddenStreams() []string {
+	var hidden []string
+	for _, fs := range filterStreams {
+		if button, ok := streamFilterButtons[fs.stream]; ok && !button.GetActive() {
+			hidden = append(hidden, fs.stream.String())
+		}
+	}
+	return hidden
+}
+
+// setHiddenStreams restores stream filters saved with the session
+func setHiddenStreams(names []string) {
+	savedHiddenStreams = names
+	for _, fs := range filterStreams {
+		if button, ok := streamFilterButtons[fs.stream]; ok {
+			button.SetActive(!containsString(names, fs.stream.String()))
+		}
+	}
+}
+
+// setStreamPreference sets a preference variable in the session, queued
+// behind any running command
+func setStreamPreference(stream translation.StreamType, enabled bool) {
+	name := preferenceLabel(stream)
+	value := "SilentlyContinue"
+	if enabled {
+		value = "Continue"
+	}
+	runOrQueue(fmt.Sprintf("%s = '%s'", name, value), func() {
+		tl := translationLayer
+		if tl == nil {
+			return
+		}
+		setExecuting(true)
+		go func() {
+			err := tl.SetStreamPreference(stream, enabled)
+			glib.IdleAdd(func() bool {
+				setExecuting(false)
+				if err != nil {
+					statusLabel.SetText(fmt.Sprintf("Failed to set %s: %v", name, err))
+				} else {
+					statusLabel.SetText(fmt.Sprintf("%s = '%s'", name, value))
+				}
+				return false
+			})
+		}()
+	})
+}
+
+func preferenceLabel(stream translation.StreamType) string {
+	for _, ps := range preferenceStreams {
+		if ps.stream == stream {
+			return ps.label
+		}
+	}
+	return ""
+}
+
+// refreshPreferenceChecks reads the session's preference variables into the
+// toggles, e.g. after switching to another PowerShell. The query is queued
+// behind any running command.
+func refreshPreferenceChecks() {
+	if translationLayer == nil || len(preferenceChecks) == 0 {
+		return
+	}
+	runOrQueue("Read preference variables", func() {
+		tl := translationLayer
+		if tl == nil {
+			return
+		}
+		setExecuting(true)
+		go func() {
+			prefs, err := tl.GetStreamPreferences()
+			glib.IdleAdd(func() bool {
+				setExecuting(false)
+				if err != nil {
+					return false
+				}
+				updatingPreferenceChecks = true
+				preferenceChecks[translation.VerboseStream].SetActive(prefs.Verbose)
+				preferenceChecks[translation.DebugStream].SetActive(prefs.Debug)
+				preferenceChecks[translation.InformationStream].SetActive(prefs.Information)
+				updatingPreferenceChecks = false
+				return false
+			})
+		}()
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+)
+
+const (
+	inspectorDepth       = 2 // Serialization depth of inspected objects
+	inspectorHeight      = 260
+	inspectorWidth       = 720
+	inspectorLabelColor  = "#9CDCFE"
+	inspectorPlaceholder = "Loading..."
+)
+
+// Object inspector columns
+const (
+	inspectorColumnName = iota
+	inspectorColumnValue
+	inspectorColumnType
+	inspectorColumnIndex       // Index of the captured object for top-level rows, -1 below
+	inspectorColumnPlaceholder // Set on the child of a row that is not loaded yet
+)
+
+// captureObjects keeps output objects for the inspector. Off by default,
+// since it replaces Out-Default in the session with a proxy function.
+var captureObjects bool
+
+// Captures whose inspector has left the console, per session, waiting to
+// be released after the next command
+var (
+	releasedCaptures   = make(map[*translation.TranslationLayer][]int)
+	releasedCapturesMu sync.Mutex
+)
+
+// setObjectCapture turns the object inspector on or off in every session,
+// queued behind any running command
+func setObjectCapture(enabled bool) {
+	if enabled == captureObjects {
+		return
+	}
+	captureObjects = enabled
+
+	label := "Disable object inspector"
+	if enabled {
+		label = "Enable object inspector"
+	}
+	runOrQueue(label, func() {
+		var layers []*translation.TranslationLayer
+		for _, tl := range translationLayers {
+			layers = append(layers, tl)
+		}
+		setExecuting(true)
+		go func() {
+			var firstErr error
+			for _, tl := range layers {
+				if err := tl.SetObjectCapture(enabled); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+			glib.IdleAdd(func() bool {
+				setExecuting(false)
+				if firstErr != nil {
+					statusLabel.SetText(fmt.Sprintf("Failed to update object capture: %v", firstErr))
+				}
+				return false
+			})
+		}()
+	})
+}
+
+// collectObjectCapture returns the objects written by the last command,
+// then releases the captures whose inspector has left the console. Runs on
+// worker goroutines, before any other query after the command.
+func collectObjectCapture(tl *translation.TranslationLayer) *translation.ObjectCapture {
+	capture, err := tl.TakeObjectCapture()
+
+	releasedCapturesMu.Lock()
+	ids := releasedCaptures[tl]
+	delete(releasedCaptures, tl)
+	releasedCapturesMu.Unlock()
+	if releaseErr := tl.ReleaseObjectCaptures(ids); releaseErr != nil {
+		log.Printf("Failed to release object captures: %v", releaseErr)
+	}
+
+	if err != nil {
+		return nil
+	}
+	return capture
+}
+
+// releaseObjectCapture marks a capture for release. The session is only
+// queried while a command holds it, so this waits for the next command.
+func releaseObjectCapture(tl *translation.TranslationLayer, id int) {
+	releasedCapturesMu.Lock()
+	releasedCaptures[tl] = append(releasedCaptures[tl], id)
+	releasedCapturesMu.Unlock()
+}
+
+// displayObjectCapture queues an inspector for the captured objects after
+// the output already queued
+func displayObjectCapture(capture *translation.ObjectCapture) {
+	if capture == nil || capture.Count == 0 {
+		return
+	}
+	tl := translationLayer
+	queueConsoleWidget(func() gtk.IWidget {
+		return createObjectInspector(tl, capture)
+	}, func() {
+		releaseObjectCapture(tl, capture.ID)
+	})
+}
+
+// inspectorTitle describes the captured objects, e.g. "3 objects (Process)"
+func inspectorTitle(capture *translation.ObjectCapture) string {
+	typeName := ""
+	for _, obj := range capture.Objects {
+		if typeName == "" {
+			typeName = obj.TypeName
+		} else if typeName != obj.TypeName {
+			typeName = "mixed types"
+			break
+		}
+	}
+	if i := strings.LastIndexByte(typeName, '.'); i >= 0 && typeName != "mixed types" {
+		typeName = typeName[i+1:]
+	}
+
+	noun := "objects"
+	if capture.Count == 1 {
+		noun = "object"
+	}
+	title := fmt.Sprintf("Inspect %d %s", capture.Count, noun)
+	if typeName != "" {
+		title += " (" + typeName + ")"
+	}
+	if len(capture.Objects) < capture.Count {
+		title += fmt.Sprintf(", first %d listed", len(capture.Objects))
+	}
+	return title
+}
+
+// createObjectInspector builds an expander with a tree of the captured
+// objects. Each object is fetched from the session when first expanded.
+func createObjectInspector(tl *translation.TranslationLayer, capture *translation.ObjectCapture) gtk.IWidget {
+	title, _ := gtk.LabelNew("")
+	title.SetMarkup(fmt.Sprintf(`<span foreground="%s">%s</span>`,
+		inspectorLabelColor, glib.MarkupEscapeText(inspectorTitle(capture))))
+
+	expander, _ := gtk.ExpanderNew("")
+	expander.SetLabelWidget(title)
+
+	store, _ := gtk.TreeStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_INT, glib.TYPE_BOOLEAN)
+	for i, obj := range capture.Objects {
+		iter := store.Append(nil)
+		setInspectorRow(store, iter, fmt.Sprintf("[%d]", i), firstLine(obj.Text), obj.TypeName, i)
+		placeholder := store.Append(iter)
+		setInspectorRow(store, placeholder, inspectorPlaceholder, "", "", -1)
+		store.SetValue(placeholder, inspectorColumnPlaceholder, true)
+	}
+
+	view, _ := gtk.TreeViewNew()
+	view.SetModel(store)
+	view.SetHeadersVisible(true)
+	view.SetEnableSearch(true)
+	view.SetSearchColumn(inspectorColumnName)
+	for _, col := range []struct {
+		title  string
+		column int
+		expand bool
+	}{
+		{"Name", inspectorColumnName, false},
+		{"Value", inspectorColumnValue, true},
+		{"Type", inspectorColumnType, false},
+	} {
+		renderer, _ := gtk.CellRendererTextNew()
+		column, _ := gtk.TreeViewColumnNewWithAttribute(col.title, renderer, "text", col.column)
+		column.SetResizable(true)
+		column.SetExpand(col.expand)
+		view.AppendColumn(column)
+	}
+
+	view.Connect("row-expanded", func(_ *gtk.TreeView, iter *gtk.TreeIter, _ *gtk.TreePath) {
+		loadInspectedObject(tl, capture.ID, store, iter)
+	})
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scroll.SetSizeRequest(inspectorWidth, inspectorHeight)
+	scroll.Add(view)
+
+	expander.Add(scroll)
+	expander.ShowAll()
+	return expander
+}
+
+func setInspectorRow(store *gtk.TreeStore, iter *gtk.TreeIter, name, value, typeName string, index int) {
+	store.SetValue(iter, inspectorColumnName, name)
+	store.SetValue(iter, inspectorColumnValue, value)
+	store.SetValue(iter, inspectorColumnType, typeName)
+	store.SetValue(iter, inspectorColumnIndex, index)
+	store.SetValue(iter, inspectorColumnPlaceholder, false)
+}
+
+// loadInspectedObject replaces the placeholder under a top-level row with
+// the deserialized object, queued behind any running command
+func loadInspectedObject(tl *translation.TranslationLayer, captureID int, store *gtk.TreeStore, iter *gtk.TreeIter) {
+	model := store.ToTreeModel()
+	child := &gtk.TreeIter{}
+	if !model.IterChildren(iter, child) || !inspectorBool(model, child, inspectorColumnPlaceholder) {
+		return
+	}
+	index := inspectorInt(model, iter, inspectorColumnIndex)
+	if index < 0 {
+		return
+	}
+	path, _ := model.GetPath(iter)
+	rowPath := path.String()
+
+	runOrQueue(fmt.Sprintf("Inspect object [%d]", index), func() {
+		if tl != translationLayer || tl == nil {
+			fillInspectedObject(store, rowPath, translation.PSOutput{}, fmt.Errorf("the session has changed"))
+			return
+		}
+		setExecuting(true)
+		go func() {
+			output, err := tl.InspectObject(captureID, index, inspectorDepth)
+			glib.IdleAdd(func() bool {
+				setExecuting(false)
+				fillInspectedObject(store, rowPath, output, err)
+				return false
+			})
+		}()
+	})
+}
+
+// fillInspectedObject shows a fetched object under the row at rowPath
+func fillInspectedObject(store *gtk.TreeStore, rowPath string, output translation.PSOutput, err error) {
+	path, pathErr := gtk.TreePathNewFromString(rowPath)
+	if pathErr != nil {
+		return
+	}
+	iter, iterErr := store.GetIter(path)
+	if iterErr != nil {
+		return
+	}
+
+	// Remove the placeholder
+	child := &gtk.TreeIter{}
+	model := store.ToTreeModel()
+	if !model.IterChildren(iter, child) || !inspectorBool(model, child, inspectorColumnPlaceholder) {
+		return
+	}
+	store.Remove(child)
+
+	if err != nil {
+		row := store.Append(iter)
+		setInspectorRow(store, row, "(unavailable)", err.Error(), "", -1)
+		return
+	}
+
+	seen := make(map[*translation.PSObject]bool)
+	if obj, ok := output.ObjectData.(*translation.PSObject); ok {
+		seen[obj] = true
+		store.SetValue(iter, inspectorColumnValue, firstLine(obj.String()))
+		if typeName := obj.TypeName(); typeName != "" {
+			store.SetValue(iter, inspectorColumnType, strings.TrimPrefix(typeName, "Deserialized."))
+		}
+		addInspectorChildren(store, iter, obj, seen)
+	} else {
+		store.SetValue(iter, inspectorColumnValue, translation.FormatCLIXMLValue(output.ObjectData))
+	}
+}
+
+// addInspectorChildren adds the type names, items, entries and properties
+// of obj under parent
+func addInspectorChildren(store *gtk.TreeStore, parent *gtk.TreeIter, obj *translation.PSObject, seen map[*translation.PSObject]bool) {
+	if len(obj.TypeNames) > 0 {
+		row := store.Append(parent)
+		setInspectorRow(store, row, "PSTypeNames", strings.Join(obj.TypeNames, ", "), "", -1)
+		for _, name := range obj.TypeNames {
+			setInspectorRow(store, store.Append(row), name, "", "", -1)
+		}
+	}
+	if obj.Value != nil && len(obj.Properties) == 0 && obj.Kind == translation.NoCollection {
+		addInspectorValue(store, parent, "Value", obj.Value, seen)
+	}
+	for i, item := range obj.Items {
+		addInspectorValue(store, parent, fmt.Sprintf("[%d]", i), item, seen)
+	}
+	for _, entry := range obj.Entries {
+		addInspectorValue(store, parent, translation.FormatCLIXMLValue(entry.Key), entry.Value, seen)
+	}
+	for _, prop := range obj.Properties {
+		addInspectorValue(store, parent, prop.Name, prop.Value, seen)
+	}
+	for _, prop := range obj.Members {
+		addInspectorValue(store, parent, prop.Name, prop.Value, seen)
+	}
+}
+
+// addInspectorValue adds a row for value, with children for objects not
+// already shown on the path to it
+func addInspectorValue(store *gtk.TreeStore, parent *gtk.TreeIter, name string, value interface{}, seen map[*translation.PSObject]bool) {
+	row := store.Append(parent)
+	obj, ok := value.(*translation.PSObject)
+	if !ok {
+		setInspectorRow(store, row, name, firstLine(translation.FormatCLIXMLValue(value)), inspectorTypeName(value), -1)
+		return
+	}
+
+	text := obj.String()
+	switch obj.Kind {
+	case translation.NoCollection:
+	case translation.DictionaryCollection:
+		text = fmt.Sprintf("%d entries", len(obj.Entries))
+	default:
+		text = fmt.Sprintf("%d items", len(obj.Items))
+	}
+	setInspectorRow(store, row, name, firstLine(text), strings.TrimPrefix(obj.TypeName(), "Deserialized."), -1)
+
+	if seen[obj] {
+		store.SetValue(row, inspectorColumnValue, "(shown above)")
+		return
+	}
+	seen[obj] = true
+	addInspectorChildren(store, row, obj, seen)
+	delete(seen, obj)
+}
+
+// inspectorTypeName names the .NET type of a deserialized primitive
+func inspectorTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return ""
+	case string:
+		return "System.String"
+	case bool:
+		return "System.Boolean"
+	case int32:
+		return "System.Int32"
+	case int64:
+		return "System.Int64"
+	case float64:
+		return "System.Double"
+	case translation.Char:
+		return "System.Char"
+	case translation.SecureString:
+		return "System.Security.SecureString"
+	case []byte:
+		return "System.Byte[]"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", value), "*")
+}
+
+// firstLine returns the first line of text, marking that more follows
+func firstLine(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return strings.TrimSpace(text[:i]) + " ..."
+	}
+	return text
+}
+
+func inspectorInt(model *gtk.TreeModel, iter *gtk.TreeIter, column int) int {
+	value, _ := model.GetValue(iter, column)
+	goValue, _ := value.GoValue()
+	n, _ := goValue.(int)
+	return n
+}
+
+func inspectorBool(model *gtk.TreeModel, iter *gtk.TreeIter, column int) bool {
+	value, _ := model.GetValue(iter, column)
+	goValue, _ := value.GoValue()
+	b, _ := goValue.(bool)
+	return b
+}
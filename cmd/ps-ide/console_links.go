@@ -0,0 +1,247 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// SourceLocation is a script position referenced by console output
+type SourceLocation struct {
+	File   string
+	Line   int // 1-based
+	Column int // 1-based, 0 if unknown
+}
+
+var (
+	// NormalView errors: "At /path/script.ps1:12 char:5"
+	normalViewLocationRegex = regexp.MustCompile(`At (.+?):(\d+) char:(\d+)`)
+	// ConciseView errors and stack traces: "/path/script.ps1:12" or
+	// "Untitled1.ps1:12:5", optionally preceded by "at <ScriptBlock>, "
+	conciseLocationRegex = regexp.MustCompile(`((?:/|~/|[A-Za-z]:\\)[^\s:]+|[^\s:/\\]+\.ps[md]?1)(?:: line |:)(\d+)(?::(\d+))?`)
+
+	consoleLinkTag     *gtk.TextTag
+	consoleLinkHovered bool
+)
+
+// createConsoleLinkTag creates the tag used for clickable source locations
+func createConsoleLinkTag(buffer *gtk.TextBuffer) {
+	consoleLinkTag = buffer.CreateTag("source-link", map[string]interface{}{
+		"underline":  1, // PANGO_UNDERLINE_SINGLE
+		"foreground": "#8CB4FF",
+	})
+}
+
+// findSourceLocations returns the locations in text with their rune ranges
+func findSourceLocations(text string) ([]SourceLocation, [][2]int) {
+	var locations []SourceLocation
+	var ranges [][2]int
+	covered := make(map[int]bool)
+
+	add := func(m []int, fileIdx, lineIdx, colIdx int) {
+		if covered[m[0]] {
+			return
+		}
+		loc := SourceLocation{File: text[m[fileIdx]:m[fileIdx+1]]}
+		loc.Line, _ = strconv.Atoi(text[m[lineIdx]:m[lineIdx+1]])
+		if m[colIdx] >= 0 {
+			loc.Column, _ = strconv.Atoi(text[m[colIdx]:m[colIdx+1]])
+		}
+		if loc.Line <= 0 {
+			return
+		}
+		// Link only the file:line part, not the "At " prefix
+		start := utf8.RuneCountInString(text[:m[fileIdx]])
+		end := start + utf8.RuneCountInString(text[m[fileIdx]:m[1]])
+		locations = append(locations, loc)
+		ranges = append(ranges, [2]int{start, end})
+		for i := m[0]; i < m[1]; i++ {
+			covered[i] = true
+		}
+	}
+
+	for _, m := range normalViewLocationRegex.FindAllStringSubmatchIndex(text, -1) {
+		add(m, 2, 4, 6)
+	}
+	for _, m := range conciseLocationRegex.FindAllStringSubmatchIndex(text, -1) {
+		add(m, 2, 4, 6)
+	}
+
+	return locations, ranges
+}
+
+// linkifyConsoleOutput tags source locations in console text from startOffset to the end
+func linkifyConsoleOutput(startOffset int) {
+	if consoleTextBuffer == nil || consoleLinkTag == nil {
+		return
+	}
+
+	start := consoleTextBuffer.GetIterAtOffset(startOffset)
+	end := consoleTextBuffer.GetEndIter()
+	text, _ := consoleTextBuffer.GetText(start, end, false)
+
+	locations, ranges := findSourceLocations(text)
+	for i, loc := range locations {
+		if !sourceLocationExists(loc) {
+			continue
+		}
+		linkStart := consoleTextBuffer.GetIterAtOffset(startOffset + ranges[i][0])
+		linkEnd := consoleTextBuffer.GetIterAtOffset(startOffset + ranges[i][1])
+		consoleTextBuffer.ApplyTag(consoleLinkTag, linkStart, linkEnd)
+	}
+}
+
+// sourceLocationExists reports whether a location refers to an open tab or a file on disk
+func sourceLocationExists(loc SourceLocation) bool {
+	if findTabForSource(loc.File) != nil {
+		return true
+	}
+	_, err := os.Stat(loc.File)
+	return err == nil
+}
+
+// consoleLocationAt returns the source location under a console buffer iter
+func consoleLocationAt(iter *gtk.TextIter) (SourceLocation, bool) {
+	if consoleLinkTag == nil || !iter.HasTag(consoleLinkTag) {
+		return SourceLocation{}, false
+	}
+
+	lineStart := consoleTextBuffer.GetIterAtLine(iter.GetLine())
+	lineEnd := consoleTextBuffer.GetIterAtLine(iter.GetLine())
+	if !lineEnd.EndsLine() {
+		lineEnd.ForwardToLineEnd()
+	}
+	text, _ := consoleTextBuffer.GetText(lineStart, lineEnd, false)
+
+	column := iter.GetLineOffset()
+	locations, ranges := findSourceLocations(text)
+	for i, loc := range locations {
+		if column >= ranges[i][0] && column <= ranges[i][1] {
+			return loc, true
+		}
+	}
+	return SourceLocation{}, false
+}
+
+// consoleIterAtPointer returns the console buffer iter under widget coordinates
+func consoleIterAtPointer(x, y float64) *gtk.TextIter {
+	bx, by := consoleTextView.WindowToBufferCoords(gtk.TEXT_WINDOW_WIDGET, int(x), int(y))
+	return consoleTextView.GetIterAtLocation(bx, by)
+}
+
+// onConsoleLinkClick opens the location under a left click, if any
+func onConsoleLinkClick(event *gdk.Event) bool {
+	button := gdk.EventButtonNewFromEvent(event)
+	if button.Button() != 1 {
+		return false
+	}
+
+	// Don't hijack drag-selections
+	if _, _, hasSelection := consoleTextBuffer.GetSelectionBounds(); hasSelection {
+		return false
+	}
+
+	loc, ok := consoleLocationAt(consoleIterAtPointer(button.X(), button.Y()))
+	if !ok {
+		return false
+	}
+	navigateToSource(loc)
+	return true
+}
+
+// onConsoleLinkMotion shows a hand cursor over source links
+func onConsoleLinkMotion(event *gdk.Event) bool {
+	motion := gdk.EventMotionNewFromEvent(event)
+	x, y := motion.MotionVal()
+	iter := consoleIterAtPointer(x, y)
+	hovering := consoleLinkTag != nil && iter.HasTag(consoleLinkTag)
+	if hovering == consoleLinkHovered {
+		return false
+	}
+	consoleLinkHovered = hovering
+
+	window := consoleTextView.GetWindow(gtk.TEXT_WINDOW_TEXT)
+	if window == nil {
+		return false
+	}
+	cursorName := "text"
+	if hovering {
+		cursorName = "pointer"
+	}
+	display, err := gdk.DisplayGetDefault()
+	if err != nil {
+		return false
+	}
+	cursor, err := gdk.CursorNewFromName(display, cursorName)
+	if err == nil {
+		window.SetCursor(cursor)
+	}
+	return false
+}
+
+// findTabForSource returns the open tab for a file path or untitled tab name
+func findTabForSource(file string) *ScriptTab {
+	abs, _ := filepath.Abs(file)
+	for _, tab := range openTabs {
+		if tab.filename != "" && (tab.filename == file || tab.filename == abs) {
+			return tab
+		}
+		if tab.filename == "" && tabSourceName(tab) == file {
+			return tab
+		}
+	}
+	return nil
+}
+
+// navigateToSource shows a script location in its tab, opening the file if needed
+func navigateToSource(loc SourceLocation) {
+	tab := findTabForSource(loc.File)
+	if tab == nil {
+		tab = openFile(loc.File)
+		if tab == nil {
+			statusLabel.SetText("Cannot open " + loc.File)
+			return
+		}
+	}
+
+	for i, t := range openTabs {
+		if t == tab {
+			setCurrentTab(i)
+		}
+	}
+
+	goToLine(tab, loc.Line, loc.Column)
+}
+
+// goToLine places the cursor at a 1-based line and column and scrolls to it
+func goToLine(tab *ScriptTab, line, column int) {
+	if line < 1 {
+		line = 1
+	}
+	if line > tab.buffer.GetLineCount() {
+		line = tab.buffer.GetLineCount()
+	}
+
+	revealLines(tab, line-1, line-1)
+	iter := tab.buffer.GetIterAtLine(line - 1)
+	if column > 1 {
+		lineEnd := tab.buffer.GetIterAtLine(line - 1)
+		if !lineEnd.EndsLine() {
+			lineEnd.ForwardToLineEnd()
+		}
+		if column-1 <= lineEnd.GetLineOffset() {
+			iter.SetLineOffset(column - 1)
+		} else {
+			iter = lineEnd
+		}
+	}
+
+	tab.buffer.PlaceCursor(iter)
+	tab.textView.ScrollToIter(iter, 0.1, true, 0.0, 0.3)
+	tab.textView.GrabFocus()
+}
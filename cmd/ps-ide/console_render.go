@@ -0,0 +1,362 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	defaultConsoleScrollback = 10000 // Lines kept in the console by default
+	minConsoleScrollback     = 1000
+	maxConsoleScrollback     = 1000000
+
+	renderLinesPerFrame = 500 // Lines inserted per frame while output is pending
+	renderFrameInterval = 16  // Milliseconds between frames
+
+	// maxRenderBacklog is the most lines left waiting, about a quarter
+	// second of frames. Output is streamed as it is read, so it only backs
+	// up when it arrives faster than frames insert it.
+	maxRenderBacklog = 15 * renderLinesPerFrame
+)
+
+// consoleChunk is output waiting to be inserted into the console. Adjacent
+// output with the same tags is coalesced into one chunk, whose parts are
+// only joined when they are rendered.
+type consoleChunk struct {
+	parts   []chunkPart
+	tags    []*gtk.TextTag
+	lines   int  // Newlines in all parts
+	linkify bool // Scan for script locations after inserting
+
+	// widget creates a widget shown on its own line instead of text
+	widget func() gtk.IWidget
+	// release, if set, is called once the widget has left the console or
+	// was dropped before it was shown
+	release func()
+}
+
+// shownWidget is a console widget waiting to be released
+type shownWidget struct {
+	anchor  *gtk.TextChildAnchor
+	release func()
+}
+
+// chunkPart is one queued text of a chunk
+type chunkPart struct {
+	text  string
+	lines int // Newlines in text
+}
+
+// cut removes the first n lines of a chunk, or all of it if it has fewer,
+// and appends them to keep unless it is nil. It returns the lines removed.
+// Only the part the cut falls in is scanned.
+func (c *consoleChunk) cut(n int, keep *strings.Builder) int {
+	removed := 0
+	for len(c.parts) > 0 && removed < n {
+		part := c.parts[0]
+		if part.lines > n-removed {
+			at := nthNewline(part.text, n-removed) + 1
+			if keep != nil {
+				keep.WriteString(part.text[:at])
+			}
+			c.parts[0] = chunkPart{part.text[at:], part.lines - (n - removed)}
+			removed = n
+			break
+		}
+		if keep != nil {
+			keep.WriteString(part.text)
+		}
+		removed += part.lines
+		c.parts = c.parts[1:]
+	}
+	c.lines -= removed
+	return removed
+}
+
+var (
+	// consoleScrollback is the maximum number of lines kept in the console
+	consoleScrollback = defaultConsoleScrollback
+
+	renderQueue     []*consoleChunk
+	pendingLines    int  // Newlines in renderQueue
+	trimmedLines    int  // Lines dropped beyond the scrollback since the last notice
+	skippedLines    int  // Lines dropped to keep up with output since the last notice
+	renderScheduled bool // A frame is scheduled
+	promptPending   bool // displayPrompt waits for the queue to drain
+
+	// Widgets in the console that have a release function
+	shownWidgets []shownWidget
+)
+
+// queueConsoleText queues text for the console with the given tags
+func queueConsoleText(text string, linkify bool, tags ...*gtk.TextTag) {
+	if text == "" {
+		return
+	}
+	lines := strings.Count(text, "\n")
+	pendingLines += lines
+
+	if n := len(renderQueue); n > 0 {
+		last := renderQueue[n-1]
+		if last.widget == nil && last.linkify == linkify && sameTags(last.tags, tags) {
+			last.parts = append(last.parts, chunkPart{text, lines})
+			last.lines += lines
+			dropExcessOutput()
+			scheduleConsoleRender()
+			return
+		}
+	}
+	renderQueue = append(renderQueue, &consoleChunk{parts: []chunkPart{{text, lines}}, tags: tags, lines: lines, linkify: linkify})
+	dropExcessOutput()
+	scheduleConsoleRender()
+}
+
+// queueConsoleWidget queues a widget for its own line of the console, with
+// tags applied to that line so stream filters hide it. release, if not
+// nil, is called once the widget is trimmed or cleared from the console.
+func queueConsoleWidget(create func() gtk.IWidget, release func(), tags ...*gtk.TextTag) {
+	pendingLines++
+	renderQueue = append(renderQueue, &consoleChunk{tags: tags, lines: 1, widget: create, release: release})
+	dropExcessOutput()
+	scheduleConsoleRender()
+}
+
+func sameTags(a, b []*gtk.TextTag) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// dropExcessOutput drops the oldest queued lines once more are waiting
+// than the scrollback keeps, since they would be trimmed as soon as
+// rendered, or than maxRenderBacklog, since output is arriving faster than
+// frames can insert it
+func dropExcessOutput() {
+	trimmedLines += dropQueuedLines(pendingLines - consoleScrollback)
+	skippedLines += dropQueuedLines(pendingLines - maxRenderBacklog)
+}
+
+// dropQueuedLines removes up to n of the oldest queued lines and returns
+// how many were removed
+func dropQueuedLines(n int) int {
+	removed := 0
+	for removed < n && len(renderQueue) > 0 {
+		chunk := renderQueue[0]
+		if chunk.lines <= n-removed {
+			renderQueue = renderQueue[1:]
+			removed += chunk.lines
+			if chunk.release != nil {
+				chunk.release()
+			}
+			continue
+		}
+		removed += chunk.cut(n-removed, nil)
+	}
+	pendingLines -= removed
+	return removed
+}
+
+// nthNewline returns the byte index of the nth newline (1-based) in text
+func nthNewline(text string, n int) int {
+	index := -1
+	for i := 0; i < n; i++ {
+		next := strings.IndexByte(text[index+1:], '\n')
+		if next < 0 {
+			return len(text) - 1
+		}
+		index += next + 1
+	}
+	return index
+}
+
+// consoleRenderPending reports whether output or a prompt is waiting to be shown
+func consoleRenderPending() bool {
+	return len(renderQueue) > 0 || promptPending
+}
+
+func scheduleConsoleRender() {
+	if renderScheduled {
+		return
+	}
+	renderScheduled = true
+	glib.TimeoutAdd(renderFrameInterval, renderConsoleFrame)
+}
+
+// renderConsoleFrame inserts up to renderLinesPerFrame queued lines, then
+// shows the pending prompt once the queue is empty
+func renderConsoleFrame() bool {
+	if consoleTextBuffer == nil {
+		renderScheduled = false
+		return false
+	}
+
+	if len(renderQueue) > 0 {
+		stashTypeAhead()
+
+		if trimmedLines > 0 {
+			insertConsoleText(fmt.Sprintf("... %d lines not shown; the console keeps only the last %d lines ...\n", trimmedLines, consoleScrollback),
+				consoleTags["warning"])
+			trimmedLines = 0
+		}
+		if skippedLines > 0 {
+			insertConsoleText(fmt.Sprintf("... %d lines skipped; output arrived faster than it could be shown ...\n", skippedLines),
+				consoleTags["warning"])
+			skippedLines = 0
+		}
+
+		budget := renderLinesPerFrame
+		for budget > 0 && len(renderQueue) > 0 {
+			chunk := renderQueue[0]
+			if chunk.widget != nil {
+				renderQueue = renderQueue[1:]
+				pendingLines--
+				budget--
+				anchor := insertConsoleWidget(chunk.widget(), chunk.tags...)
+				if chunk.release != nil {
+					if anchor != nil {
+						shownWidgets = append(shownWidgets, shownWidget{anchor, chunk.release})
+					} else {
+						chunk.release()
+					}
+				}
+				continue
+			}
+			var text strings.Builder
+			lines := chunk.cut(budget, &text)
+			if len(chunk.parts) == 0 {
+				renderQueue = renderQueue[1:]
+			}
+			pendingLines -= lines
+			// Text without newlines still costs one line of budget
+			budget -= lines
+			if lines == 0 {
+				budget--
+			}
+
+			startOffset := insertConsoleText(text.String(), chunk.tags...)
+			if chunk.linkify {
+				linkifyConsoleOutput(startOffset)
+			}
+		}
+
+		// Text typed during the frame goes after the next output
+		movePromptMarkToEnd()
+		trimConsoleScrollback()
+		consoleTextView.ScrollToIter(consoleTextBuffer.GetEndIter(), 0.0, false, 0.0, 0.0)
+		return true
+	}
+
+	renderScheduled = false
+	if promptPending {
+		promptPending = false
+		displayPrompt()
+		// Commands queued while output rendered can start now
+		scheduleQueueDispatch()
+	}
+	return false
+}
+
+// insertConsoleText inserts text at the end of the console with the given
+// tags and returns the offset it was inserted at
+func insertConsoleText(text string, tags ...*gtk.TextTag) int {
+	endIter := consoleTextBuffer.GetEndIter()
+	startOffset := endIter.GetOffset()
+	consoleTextBuffer.Insert(endIter, text)
+
+	start := consoleTextBuffer.GetIterAtOffset(startOffset)
+	end := consoleTextBuffer.GetEndIter()
+	for _, tag := range tags {
+		if tag != nil {
+			consoleTextBuffer.ApplyTag(tag, start, end)
+		}
+	}
+	return startOffset
+}
+
+// insertConsoleWidget adds a widget on its own line at the end of the
+// console and returns its anchor, or nil if it could not be added
+func insertConsoleWidget(widget gtk.IWidget, tags ...*gtk.TextTag) *gtk.TextChildAnchor {
+	startOffset := consoleTextBuffer.GetEndIter().GetOffset()
+	if startOffset > 0 && !consoleTextBuffer.GetIterAtOffset(startOffset-1).EndsLine() {
+		consoleTextBuffer.Insert(consoleTextBuffer.GetEndIter(), "\n")
+		startOffset++
+	}
+	anchor, err := consoleTextBuffer.CreateChildAnchor(consoleTextBuffer.GetEndIter())
+	if err != nil {
+		return nil
+	}
+	consoleTextView.AddChildAtAnchor(widget, anchor)
+	consoleTextBuffer.Insert(consoleTextBuffer.GetEndIter(), "\n")
+
+	start := consoleTextBuffer.GetIterAtOffset(startOffset)
+	end := consoleTextBuffer.GetEndIter()
+	for _, tag := range tags {
+		if tag != nil {
+			consoleTextBuffer.ApplyTag(tag, start, end)
+		}
+	}
+	return anchor
+}
+
+// trimConsoleScrollback deletes the oldest lines beyond the scrollback limit
+func trimConsoleScrollback() {
+	excess := consoleTextBuffer.GetLineCount() - consoleScrollback
+	if excess <= 0 {
+		return
+	}
+	consoleTextBuffer.Delete(consoleTextBuffer.GetStartIter(), consoleTextBuffer.GetIterAtLine(excess))
+	pruneConsoleBlocks()
+	pruneConsoleWidgets()
+}
+
+// pruneConsoleWidgets releases widgets whose line was deleted from the console
+func pruneConsoleWidgets() {
+	kept := shownWidgets[:0]
+	for _, w := range shownWidgets {
+		if w.anchor.GetDeleted() {
+			w.release()
+		} else {
+			kept = append(kept, w)
+		}
+	}
+	shownWidgets = kept
+}
+
+// clearRenderQueue discards output that has not been shown yet
+func clearRenderQueue() {
+	for _, chunk := range renderQueue {
+		if chunk.release != nil {
+			chunk.release()
+		}
+	}
+	renderQueue = nil
+	pendingLines = 0
+	trimmedLines = 0
+	skippedLines = 0
+}
+
+// setConsoleScrollback changes the scrollback limit and trims the console
+func setConsoleScrollback(lines int) {
+	if lines <= 0 {
+		lines = defaultConsoleScrollback
+	}
+	if lines < minConsoleScrollback {
+		lines = minConsoleScrollback
+	}
+	if lines > maxConsoleScrollback {
+		lines = maxConsoleScrollback
+	}
+	consoleScrollback = lines
+	if consoleTextBuffer != nil {
+		trimConsoleScrollback()
+	}
+}
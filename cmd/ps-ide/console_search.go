@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// ConsoleSearchBar is the inline find bar above the console
+type ConsoleSearchBar struct {
+	revealer       *gtk.Revealer
+	searchEntry    *gtk.SearchEntry
+	matchCaseCheck *gtk.CheckButton
+	wholeWordCheck *gtk.CheckButton
+	regexCheck     *gtk.CheckButton
+	countLabel     *gtk.Label
+	matches        [][2]int // Rune offsets into the console buffer
+	current        int      // Index of the current match, -1 if none
+}
+
+var (
+	consoleSearch        *ConsoleSearchBar
+	consoleSearchTag     *gtk.TextTag
+	consoleSearchCurrTag *gtk.TextTag
+)
+
+// createConsoleSearchTags creates the tags for console search matches
+func createConsoleSearchTags(buffer *gtk.TextBuffer) {
+	consoleSearchTag = buffer.CreateTag("search-match", map[string]interface{}{
+		"background": "#6B5B00",
+	})
+	consoleSearchCurrTag = buffer.CreateTag("search-current", map[string]interface{}{
+		"background": "#D98E04",
+		"foreground": "#000000",
+	})
+}
+
+// createConsoleSearchBar returns the console with a hidden search bar above it
+func createConsoleSearchBar(console gtk.IWidget) *gtk.Box {
+	sb := &ConsoleSearchBar{current: -1}
+	consoleSearch = sb
+
+	bar, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	bar.SetMarginStart(4)
+	bar.SetMarginEnd(4)
+	bar.SetMarginTop(2)
+	bar.SetMarginBottom(2)
+
+	sb.searchEntry, _ = gtk.SearchEntryNew()
+	sb.searchEntry.SetPlaceholderText("Find in console")
+	sb.searchEntry.SetSizeRequest(220, -1)
+	bar.PackStart(sb.searchEntry, false, false, 0)
+
+	prevButton, _ := gtk.ButtonNewFromIconName("go-up-symbolic", gtk.ICON_SIZE_BUTTON)
+	prevButton.SetTooltipText("Previous match (Shift+Enter)")
+	bar.PackStart(prevButton, false, false, 0)
+
+	nextButton, _ := gtk.ButtonNewFromIconName("go-down-symbolic", gtk.ICON_SIZE_BUTTON)
+	nextButton.SetTooltipText("Next match (Enter)")
+	bar.PackStart(nextButton, false, false, 0)
+
+	sb.matchCaseCheck, _ = gtk.CheckButtonNewWithLabel("Match case")
+	bar.PackStart(sb.matchCaseCheck, false, false, 0)
+	sb.wholeWordCheck, _ = gtk.CheckButtonNewWithLabel("Whole word")
+	bar.PackStart(sb.wholeWordCheck, false, false, 0)
+	sb.regexCheck, _ = gtk.CheckButtonNewWithLabel("Regular expressions")
+	bar.PackStart(sb.regexCheck, false, false, 0)
+
+	sb.countLabel, _ = gtk.LabelNew("")
+	bar.PackStart(sb.countLabel, false, false, 0)
+
+	closeButton, _ := gtk.ButtonNewFromIconName("window-close-symbolic", gtk.ICON_SIZE_BUTTON)
+	closeButton.SetRelief(gtk.RELIEF_NONE)
+	closeButton.SetTooltipText("Close (Escape)")
+	bar.PackEnd(closeButton, false, false, 0)
+
+	sb.searchEntry.Connect("search-changed", func() { sb.search(true) })
+	sb.matchCaseCheck.Connect("toggled", func() { sb.search(true) })
+	sb.wholeWordCheck.Connect("toggled", func() { sb.search(true) })
+	sb.regexCheck.Connect("toggled", func() { sb.search(true) })
+	prevButton.Connect("clicked", func() { sb.step(-1) })
+	nextButton.Connect("clicked", func() { sb.step(1) })
+	closeButton.Connect("clicked", func() { sb.hide() })
+
+	sb.searchEntry.Connect("key-press-event", func(_ *gtk.SearchEntry, event *gdk.Event) bool {
+		keyEvent := gdk.EventKeyNewFromEvent(event)
+		switch keyEvent.KeyVal() {
+		case gdk.KEY_Escape:
+			sb.hide()
+			return true
+		case gdk.KEY_Return, gdk.KEY_KP_Enter:
+			if keyEvent.State()&uint(gdk.SHIFT_MASK) != 0 {
+				sb.step(-1)
+			} else {
+				sb.step(1)
+			}
+			return true
+		}
+		return false
+	})
+
+	sb.revealer, _ = gtk.RevealerNew()
+	sb.revealer.SetTransitionType(gtk.REVEALER_TRANSITION_TYPE_SLIDE_DOWN)
+	sb.revealer.Add(bar)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	box.PackStart(sb.revealer, false, false, 0)
+	box.PackStart(console, true, true, 0)
+	return box
+}
+
+// showConsoleSearch opens the console search bar, pre-filled with the
+// console selection
+func showConsoleSearch() {
+	sb := consoleSearch
+	if sb == nil {
+		return
+	}
+
+	if start, end, ok := consoleTextBuffer.GetSelectionBounds(); ok {
+		text, _ := consoleTextBuffer.GetText(start, end, false)
+		if len(text) < 100 && !strings.Contains(text, "\n") {
+			sb.searchEntry.SetText(text)
+		}
+	}
+
+	sb.revealer.SetRevealChild(true)
+	sb.searchEntry.GrabFocus()
+	sb.searchEntry.SelectRegion(0, -1)
+	sb.search(true)
+}
+
+func (sb *ConsoleSearchBar) hide() {
+	sb.revealer.SetRevealChild(false)
+	sb.clearHighlights()
+	sb.matches = nil
+	sb.current = -1
+	consoleTextView.GrabFocus()
+}
+
+func (sb *ConsoleSearchBar) visible() bool {
+	return sb.revealer.GetRevealChild()
+}
+
+// search finds and highlights all matches, keeping the current match near
+// its previous position. scroll brings the current match into view.
+func (sb *ConsoleSearchBar) search(scroll bool) {
+	sb.clearHighlights()
+
+	searchText, _ := sb.searchEntry.GetText()
+	text, _ := consoleTextBuffer.GetText(consoleTextBuffer.GetStartIter(), consoleTextBuffer.GetEndIter(), false)
+
+	previous := -1
+	if sb.current >= 0 && sb.current < len(sb.matches) {
+		previous = sb.matches[sb.current][0]
+	}
+
+	sb.matches = findAllInText(text, searchText,
+		sb.matchCaseCheck.GetActive(), sb.wholeWordCheck.GetActive(), sb.regexCheck.GetActive())
+
+	for _, m := range sb.matches {
+		consoleTextBuffer.ApplyTag(consoleSearchTag,
+			consoleTextBuffer.GetIterAtOffset(m[0]), consoleTextBuffer.GetIterAtOffset(m[1]))
+	}
+
+	sb.current = -1
+	if len(sb.matches) > 0 {
+		// Start from the newest output unless a match was already selected
+		sb.current = len(sb.matches) - 1
+		if previous >= 0 {
+			for i, m := range sb.matches {
+				if m[0] >= previous {
+					sb.current = i
+					break
+				}
+			}
+		}
+	}
+	sb.showCurrent(scroll)
+}
+
+// step moves to the next (1) or previous (-1) match, wrapping around
+func (sb *ConsoleSearchBar) step(delta int) {
+	if len(sb.matches) == 0 {
+		return
+	}
+	sb.current = (sb.current + delta + len(sb.matches)) % len(sb.matches)
+	sb.showCurrent(true)
+}
+
+// showCurrent highlights and scrolls to the current match and updates the count
+func (sb *ConsoleSearchBar) showCurrent(scroll bool) {
+	consoleTextBuffer.RemoveTag(consoleSearchCurrTag, consoleTextBuffer.GetStartIter(), consoleTextBuffer.GetEndIter())
+
+	searchText, _ := sb.searchEntry.GetText()
+	switch {
+	case searchText == "":
+		sb.countLabel.SetText("")
+		return
+	case len(sb.matches) == 0:
+		sb.countLabel.SetText("No matches")
+		return
+	}
+
+	m := sb.matches[sb.current]
+	start := consoleTextBuffer.GetIterAtOffset(m[0])
+	end := consoleTextBuffer.GetIterAtOffset(m[1])
+	consoleTextBuffer.ApplyTag(consoleSearchCurrTag, start, end)
+	if scroll {
+		consoleTextView.ScrollToIter(start, 0.0, true, 0.0, 0.3)
+	}
+	sb.countLabel.SetText(fmt.Sprintf("%d of %d", sb.current+1, len(sb.matches)))
+}
+
+func (sb *ConsoleSearchBar) clearHighlights() {
+	start, end := consoleTextBuffer.GetStartIter(), consoleTextBuffer.GetEndIter()
+	consoleTextBuffer.RemoveTag(consoleSearchTag, start, end)
+	consoleTextBuffer.RemoveTag(consoleSearchCurrTag, start, end)
+}
+
+// refreshConsoleSearch re-runs an open search after new console output
+func refreshConsoleSearch() {
+	if consoleSearch != nil && consoleSearch.visible() {
+		consoleSearch.search(false)
+	}
+}
+
+// consoleHasFocus reports whether the console or its search bar has focus
+func consoleHasFocus() bool {
+	if consoleTextView != nil && consoleTextView.HasFocus() {
+		return true
+	}
+	return consoleSearch != nil && consoleSearch.searchEntry.HasFocus()
+}
@@ -0,0 +1,767 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+)
+
+var (
+	// Variable and command breakpoints. Line breakpoints live on their tab
+	// as text marks so they move with edits.
+	otherBreakpoints      []translation.Breakpoint
+	breakpointMarkCounter int
+
+	// Copy of all breakpoints that worker goroutines can read
+	breakpointSnapshot      []translation.Breakpoint
+	breakpointSnapshotMutex sync.Mutex
+
+	// Breakpoints last set in each session, to skip redundant syncs
+	syncedBreakpoints      = make(map[*translation.TranslationLayer]string)
+	syncedBreakpointsMutex sync.Mutex
+
+	// Line to stop at when the next F5 run starts (Run to Cursor)
+	pendingRunToLine int
+
+	// Call Stack pane
+	callStackPane   *gtk.ScrolledWindow
+	callStackStore  *gtk.ListStore
+	callStackView   *gtk.TreeView
+	callStackFrames []translation.StackFrame
+)
+
+// createDebugTags creates the breakpoint and current-line tags for a tab.
+// The current line is created last so it draws over breakpoints.
+func createDebugTags(tab *ScriptTab) {
+	tab.buffer.CreateTag("breakpoint-line", map[string]interface{}{
+		"paragraph-background": "#F6C8C8",
+	})
+	tab.buffer.CreateTag("debug-current-line", map[string]interface{}{
+		"paragraph-background": "#FFEE62",
+	})
+}
+
+func lookupTag(buffer *gtk.TextBuffer, name string) *gtk.TextTag {
+	table, err := buffer.GetTagTable()
+	if err != nil {
+		return nil
+	}
+	tag, err := table.Lookup(name)
+	if err != nil {
+		return nil
+	}
+	return tag
+}
+
+// applyLineTag applies tag to a whole 0-based line
+func applyLineTag(buffer *gtk.TextBuffer, tag *gtk.TextTag, line int) {
+	if tag == nil || line < 0 || line >= buffer.GetLineCount() {
+		return
+	}
+	start := buffer.GetIterAtLine(line)
+	end := buffer.GetIterAtLine(line)
+	end.ForwardLine()
+	buffer.ApplyTag(tag, start, end)
+}
+
+// refreshDebugDecorations redraws breakpoint and current-line highlights
+// in the editor and gutter of a tab
+func refreshDebugDecorations(tab *ScriptTab) {
+	editorTags := []*gtk.TextTag{lookupTag(tab.buffer, "breakpoint-line"), lookupTag(tab.buffer, "debug-current-line")}
+
+	for _, tag := range editorTags {
+		if tag != nil {
+			tab.buffer.RemoveTag(tag, tab.buffer.GetStartIter(), tab.buffer.GetEndIter())
+		}
+	}
+
+	for _, line := range tabBreakpointLines(tab) {
+		applyLineTag(tab.buffer, editorTags[0], line-1)
+	}
+	if tab.debugLine > 0 {
+		applyLineTag(tab.buffer, editorTags[1], tab.debugLine-1)
+	}
+
+	queueGutterDraw(tab)
+	updateBreakpointSnapshot()
+}
+
+// debugGutterMarkers marks breakpoints and the line the debugger stopped
+// at, colouring their line numbers as the editor lines are
+func debugGutterMarkers(tab *ScriptTab) map[int]GutterMarker {
+	markers := make(map[int]GutterMarker)
+	for _, line := range tabBreakpointLines(tab) {
+		markers[line-1] = GutterMarker{
+			Symbol:           "●",
+			Color:            "#E51400",
+			NumberBackground: "#E51400",
+			NumberColor:      "#FFFFFF",
+			Tooltip:          "Breakpoint (click to remove)",
+		}
+	}
+	if tab.debugLine > 0 {
+		tooltip := "Stopped here"
+		if _, ok := markers[tab.debugLine-1]; ok {
+			tooltip = "Stopped at this breakpoint"
+		}
+		markers[tab.debugLine-1] = GutterMarker{
+			Symbol:           "➜",
+			Color:            "#B08800",
+			NumberBackground: "#FFEE62",
+			NumberColor:      "#000000",
+			Tooltip:          tooltip,
+		}
+	}
+	return markers
+}
+
+// tabBreakpointLines returns the sorted 1-based lines with breakpoints,
+// dropping marks that edits have merged onto the same line
+func tabBreakpointLines(tab *ScriptTab) []int {
+	seen := make(map[int]bool)
+	var lines []int
+	kept := tab.breakpoints[:0]
+	for _, mark := range tab.breakpoints {
+		line := tab.buffer.GetIterAtMark(mark).GetLine() + 1
+		if seen[line] {
+			tab.buffer.DeleteMark(mark)
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+		kept = append(kept, mark)
+	}
+	tab.breakpoints = kept
+	sort.Ints(lines)
+	return lines
+}
+
+// toggleLineBreakpoint adds or removes the breakpoint on a 0-based line
+func toggleLineBreakpoint(tab *ScriptTab, line int) {
+	if line < 0 || line >= tab.buffer.GetLineCount() {
+		return
+	}
+
+	removed := false
+	kept := tab.breakpoints[:0]
+	for _, mark := range tab.breakpoints {
+		if tab.buffer.GetIterAtMark(mark).GetLine() == line {
+			tab.buffer.DeleteMark(mark)
+			removed = true
+			continue
+		}
+		kept = append(kept, mark)
+	}
+	tab.breakpoints = kept
+
+	if !removed {
+		addLineBreakpoint(tab, line)
+		if tab.filename == "" {
+			statusLabel.SetText("Breakpoints take effect once the script is saved")
+		}
+	}
+
+	refreshDebugDecorations(tab)
+}
+
+// addLineBreakpoint marks a 0-based line. Right gravity keeps the mark on
+// the line's text when a newline is inserted before it.
+func addLineBreakpoint(tab *ScriptTab, line int) {
+	breakpointMarkCounter++
+	name := fmt.Sprintf("breakpoint-%d", breakpointMarkCounter)
+	mark := tab.buffer.CreateMark(name, tab.buffer.GetIterAtLine(line), false)
+	tab.breakpoints = append(tab.breakpoints, mark)
+}
+
+// setTabBreakpoints replaces a tab's breakpoints with 1-based lines
+func setTabBreakpoints(tab *ScriptTab, lines []int) {
+	clearTabBreakpoints(tab)
+	for _, line := range lines {
+		if line >= 1 && line <= tab.buffer.GetLineCount() {
+			addLineBreakpoint(tab, line-1)
+		}
+	}
+	refreshDebugDecorations(tab)
+}
+
+func clearTabBreakpoints(tab *ScriptTab) {
+	for _, mark := range tab.breakpoints {
+		tab.buffer.DeleteMark(mark)
+	}
+	tab.breakpoints = nil
+}
+
+func toggleBreakpointAtCursor() {
+	tab := getCurrentTab()
+	if tab == nil {
+		return
+	}
+	iter := tab.buffer.GetIterAtMark(tab.buffer.GetInsert())
+	toggleLineBreakpoint(tab, iter.GetLine())
+}
+
+func removeAllBreakpoints() {
+	for _, tab := range openTabs {
+		clearTabBreakpoints(tab)
+		refreshDebugDecorations(tab)
+	}
+	otherBreakpoints = nil
+	updateBreakpointSnapshot()
+	statusLabel.SetText("All breakpoints removed")
+}
+
+// collectBreakpoints returns every breakpoint that can be set in a session.
+// Line breakpoints in unsaved tabs are skipped; Set-PSBreakpoint needs a file.
+func collectBreakpoints() []translation.Breakpoint {
+	var bps []translation.Breakpoint
+	for _, tab := range openTabs {
+		if tab.filename == "" {
+			continue
+		}
+		for _, line := range tabBreakpointLines(tab) {
+			bps = append(bps, translation.Breakpoint{Kind: translation.LineBreakpoint, Script: tab.filename, Line: line})
+		}
+	}
+	return append(bps, otherBreakpoints...)
+}
+
+func updateBreakpointSnapshot() {
+	bps := collectBreakpoints()
+	breakpointSnapshotMutex.Lock()
+	breakpointSnapshot = bps
+	breakpointSnapshotMutex.Unlock()
+}
+
+func currentBreakpoints() []translation.Breakpoint {
+	breakpointSnapshotMutex.Lock()
+	defer breakpointSnapshotMutex.Unlock()
+	return breakpointSnapshot
+}
+
+// syncBreakpoints sets the current breakpoints in a session if they changed
+// since the last sync. Runs on worker goroutines before commands execute.
+func syncBreakpoints(tl *translation.TranslationLayer) {
+	bps := currentBreakpoints()
+	var signature []string
+	for _, bp := range bps {
+		signature = append(signature, bp.SetCommand())
+	}
+	joined := strings.Join(signature, "\n")
+
+	syncedBreakpointsMutex.Lock()
+	unchanged := syncedBreakpoints[tl] == joined
+	syncedBreakpointsMutex.Unlock()
+	if unchanged {
+		return
+	}
+
+	if err := tl.SyncBreakpoints(bps); err != nil {
+		return
+	}
+	syncedBreakpointsMutex.Lock()
+	syncedBreakpoints[tl] = joined
+	syncedBreakpointsMutex.Unlock()
+}
+
+// probeDebugger checks whether the last command stopped in the debugger and
+// fetches the call stack if so. The check is skipped when nothing could have
+// stopped it and no [DBG] prompt was seen, unless force is set.
+func probeDebugger(tl *translation.TranslationLayer, force bool) (*translation.DebugStop, []translation.StackFrame) {
+	if !force && !tl.IsDebugStopped() && !tl.DebugPromptPending() && len(currentBreakpoints()) == 0 {
+		return nil, nil
+	}
+	stop, err := tl.RefreshDebugState()
+	if err != nil || stop == nil {
+		return nil, nil
+	}
+	frames, _ := tl.GetCallStack()
+	return stop, frames
+}
+
+// onDebuggerStop shows a stop reported while no command is being waited
+// for, such as a breakpoint in a script still running after its command
+// returned. Stops during a command are shown when it completes. Called
+// from the pipe reader.
+func onDebuggerStop(tl *translation.TranslationLayer) {
+	glib.IdleAdd(func() bool {
+		if tl != translationLayer || isBusy() {
+			return false
+		}
+		// Hold off user commands while the session is asked about the stop
+		setExecuting(true)
+		go func() {
+			stop, frames := probeDebugger(tl, true)
+			watches := evaluateWatches(tl)
+			glib.IdleAdd(func() bool {
+				setExecuting(false)
+				if tl == translationLayer {
+					updateDebugUI(stop, frames)
+					updateWatchResults(watches)
+				}
+				return false
+			})
+		}()
+		return false
+	})
+}
+
+func isDebugStopped() bool {
+	return translationLayer != nil && translationLayer.IsDebugStopped()
+}
+
+// updateDebugUI shows where the debugger stopped, or clears the current
+// line and call stack when it is no longer stopped
+func updateDebugUI(stop *translation.DebugStop, frames []translation.StackFrame) {
+	for _, tab := range openTabs {
+		if tab.debugLine != 0 {
+			tab.debugLine = 0
+			refreshDebugDecorations(tab)
+		}
+	}
+	setCallStack(frames)
+
+	if stop == nil {
+		return
+	}
+
+	if stop.ScriptName != "" && stop.Line > 0 {
+		showDebugLocation(stop)
+	}
+	showToolPanelPage(callStackPane)
+	statusLabel.SetText(fmt.Sprintf("Stopped at %s:%d - F5 Continue, F10 Step Over, F11 Step Into, Shift+F11 Step Out, Shift+F5 Stop",
+		getBaseName(stop.ScriptName), stop.Line))
+}
+
+// showDebugLocation highlights the line the debugger stopped at without
+// taking focus from the console
+func showDebugLocation(stop *translation.DebugStop) {
+	tab := findTabForSource(stop.ScriptName)
+	if tab == nil {
+		tab = openFile(stop.ScriptName)
+		if tab == nil {
+			return
+		}
+	}
+	for i, t := range openTabs {
+		if t == tab {
+			setCurrentTab(i)
+		}
+	}
+
+	tab.debugLine = stop.Line
+	refreshDebugDecorations(tab)
+
+	if stop.Line <= tab.buffer.GetLineCount() {
+		revealLines(tab, stop.Line-1, stop.Line-1)
+		iter := tab.buffer.GetIterAtLine(stop.Line - 1)
+		tab.textView.ScrollToIter(iter, 0.1, true, 0.0, 0.3)
+	}
+}
+
+// runDebuggerCommand runs work as if command was typed at the [DBG] prompt
+func runDebuggerCommand(command string, work func(tl *translation.TranslationLayer) (string, error)) {
+	tl := translationLayer
+	if tl == nil || isBusy() {
+		return
+	}
+
+	clearUserInput()
+	consoleTextBuffer.Insert(consoleTextBuffer.GetEndIter(), command+"\n")
+
+	setExecuting(true)
+	beginConsoleBlock(command)
+	updateBreakpointSnapshot()
+
+	go func() {
+		syncBreakpoints(tl)
+		_, err := work(tl)
+		objects := collectObjectCapture(tl)
+		errors := collectErrorRecords(tl)
+		stop, frames := probeDebugger(tl, true)
+		watches := evaluateWatches(tl)
+
+		glib.IdleAdd(func() bool {
+			flushStreamedOutput()
+			if err != nil {
+				displayOutput(fmt.Sprintf("\nError: %v\n", err))
+			}
+			displayObjectCapture(objects)
+			displayErrorRecords(errors)
+			addRunErrors(errors)
+			displayPrompt()
+			setExecuting(false)
+			statusLabel.SetText("Ready")
+			updateDebugUI(stop, frames)
+			updateWatchResults(watches)
+			return false
+		})
+	}()
+}
+
+// debugStep sends a stepping command while stopped at a breakpoint
+func debugStep(action translation.DebugAction) {
+	if !isDebugStopped() {
+		statusLabel.SetText("The debugger is not stopped at a breakpoint")
+		return
+	}
+	runDebuggerCommand(string(action), func(tl *translation.TranslationLayer) (string, error) {
+		return tl.DebugStep(action)
+	})
+}
+
+// stopDebugger quits the debugger, or stops the running command
+func stopDebugger() {
+	if isDebugStopped() {
+		debugStep(translation.DebugQuit)
+		return
+	}
+	stopExecution()
+}
+
+// runToCursor continues (or starts the script) and stops at the cursor line
+func runToCursor() {
+	tab := getCurrentTab()
+	if tab == nil || translationLayer == nil || isBusy() {
+		return
+	}
+	line := tab.buffer.GetIterAtMark(tab.buffer.GetInsert()).GetLine() + 1
+
+	if !isDebugStopped() {
+		pendingRunToLine = line
+		runScript()
+		return
+	}
+
+	if tab.filename == "" {
+		statusLabel.SetText("Save the script to run to the cursor")
+		return
+	}
+	filename := tab.filename
+	runDebuggerCommand(string(translation.DebugContinue), func(tl *translation.TranslationLayer) (string, error) {
+		return tl.RunToLine(filename, line)
+	})
+}
+
+// takeRunToLine returns and clears the pending Run to Cursor line
+func takeRunToLine() int {
+	line := pendingRunToLine
+	pendingRunToLine = 0
+	return line
+}
+
+// createCallStackPane creates the Call Stack tool panel page
+func createCallStackPane() *gtk.ScrolledWindow {
+	// Function, location, frame index
+	callStackStore, _ = gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_INT)
+
+	callStackView, _ = gtk.TreeViewNew()
+	callStackView.SetModel(callStackStore)
+	callStackView.SetHeadersVisible(true)
+
+	functionRenderer, _ := gtk.CellRendererTextNew()
+	functionColumn, _ := gtk.TreeViewColumnNewWithAttribute("Function", functionRenderer, "text", 0)
+	functionColumn.SetResizable(true)
+	callStackView.AppendColumn(functionColumn)
+
+	locationRenderer, _ := gtk.CellRendererTextNew()
+	locationColumn, _ := gtk.TreeViewColumnNewWithAttribute("Location", locationRenderer, "text", 1)
+	locationColumn.SetExpand(true)
+	callStackView.AppendColumn(locationColumn)
+
+	// Double-click to show the frame's line
+	callStackView.Connect("row-activated", func() {
+		selection, _ := callStackView.GetSelection()
+		model, iter, ok := selection.GetSelected()
+		if !ok {
+			return
+		}
+		value, _ := model.(*gtk.TreeModel).GetValue(iter, 2)
+		goValue, _ := value.GoValue()
+		index, _ := goValue.(int)
+		if index < 0 || index >= len(callStackFrames) {
+			return
+		}
+		frame := callStackFrames[index]
+		if frame.ScriptName != "" && frame.Line > 0 {
+			navigateToSource(SourceLocation{File: frame.ScriptName, Line: frame.Line})
+		}
+	})
+
+	callStackPane, _ = gtk.ScrolledWindowNew(nil, nil)
+	callStackPane.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	callStackPane.Add(callStackView)
+
+	return callStackPane
+}
+
+func setCallStack(frames []translation.StackFrame) {
+	if callStackStore == nil {
+		return
+	}
+	callStackFrames = frames
+	callStackStore.Clear()
+	for i, frame := range frames {
+		iter := callStackStore.Append()
+		callStackStore.Set(iter, []int{0, 1, 2}, []interface{}{frame.FunctionName, frame.Location, i})
+	}
+}
+
+func showCallStack() {
+	showToolPanelPage(callStackPane)
+}
+
+// showVariableBreakpointDialog asks for a variable to break on
+func showVariableBreakpointDialog() {
+	dialog, _ := gtk.DialogNew()
+	dialog.SetTitle("Set Variable Breakpoint")
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetModal(true)
+	dialog.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	dialog.AddButton("OK", gtk.RESPONSE_OK)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(6)
+	grid.SetColumnSpacing(8)
+	grid.SetMarginStart(10)
+	grid.SetMarginEnd(10)
+	grid.SetMarginTop(10)
+	grid.SetMarginBottom(10)
+
+	nameLabel, _ := gtk.LabelNew("Variable name:")
+	nameLabel.SetHAlign(gtk.ALIGN_END)
+	nameEntry, _ := gtk.EntryNew()
+	nameEntry.SetHExpand(true)
+	nameEntry.SetActivatesDefault(true)
+	if word := wordAtCursor(); strings.HasPrefix(word, "$") {
+		nameEntry.SetText(strings.TrimPrefix(word, "$"))
+	}
+
+	modeLabel, _ := gtk.LabelNew("Break on:")
+	modeLabel.SetHAlign(gtk.ALIGN_END)
+	modeCombo, _ := gtk.ComboBoxTextNew()
+	modeCombo.AppendText("Write")
+	modeCombo.AppendText("Read")
+	modeCombo.AppendText("ReadWrite")
+	modeCombo.SetActive(0)
+
+	grid.Attach(nameLabel, 0, 0, 1, 1)
+	grid.Attach(nameEntry, 1, 0, 1, 1)
+	grid.Attach(modeLabel, 0, 1, 1, 1)
+	grid.Attach(modeCombo, 1, 1, 1, 1)
+
+	contentArea, _ := dialog.GetContentArea()
+	contentArea.PackStart(grid, true, true, 0)
+	dialog.ShowAll()
+
+	if dialog.Run() == gtk.RESPONSE_OK {
+		name, _ := nameEntry.GetText()
+		name = strings.TrimPrefix(strings.TrimSpace(name), "$")
+		if name != "" {
+			otherBreakpoints = append(otherBreakpoints, translation.Breakpoint{
+				Kind:     translation.VariableBreakpoint,
+				Variable: name,
+				Mode:     modeCombo.GetActiveText(),
+			})
+			updateBreakpointSnapshot()
+		}
+	}
+	dialog.Destroy()
+}
+
+// showCommandBreakpointDialog asks for a command to break on
+func showCommandBreakpointDialog() {
+	dialog, _ := gtk.DialogNew()
+	dialog.SetTitle("Set Command Breakpoint")
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetModal(true)
+	dialog.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	dialog.AddButton("OK", gtk.RESPONSE_OK)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 8)
+	box.SetMarginStart(10)
+	box.SetMarginEnd(10)
+	box.SetMarginTop(10)
+	box.SetMarginBottom(10)
+
+	label, _ := gtk.LabelNew("Command (wildcards allowed):")
+	entry, _ := gtk.EntryNew()
+	entry.SetHExpand(true)
+	entry.SetActivatesDefault(true)
+	if word := wordAtCursor(); word != "" && !strings.HasPrefix(word, "$") {
+		entry.SetText(word)
+	}
+	box.PackStart(label, false, false, 0)
+	box.PackStart(entry, true, true, 0)
+
+	contentArea, _ := dialog.GetContentArea()
+	contentArea.PackStart(box, true, true, 0)
+	dialog.ShowAll()
+
+	if dialog.Run() == gtk.RESPONSE_OK {
+		command, _ := entry.GetText()
+		command = strings.TrimSpace(command)
+		if command != "" {
+			otherBreakpoints = append(otherBreakpoints, translation.Breakpoint{
+				Kind:    translation.CommandBreakpoint,
+				Command: command,
+			})
+			updateBreakpointSnapshot()
+		}
+	}
+	dialog.Destroy()
+}
+
+// wordAtCursor returns the command or variable name under the editor cursor
+func wordAtCursor() string {
+	tab := getCurrentTab()
+	if tab == nil {
+		return ""
+	}
+	iter := tab.buffer.GetIterAtMark(tab.buffer.GetInsert())
+	lineStart := tab.buffer.GetIterAtLine(iter.GetLine())
+	lineEnd := tab.buffer.GetIterAtLine(iter.GetLine())
+	if !lineEnd.EndsLine() {
+		lineEnd.ForwardToLineEnd()
+	}
+	text, _ := tab.buffer.GetText(lineStart, lineEnd, true)
+	runes := []rune(text)
+	column := iter.GetLineOffset()
+
+	start, end := column, column
+	for start > 0 && isIdentifierRune(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && isIdentifierRune(runes[end]) {
+		end++
+	}
+	if start > 0 && runes[start-1] == '$' {
+		start--
+	}
+	return string(runes[start:end])
+}
+
+// breakpointEntry is one row of the breakpoint list
+type breakpointEntry struct {
+	tab        *ScriptTab // Line breakpoints only
+	line       int        // 1-based, line breakpoints only
+	otherIndex int        // Index into otherBreakpoints otherwise
+}
+
+// showBreakpointsDialog lists all breakpoints and lets the user remove them
+func showBreakpointsDialog() {
+	const (
+		responseRemove    gtk.ResponseType = 1
+		responseRemoveAll gtk.ResponseType = 2
+	)
+
+	dialog, _ := gtk.DialogNew()
+	dialog.SetTitle("Breakpoints")
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(520, 320)
+	dialog.AddButton("Remove", responseRemove)
+	dialog.AddButton("Remove All", responseRemoveAll)
+	dialog.AddButton("Close", gtk.RESPONSE_CLOSE)
+
+	listStore, _ := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_INT)
+	treeView, _ := gtk.TreeViewNew()
+	treeView.SetModel(listStore)
+	treeView.SetHeadersVisible(true)
+
+	kindRenderer, _ := gtk.CellRendererTextNew()
+	kindColumn, _ := gtk.TreeViewColumnNewWithAttribute("Type", kindRenderer, "text", 0)
+	treeView.AppendColumn(kindColumn)
+
+	descRenderer, _ := gtk.CellRendererTextNew()
+	descColumn, _ := gtk.TreeViewColumnNewWithAttribute("Breakpoint", descRenderer, "text", 1)
+	descColumn.SetExpand(true)
+	treeView.AppendColumn(descColumn)
+
+	var entries []breakpointEntry
+	populate := func() {
+		entries = nil
+		listStore.Clear()
+		add := func(entry breakpointEntry, kind, description string) {
+			iter := listStore.Append()
+			listStore.Set(iter, []int{0, 1, 2}, []interface{}{kind, description, len(entries)})
+			entries = append(entries, entry)
+		}
+		for _, tab := range openTabs {
+			for _, line := range tabBreakpointLines(tab) {
+				add(breakpointEntry{tab: tab, line: line}, translation.LineBreakpoint.String(),
+					fmt.Sprintf("%s:%d", tabDisplayName(tab), line))
+			}
+		}
+		for i, bp := range otherBreakpoints {
+			add(breakpointEntry{otherIndex: i}, bp.Kind.String(), bp.Description())
+		}
+	}
+	populate()
+
+	selection, _ := treeView.GetSelection()
+	selectedEntry := func() (breakpointEntry, bool) {
+		model, iter, ok := selection.GetSelected()
+		if !ok {
+			return breakpointEntry{}, false
+		}
+		value, _ := model.(*gtk.TreeModel).GetValue(iter, 2)
+		goValue, _ := value.GoValue()
+		index, _ := goValue.(int)
+		if index < 0 || index >= len(entries) {
+			return breakpointEntry{}, false
+		}
+		return entries[index], true
+	}
+
+	// Double-click a line breakpoint to show it
+	treeView.Connect("row-activated", func() {
+		if entry, ok := selectedEntry(); ok && entry.tab != nil {
+			for i, t := range openTabs {
+				if t == entry.tab {
+					setCurrentTab(i)
+				}
+			}
+			goToLine(entry.tab, entry.line, 0)
+		}
+	})
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scroll.Add(treeView)
+
+	contentArea, _ := dialog.GetContentArea()
+	contentArea.PackStart(scroll, true, true, 10)
+	dialog.ShowAll()
+
+	for {
+		response := dialog.Run()
+		if response == responseRemoveAll {
+			removeAllBreakpoints()
+			populate()
+			continue
+		}
+		if response != responseRemove {
+			break
+		}
+		entry, ok := selectedEntry()
+		if !ok {
+			continue
+		}
+		if entry.tab != nil {
+			toggleLineBreakpoint(entry.tab, entry.line-1)
+		} else {
+			otherBreakpoints = append(otherBreakpoints[:entry.otherIndex], otherBreakpoints[entry.otherIndex+1:]...)
+			updateBreakpointSnapshot()
+		}
+		populate()
+	}
+
+	dialog.Destroy()
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+	"github.com/laurie/ps-ide-go/internal/psanalyzer"
+)
+
+const (
+	diagnosticsDelay         = 600 // Milliseconds after the last edit before parsing
+	diagnosticTagName        = "diagnostic-error"
+	diagnosticWarningTagName = "diagnostic-warning"
+	diagnosticErrorText      = "#E51400"
+	diagnosticWarningText    = "#B8860B"
+)
+
+var (
+	syntaxChecker    *translation.SyntaxChecker
+	diagnosticsLabel *gtk.Label
+)
+
+// DiagnosticSeverity tells parse errors from analyzer warnings
+type DiagnosticSeverity int
+
+const (
+	SeverityError DiagnosticSeverity = iota
+	SeverityWarning
+)
+
+// Diagnostic is a problem found in a script tab. Positions are 0-based,
+// columns in characters, and the end is exclusive.
+type Diagnostic struct {
+	Severity  DiagnosticSeverity
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Message   string
+	Rule      string          // Analyzer rule ID, empty for parse errors
+	Fix       *psanalyzer.Fix // Quick fix, nil if none
+}
+
+// setupDiagnostics creates the underline tags and the hover tooltip for a
+// tab. The error tag is created last so it wins where both apply.
+func setupDiagnostics(tab *ScriptTab) {
+	tab.buffer.CreateTag(diagnosticWarningTagName, map[string]interface{}{
+		"underline": 1, // PANGO_UNDERLINE_SINGLE
+	})
+	tab.buffer.CreateTag(diagnosticTagName, map[string]interface{}{
+		"underline": 4, // PANGO_UNDERLINE_ERROR
+	})
+
+	tab.textView.Set("has-tooltip", true)
+	tab.textView.Connect("query-tooltip", func(view *gtk.TextView, x, y int, keyboard bool, tooltip *gtk.Tooltip) bool {
+		var iter *gtk.TextIter
+		if keyboard {
+			iter = tab.buffer.GetIterAtMark(tab.buffer.GetInsert())
+		} else {
+			bx, by := view.WindowToBufferCoords(gtk.TEXT_WINDOW_WIDGET, x, y)
+			iter = view.GetIterAtLocation(bx, by)
+		}
+		message := diagnosticMessageAt(tab, iter)
+		if message == "" {
+			return false
+		}
+		tooltip.SetText(message)
+		return true
+	})
+}
+
+// scheduleDiagnostics parses the tab once edits have settled
+func scheduleDiagnostics(tab *ScriptTab) {
+	tab.diagnosticsVersion++
+	version := tab.diagnosticsVersion
+	glib.TimeoutAdd(diagnosticsDelay, func() bool {
+		if version == tab.diagnosticsVersion {
+			checkDiagnostics(tab)
+		}
+		return false
+	})
+}
+
+// isScriptTab reports whether a tab holds PowerShell, as opposed to e.g.
+// console output or another kind of file
+func isScriptTab(tab *ScriptTab) bool {
+	if tab.filename == "" {
+		return tab.title == ""
+	}
+	switch strings.ToLower(filepath.Ext(tab.filename)) {
+	case ".ps1", ".psm1", ".psd1":
+		return true
+	}
+	return false
+}
+
+func isTabOpen(tab *ScriptTab) bool {
+	for _, t := range openTabs {
+		if t == tab {
+			return true
+		}
+	}
+	return false
+}
+
+// getSyntaxChecker returns the checker for the selected PowerShell,
+// replacing one for a previous selection
+func getSyntaxChecker() *translation.SyntaxChecker {
+	path := globalPowerShellPath()
+	if syntaxChecker == nil || syntaxChecker.Executable() != path {
+		if old := syntaxChecker; old != nil {
+			go old.Stop()
+		}
+		syntaxChecker = translation.NewSyntaxChecker(path)
+		loadAnalyzerData(path)
+	}
+	return syntaxChecker
+}
+
+// stopSyntaxChecker ends the helper process on exit
+func stopSyntaxChecker() {
+	if syntaxChecker != nil {
+		syntaxChecker.Stop()
+	}
+}
+
+// checkDiagnostics parses the tab's text in the helper process, runs the
+// script analyzer over it and shows the results, unless the text has
+// changed in the meantime. Warnings are shown even if parsing fails.
+func checkDiagnostics(tab *ScriptTab) {
+	if !isTabOpen(tab) {
+		return
+	}
+	if !isScriptTab(tab) {
+		setDiagnostics(tab, nil, false)
+		return
+	}
+
+	start, end := tab.buffer.GetBounds()
+	text, _ := tab.buffer.GetText(start, end, true)
+	version := tab.diagnosticsVersion
+	checker := getSyntaxChecker()
+	analyzer := scriptAnalyzer
+	go func() {
+		warnings := analyzeScript(analyzer, text)
+		parseErrors, err := checker.Check(text)
+		glib.IdleAdd(func() bool {
+			if version != tab.diagnosticsVersion || !isTabOpen(tab) {
+				return false
+			}
+			if err != nil {
+				translation.DebugLog("Syntax check failed: %v", err)
+				setDiagnostics(tab, warnings, false)
+				return false
+			}
+			diagnostics := make([]Diagnostic, 0, len(parseErrors)+len(warnings))
+			for _, pe := range parseErrors {
+				diagnostics = append(diagnostics, Diagnostic{
+					Severity:  SeverityError,
+					Line:      pe.StartLine - 1,
+					Column:    pe.StartColumn - 1,
+					EndLine:   pe.EndLine - 1,
+					EndColumn: pe.EndColumn - 1,
+					Message:   pe.Message,
+				})
+			}
+			setDiagnostics(tab, append(diagnostics, warnings...), true)
+			return false
+		})
+	}()
+}
+
+// setDiagnostics replaces the underlines of a tab. checked tells whether
+// the script was parsed, i.e. whether a lack of errors means anything.
+func setDiagnostics(tab *ScriptTab, diagnostics []Diagnostic, checked bool) {
+	tab.diagnostics = diagnostics
+	tab.diagnosticsChecked = checked
+
+	errorTag := lookupTag(tab.buffer, diagnosticTagName)
+	warningTag := lookupTag(tab.buffer, diagnosticWarningTagName)
+	if errorTag != nil && warningTag != nil {
+		start, end := tab.buffer.GetBounds()
+		tab.buffer.RemoveTag(errorTag, start, end)
+		tab.buffer.RemoveTag(warningTag, start, end)
+		for _, d := range diagnostics {
+			start, end := diagnosticIters(tab.buffer, d)
+			if d.Severity == SeverityWarning {
+				tab.buffer.ApplyTag(warningTag, start, end)
+			} else {
+				tab.buffer.ApplyTag(errorTag, start, end)
+			}
+		}
+	}
+
+	if tab == getCurrentTab() {
+		updateDiagnosticsStatus()
+	}
+	queueGutterDraw(tab)
+	updateProblemsList()
+}
+
+// diagnosticIters returns the range to underline. An empty range, such as
+// a missing } at the end of the script, underlines the nearest character.
+func diagnosticIters(buffer *gtk.TextBuffer, d Diagnostic) (*gtk.TextIter, *gtk.TextIter) {
+	start := clampedIter(buffer, d.Line, d.Column)
+	end := clampedIter(buffer, d.EndLine, d.EndColumn)
+	if end.Compare(start) <= 0 {
+		end = clampedIter(buffer, d.Line, d.Column)
+		if start.EndsLine() {
+			start.BackwardChar()
+		} else {
+			end.ForwardChar()
+		}
+	}
+	return start, end
+}
+
+// clampedIter returns the iter at a line and column, kept inside the buffer
+func clampedIter(buffer *gtk.TextBuffer, line, column int) *gtk.TextIter {
+	line = max(0, min(line, buffer.GetLineCount()-1))
+	lineEnd := buffer.GetIterAtLine(line)
+	if !lineEnd.EndsLine() {
+		lineEnd.ForwardToLineEnd()
+	}
+	return buffer.GetIterAtLineOffset(line, max(0, min(column, lineEnd.GetLineOffset())))
+}
+
+// diagnosticsAt returns the diagnostics whose range holds iter
+func diagnosticsAt(tab *ScriptTab, iter *gtk.TextIter) []Diagnostic {
+	if iter == nil {
+		return nil
+	}
+	var found []Diagnostic
+	for _, d := range tab.diagnostics {
+		start, end := diagnosticIters(tab.buffer, d)
+		if iter.Compare(start) >= 0 && iter.Compare(end) < 0 {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+// diagnosticMessageAt returns the messages of the diagnostics at iter
+func diagnosticMessageAt(tab *ScriptTab, iter *gtk.TextIter) string {
+	var messages []string
+	for _, d := range diagnosticsAt(tab, iter) {
+		messages = append(messages, diagnosticText(d))
+	}
+	return strings.Join(messages, "\n")
+}
+
+// diagnosticText returns the message of a diagnostic, warnings followed by
+// their rule
+func diagnosticText(d Diagnostic) string {
+	if d.Rule != "" {
+		return fmt.Sprintf("%s (%s)", d.Message, d.Rule)
+	}
+	return d.Message
+}
+
+// diagnosticGutterMarkers shows an error or warning icon on the lines
+// problems start on, errors winning over warnings
+func diagnosticGutterMarkers(tab *ScriptTab) map[int]GutterMarker {
+	markers := make(map[int]GutterMarker)
+	for _, d := range tab.diagnostics {
+		marker := markers[d.Line]
+		if d.Severity == SeverityError {
+			marker.Icon, marker.Symbol, marker.Color = "dialog-error", "✖", diagnosticErrorText
+		} else if marker.Icon == "" {
+			marker.Icon, marker.Symbol, marker.Color = "dialog-warning", "▲", diagnosticWarningText
+		}
+		if marker.Tooltip != "" {
+			marker.Tooltip += "\n"
+		}
+		marker.Tooltip += diagnosticText(d)
+		markers[d.Line] = marker
+	}
+	return markers
+}
+
+// showLineDiagnostic moves the cursor to the first problem on a 0-based
+// line, where the context menu offers its quick fix
+func showLineDiagnostic(tab *ScriptTab, line int) {
+	for _, d := range tab.diagnostics {
+		if d.Line == line {
+			start, _ := diagnosticIters(tab.buffer, d)
+			tab.buffer.PlaceCursor(start)
+			tab.textView.GrabFocus()
+			return
+		}
+	}
+}
+
+// countDiagnostics returns the number of errors and warnings of a tab
+func countDiagnostics(tab *ScriptTab) (errors, warnings int) {
+	for _, d := range tab.diagnostics {
+		if d.Severity == SeverityWarning {
+			warnings++
+		} else {
+			errors++
+		}
+	}
+	return errors, warnings
+}
+
+// updateDiagnosticsStatus shows the error and warning counts of the
+// current tab
+func updateDiagnosticsStatus() {
+	if diagnosticsLabel == nil {
+		return
+	}
+	tab := getCurrentTab()
+	if tab == nil {
+		diagnosticsLabel.SetText("")
+		return
+	}
+	errors, warnings := countDiagnostics(tab)
+
+	var parts []string
+	switch {
+	case errors > 0:
+		parts = append(parts, fmt.Sprintf(`<span foreground="%s">%s</span>`,
+			diagnosticErrorText, plural(errors, "syntax error", "syntax errors")))
+	case tab.diagnosticsChecked:
+		parts = append(parts, "No syntax errors")
+	}
+	if warnings > 0 {
+		parts = append(parts, fmt.Sprintf(`<span foreground="%s">%s</span>`,
+			diagnosticWarningText, plural(warnings, "warning", "warnings")))
+	}
+	diagnosticsLabel.SetMarkup(strings.Join(parts, ", "))
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
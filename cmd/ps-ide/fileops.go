@@ -27,6 +27,42 @@ func getCurrentTabIndex() int {
 	return tabNum - 1
 }
 
+// openFile loads a script into a new tab, reusing the current tab if it is
+// an empty untitled one. It returns nil if the file cannot be read.
+func openFile(filename string) *ScriptTab {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+
+	tab := getCurrentTab()
+	shouldReplaceCurrentTab := false
+	if tab != nil && tab.filename == "" && !tab.modified {
+		start := tab.buffer.GetStartIter()
+		end := tab.buffer.GetEndIter()
+		currentContent, _ := tab.buffer.GetText(start, end, true)
+		if currentContent == "" {
+			shouldReplaceCurrentTab = true
+		}
+	}
+
+	if !shouldReplaceCurrentTab {
+		tab = createNewTab()
+	}
+	tab.buffer.SetText(string(content))
+	tab.undoStack.Clear()
+	tab.filename = filename
+	tab.modified = false
+	updateTabTitle(tab)
+
+	// Trigger syntax highlighting for opened file
+	if tab.syntaxHighlighter != nil {
+		tab.syntaxHighlighter.Highlight()
+	}
+
+	return tab
+}
+
 func newScript() {
 	createNewTab()
 	statusLabel.SetText("New script created")
@@ -55,43 +91,7 @@ func openScript(win *gtk.Window) {
 		filename := dialog.GetFilename()
 		lastOpenDirectory = filepath.Dir(filename)
 
-		content, err := os.ReadFile(filename)
-		if err == nil {
-			currentTab := getCurrentTab()
-
-			shouldReplaceCurrentTab := false
-			if currentTab != nil && currentTab.filename == "" && !currentTab.modified {
-				start := currentTab.buffer.GetStartIter()
-				end := currentTab.buffer.GetEndIter()
-				currentContent, _ := currentTab.buffer.GetText(start, end, false)
-				if currentContent == "" {
-					shouldReplaceCurrentTab = true
-				}
-			}
-
-			if shouldReplaceCurrentTab {
-				currentTab.buffer.SetText(string(content))
-				currentTab.filename = filename
-				currentTab.modified = false
-				updateTabTitle(currentTab)
-
-				// Trigger syntax highlighting for opened file
-				if currentTab.syntaxHighlighter != nil {
-					currentTab.syntaxHighlighter.Highlight()
-				}
-			} else {
-				tab := createNewTab()
-				tab.buffer.SetText(string(content))
-				tab.filename = filename
-				tab.modified = false
-				updateTabTitle(tab)
-
-				// Trigger syntax highlighting for opened file
-				if tab.syntaxHighlighter != nil {
-					tab.syntaxHighlighter.Highlight()
-				}
-			}
-
+		if openFile(filename) != nil {
 			statusLabel.SetText("Opened: " + filename)
 		} else {
 			statusLabel.SetText("Error opening file")
@@ -113,7 +113,7 @@ func saveScript(win *gtk.Window) {
 	}
 
 	start, end := tab.buffer.GetBounds()
-	content, _ := tab.buffer.GetText(start, end, false)
+	content, _ := tab.buffer.GetText(start, end, true)
 
 	err := os.WriteFile(tab.filename, []byte(content), 0644)
 	if err == nil {
@@ -162,13 +162,15 @@ func saveScriptAs(win *gtk.Window) {
 		lastOpenDirectory = filepath.Dir(filename)
 
 		start, end := tab.buffer.GetBounds()
-		content, _ := tab.buffer.GetText(start, end, false)
+		content, _ := tab.buffer.GetText(start, end, true)
 
 		err := os.WriteFile(filename, []byte(content), 0644)
 		if err == nil {
 			tab.filename = filename
 			tab.modified = false
 			updateTabTitle(tab)
+			scheduleDiagnostics(tab) // The new name may change the checks and the Problems pane
+			scheduleOutline(tab)
 			statusLabel.SetText("Saved: " + filename)
 		} else {
 			statusLabel.SetText("Error saving file")
@@ -185,7 +187,7 @@ func saveCurrentFile() {
 	}
 
 	start, end := tab.buffer.GetBounds()
-	content, _ := tab.buffer.GetText(start, end, false)
+	content, _ := tab.buffer.GetText(start, end, true)
 
 	err := os.WriteFile(tab.filename, []byte(content), 0644)
 	if err == nil {
@@ -237,13 +239,15 @@ func showSaveAsDialog() bool {
 		lastOpenDirectory = filepath.Dir(filename)
 
 		start, end := tab.buffer.GetBounds()
-		content, _ := tab.buffer.GetText(start, end, false)
+		content, _ := tab.buffer.GetText(start, end, true)
 
 		err := os.WriteFile(filename, []byte(content), 0644)
 		if err == nil {
 			tab.filename = filename
 			tab.modified = false
 			updateTabTitle(tab)
+			scheduleDiagnostics(tab) // The new name may change the checks and the Problems pane
+			scheduleOutline(tab)
 			statusLabel.SetText("Saved: " + filename)
 			saved = true
 		} else {
@@ -3,6 +3,7 @@ package main
 import (
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/gtk"
@@ -171,7 +172,7 @@ func showFindDialog() {
 	if tab != nil {
 		start, end, hasSelection := tab.buffer.GetSelectionBounds()
 		if hasSelection {
-			text, _ := tab.buffer.GetText(start, end, false)
+			text, _ := tab.buffer.GetText(start, end, true)
 			// Only pre-fill if text is reasonable length (single line)
 			if len(text) < 100 && !strings.Contains(text, "\n") {
 				findDialog.searchEntry.SetText(text)
@@ -237,6 +238,7 @@ func (fd *FindDialog) findNext() {
 
 	if found {
 		// Highlight found text
+		revealLines(tab, matchStart.GetLine(), matchEnd.GetLine())
 		highlightFoundText(tab.buffer, tab.textView, matchStart, matchEnd)
 
 		// Update last search position for next search
@@ -270,7 +272,7 @@ func findInBuffer(buffer *gtk.TextBuffer, searchText string,
 	// Get all text
 	bufStart := buffer.GetStartIter()
 	bufEnd := buffer.GetEndIter()
-	text, _ := buffer.GetText(bufStart, bufEnd, false)
+	text, _ := buffer.GetText(bufStart, bufEnd, true)
 
 	wrapped := false
 	var matchStart, matchEnd *gtk.TextIter
@@ -426,3 +428,41 @@ func highlightFoundText(buffer *gtk.TextBuffer, textView *gtk.TextView,
 	// Scroll to make visible (centered at 30% from top)
 	textView.ScrollToIter(start, 0.0, true, 0.0, 0.3)
 }
+
+// findAllInText returns the rune ranges of every match in text, using the
+// same options as findInBuffer
+func findAllInText(text, searchText string, matchCase, wholeWord, isRegex bool) [][2]int {
+	if searchText == "" {
+		return nil
+	}
+
+	pattern := searchText
+	if !isRegex {
+		pattern = regexp.QuoteMeta(searchText)
+	}
+	if wholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if !matchCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+
+	// Convert byte offsets to the rune offsets GTK iters use
+	var matches [][2]int
+	runeOffset, byteOffset := 0, 0
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		if m[0] == m[1] {
+			continue // Skip empty regex matches
+		}
+		runeOffset += utf8.RuneCountInString(text[byteOffset:m[0]])
+		start := runeOffset
+		runeOffset += utf8.RuneCountInString(text[m[0]:m[1]])
+		byteOffset = m[1]
+		matches = append(matches, [2]int{start, runeOffset})
+	}
+	return matches
+}
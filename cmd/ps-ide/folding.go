@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/internal/psanalyzer"
+)
+
+const (
+	foldingDelay       = 300 // Milliseconds after the last edit before fold ranges are found again
+	foldedTagName      = "folded"
+	foldedLineTagName  = "folded-line"
+	foldExpandedMarker = "⊟"
+	foldFoldedMarker   = "⊞"
+)
+
+var foldMarkCounter int
+
+// Fold is a folded range of a tab. start sits at the start of the first
+// hidden line and end at the start of the line after the last one, so
+// whole lines, newlines included, are hidden and leave no empty row. The
+// hidden text between the marks follows edits elsewhere in the buffer.
+type Fold struct {
+	start *gtk.TextMark
+	end   *gtk.TextMark
+}
+
+// setupFolding creates the tags that hide folded lines, and unfolds folds
+// that edits, cuts and copies reach into. Copies would otherwise leave the
+// hidden text out.
+func setupFolding(tab *ScriptTab) {
+	tab.buffer.CreateTag(foldedTagName, map[string]interface{}{"invisible": true})
+	tab.buffer.CreateTag(foldedLineTagName, map[string]interface{}{"paragraph-background": "#E8EEF7"})
+
+	var touched []*Fold
+	tab.buffer.Connect("insert-text", func(_ *gtk.TextBuffer, iter *gtk.TextIter, _ string, _ int) {
+		offset := iter.GetOffset()
+		for _, f := range tab.folds {
+			start, end := foldOffsets(tab, f)
+			if start < offset && offset < end {
+				touched = append(touched, f)
+			}
+		}
+	})
+	tab.buffer.Connect("delete-range", func(_ *gtk.TextBuffer, from, to *gtk.TextIter) {
+		a, b := from.GetOffset(), to.GetOffset()
+		for _, f := range tab.folds {
+			start, end := foldOffsets(tab, f)
+			if b >= start && a < end {
+				touched = append(touched, f)
+			}
+		}
+	})
+	unfoldTouched := func() {
+		if len(touched) == 0 {
+			return
+		}
+		for _, f := range touched {
+			removeFold(tab, f)
+		}
+		touched = nil
+		applyFolds(tab)
+	}
+	tab.buffer.ConnectAfter("insert-text", unfoldTouched)
+	tab.buffer.ConnectAfter("delete-range", unfoldTouched)
+
+	revealSelection := func() {
+		if start, end, ok := tab.buffer.GetSelectionBounds(); ok {
+			revealLines(tab, start.GetLine(), end.GetLine())
+		}
+	}
+	tab.textView.Connect("copy-clipboard", revealSelection)
+	tab.textView.Connect("cut-clipboard", revealSelection)
+}
+
+func foldOffsets(tab *ScriptTab, f *Fold) (int, int) {
+	return tab.buffer.GetIterAtMark(f.start).GetOffset(), tab.buffer.GetIterAtMark(f.end).GetOffset()
+}
+
+// foldLines returns the 0-based visible line of a fold and its last hidden
+// line: the lines of the characters before each mark
+func foldLines(tab *ScriptTab, f *Fold) (int, int) {
+	start := tab.buffer.GetIterAtMark(f.start)
+	start.BackwardChar()
+	end := tab.buffer.GetIterAtMark(f.end)
+	end.BackwardChar()
+	return start.GetLine(), end.GetLine()
+}
+
+// scheduleFolding finds the fold ranges again once edits have settled
+func scheduleFolding(tab *ScriptTab) {
+	tab.foldingVersion++
+	version := tab.foldingVersion
+	glib.TimeoutAdd(foldingDelay, func() bool {
+		if version != tab.foldingVersion || !isTabOpen(tab) {
+			return false
+		}
+		start, end := tab.buffer.GetBounds()
+		text, _ := tab.buffer.GetText(start, end, true)
+		go func() {
+			ranges := psanalyzer.FoldRanges(text)
+			glib.IdleAdd(func() bool {
+				if version == tab.foldingVersion && isTabOpen(tab) {
+					tab.foldRanges = ranges
+					queueGutterDraw(tab)
+				}
+				return false
+			})
+		}()
+		return false
+	})
+}
+
+// foldRangeAt returns the range starting on a line or, failing that, the
+// innermost range hiding it
+func foldRangeAt(tab *ScriptTab, line int) (psanalyzer.FoldRange, bool) {
+	var found psanalyzer.FoldRange
+	ok := false
+	for _, r := range tab.foldRanges {
+		if r.Line == line {
+			return r, true
+		}
+		if r.Line < line && line <= r.EndLine && (!ok || r.Line > found.Line) {
+			found, ok = r, true
+		}
+	}
+	return found, ok
+}
+
+// foldAt returns the fold whose visible line is line, or nil
+func foldAt(tab *ScriptTab, line int) *Fold {
+	for _, f := range tab.folds {
+		if first, _ := foldLines(tab, f); first == line {
+			return f
+		}
+	}
+	return nil
+}
+
+// addFold hides the lines after line up to endLine
+func addFold(tab *ScriptTab, line, endLine int) {
+	if line < 0 || endLine <= line || endLine >= tab.buffer.GetLineCount() || foldAt(tab, line) != nil {
+		return
+	}
+	start := tab.buffer.GetIterAtLine(line + 1)
+	end := tab.buffer.GetEndIter()
+	if endLine+1 < tab.buffer.GetLineCount() {
+		end = tab.buffer.GetIterAtLine(endLine + 1)
+	}
+	// Text typed at the start of the next visible line stays visible
+	foldMarkCounter++
+	tab.folds = append(tab.folds, &Fold{
+		start: tab.buffer.CreateMark(fmt.Sprintf("fold-%d-start", foldMarkCounter), start, false),
+		end:   tab.buffer.CreateMark(fmt.Sprintf("fold-%d-end", foldMarkCounter), end, true),
+	})
+
+	// Keep the cursor out of the hidden text
+	cursor := tab.buffer.GetIterAtMark(tab.buffer.GetInsert())
+	if cursor.GetLine() > line && cursor.GetLine() <= endLine {
+		headerEnd := tab.buffer.GetIterAtLine(line)
+		if !headerEnd.EndsLine() {
+			headerEnd.ForwardToLineEnd()
+		}
+		tab.buffer.PlaceCursor(headerEnd)
+	}
+}
+
+func removeFold(tab *ScriptTab, f *Fold) {
+	for i, other := range tab.folds {
+		if other == f {
+			tab.folds = append(tab.folds[:i], tab.folds[i+1:]...)
+			tab.buffer.DeleteMark(f.start)
+			tab.buffer.DeleteMark(f.end)
+			return
+		}
+	}
+}
+
+// applyFolds hides the folded text and redraws the gutter
+func applyFolds(tab *ScriptTab) {
+	start, end := tab.buffer.GetBounds()
+	hidden := lookupTag(tab.buffer, foldedTagName)
+	header := lookupTag(tab.buffer, foldedLineTagName)
+	tab.buffer.RemoveTag(hidden, start, end)
+	tab.buffer.RemoveTag(header, start, end)
+	for _, f := range tab.folds {
+		from := tab.buffer.GetIterAtMark(f.start)
+		to := tab.buffer.GetIterAtMark(f.end)
+		tab.buffer.ApplyTag(hidden, from, to)
+		first, _ := foldLines(tab, f)
+		applyLineTag(tab.buffer, header, first)
+	}
+	queueGutterDraw(tab)
+}
+
+// toggleFold folds the range at a line, or unfolds it if it is folded
+func toggleFold(tab *ScriptTab, line int) {
+	if f := foldAt(tab, line); f != nil {
+		removeFold(tab, f)
+		applyFolds(tab)
+		return
+	}
+	if r, ok := foldRangeAt(tab, line); ok {
+		addFold(tab, r.Line, r.EndLine)
+		applyFolds(tab)
+	}
+}
+
+func toggleFoldAtCursor() {
+	tab := getCurrentTab()
+	if tab == nil {
+		return
+	}
+	if len(tab.foldRanges) == 0 {
+		updateFoldRanges(tab)
+	}
+	toggleFold(tab, tab.buffer.GetIterAtMark(tab.buffer.GetInsert()).GetLine())
+}
+
+// foldAll folds every range of the current tab, nested ones included, so
+// unfolding a block shows its inner blocks folded
+func foldAll() {
+	tab := getCurrentTab()
+	if tab == nil {
+		return
+	}
+	updateFoldRanges(tab)
+	for _, r := range tab.foldRanges {
+		addFold(tab, r.Line, r.EndLine)
+	}
+	applyFolds(tab)
+	statusLabel.SetText(fmt.Sprintf("Folded %d blocks", len(tab.folds)))
+}
+
+func unfoldAll() {
+	tab := getCurrentTab()
+	if tab == nil {
+		return
+	}
+	for len(tab.folds) > 0 {
+		removeFold(tab, tab.folds[0])
+	}
+	applyFolds(tab)
+}
+
+// updateFoldRanges finds the fold ranges now rather than after the delay
+func updateFoldRanges(tab *ScriptTab) {
+	tab.foldingVersion++
+	start, end := tab.buffer.GetBounds()
+	text, _ := tab.buffer.GetText(start, end, true)
+	tab.foldRanges = psanalyzer.FoldRanges(text)
+}
+
+// foldGutterMarkers shows a marker on each line a range can be folded or
+// unfolded from
+func foldGutterMarkers(tab *ScriptTab) map[int]GutterMarker {
+	markers := make(map[int]GutterMarker)
+	for _, r := range tab.foldRanges {
+		markers[r.Line] = GutterMarker{Symbol: foldExpandedMarker, Tooltip: "Fold (Ctrl+M)"}
+	}
+	for _, f := range tab.folds {
+		first, last := foldLines(tab, f)
+		markers[first] = GutterMarker{
+			Symbol:  foldFoldedMarker,
+			Color:   gutterNumberColor,
+			Tooltip: fmt.Sprintf("Unfold %s (Ctrl+M)", plural(last-first, "line", "lines")),
+		}
+	}
+	return markers
+}
+
+// clickFoldMarker toggles the fold of a line that has a fold marker
+func clickFoldMarker(tab *ScriptTab, line int) {
+	if foldAt(tab, line) != nil {
+		toggleFold(tab, line)
+		return
+	}
+	for _, r := range tab.foldRanges {
+		if r.Line == line {
+			toggleFold(tab, line)
+			return
+		}
+	}
+}
+
+// revealLines unfolds the folds hiding any of the 0-based lines from to to
+func revealLines(tab *ScriptTab, from, to int) {
+	changed := false
+	for i := len(tab.folds) - 1; i >= 0; i-- {
+		f := tab.folds[i]
+		first, last := foldLines(tab, f)
+		if from <= last && to > first {
+			removeFold(tab, f)
+			changed = true
+		}
+	}
+	if changed {
+		applyFolds(tab)
+	}
+}
+
+// tabFoldLines returns the 1-based visible lines of a tab's folds
+func tabFoldLines(tab *ScriptTab) []int {
+	var lines []int
+	for _, f := range tab.folds {
+		first, _ := foldLines(tab, f)
+		lines = append(lines, first+1)
+	}
+	return lines
+}
+
+// setTabFolds folds the ranges starting on the given 1-based lines
+func setTabFolds(tab *ScriptTab, lines []int) {
+	if len(lines) == 0 {
+		return
+	}
+	updateFoldRanges(tab)
+	for _, line := range lines {
+		for _, r := range tab.foldRanges {
+			if r.Line == line-1 {
+				addFold(tab, r.Line, r.EndLine)
+			}
+		}
+	}
+	applyFolds(tab)
+}
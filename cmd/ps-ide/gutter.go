@@ -0,0 +1,436 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/cairo"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+const (
+	gutterBackground  = "#F0F0F0"
+	gutterBorder      = "#D0D0D0"
+	gutterNumberColor = "#2B91AF"
+	gutterSymbolColor = "#606060"
+	gutterHoverColor  = "#E2E2E2"
+	gutterPadding     = 4 // Pixels either side of the line numbers
+)
+
+// GutterMarker is what a gutter column shows on a line
+type GutterMarker struct {
+	Icon             string // Icon theme name, drawn instead of Symbol if it loads
+	Symbol           string // Text such as ● or ⊞
+	Color            string // Colour of Symbol, gray if empty
+	NumberBackground string // Fills the line number, e.g. red for breakpoints; empty for none
+	NumberColor      string // Colour of the line number, blue if empty
+	Tooltip          string
+}
+
+// GutterColumn is a column of markers in the gutter of every tab. Columns
+// with a negative Order go left of the line numbers, the others right, in
+// Order. Each is one row of text wide, so icons scale with the zoom.
+type GutterColumn struct {
+	Name    string
+	Order   int
+	Markers func(tab *ScriptTab) map[int]GutterMarker // Markers by 0-based line
+	Click   func(tab *ScriptTab, line int)            // Called for clicks on the column, nil to ignore them
+
+	// Hover is called as the pointer moves onto a line of the column, and
+	// with -1 as it leaves the column; nil to ignore hovering
+	Hover func(tab *ScriptTab, line int)
+}
+
+// Gutter draws line numbers and marker columns beside a tab's editor. It
+// sits in the editor's viewport and takes line positions from the text
+// view, so it stays aligned with wrapped, folded and zoomed lines.
+type Gutter struct {
+	area        *gtk.DrawingArea
+	width       int
+	hoverLine   int           // 0-based line under the pointer, -1 if none
+	hoverColumn *GutterColumn // Column under the pointer, nil if none
+
+	// Markers of every column, kept until queueGutterDraw; nil if stale
+	markers map[*GutterColumn]map[int]GutterMarker
+}
+
+// gutterCell is the part of the gutter a column takes, or the line numbers
+// when column is nil
+type gutterCell struct {
+	column *GutterColumn
+	x      int
+	width  int
+}
+
+var (
+	gutterColumns []*GutterColumn
+
+	// Font measurements, kept until the zoom changes
+	gutterFontZoom    float64
+	gutterFontDesc    *pango.FontDescription
+	gutterDigitWidth  int
+	gutterTextHeight  int
+	gutterIconsBySize = make(map[string]*gdk.Pixbuf)
+)
+
+// registerGutterColumn adds a marker column to the gutters of all tabs.
+// Markers are cached between edits, so subsystems call queueGutterDraw
+// when their markers change.
+func registerGutterColumn(column *GutterColumn) {
+	gutterColumns = append(gutterColumns, column)
+	sort.SliceStable(gutterColumns, func(i, j int) bool { return gutterColumns[i].Order < gutterColumns[j].Order })
+	for _, tab := range openTabs {
+		queueGutterDraw(tab)
+	}
+}
+
+// registerDefaultGutterColumns adds the diagnostics and breakpoint columns
+// left of the line numbers and the fold markers right of them
+func registerDefaultGutterColumns() {
+	registerGutterColumn(&GutterColumn{Name: "diagnostics", Order: -2, Markers: diagnosticGutterMarkers, Click: showLineDiagnostic})
+	registerGutterColumn(&GutterColumn{Name: "breakpoints", Order: -1, Markers: debugGutterMarkers, Click: toggleLineBreakpoint})
+	registerGutterColumn(&GutterColumn{Name: "folding", Order: 1, Markers: foldGutterMarkers, Click: clickFoldMarker})
+}
+
+func createGutter() *Gutter {
+	area, _ := gtk.DrawingAreaNew()
+	area.AddEvents(int(gdk.BUTTON_PRESS_MASK | gdk.POINTER_MOTION_MASK | gdk.LEAVE_NOTIFY_MASK))
+	area.Set("has-tooltip", true)
+	return &Gutter{area: area, hoverLine: -1}
+}
+
+// setupGutter connects the gutter's drawing, clicks, hover and tooltips
+func setupGutter(tab *ScriptTab) {
+	g := tab.gutter
+	g.area.Connect("draw", func(_ *gtk.DrawingArea, cr *cairo.Context) bool {
+		drawGutter(tab, cr)
+		return true
+	})
+	g.area.Connect("button-press-event", func(_ *gtk.DrawingArea, event *gdk.Event) bool {
+		button := gdk.EventButtonNewFromEvent(event)
+		if button.Type() != gdk.EVENT_BUTTON_PRESS || button.Button() != 1 {
+			return false
+		}
+		line := gutterLineAt(tab, int(button.Y()))
+		if line < 0 {
+			return true
+		}
+		cell := gutterCellAt(tab, int(button.X()))
+		switch {
+		case cell.column == nil:
+			toggleLineBreakpoint(tab, line)
+		case cell.column.Click != nil:
+			cell.column.Click(tab, line)
+		}
+		return true
+	})
+	g.area.Connect("motion-notify-event", func(_ *gtk.DrawingArea, event *gdk.Event) bool {
+		x, y := gdk.EventMotionNewFromEvent(event).MotionVal()
+		line := gutterLineAt(tab, int(y))
+		var column *GutterColumn
+		if line >= 0 {
+			column = gutterCellAt(tab, int(x)).column
+		}
+		setGutterHover(tab, column, line)
+		return false
+	})
+	g.area.Connect("leave-notify-event", func() bool {
+		setGutterHover(tab, nil, -1)
+		return false
+	})
+	g.area.Connect("query-tooltip", func(_ *gtk.DrawingArea, x, y int, keyboard bool, tooltip *gtk.Tooltip) bool {
+		if keyboard {
+			return false
+		}
+		text := gutterTooltip(tab, x, y)
+		if text == "" {
+			return false
+		}
+		tooltip.SetText(text)
+		return true
+	})
+
+	// Line heights change when the editor wraps or the zoom changes
+	tab.textView.Connect("size-allocate", func() {
+		g.area.QueueDraw()
+	})
+	queueGutterDraw(tab)
+}
+
+// queueGutterDraw redraws a tab's gutter with fresh markers, widening it
+// first if the line numbers or zoom need more room
+func queueGutterDraw(tab *ScriptTab) {
+	if tab.gutter == nil {
+		return
+	}
+	tab.gutter.markers = nil
+	if _, width := gutterCells(tab); width != tab.gutter.width {
+		tab.gutter.width = width
+		tab.gutter.area.SetSizeRequest(width, -1)
+	}
+	tab.gutter.area.QueueDraw()
+}
+
+// setGutterHover highlights the line under the pointer and calls the Hover
+// of the column it left and the column it is over
+func setGutterHover(tab *ScriptTab, column *GutterColumn, line int) {
+	g := tab.gutter
+	if g.hoverLine == line && g.hoverColumn == column {
+		return
+	}
+	if g.hoverLine != line {
+		g.area.QueueDraw()
+	}
+	if left := g.hoverColumn; left != nil && left != column && left.Hover != nil {
+		left.Hover(tab, -1)
+	}
+	g.hoverLine, g.hoverColumn = line, column
+	if column != nil && column.Hover != nil {
+		column.Hover(tab, line)
+	}
+}
+
+// updateGutterFont measures the editor font at the current zoom
+func updateGutterFont() {
+	if gutterFontDesc != nil && gutterFontZoom == currentZoom {
+		return
+	}
+	gutterFontZoom = currentZoom
+	family := strings.ReplaceAll(DefaultFontFamily, "'", "")
+	gutterFontDesc = pango.FontDescriptionFromString(fmt.Sprintf("%s %.1f", family, DefaultFontSize*(currentZoom/100.0)))
+
+	surface := cairo.CreateImageSurface(cairo.FORMAT_ARGB32, 1, 1)
+	layout := pango.CairoCreateLayout(cairo.Create(surface))
+	layout.SetFontDescription(gutterFontDesc)
+	layout.SetText("0", 1)
+	width, height := layout.GetSize()
+	gutterDigitWidth = max(1, width/pango.PANGO_SCALE)
+	gutterTextHeight = max(1, height/pango.PANGO_SCALE)
+}
+
+// gutterCells lays out the columns and line numbers and returns them with
+// the gutter's width
+func gutterCells(tab *ScriptTab) ([]gutterCell, int) {
+	updateGutterFont()
+	digits := len(fmt.Sprint(tab.buffer.GetLineCount()))
+	var cells []gutterCell
+	x := 0
+	numbers := false
+	for _, column := range gutterColumns {
+		if column.Order >= 0 && !numbers {
+			cells = append(cells, gutterCell{x: x, width: digits*gutterDigitWidth + 2*gutterPadding})
+			x += cells[len(cells)-1].width
+			numbers = true
+		}
+		cells = append(cells, gutterCell{column: column, x: x, width: gutterTextHeight})
+		x += gutterTextHeight
+	}
+	if !numbers {
+		cells = append(cells, gutterCell{x: x, width: digits*gutterDigitWidth + 2*gutterPadding})
+		x += cells[len(cells)-1].width
+	}
+	return cells, x + 1 // Room for the border
+}
+
+// gutterCellAt returns the cell at x in the gutter
+func gutterCellAt(tab *ScriptTab, x int) gutterCell {
+	cells, _ := gutterCells(tab)
+	for _, cell := range cells {
+		if x < cell.x+cell.width {
+			return cell
+		}
+	}
+	return cells[len(cells)-1]
+}
+
+// bufferToGutterY converts a y in the editor's buffer coordinates to one
+// in the gutter
+func bufferToGutterY(tab *ScriptTab, y int) int {
+	_, wy := tab.textView.BufferToWindowCoords(gtk.TEXT_WINDOW_WIDGET, 0, y)
+	_, gy, _ := tab.textView.TranslateCoordinates(tab.gutter.area, 0, wy)
+	return gy
+}
+
+func gutterToBufferY(tab *ScriptTab, y int) int {
+	_, wy, _ := tab.gutter.area.TranslateCoordinates(tab.textView, 0, y)
+	_, by := tab.textView.WindowToBufferCoords(gtk.TEXT_WINDOW_WIDGET, 0, wy)
+	return by
+}
+
+// gutterLineAt returns the 0-based line at y in the gutter, or -1 below the
+// last line
+func gutterLineAt(tab *ScriptTab, y int) int {
+	by := gutterToBufferY(tab, y)
+	iter, top := tab.textView.GetLineAtY(by)
+	_, height := tab.textView.GetLineYrange(iter)
+	if by < top || by >= top+height {
+		return -1
+	}
+	return iter.GetLine()
+}
+
+// gutterMarkers returns the markers of every column, from the cache unless
+// the buffer or markers changed since they were last collected
+func gutterMarkers(tab *ScriptTab) map[*GutterColumn]map[int]GutterMarker {
+	if tab.gutter.markers != nil {
+		return tab.gutter.markers
+	}
+	markers := make(map[*GutterColumn]map[int]GutterMarker)
+	for _, column := range gutterColumns {
+		if column.Markers != nil {
+			markers[column] = column.Markers(tab)
+		}
+	}
+	tab.gutter.markers = markers
+	return markers
+}
+
+// gutterTooltip returns the tooltip of the marker at x, y in the gutter.
+// Over the line numbers it lists every marker of the line.
+func gutterTooltip(tab *ScriptTab, x, y int) string {
+	line := gutterLineAt(tab, y)
+	if line < 0 {
+		return ""
+	}
+	cell := gutterCellAt(tab, x)
+	markers := gutterMarkers(tab)
+	if cell.column != nil {
+		return markers[cell.column][line].Tooltip
+	}
+	var tooltips []string
+	for _, column := range gutterColumns {
+		if tooltip := markers[column][line].Tooltip; tooltip != "" {
+			tooltips = append(tooltips, tooltip)
+		}
+	}
+	return strings.Join(tooltips, "\n")
+}
+
+func drawGutter(tab *ScriptTab, cr *cairo.Context) {
+	cells, width := gutterCells(tab)
+	_, top, _, bottom := cr.ClipExtents()
+
+	setSourceColor(cr, gutterBackground)
+	cr.Rectangle(0, top, float64(width), bottom-top)
+	cr.Fill()
+	setSourceColor(cr, gutterBorder)
+	cr.Rectangle(float64(width-1), top, 1, bottom-top)
+	cr.Fill()
+
+	markers := gutterMarkers(tab)
+	layout := pango.CairoCreateLayout(cr)
+	layout.SetFontDescription(gutterFontDesc)
+
+	iter, _ := tab.textView.GetLineAtY(gutterToBufferY(tab, int(top)))
+	for {
+		lineY, lineHeight := tab.textView.GetLineYrange(iter)
+		y := bufferToGutterY(tab, lineY)
+		if float64(y) > bottom {
+			break
+		}
+		// Lines hidden by a fold take no room
+		if lineHeight > 0 {
+			// Centre on the first row of a wrapped line
+			row := tab.textView.GetIterLocation(iter)
+			rowY := bufferToGutterY(tab, row.GetY())
+			drawGutterLine(tab, cr, layout, cells, markers, iter.GetLine(), y, lineHeight, rowY, row.GetHeight())
+		}
+		if !iter.ForwardLine() {
+			break
+		}
+	}
+}
+
+// drawGutterLine draws the number and markers of a 0-based line. y and
+// height span the whole line; rowY and rowHeight its first row of text.
+func drawGutterLine(tab *ScriptTab, cr *cairo.Context, layout *pango.Layout, cells []gutterCell,
+	markers map[*GutterColumn]map[int]GutterMarker, line, y, height, rowY, rowHeight int) {
+	if line == tab.gutter.hoverLine {
+		setSourceColor(cr, gutterHoverColor)
+		cr.Rectangle(0, float64(y), float64(tab.gutter.width-1), float64(height))
+		cr.Fill()
+	}
+
+	// Columns further right style the number over those further left
+	numberBackground, numberColor := "", gutterNumberColor
+	for _, column := range gutterColumns {
+		marker := markers[column][line]
+		if marker.NumberBackground != "" {
+			numberBackground = marker.NumberBackground
+		}
+		if marker.NumberColor != "" {
+			numberColor = marker.NumberColor
+		}
+	}
+
+	for _, cell := range cells {
+		if cell.column == nil {
+			if numberBackground != "" {
+				setSourceColor(cr, numberBackground)
+				cr.Rectangle(float64(cell.x), float64(y), float64(cell.width), float64(height))
+				cr.Fill()
+			}
+			number := fmt.Sprint(line + 1)
+			layout.SetText(number, len(number))
+			textWidth, textHeight := layout.GetSize()
+			setSourceColor(cr, numberColor)
+			cr.MoveTo(float64(cell.x+cell.width-gutterPadding-textWidth/pango.PANGO_SCALE),
+				float64(rowY+(rowHeight-textHeight/pango.PANGO_SCALE)/2))
+			pango.CairoShowLayout(cr, layout)
+			continue
+		}
+
+		marker, ok := markers[cell.column][line]
+		if !ok {
+			continue
+		}
+		if icon := gutterIcon(marker.Icon, min(cell.width, rowHeight)-2); icon != nil {
+			gtk.GdkCairoSetSourcePixBuf(cr, icon,
+				float64(cell.x+(cell.width-icon.GetWidth())/2), float64(rowY+(rowHeight-icon.GetHeight())/2))
+			cr.Rectangle(float64(cell.x), float64(rowY), float64(cell.width), float64(rowHeight))
+			cr.Fill()
+			continue
+		}
+		if marker.Symbol != "" {
+			layout.SetText(marker.Symbol, len(marker.Symbol))
+			textWidth, textHeight := layout.GetSize()
+			color := marker.Color
+			if color == "" {
+				color = gutterSymbolColor
+			}
+			setSourceColor(cr, color)
+			cr.MoveTo(float64(cell.x+(cell.width-textWidth/pango.PANGO_SCALE)/2),
+				float64(rowY+(rowHeight-textHeight/pango.PANGO_SCALE)/2))
+			pango.CairoShowLayout(cr, layout)
+		}
+	}
+}
+
+// gutterIcon loads a themed icon at a size, or returns nil if there is none
+func gutterIcon(name string, size int) *gdk.Pixbuf {
+	if name == "" || size < 4 {
+		return nil
+	}
+	key := fmt.Sprintf("%s/%d", name, size)
+	if icon, ok := gutterIconsBySize[key]; ok {
+		return icon
+	}
+	var icon *gdk.Pixbuf
+	if theme, err := gtk.IconThemeGetDefault(); err == nil {
+		icon, _ = theme.LoadIcon(name, size, gtk.ICON_LOOKUP_FORCE_SIZE)
+	}
+	gutterIconsBySize[key] = icon
+	return icon
+}
+
+// setSourceColor sets a colour such as "#E51400" to draw with
+func setSourceColor(cr *cairo.Context, spec string) {
+	color := gdk.NewRGBA()
+	if !color.Parse(spec) {
+		return
+	}
+	cr.SetSourceRGBA(color.GetRed(), color.GetGreen(), color.GetBlue(), color.GetAlpha())
+}
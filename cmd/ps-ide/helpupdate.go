@@ -10,7 +10,7 @@ import (
 
 // updatePowerShellHelp executes Update-Help command
 func updatePowerShellHelp() error {
-	cmd := exec.Command("pwsh", "-NoProfile", "-Command",
+	cmd := exec.Command(powerShellPath(), "-NoProfile", "-Command",
 		"Update-Help -Force -ErrorAction SilentlyContinue")
 	return cmd.Run()
 }
@@ -7,6 +7,8 @@ import (
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+	"github.com/laurie/ps-ide-go/internal/psanalyzer"
 )
 
 var (
@@ -42,9 +44,21 @@ type ScriptTab struct {
 	undoStack         *UndoStack
 	filename          string
 	modified          bool
-	lineNumView       *LineNumberView
+	gutter            *Gutter
 	syntaxHighlighter SyntaxHighlighterInterface
-	tabID             int // Unique, stable ID for this tab
+	tabID             int             // Unique, stable ID for this tab
+	powerShellPath    string          // Per-tab PowerShell executable, empty for the global default
+	breakpoints       []*gtk.TextMark // Line breakpoints, kept as marks so they follow edits
+	debugLine         int             // 1-based line the debugger is stopped at, 0 if none
+	title             string          // Name of an unsaved tab, e.g. console output; UntitledN.ps1 if empty
+
+	diagnostics        []Diagnostic // Parse errors and analyzer warnings from the last check
+	diagnosticsChecked bool         // The current diagnostics come from a completed check
+	diagnosticsVersion int          // Bumped on every edit; stale checks are dropped
+
+	foldRanges     []psanalyzer.FoldRange // Foldable ranges from the last scan
+	folds          []*Fold                // Folded ranges
+	foldingVersion int                    // Bumped on every edit; stale scans are dropped
 }
 
 var openTabs []*ScriptTab
@@ -53,6 +67,10 @@ func main() {
 	gtk.Init(nil)
 	tabCounter = 1
 
+	// Load settings (PowerShell executable, etc.) before anything spawns pwsh
+	loadAppConfig()
+	configureScriptAnalyzer()
+
 	// Setup optimal font rendering for crisp, clear text
 	SetupFontRendering()
 
@@ -70,7 +88,9 @@ func main() {
 	// Save session on window close
 	win.Connect("destroy", func() {
 		saveSession()
-		shutdownTranslationLayer()
+		stopTranscript()
+		shutdownAllTranslationLayers()
+		stopSyntaxChecker()
 		gtk.MainQuit()
 	})
 
@@ -111,6 +131,10 @@ func main() {
 	// Add tab bar (StackSwitcher) to main layout - stays fixed
 	mainVBox.PackStart(stackSwitcher, false, false, 0)
 
+	// Breakpoint, diagnostic and fold markers beside the line numbers
+	registerDefaultGutterColumns()
+	createCompletionPopover()
+
 	// Try to load previous session, if fails create default tab
 	if !loadSession() {
 		createNewTab()
@@ -125,22 +149,36 @@ func main() {
 		log.Fatal("Unable to create console:", consoleErr)
 	}
 
+	// Look for other installed PowerShell versions in the background
+	discoverPowerShellInstalls()
+
 	// Initialize Translation Layer
 	if err := initTranslationLayer(); err != nil {
 		log.Printf("Warning: Translation Layer failed to initialize: %v", err)
 		log.Println("PowerShell functionality will be limited")
+	} else {
+		startAutoTranscript()
 	}
 
 	// Split pane layout (editor content top, console bottom)
+	consoleArea, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	consoleArea.PackStart(createConsoleFilterBar(), false, false, 0)
+	consoleArea.PackStart(createConsoleSearchBar(consoleScroll), true, true, 0)
 	paned, _ := gtk.PanedNew(gtk.ORIENTATION_VERTICAL)
-	paned.Pack1(contentStack, true, true)  // Stack content resizable and shrinkable
-	paned.Pack2(consoleScroll, true, true) // console resizable and shrinkable
-	paned.SetWideHandle(true)              // Make divider easier to grab
+	paned.Pack1(contentStack, true, true)                     // Stack content resizable and shrinkable
+	paned.Pack2(createToolPanelPane(consoleArea), true, true) // console and tool panel, resizable and shrinkable
+	paned.SetWideHandle(true)                                 // Make divider easier to grab
 
 	// Set minimum sizes
 	contentStack.SetSizeRequest(-1, 150)
 	consoleScroll.SetSizeRequest(-1, 150)
 
+	// Debugger panes
+	addToolPanelPage("Call Stack", createCallStackPane())
+	addToolPanelPage("Watch", createWatchPane())
+	addToolPanelPage("Problems", createProblemsPane())
+	addToolPanelPage("Outline", createOutlinePane())
+
 	// Create horizontal paned for command add-on (editor+console | command-addon)
 	commandAddOnPane, _ = gtk.PanedNew(gtk.ORIENTATION_HORIZONTAL)
 	commandAddOnPane.SetWideHandle(true)
@@ -170,6 +208,10 @@ func main() {
 
 	win.Add(mainVBox)
 	win.ShowAll()
+	updatePSVersionLabel()
+
+	// Tool panel is shown on demand (e.g. when the debugger stops)
+	setToolPanelVisible(false)
 
 	// Hide Command Add-On after ShowAll (ShowAll shows everything)
 	if commandAddOn != nil && !pendingCommandAddOnShow {
@@ -255,11 +297,35 @@ func onKeyPress(_ interface{}, event *gdk.Event) bool {
 	state := keyEvent.State()
 
 	ctrl := (state & gdk.CONTROL_MASK) != 0
+	shift := (state & uint(gdk.SHIFT_MASK)) != 0
+	alt := (state & uint(gdk.MOD1_MASK)) != 0
 
+	if handleCompletionKey(keyval, state) {
+		return true
+	}
+	if ctrl && keyval == gdk.KEY_space {
+		triggerCompletion()
+		return true
+	}
 	if ctrl && keyval == gdk.KEY_n {
 		newScript()
 		return true
 	}
+	if ctrl && (keyval == gdk.KEY_M || keyval == gdk.KEY_m) {
+		switch {
+		case alt:
+			unfoldAll()
+		case shift:
+			foldAll()
+		default:
+			toggleFoldAtCursor()
+		}
+		return true
+	}
+	if ctrl && shift && (keyval == gdk.KEY_O || keyval == gdk.KEY_o) {
+		showGoToSymbolDialog()
+		return true
+	}
 	if ctrl && keyval == gdk.KEY_o {
 		openScript(mainWindow)
 		return true
@@ -297,6 +363,10 @@ func onKeyPress(_ interface{}, event *gdk.Event) bool {
 		return true
 	}
 	if ctrl && keyval == gdk.KEY_f {
+		if consoleHasFocus() {
+			showConsoleSearch()
+			return true
+		}
 		showFindDialog()
 		return true
 	}
@@ -304,10 +374,46 @@ func onKeyPress(_ interface{}, event *gdk.Event) bool {
 		showSnippetsDialog()
 		return true
 	}
+	if ctrl && shift && keyval == gdk.KEY_F9 {
+		removeAllBreakpoints()
+		return true
+	}
+	if ctrl && shift && (keyval == gdk.KEY_L || keyval == gdk.KEY_l) {
+		showBreakpointsDialog()
+		return true
+	}
+	if ctrl && shift && (keyval == gdk.KEY_D || keyval == gdk.KEY_d) {
+		showCallStack()
+		return true
+	}
+	if shift && keyval == gdk.KEY_F5 {
+		stopDebugger()
+		return true
+	}
 	if keyval == gdk.KEY_F5 {
 		runScript()
 		return true
 	}
+	if keyval == gdk.KEY_F9 {
+		toggleBreakpointAtCursor()
+		return true
+	}
+	if ctrl && keyval == gdk.KEY_F10 {
+		runToCursor()
+		return true
+	}
+	if keyval == gdk.KEY_F10 {
+		debugStep(translation.DebugStepOver)
+		return true
+	}
+	if shift && keyval == gdk.KEY_F11 {
+		debugStep(translation.DebugStepOut)
+		return true
+	}
+	if keyval == gdk.KEY_F11 {
+		debugStep(translation.DebugStepInto)
+		return true
+	}
 	if keyval == gdk.KEY_F8 {
 		runSelection()
 		return true
@@ -458,11 +564,18 @@ func applyCss() {
 }
 
 func onTabSwitch() {
+	hideCompletion()
 	tab := getCurrentTab()
 	if tab != nil && tab.buffer != nil {
 		updateCursorPosition(tab.buffer)
 		updateToolbarButtons()
 	}
+	updateDiagnosticsStatus()
+	refreshOutline()
+	refreshRunConfigCombo()
+	if translationLayer != nil {
+		activateTabPowerShell()
+	}
 }
 
 func getCurrentTab() *ScriptTab {
@@ -495,10 +608,7 @@ func updateTabTitle(tab *ScriptTab) {
 	}
 
 	pageName := fmt.Sprintf("tab-%d", tab.tabID)
-	title := fmt.Sprintf("Untitled%d.ps1", tab.tabID)
-	if tab.filename != "" {
-		title = getBaseName(tab.filename)
-	}
+	title := tabDisplayName(tab)
 	if tab.modified {
 		title = "* " + title
 	}
@@ -508,6 +618,29 @@ func updateTabTitle(tab *ScriptTab) {
 	if err == nil && child != nil {
 		contentStack.ChildSetProperty(child.ToWidget(), "title", title)
 	}
+
+	// The script may have been saved under a new name
+	syncRunConfigCombo()
+}
+
+// tabDisplayName returns the tab title without the modified marker
+func tabDisplayName(tab *ScriptTab) string {
+	if tab.filename != "" {
+		return getBaseName(tab.filename)
+	}
+	if tab.title != "" {
+		return tab.title
+	}
+	return fmt.Sprintf("Untitled%d.ps1", tab.tabID)
+}
+
+// tabSourceName returns the name PowerShell reports for code run from a
+// tab: the full path if saved, otherwise the untitled tab name
+func tabSourceName(tab *ScriptTab) string {
+	if tab.filename != "" {
+		return tab.filename
+	}
+	return tabDisplayName(tab)
 }
 
 func createStatusBar() *gtk.Box {
@@ -520,6 +653,7 @@ func createStatusBar() *gtk.Box {
 
 	statusLabel, _ = gtk.LabelNew("Ready")
 	statusBox.PackStart(statusLabel, false, false, 0)
+	statusBox.PackStart(createQueueIndicator(), false, false, 0)
 
 	spacer, _ := gtk.LabelNew("")
 	statusBox.PackStart(spacer, true, true, 0)
@@ -544,6 +678,13 @@ func createStatusBar() *gtk.Box {
 	cursorPosLabel, _ = gtk.LabelNew("Ln 1, Col 1")
 	statusBox.PackEnd(cursorPosLabel, false, false, 12)
 
+	diagnosticsLabel, _ = gtk.LabelNew("")
+	statusBox.PackEnd(diagnosticsLabel, false, false, 12)
+	statusBox.PackEnd(createProblemsIndicator(), false, false, 0)
+
+	psVersionLabel, _ = gtk.LabelNew("PowerShell")
+	statusBox.PackEnd(psVersionLabel, false, false, 12)
+
 	return statusBox
 }
 
@@ -570,24 +711,8 @@ func applyZoom(zoomPercent float64) {
 			tab.textView.QueueDraw()
 		}
 
-		// Also update line numbers when zooming
-		if tab.lineNumView != nil && tab.lineNumView.textView != nil {
-			provider, _ := gtk.CssProviderNew()
-			css := fmt.Sprintf(`textview { 
-				font-family: %s; 
-				font-size: %.1fpt; 
-				background-color: #F0F0F0;
-				padding: 3px;
-				border-right: 1px solid #D0D0D0;
-			}
-			textview text {
-				color: #2B91AF;
-			}`, DefaultFontFamily, fontSize)
-			provider.LoadFromData(css)
-			styleContext, _ := tab.lineNumView.textView.GetStyleContext()
-			styleContext.AddProvider(provider, gtk.STYLE_PROVIDER_PRIORITY_USER)
-			tab.lineNumView.textView.QueueDraw()
-		}
+		// The gutter measures the new font size
+		queueGutterDraw(tab)
 
 		// Update syntax highlighting after zoom
 		if tab.syntaxHighlighter != nil {
@@ -657,9 +782,6 @@ func updateToolbarButtons() {
 
 func setExecuting(executing bool) {
 	isExecuting = executing
-	if runButton != nil {
-		runButton.SetSensitive(!executing)
-	}
 	if stopButton != nil {
 		stopButton.SetSensitive(executing)
 	}
@@ -667,6 +789,10 @@ func setExecuting(executing bool) {
 		statusLabel.SetText("Executing...")
 	} else {
 		statusLabel.SetText("Ready")
+		// Apply any tab PowerShell switch deferred during execution
+		activateTabPowerShell()
+		// Start the next queued command, if any
+		scheduleQueueDispatch()
 	}
 }
 
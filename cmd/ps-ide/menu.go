@@ -64,7 +64,7 @@ func createMenuBar(win *gtk.Window) *gtk.MenuBar {
 	newRemotePSTabItem.SetSensitive(false) // Disabled - remote connectivity not implemented
 	exitItem.Connect("activate", func() {
 		saveSession()
-		shutdownTranslationLayer()
+		shutdownAllTranslationLayers()
 		gtk.MainQuit()
 	})
 
@@ -79,6 +79,9 @@ func createMenuBar(win *gtk.Window) *gtk.MenuBar {
 	copyItem, _ := gtk.MenuItemNewWithLabel("Copy")
 	pasteItem, _ := gtk.MenuItemNewWithLabel("Paste")
 	findItem, _ := gtk.MenuItemNewWithLabel("Find in Script...")
+	findConsoleItem, _ := gtk.MenuItemNewWithLabel("Find in Console...")
+	goToSymbolItem, _ := gtk.MenuItemNewWithLabel("Go to Symbol...")
+	completeItem, _ := gtk.MenuItemNewWithLabel("Complete Word                    Ctrl+Space")
 	clearItem, _ := gtk.MenuItemNewWithLabel("Clear Console")
 
 	editMenu.Append(undoItem)
@@ -91,6 +94,9 @@ func createMenuBar(win *gtk.Window) *gtk.MenuBar {
 	sep3, _ := gtk.SeparatorMenuItemNew()
 	editMenu.Append(sep3)
 	editMenu.Append(findItem)
+	editMenu.Append(findConsoleItem)
+	editMenu.Append(goToSymbolItem)
+	editMenu.Append(completeItem)
 	sep3b, _ := gtk.SeparatorMenuItemNew()
 	editMenu.Append(sep3b)
 	editMenu.Append(clearItem)
@@ -101,6 +107,9 @@ func createMenuBar(win *gtk.Window) *gtk.MenuBar {
 	copyItem.Connect("activate", func() { copyText() })
 	pasteItem.Connect("activate", func() { pasteText() })
 	findItem.Connect("activate", func() { showFindDialog() })
+	findConsoleItem.Connect("activate", func() { showConsoleSearch() })
+	goToSymbolItem.Connect("activate", func() { showGoToSymbolDialog() })
+	completeItem.Connect("activate", func() { triggerCompletion() })
 	clearItem.Connect("activate", func() { clearConsole() })
 
 	// View Menu
@@ -117,10 +126,35 @@ func createMenuBar(win *gtk.Window) *gtk.MenuBar {
 	sep5, _ := gtk.SeparatorMenuItemNew()
 	viewMenu.Append(sep5)
 	viewMenu.Append(showCommandAddonItem)
+	showToolPanelItem, _ := gtk.CheckMenuItemNewWithLabel("Show Tool Pane")
+	showToolPanelMenuItem = showToolPanelItem // Store global reference
+	viewMenu.Append(showToolPanelItem)
+	problemsItem, _ := gtk.MenuItemNewWithLabel("Show Problems")
+	viewMenu.Append(problemsItem)
+	problemsItem.Connect("activate", func() { showProblems() })
+	outlineItem, _ := gtk.MenuItemNewWithLabel("Show Outline")
+	viewMenu.Append(outlineItem)
+	outlineItem.Connect("activate", func() { showOutline() })
+	foldSep, _ := gtk.SeparatorMenuItemNew()
+	viewMenu.Append(foldSep)
+	toggleFoldItem, _ := gtk.MenuItemNewWithLabel("Toggle Fold                    Ctrl+M")
+	viewMenu.Append(toggleFoldItem)
+	toggleFoldItem.Connect("activate", func() { toggleFoldAtCursor() })
+	foldAllItem, _ := gtk.MenuItemNewWithLabel("Fold All                    Ctrl+Shift+M")
+	viewMenu.Append(foldAllItem)
+	foldAllItem.Connect("activate", func() { foldAll() })
+	unfoldAllItem, _ := gtk.MenuItemNewWithLabel("Unfold All                    Ctrl+Alt+M")
+	viewMenu.Append(unfoldAllItem)
+	unfoldAllItem.Connect("activate", func() { unfoldAll() })
 
 	showCommandAddonItem.Connect("toggled", func() {
 		toggleCommandAddOn()
 	})
+	showToolPanelItem.Connect("toggled", func() {
+		if !updatingToolPanelMenu {
+			setToolPanelVisible(showToolPanelItem.GetActive())
+		}
+	})
 
 	// Tools Menu
 	toolsMenu, _ := gtk.MenuNew()
@@ -135,10 +169,17 @@ func createMenuBar(win *gtk.Window) *gtk.MenuBar {
 	})
 	toolsMenu.Append(debugLoggingItem)
 
+	// PowerShell executable selection
+	toolsMenu.Append(createPowerShellVersionMenuItem())
+
+	// Session transcripts
+	appendTranscriptMenuItems(toolsMenu)
+
 	sep4, _ := gtk.SeparatorMenuItemNew()
 	toolsMenu.Append(sep4)
 
 	optionsItem, _ := gtk.MenuItemNewWithLabel("Options...")
+	optionsItem.Connect("activate", func() { showOptionsDialog() })
 	toolsMenu.Append(optionsItem)
 
 	// Debug Menu
@@ -148,14 +189,62 @@ func createMenuBar(win *gtk.Window) *gtk.MenuBar {
 
 	runItem, _ := gtk.MenuItemNewWithLabel("Run/Continue (F5)")
 	runSelectionItem, _ := gtk.MenuItemNewWithLabel("Run Selection (F8)")
-	stopItem, _ := gtk.MenuItemNewWithLabel("Stop Debugger")
+	stepOverItem, _ := gtk.MenuItemNewWithLabel("Step Over (F10)")
+	stepIntoItem, _ := gtk.MenuItemNewWithLabel("Step Into (F11)")
+	stepOutItem, _ := gtk.MenuItemNewWithLabel("Step Out (Shift+F11)")
+	runToCursorItem, _ := gtk.MenuItemNewWithLabel("Run to Cursor (Ctrl+F10)")
+	stopItem, _ := gtk.MenuItemNewWithLabel("Stop Debugger (Shift+F5)")
+	toggleBreakpointItem, _ := gtk.MenuItemNewWithLabel("Toggle Breakpoint (F9)")
+	variableBreakpointItem, _ := gtk.MenuItemNewWithLabel("Set Variable Breakpoint...")
+	commandBreakpointItem, _ := gtk.MenuItemNewWithLabel("Set Command Breakpoint...")
+	removeBreakpointsItem, _ := gtk.MenuItemNewWithLabel("Remove All Breakpoints (Ctrl+Shift+F9)")
+	listBreakpointsItem, _ := gtk.MenuItemNewWithLabel("List Breakpoints (Ctrl+Shift+L)")
+	callStackItem, _ := gtk.MenuItemNewWithLabel("Display Call Stack (Ctrl+Shift+D)")
+	addWatchItem, _ := gtk.MenuItemNewWithLabel("Add Watch")
+	watchPaneItem, _ := gtk.MenuItemNewWithLabel("Display Watch Pane")
+	runConfigsItem, _ := gtk.MenuItemNewWithLabel("Run Configurations...")
 	debugMenu.Append(runItem)
 	debugMenu.Append(runSelectionItem)
+	debugMenu.Append(stepOverItem)
+	debugMenu.Append(stepIntoItem)
+	debugMenu.Append(stepOutItem)
+	debugMenu.Append(runToCursorItem)
 	debugMenu.Append(stopItem)
+	debugSep1, _ := gtk.SeparatorMenuItemNew()
+	debugMenu.Append(debugSep1)
+	debugMenu.Append(toggleBreakpointItem)
+	debugMenu.Append(variableBreakpointItem)
+	debugMenu.Append(commandBreakpointItem)
+	debugMenu.Append(removeBreakpointsItem)
+	debugMenu.Append(listBreakpointsItem)
+	debugMenu.Append(callStackItem)
+	debugMenu.Append(addWatchItem)
+	debugMenu.Append(watchPaneItem)
+	debugSep, _ := gtk.SeparatorMenuItemNew()
+	debugMenu.Append(debugSep)
+	debugMenu.Append(runConfigsItem)
 
 	runItem.Connect("activate", func() { runScript() })
 	runSelectionItem.Connect("activate", func() { runSelection() })
-	stopItem.Connect("activate", func() { stopExecution() })
+	stepOverItem.Connect("activate", func() { debugStep(translation.DebugStepOver) })
+	stepIntoItem.Connect("activate", func() { debugStep(translation.DebugStepInto) })
+	stepOutItem.Connect("activate", func() { debugStep(translation.DebugStepOut) })
+	runToCursorItem.Connect("activate", func() { runToCursor() })
+	stopItem.Connect("activate", func() { stopDebugger() })
+	toggleBreakpointItem.Connect("activate", func() { toggleBreakpointAtCursor() })
+	variableBreakpointItem.Connect("activate", func() { showVariableBreakpointDialog() })
+	commandBreakpointItem.Connect("activate", func() { showCommandBreakpointDialog() })
+	removeBreakpointsItem.Connect("activate", func() { removeAllBreakpoints() })
+	listBreakpointsItem.Connect("activate", func() { showBreakpointsDialog() })
+	callStackItem.Connect("activate", func() { showCallStack() })
+	addWatchItem.Connect("activate", func() { addWatchAtCursor() })
+	watchPaneItem.Connect("activate", func() { showWatchPane() })
+	runConfigsItem.Connect("activate", func() {
+		if tab := getCurrentTab(); tab != nil {
+			showRunConfigurationsDialog(tab)
+			refreshRunConfigCombo()
+		}
+	})
 
 	// Add-ons Menu
 	addonsMenu, _ := gtk.MenuNew()
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+	"github.com/laurie/ps-ide-go/internal/psanalyzer"
+)
+
+// showOptionsDialog shows the Tools > Options dialog
+func showOptionsDialog() {
+	dialog, _ := gtk.DialogNew()
+	dialog.SetTitle("Options")
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetModal(true)
+	dialog.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	dialog.AddButton("OK", gtk.RESPONSE_OK)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(6)
+	grid.SetColumnSpacing(8)
+	grid.SetMarginStart(10)
+	grid.SetMarginEnd(10)
+	grid.SetMarginTop(10)
+	grid.SetMarginBottom(10)
+
+	scrollbackLabel, _ := gtk.LabelNew("Console scrollback (lines):")
+	scrollbackLabel.SetHAlign(gtk.ALIGN_END)
+	scrollbackSpin, _ := gtk.SpinButtonNewWithRange(minConsoleScrollback, maxConsoleScrollback, 1000)
+	scrollbackSpin.SetValue(float64(consoleScrollback))
+	scrollbackSpin.SetActivatesDefault(true)
+	scrollbackSpin.SetTooltipText("Older console lines are removed beyond this limit")
+
+	autoTranscriptCheck, _ := gtk.CheckButtonNewWithLabel("Start a transcript for every session")
+	autoTranscriptCheck.SetActive(autoTranscript)
+	autoTranscriptCheck.SetTooltipText("Transcripts are saved in " + translation.DefaultTranscriptDir())
+
+	formatLabel, _ := gtk.LabelNew("Transcript format:")
+	formatLabel.SetHAlign(gtk.ALIGN_END)
+	formatCombo, _ := gtk.ComboBoxTextNew()
+	formatCombo.Append(transcriptFormatText, "Text (Start-Transcript)")
+	formatCombo.Append(transcriptFormatJSONL, "JSON Lines")
+	formatCombo.SetActiveID(autoTranscriptFormat)
+
+	captureObjectsCheck, _ := gtk.CheckButtonNewWithLabel("Show an object inspector after command output")
+	captureObjectsCheck.SetActive(captureObjects)
+	captureObjectsCheck.SetTooltipText("Keeps the output objects of the last 20 commands, up to 1000 each, alive in the session " +
+		"until their inspector leaves the console, so large objects use memory for longer. " +
+		"Replaces Out-Default with a proxy function in each session, " +
+		"so Get-Command Out-Default shows a function instead of the cmdlet")
+
+	grid.Attach(scrollbackLabel, 0, 0, 1, 1)
+	grid.Attach(scrollbackSpin, 1, 0, 1, 1)
+	grid.Attach(autoTranscriptCheck, 0, 1, 2, 1)
+	grid.Attach(formatLabel, 0, 2, 1, 1)
+	grid.Attach(formatCombo, 1, 2, 1, 1)
+	grid.Attach(captureObjectsCheck, 0, 3, 2, 1)
+
+	// Script analyzer rules
+	rulesLabel, _ := gtk.LabelNew("")
+	rulesLabel.SetMarkup("<b>Script analyzer rules</b>")
+	rulesLabel.SetHAlign(gtk.ALIGN_START)
+	rulesLabel.SetMarginTop(8)
+	grid.Attach(rulesLabel, 0, 4, 2, 1)
+	ruleChecks := make(map[string]*gtk.CheckButton)
+	for i, rule := range psanalyzer.Rules {
+		check, _ := gtk.CheckButtonNewWithLabel(rule.Name)
+		check.SetActive(appConfig == nil || !containsString(appConfig.DisabledAnalyzerRules, rule.ID))
+		check.SetTooltipText(rule.Description + " (" + rule.ID + ")")
+		grid.Attach(check, 0, 5+i, 2, 1)
+		ruleChecks[rule.ID] = check
+	}
+
+	contentArea, _ := dialog.GetContentArea()
+	contentArea.PackStart(grid, true, true, 0)
+	dialog.ShowAll()
+
+	if dialog.Run() == gtk.RESPONSE_OK {
+		setConsoleScrollback(scrollbackSpin.GetValueAsInt())
+		autoTranscript = autoTranscriptCheck.GetActive()
+		autoTranscriptFormat = formatCombo.GetActiveID()
+		setObjectCapture(captureObjectsCheck.GetActive())
+
+		if appConfig != nil {
+			var disabled []string
+			for _, rule := range psanalyzer.Rules {
+				if !ruleChecks[rule.ID].GetActive() {
+					disabled = append(disabled, rule.ID)
+				}
+			}
+			appConfig.DisabledAnalyzerRules = disabled
+			saveAppConfig()
+			configureScriptAnalyzer()
+		}
+	}
+	dialog.Destroy()
+}
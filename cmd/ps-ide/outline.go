@@ -0,0 +1,512 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/internal/psanalyzer"
+)
+
+const outlineDelay = 400 // Milliseconds after the last edit before the outline is rebuilt
+
+// OutlineEntry is a symbol of the current tab. Positions are 0-based,
+// columns in characters.
+type OutlineEntry struct {
+	Kind      psanalyzer.SymbolKind
+	Name      string
+	Detail    string
+	Container string // Names of the enclosing symbols, e.g. "Setup > Foo"
+	Line      int
+	Column    int
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	path      string // Tree path of the row in the Outline pane
+}
+
+var (
+	outlinePane    *gtk.Box
+	outlineStore   *gtk.TreeStore
+	outlineView    *gtk.TreeView
+	outlineEntries []OutlineEntry // In tree order, parents before children
+	outlineTab     *ScriptTab
+	outlineShape   string // Kinds and names of the entries, to tell edits that move symbols from ones that change them
+	outlineVersion int
+	syncingOutline bool // Set while the selection follows the cursor
+)
+
+// Outline pane columns
+const (
+	outlineColumnIcon = iota
+	outlineColumnName
+	outlineColumnDetail
+	outlineColumnKind
+	outlineColumnIndex // Index into outlineEntries
+)
+
+var outlineIcons = map[psanalyzer.SymbolKind]string{
+	psanalyzer.SymbolFunction:      "system-run",
+	psanalyzer.SymbolFilter:        "system-run",
+	psanalyzer.SymbolWorkflow:      "system-run",
+	psanalyzer.SymbolConfiguration: "preferences-system",
+	psanalyzer.SymbolClass:         "package-x-generic",
+	psanalyzer.SymbolConstructor:   "list-add",
+	psanalyzer.SymbolMethod:        "system-run",
+	psanalyzer.SymbolProperty:      "document-properties",
+	psanalyzer.SymbolEnum:          "view-list",
+	psanalyzer.SymbolEnumValue:     "media-record",
+	psanalyzer.SymbolRegion:        "folder",
+	psanalyzer.SymbolParamBlock:    "document-properties",
+}
+
+// createOutlinePane creates the Outline tool panel page
+func createOutlinePane() *gtk.Box {
+	outlineStore, _ = gtk.TreeStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_STRING, glib.TYPE_INT)
+
+	outlineView, _ = gtk.TreeViewNew()
+	outlineView.SetModel(outlineStore)
+	outlineView.SetHeadersVisible(false)
+	outlineView.SetEnableSearch(true)
+	outlineView.SetSearchColumn(outlineColumnName)
+	outlineView.SetTooltipColumn(outlineColumnKind)
+
+	column, _ := gtk.TreeViewColumnNew()
+	iconRenderer, _ := gtk.CellRendererPixbufNew()
+	column.PackStart(iconRenderer, false)
+	column.AddAttribute(iconRenderer, "icon-name", outlineColumnIcon)
+	nameRenderer, _ := gtk.CellRendererTextNew()
+	column.PackStart(nameRenderer, false)
+	column.AddAttribute(nameRenderer, "text", outlineColumnName)
+	detailRenderer, _ := gtk.CellRendererTextNew()
+	detailRenderer.Set("foreground", "#808080")
+	column.PackStart(detailRenderer, true)
+	column.AddAttribute(detailRenderer, "text", outlineColumnDetail)
+	outlineView.AppendColumn(column)
+
+	// Selecting a symbol shows it; activating it also moves the focus there
+	selection, _ := outlineView.GetSelection()
+	selection.Connect("changed", func() {
+		if entry, ok := selectedOutlineEntry(); ok && !syncingOutline {
+			showOutlineEntry(entry, false)
+		}
+	})
+	outlineView.Connect("row-activated", func() {
+		if entry, ok := selectedOutlineEntry(); ok {
+			showOutlineEntry(entry, true)
+		}
+	})
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scroll.Add(outlineView)
+
+	outlinePane, _ = gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	outlinePane.PackStart(scroll, true, true, 0)
+	return outlinePane
+}
+
+// scheduleOutline rebuilds the outline once edits to the current tab have
+// settled
+func scheduleOutline(tab *ScriptTab) {
+	if tab != getCurrentTab() {
+		return
+	}
+	outlineVersion++
+	version := outlineVersion
+	glib.TimeoutAdd(outlineDelay, func() bool {
+		if version == outlineVersion {
+			refreshOutline()
+		}
+		return false
+	})
+}
+
+// refreshOutline rebuilds the outline of the current tab in the background
+func refreshOutline() {
+	if outlineStore == nil {
+		return
+	}
+	outlineVersion++
+	version := outlineVersion
+	tab := getCurrentTab()
+	if tab == nil || !isScriptTab(tab) {
+		setOutline(tab, nil)
+		return
+	}
+
+	start, end := tab.buffer.GetBounds()
+	text, _ := tab.buffer.GetText(start, end, true)
+	go func() {
+		entries := outlineEntriesOf(text)
+		glib.IdleAdd(func() bool {
+			if version == outlineVersion && tab == getCurrentTab() {
+				setOutline(tab, entries)
+			}
+			return false
+		})
+	}()
+}
+
+// outlineEntriesOf returns the symbols of a script in tree order. It is
+// safe to call off the main thread.
+func outlineEntriesOf(text string) []OutlineEntry {
+	lines := strings.Split(text, "\n")
+	column := func(line, offset int) int {
+		if line >= len(lines) || offset > len(lines[line]) {
+			return offset
+		}
+		return utf8.RuneCountInString(lines[line][:offset])
+	}
+
+	var entries []OutlineEntry
+	var add func(symbols []*psanalyzer.Symbol, container string)
+	add = func(symbols []*psanalyzer.Symbol, container string) {
+		for _, s := range symbols {
+			entries = append(entries, OutlineEntry{
+				Kind:      s.Kind,
+				Name:      s.Name,
+				Detail:    s.Detail,
+				Container: container,
+				Line:      s.Line,
+				Column:    column(s.Line, s.Column),
+				StartLine: s.StartLine,
+				StartCol:  column(s.StartLine, s.StartColumn),
+				EndLine:   s.EndLine,
+				EndCol:    column(s.EndLine, s.EndColumn),
+			})
+			inner := s.Name
+			if container != "" {
+				inner = container + " > " + s.Name
+			}
+			add(s.Children, inner)
+		}
+	}
+	add(psanalyzer.Outline(text), "")
+	return entries
+}
+
+// setOutline shows the symbols of a tab. If only their positions changed,
+// the rows, their selection and what is expanded are kept.
+func setOutline(tab *ScriptTab, entries []OutlineEntry) {
+	var shape strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&shape, "%d %s %s %s\n", e.Kind, e.Container, e.Name, e.Detail)
+	}
+	if tab == outlineTab && shape.String() == outlineShape && len(entries) == len(outlineEntries) {
+		for i := range entries {
+			entries[i].path = outlineEntries[i].path
+		}
+		outlineEntries = entries
+		syncOutlineToCursor(tab)
+		return
+	}
+
+	// Remember the collapsed rows, by name, when the tab is the same
+	collapsed := make(map[string]bool)
+	if tab == outlineTab {
+		for _, e := range outlineEntries {
+			if path, err := gtk.TreePathNewFromString(e.path); err == nil && !outlineView.RowExpanded(path) {
+				collapsed[e.Container+" > "+e.Name] = true
+			}
+		}
+	}
+
+	syncingOutline = true
+	defer func() { syncingOutline = false }()
+
+	outlineTab = tab
+	outlineShape = shape.String()
+	outlineEntries = entries
+	outlineStore.Clear()
+
+	// Entries are in tree order, so the parent of an entry is the last
+	// entry before it whose container is one level up
+	parents := make(map[string]*gtk.TreeIter)
+	for i := range outlineEntries {
+		e := &outlineEntries[i]
+		iter := outlineStore.Append(parents[e.Container])
+		outlineStore.SetValue(iter, outlineColumnIcon, outlineIcons[e.Kind])
+		outlineStore.SetValue(iter, outlineColumnName, e.Name)
+		outlineStore.SetValue(iter, outlineColumnDetail, e.Detail)
+		outlineStore.SetValue(iter, outlineColumnKind, e.Kind.String())
+		outlineStore.SetValue(iter, outlineColumnIndex, i)
+		if path, err := outlineStore.GetPath(iter); err == nil {
+			e.path = path.String()
+		}
+		inner := e.Name
+		if e.Container != "" {
+			inner = e.Container + " > " + e.Name
+		}
+		parents[inner] = iter
+	}
+
+	outlineView.ExpandAll()
+	for _, e := range outlineEntries {
+		if collapsed[e.Container+" > "+e.Name] {
+			if path, err := gtk.TreePathNewFromString(e.path); err == nil {
+				outlineView.CollapseRow(path)
+			}
+		}
+	}
+	syncOutlineToCursor(tab)
+}
+
+// syncOutlineToCursor selects the innermost symbol around the cursor
+func syncOutlineToCursor(tab *ScriptTab) {
+	if outlineView == nil || tab == nil || tab != outlineTab {
+		return
+	}
+	iter := tab.buffer.GetIterAtMark(tab.buffer.GetInsert())
+	line, col := iter.GetLine(), iter.GetLineOffset()
+
+	found := -1
+	for i, e := range outlineEntries {
+		after := line > e.StartLine || line == e.StartLine && col >= e.StartCol
+		before := line < e.EndLine || line == e.EndLine && col <= e.EndCol
+		if after && before {
+			found = i // Children follow their parents, so the last match is innermost
+		}
+	}
+
+	syncingOutline = true
+	defer func() { syncingOutline = false }()
+	selection, _ := outlineView.GetSelection()
+	if found < 0 {
+		selection.UnselectAll()
+		return
+	}
+	path, err := gtk.TreePathNewFromString(outlineEntries[found].path)
+	if err != nil {
+		return
+	}
+	outlineView.ExpandToPath(path)
+	selection.SelectPath(path)
+	outlineView.ScrollToCell(path, nil, false, 0, 0)
+}
+
+// selectedOutlineEntry returns the symbol of the selected row
+func selectedOutlineEntry() (OutlineEntry, bool) {
+	selection, _ := outlineView.GetSelection()
+	model, iter, ok := selection.GetSelected()
+	if !ok {
+		return OutlineEntry{}, false
+	}
+	value, _ := model.(*gtk.TreeModel).GetValue(iter, outlineColumnIndex)
+	goValue, _ := value.GoValue()
+	index, _ := goValue.(int)
+	if index < 0 || index >= len(outlineEntries) {
+		return OutlineEntry{}, false
+	}
+	return outlineEntries[index], true
+}
+
+// showOutlineEntry moves the cursor to a symbol's name in the outline's tab
+func showOutlineEntry(entry OutlineEntry, focus bool) {
+	tab := outlineTab
+	if tab == nil || !isTabOpen(tab) {
+		return
+	}
+	if focus {
+		goToLine(tab, entry.Line+1, entry.Column+1)
+		return
+	}
+	revealLines(tab, entry.Line, entry.Line)
+	iter := tab.buffer.GetIterAtLine(entry.Line)
+	lineEnd := tab.buffer.GetIterAtLine(entry.Line)
+	if !lineEnd.EndsLine() {
+		lineEnd.ForwardToLineEnd()
+	}
+	if entry.Column <= lineEnd.GetLineOffset() {
+		iter.SetLineOffset(entry.Column)
+	}
+	tab.buffer.PlaceCursor(iter)
+	tab.textView.ScrollToIter(iter, 0.1, true, 0.0, 0.3)
+}
+
+func showOutline() {
+	showToolPanelPage(outlinePane)
+}
+
+// fuzzyScore scores how well a query matches a name: its characters must
+// appear in order. Matches at word starts and runs of matches score
+// higher. It returns -1 if the name does not match.
+func fuzzyScore(query, name string) int {
+	if query == "" {
+		return 0
+	}
+	q := []rune(strings.ToLower(query))
+	runes := []rune(name)
+	score, k, run := 0, 0, 0
+	for i, r := range runes {
+		if k == len(q) {
+			break
+		}
+		if unicode.ToLower(r) != q[k] {
+			run = 0
+			continue
+		}
+		points := 1
+		if i == 0 {
+			points += 8
+		} else if prev := runes[i-1]; !unicode.IsLetter(prev) && !unicode.IsDigit(prev) ||
+			unicode.IsUpper(r) && unicode.IsLower(prev) {
+			points += 5 // Start of a word, as in Get-ChildItem or $userName
+		}
+		run++
+		points += 2 * (run - 1)
+		score += points
+		k++
+	}
+	if k < len(q) {
+		return -1
+	}
+	return score*4 - len(runes) // Prefer shorter names among equal matches
+}
+
+// showGoToSymbolDialog lists the symbols of the current tab, filtered as
+// the user types, and moves the cursor to the chosen one
+func showGoToSymbolDialog() {
+	tab := getCurrentTab()
+	if tab == nil || !isScriptTab(tab) {
+		return
+	}
+	start, end := tab.buffer.GetBounds()
+	text, _ := tab.buffer.GetText(start, end, true)
+	entries := outlineEntriesOf(text)
+	if len(entries) == 0 {
+		statusLabel.SetText("No symbols in this script")
+		return
+	}
+
+	dialog, _ := gtk.DialogNew()
+	dialog.SetTitle("Go to Symbol")
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(500, 400)
+
+	store, _ := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_INT)
+	view, _ := gtk.TreeViewNew()
+	view.SetModel(store)
+	view.SetHeadersVisible(false)
+	view.SetEnableSearch(false)
+
+	column, _ := gtk.TreeViewColumnNew()
+	iconRenderer, _ := gtk.CellRendererPixbufNew()
+	column.PackStart(iconRenderer, false)
+	column.AddAttribute(iconRenderer, "icon-name", 0)
+	nameRenderer, _ := gtk.CellRendererTextNew()
+	column.PackStart(nameRenderer, false)
+	column.AddAttribute(nameRenderer, "text", 1)
+	detailRenderer, _ := gtk.CellRendererTextNew()
+	detailRenderer.Set("foreground", "#808080")
+	column.PackStart(detailRenderer, true)
+	column.AddAttribute(detailRenderer, "text", 2)
+	view.AppendColumn(column)
+	containerRenderer, _ := gtk.CellRendererTextNew()
+	containerRenderer.Set("foreground", "#808080")
+	containerColumn, _ := gtk.TreeViewColumnNewWithAttribute("", containerRenderer, "text", 3)
+	view.AppendColumn(containerColumn)
+
+	selection, _ := view.GetSelection()
+	selectRow := func(row int) {
+		if path, err := gtk.TreePathNewFromString(fmt.Sprint(row)); err == nil {
+			selection.SelectPath(path)
+			view.ScrollToCell(path, nil, false, 0, 0)
+		}
+	}
+
+	var shown []int // Entry indexes of the rows
+	filter := func(query string) {
+		type match struct{ index, score int }
+		var matches []match
+		for i, e := range entries {
+			if score := fuzzyScore(query, e.Name); score >= 0 {
+				matches = append(matches, match{i, score})
+			}
+		}
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+		store.Clear()
+		shown = shown[:0]
+		for _, m := range matches {
+			e := entries[m.index]
+			iter := store.Append()
+			store.Set(iter, []int{0, 1, 2, 3, 4},
+				[]interface{}{outlineIcons[e.Kind], e.Name, e.Detail, e.Container, m.index})
+			shown = append(shown, m.index)
+		}
+		if len(shown) > 0 {
+			selectRow(0)
+		}
+	}
+
+	entry, _ := gtk.SearchEntryNew()
+	entry.SetPlaceholderText("Symbol name")
+	entry.SetActivatesDefault(true)
+	entry.Connect("search-changed", func() {
+		query, _ := entry.GetText()
+		filter(strings.TrimSpace(query))
+	})
+
+	// Up and Down move through the list without leaving the entry
+	entry.Connect("key-press-event", func(_ *gtk.SearchEntry, event *gdk.Event) bool {
+		keyval := gdk.EventKeyNewFromEvent(event).KeyVal()
+		if keyval != gdk.KEY_Up && keyval != gdk.KEY_Down || len(shown) == 0 {
+			return false
+		}
+		row := 0
+		if _, iter, ok := selection.GetSelected(); ok {
+			if path, err := store.GetPath(iter); err == nil {
+				row = path.GetIndices()[0]
+				if keyval == gdk.KEY_Up && row > 0 {
+					row--
+				} else if keyval == gdk.KEY_Down && row < len(shown)-1 {
+					row++
+				}
+			}
+		}
+		selectRow(row)
+		return true
+	})
+
+	view.Connect("row-activated", func() {
+		dialog.Response(gtk.RESPONSE_OK)
+	})
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scroll.Add(view)
+
+	contentBox, _ := dialog.GetContentArea()
+	contentBox.SetSpacing(6)
+	contentBox.PackStart(entry, false, false, 0)
+	contentBox.PackStart(scroll, true, true, 0)
+
+	dialog.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	dialog.AddButton("Go To", gtk.RESPONSE_OK)
+	dialog.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	filter("")
+	dialog.ShowAll()
+	entry.GrabFocus()
+	response := dialog.Run()
+
+	if response == gtk.RESPONSE_OK {
+		if _, iter, ok := selection.GetSelected(); ok {
+			value, _ := store.GetValue(iter, 4)
+			goValue, _ := value.GoValue()
+			if index, ok := goValue.(int); ok && index >= 0 && index < len(entries) {
+				goToLine(tab, entries[index].Line+1, entries[index].Column+1)
+			}
+		}
+	}
+	dialog.Destroy()
+}
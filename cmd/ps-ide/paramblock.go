@@ -0,0 +1,301 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ScriptParameter describes one parameter declared in a script's param() block
+type ScriptParameter struct {
+	Name      string
+	Type      string // e.g. "string", "int", "switch"; empty if untyped
+	Default   string // Default value expression as written, if any
+	Mandatory bool
+	HelpText  string // HelpMessage from [Parameter()], if any
+}
+
+// IsSwitch reports whether the parameter is a [switch]
+func (p ScriptParameter) IsSwitch() bool {
+	return strings.EqualFold(p.Type, "switch") ||
+		strings.EqualFold(p.Type, "System.Management.Automation.SwitchParameter")
+}
+
+var (
+	paramVariableRegex  = regexp.MustCompile(`\$([A-Za-z_][\w]*)`)
+	paramMandatoryRegex = regexp.MustCompile(`(?i)\bMandatory\b\s*(=\s*\$(true|false))?`)
+	paramHelpRegex      = regexp.MustCompile(`(?i)\bHelpMessage\s*=\s*(?:'([^']*)'|"([^"]*)")`)
+)
+
+// parseScriptParameters extracts the parameters of the script-level param()
+// block. It returns nil if the script has no param() block.
+func parseScriptParameters(script string) []ScriptParameter {
+	body, ok := findScriptParamBlock(script)
+	if !ok {
+		return nil
+	}
+
+	var params []ScriptParameter
+	for _, decl := range splitTopLevel(body, ',') {
+		if p, ok := parseParameterDecl(decl); ok {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// findScriptParamBlock returns the text between the parentheses of the
+// script's param() block. Only comments (including #requires), using
+// statements and attributes such as [CmdletBinding()] may come before it;
+// a param( after the first statement is not the script's.
+func findScriptParamBlock(script string) (string, bool) {
+	runes := []rune(script)
+
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r) || r == ';' || r == '\uFEFF':
+		case r == '#':
+			i = skipComment(runes, i)
+		case r == '<' && i+1 < len(runes) && runes[i+1] == '#':
+			i = skipComment(runes, i)
+		case r == '[':
+			if i = matchingParen(runes, i); i < 0 {
+				return "", false
+			}
+		case hasKeyword(runes, i, "using"):
+			for i < len(runes) && runes[i] != '\n' && runes[i] != ';' {
+				i++
+			}
+		case hasKeyword(runes, i, "param"):
+			j := i + 5
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+			if j >= len(runes) || runes[j] != '(' {
+				return "", false
+			}
+			end := matchingParen(runes, j)
+			if end < 0 {
+				return "", false
+			}
+			return string(runes[j+1 : end]), true
+		default:
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+// hasKeyword reports whether the word at i is keyword, ignoring case
+func hasKeyword(runes []rune, i int, keyword string) bool {
+	end := i + len(keyword)
+	if end > len(runes) || !strings.EqualFold(string(runes[i:end]), keyword) {
+		return false
+	}
+	return end == len(runes) || !isIdentifierRune(runes[end])
+}
+
+// skipComment returns the index of the last rune of the comment starting at i
+func skipComment(runes []rune, i int) int {
+	if runes[i] == '<' {
+		for j := i + 2; j+1 < len(runes); j++ {
+			if runes[j] == '#' && runes[j+1] == '>' {
+				return j + 1
+			}
+		}
+		return len(runes) - 1
+	}
+	for j := i; j < len(runes); j++ {
+		if runes[j] == '\n' {
+			return j
+		}
+	}
+	return len(runes) - 1
+}
+
+// skipString returns the index of the closing quote of the string starting at i
+func skipString(runes []rune, i int) int {
+	quote := runes[i]
+	for j := i + 1; j < len(runes); j++ {
+		switch {
+		case quote == '"' && runes[j] == '`':
+			j++
+		case runes[j] == quote:
+			// Doubled quotes are an escaped quote
+			if j+1 < len(runes) && runes[j+1] == quote {
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return len(runes) - 1
+}
+
+// matchingParen returns the index of the ')' matching the '(' at open
+func matchingParen(runes []rune, open int) int {
+	depth := 0
+	for i := open; i < len(runes); i++ {
+		switch runes[i] {
+		case '#':
+			i = skipComment(runes, i)
+		case '<':
+			if i+1 < len(runes) && runes[i+1] == '#' {
+				i = skipComment(runes, i)
+			}
+		case '\'', '"':
+			i = skipString(runes, i)
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits s on sep, ignoring separators nested in brackets,
+// strings or comments
+func splitTopLevel(s string, sep rune) []string {
+	runes := []rune(s)
+	var parts []string
+	depth, start := 0, 0
+
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '#':
+			i = skipComment(runes, i)
+		case '<':
+			if i+1 < len(runes) && runes[i+1] == '#' {
+				i = skipComment(runes, i)
+			}
+		case '\'', '"':
+			i = skipString(runes, i)
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		default:
+			if r == sep && depth == 0 {
+				parts = append(parts, string(runes[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, string(runes[start:]))
+	return parts
+}
+
+// parseParameterDecl parses e.g. "[Parameter(Mandatory)][string]$Name = 'x'"
+func parseParameterDecl(decl string) (ScriptParameter, bool) {
+	var p ScriptParameter
+	runes := []rune(strings.TrimSpace(decl))
+	i := 0
+
+	for i < len(runes) {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '#' || (runes[i] == '<' && i+1 < len(runes) && runes[i+1] == '#'):
+			i = skipComment(runes, i) + 1
+		case runes[i] == '[':
+			end := matchingParen(runes, i)
+			if end < 0 {
+				return p, false
+			}
+			attr := strings.TrimSpace(string(runes[i+1 : end]))
+			i = end + 1
+
+			if strings.Contains(attr, "(") {
+				// Attribute such as [Parameter(...)] or [ValidateSet(...)]
+				if strings.HasPrefix(strings.ToLower(attr), "parameter") {
+					if m := paramMandatoryRegex.FindStringSubmatch(attr); m != nil {
+						p.Mandatory = m[2] == "" || strings.EqualFold(m[2], "true")
+					}
+					if m := paramHelpRegex.FindStringSubmatch(attr); m != nil {
+						p.HelpText = m[1] + m[2]
+					}
+				}
+			} else {
+				p.Type = attr
+			}
+		case runes[i] == '$':
+			rest := string(runes[i:])
+			m := paramVariableRegex.FindStringSubmatchIndex(rest)
+			if m == nil || m[0] != 0 {
+				return p, false
+			}
+			p.Name = rest[m[2]:m[3]]
+			if eq := strings.Index(rest[m[1]:], "="); eq >= 0 {
+				p.Default = strings.TrimSpace(rest[m[1]+eq+1:])
+			}
+			return p, true
+		default:
+			return p, false
+		}
+	}
+
+	return p, false
+}
+
+// buildParameterArguments turns form values into PowerShell argument text.
+// Empty values are omitted; switches are passed when their value is "true".
+func buildParameterArguments(params []ScriptParameter, values map[string]string) string {
+	var args []string
+	for _, p := range params {
+		value, ok := values[p.Name]
+		if !ok || value == "" {
+			continue
+		}
+		if p.IsSwitch() {
+			if value == "true" {
+				args = append(args, "-"+p.Name)
+			}
+			continue
+		}
+		args = append(args, "-"+p.Name, formatArgumentValue(value))
+	}
+	return strings.Join(args, " ")
+}
+
+// formatArgumentValue quotes a form value unless it is already a PowerShell
+// expression (variable, number, array, sub-expression or quoted string)
+func formatArgumentValue(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return "''"
+	}
+	switch trimmed[0] {
+	case '$', '@', '(', '\'', '"', '[':
+		return trimmed
+	}
+	if isNumeric(trimmed) {
+		return trimmed
+	}
+	return "'" + strings.ReplaceAll(trimmed, "'", "''") + "'"
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isNumeric reports whether s is a plain number such as 42, -1 or 2.5
+func isNumeric(s string) bool {
+	digits, dots := 0, 0
+	for i, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case r == '.' && i > 0:
+			dots++
+		case r == '-' && i == 0:
+		default:
+			return false
+		}
+	}
+	return digits > 0 && dots <= 1
+}
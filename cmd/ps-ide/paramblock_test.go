@@ -0,0 +1,174 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseScriptParameters(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []ScriptParameter
+	}{
+		{
+			name:   "no param block",
+			script: "Write-Output 'hi'\n",
+			want:   nil,
+		},
+		{
+			name:   "untyped and typed",
+			script: "param($Path, [int]$Count)\n",
+			want: []ScriptParameter{
+				{Name: "Path"},
+				{Name: "Count", Type: "int"},
+			},
+		},
+		{
+			name: "attributes, defaults and switches",
+			script: `[CmdletBinding()]
+param(
+    [Parameter(Mandatory, HelpMessage = 'Where to look')]
+    [string]$Path,
+
+    [Parameter(Mandatory = $false)]
+    [ValidateSet('a', 'b')]
+    [string]$Mode = 'a',
+
+    [int[]]$Ids = @(1, 2),
+
+    [switch]$Force,
+
+    [System.Management.Automation.SwitchParameter]$WhatIfOnly
+)
+`,
+			want: []ScriptParameter{
+				{Name: "Path", Type: "string", Mandatory: true, HelpText: "Where to look"},
+				{Name: "Mode", Type: "string", Default: "'a'"},
+				{Name: "Ids", Type: "int[]", Default: "@(1, 2)"},
+				{Name: "Force", Type: "switch"},
+				{Name: "WhatIfOnly", Type: "System.Management.Automation.SwitchParameter"},
+			},
+		},
+		{
+			name: "comments, #requires and using come before the block",
+			script: `#requires -Version 7
+# param($NotThis)
+<# param($NorThis) #>
+using namespace System.IO
+[CmdletBinding()]
+param(
+    # The name, with a comma, in a comment
+    [string]$Name = "a, b",
+    $Last
+)
+`,
+			want: []ScriptParameter{
+				{Name: "Name", Type: "string", Default: `"a, b"`},
+				{Name: "Last"},
+			},
+		},
+		{
+			name: "param after a statement is not the script's",
+			script: `$text = "param($Quoted)"
+param($Late)
+`,
+			want: nil,
+		},
+		{
+			name: "function param blocks are not the script's",
+			script: `function Get-Thing {
+    param([string]$Inner)
+}
+param([string]$Outer)
+`,
+			want: nil,
+		},
+		{
+			name:   "parameter named like a keyword",
+			script: "param($Params, $using)\nWrite-Output $Params\n",
+			want: []ScriptParameter{
+				{Name: "Params"},
+				{Name: "using"},
+			},
+		},
+		{
+			name:   "Mandatory=$true and double-quoted help",
+			script: `param([Parameter(Mandatory=$true, HelpMessage="Id")][int]$Id)`,
+			want: []ScriptParameter{
+				{Name: "Id", Type: "int", Mandatory: true, HelpText: "Id"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseScriptParameters(tt.script)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseScriptParameters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSwitch(t *testing.T) {
+	for _, typ := range []string{"switch", "Switch", "System.Management.Automation.SwitchParameter"} {
+		if !(ScriptParameter{Type: typ}).IsSwitch() {
+			t.Errorf("IsSwitch() = false for %q", typ)
+		}
+	}
+	if (ScriptParameter{Type: "bool"}).IsSwitch() {
+		t.Errorf("IsSwitch() = true for bool")
+	}
+}
+
+func TestFormatArgumentValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", "''"},
+		{"  ", "''"},
+		{"42", "42"},
+		{"-1", "-1"},
+		{"2.5", "2.5"},
+		{"-", "'-'"},
+		{".5", "'.5'"},
+		{"1.2.3", "'1.2.3'"},
+		{"10-2", "'10-2'"},
+		{"hello", "'hello'"},
+		{"it's", "'it''s'"},
+		{"$env:HOME", "$env:HOME"},
+		{"@(1, 2)", "@(1, 2)"},
+		{"(Get-Date)", "(Get-Date)"},
+		{"'quoted'", "'quoted'"},
+		{`"double"`, `"double"`},
+		{"[int]::MaxValue", "[int]::MaxValue"},
+	}
+	for _, tt := range tests {
+		if got := formatArgumentValue(tt.value); got != tt.want {
+			t.Errorf("formatArgumentValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBuildParameterArguments(t *testing.T) {
+	params := []ScriptParameter{
+		{Name: "Path", Type: "string"},
+		{Name: "Count", Type: "int"},
+		{Name: "Force", Type: "switch"},
+		{Name: "Quiet", Type: "switch"},
+		{Name: "Unset"},
+	}
+	values := map[string]string{
+		"Path":  "C:\\My Files",
+		"Count": "3",
+		"Force": "true",
+		"Quiet": "false",
+		"Unset": "",
+	}
+	want := `-Path 'C:\My Files' -Count 3 -Force`
+	if got := buildParameterArguments(params, values); got != want {
+		t.Errorf("buildParameterArguments() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+	"github.com/laurie/ps-ide-go/pkg/config"
+)
+
+var (
+	appConfig *config.Config
+
+	// One translation layer per PowerShell executable, started on demand
+	// and keyed by its canonical path
+	translationLayers = make(map[string]*translation.TranslationLayer)
+
+	// Installed pwsh binaries, filled in the background at startup
+	powerShellInstalls     []translation.PowerShellInstall
+	powerShellInstallsMu   sync.Mutex
+	powerShellVersionMenu  *gtk.Menu
+	psVersionLabel         *gtk.Label
+	activePowerShellPath   string
+	activePowerShellPathMu sync.Mutex
+)
+
+// loadAppConfig reads the application configuration, falling back to defaults
+func loadAppConfig() {
+	cfg, err := config.Load(config.GetConfigPath())
+	if err != nil {
+		log.Printf("Warning: failed to load config: %v", err)
+		cfg = config.Default()
+	}
+	appConfig = cfg
+}
+
+// saveAppConfig writes the application configuration to disk
+func saveAppConfig() {
+	if appConfig == nil {
+		return
+	}
+	if err := appConfig.Save(config.GetConfigPath()); err != nil {
+		log.Printf("Warning: failed to save config: %v", err)
+	}
+}
+
+// globalPowerShellPath returns the executable chosen in Tools > PowerShell Version
+func globalPowerShellPath() string {
+	if appConfig == nil {
+		return translation.DefaultExecutable
+	}
+	return translation.ResolveExecutable(appConfig.PowerShellPath)
+}
+
+// tabPowerShellPath returns the executable used by a tab: its own override
+// if set, otherwise the global choice
+func tabPowerShellPath(tab *ScriptTab) string {
+	if tab != nil && tab.powerShellPath != "" {
+		return tab.powerShellPath
+	}
+	return globalPowerShellPath()
+}
+
+// powerShellPath returns the executable of the active session. It is safe
+// to call from background goroutines.
+func powerShellPath() string {
+	activePowerShellPathMu.Lock()
+	defer activePowerShellPathMu.Unlock()
+	if activePowerShellPath == "" {
+		return globalPowerShellPath()
+	}
+	return activePowerShellPath
+}
+
+// layerForExecutable returns the translation layer for an executable,
+// starting a new PowerShell process if needed. Different spellings of one
+// binary, such as pwsh and /usr/bin/pwsh, share a layer.
+func layerForExecutable(path string) (*translation.TranslationLayer, error) {
+	key := translation.CanonicalExecutable(path)
+	if tl, ok := translationLayers[key]; ok {
+		return tl, nil
+	}
+
+	tl, err := translation.NewWithExecutable(path)
+	if err != nil {
+		return nil, err
+	}
+	translationLayers[key] = tl
+	tl.SetObjectCapture(captureObjects)
+	tl.SetOutputHandler(func(text string) { streamOutput(tl, text) })
+	tl.SetDebugStopHandler(func() { onDebuggerStop(tl) })
+	if activeTranscript != nil {
+		tl.SetTranscript(activeTranscript)
+	}
+	return tl, nil
+}
+
+// activateTabPowerShell switches the console to the session used by the
+// current tab. Switching is deferred while a command is executing or the
+// debugger is stopped.
+func activateTabPowerShell() {
+	if isExecuting || (translationLayer != nil && (translationLayer.IsExecuting() || translationLayer.IsDebugStopped())) {
+		return
+	}
+
+	path := tabPowerShellPath(getCurrentTab())
+	if translationLayer != nil && translation.SameExecutable(translationLayer.GetExecutable(), path) {
+		return
+	}
+
+	tl, err := layerForExecutable(path)
+	if err != nil {
+		displayOutput(fmt.Sprintf("\nFailed to start %s: %v\n", path, err))
+		return
+	}
+
+	previous := translationLayer
+	translationLayer = tl
+	setActivePowerShellPath(path)
+	updatePSVersionLabel()
+	refreshPreferenceChecks()
+
+	// Only announce switches, not the initial session
+	if previous != nil && consoleTextBuffer != nil {
+		displayOutput(fmt.Sprintf("\nSwitched to %s\n", path))
+		glib.TimeoutAdd(500, func() bool {
+			displayPrompt()
+			return false
+		})
+	}
+}
+
+func setActivePowerShellPath(path string) {
+	activePowerShellPathMu.Lock()
+	activePowerShellPath = path
+	activePowerShellPathMu.Unlock()
+}
+
+// shutdownAllTranslationLayers stops every PowerShell process we started
+func shutdownAllTranslationLayers() {
+	for path, tl := range translationLayers {
+		tl.Shutdown()
+		delete(translationLayers, path)
+	}
+}
+
+// updatePSVersionLabel shows the active $PSVersionTable.PSVersion. The
+// version is queried asynchronously, so poll briefly until it arrives.
+func updatePSVersionLabel() {
+	if psVersionLabel == nil {
+		return
+	}
+	if translationLayer == nil {
+		psVersionLabel.SetText("PowerShell: not running")
+		return
+	}
+
+	tl := translationLayer
+	attempts := 0
+	setLabel := func() bool {
+		if tl != translationLayer {
+			return false
+		}
+		version := tl.GetPSVersion()
+		if version == "" {
+			psVersionLabel.SetText("PowerShell ...")
+		} else {
+			psVersionLabel.SetText("PowerShell " + version)
+		}
+		psVersionLabel.SetTooltipText(tl.GetExecutable())
+		attempts++
+		return version == "" && attempts < 20
+	}
+	if setLabel() {
+		glib.TimeoutAdd(250, setLabel)
+	}
+}
+
+// discoverPowerShellInstalls scans for pwsh binaries in the background and
+// rebuilds the version menu when done
+func discoverPowerShellInstalls() {
+	go func() {
+		installs := translation.DiscoverPowerShell()
+
+		powerShellInstallsMu.Lock()
+		powerShellInstalls = installs
+		powerShellInstallsMu.Unlock()
+
+		glib.IdleAdd(func() bool {
+			rebuildPowerShellVersionMenu()
+			return false
+		})
+	}()
+}
+
+func getPowerShellInstalls() []translation.PowerShellInstall {
+	powerShellInstallsMu.Lock()
+	defer powerShellInstallsMu.Unlock()
+	return append([]translation.PowerShellInstall(nil), powerShellInstalls...)
+}
+
+// createPowerShellVersionMenuItem builds Tools > PowerShell Version
+func createPowerShellVersionMenuItem() *gtk.MenuItem {
+	item, _ := gtk.MenuItemNewWithLabel("PowerShell Version")
+	powerShellVersionMenu, _ = gtk.MenuNew()
+	item.SetSubmenu(powerShellVersionMenu)
+	rebuildPowerShellVersionMenu()
+	return item
+}
+
+// rebuildPowerShellVersionMenu fills the global version menu from the
+// discovered installs
+func rebuildPowerShellVersionMenu() {
+	if powerShellVersionMenu == nil {
+		return
+	}
+
+	powerShellVersionMenu.GetChildren().Foreach(func(item interface{}) {
+		if w, ok := item.(gtk.IWidget); ok {
+			powerShellVersionMenu.Remove(w)
+		}
+	})
+
+	current := globalPowerShellPath()
+	appendPowerShellChoices(&powerShellVersionMenu.MenuShell, current, func(path string) {
+		setGlobalPowerShellPath(path)
+	})
+
+	sep, _ := gtk.SeparatorMenuItemNew()
+	powerShellVersionMenu.Append(sep)
+
+	browseItem, _ := gtk.MenuItemNewWithLabel("Browse...")
+	browseItem.Connect("activate", func() {
+		choosePowerShellExecutable(setGlobalPowerShellPath)
+	})
+	powerShellVersionMenu.Append(browseItem)
+
+	rescanItem, _ := gtk.MenuItemNewWithLabel("Rescan Installed Versions")
+	rescanItem.Connect("activate", func() {
+		discoverPowerShellInstalls()
+	})
+	powerShellVersionMenu.Append(rescanItem)
+
+	powerShellVersionMenu.ShowAll()
+}
+
+// appendPowerShellChoices adds one radio item per discovered install (plus
+// the current path if it was not discovered) and calls onSelect on
+// activation. The current path may be a bare name such as pwsh, so paths
+// are compared once resolved.
+func appendPowerShellChoices(menu *gtk.MenuShell, current string, onSelect func(path string)) {
+	installs := getPowerShellInstalls()
+
+	found := false
+	for _, install := range installs {
+		if translation.SameExecutable(install.Path, current) {
+			found = true
+		}
+	}
+	if !found {
+		installs = append([]translation.PowerShellInstall{{Path: current}}, installs...)
+	}
+
+	var group *glib.SList
+	for _, install := range installs {
+		path := install.Path
+		label := install.Label()
+		if install.Version == "" && install.Source == "" {
+			label = path
+		}
+
+		radio, _ := gtk.RadioMenuItemNewWithLabel(group, label)
+		group, _ = radio.GetGroup()
+		radio.SetActive(translation.SameExecutable(path, current))
+		radio.Connect("toggled", func() {
+			if radio.GetActive() {
+				onSelect(path)
+			}
+		})
+		menu.Append(radio)
+	}
+}
+
+// setGlobalPowerShellPath changes and persists the default executable
+func setGlobalPowerShellPath(path string) {
+	if appConfig == nil || appConfig.PowerShellPath == path {
+		return
+	}
+	appConfig.PowerShellPath = path
+	saveAppConfig()
+	statusLabel.SetText("PowerShell executable: " + path)
+	activateTabPowerShell()
+}
+
+// setTabPowerShellPath sets a per-tab override; an empty path uses the global choice
+func setTabPowerShellPath(tab *ScriptTab, path string) {
+	if tab == nil {
+		return
+	}
+	tab.powerShellPath = path
+	if tab == getCurrentTab() {
+		activateTabPowerShell()
+	}
+}
+
+// appendTabPowerShellMenu adds the per-tab PowerShell Version submenu
+func appendTabPowerShellMenu(menu *gtk.Menu, tab *ScriptTab) {
+	item, _ := gtk.MenuItemNewWithLabel("PowerShell Version")
+	submenu, _ := gtk.MenuNew()
+	item.SetSubmenu(submenu)
+
+	defaultItem, _ := gtk.CheckMenuItemNewWithLabel("Use Global Default (" + globalPowerShellPath() + ")")
+	defaultItem.SetActive(tab.powerShellPath == "")
+	defaultItem.Connect("activate", func() {
+		setTabPowerShellPath(tab, "")
+	})
+	submenu.Append(defaultItem)
+
+	sep, _ := gtk.SeparatorMenuItemNew()
+	submenu.Append(sep)
+
+	// Nothing is selected in the radio group while following the default
+	current := tab.powerShellPath
+	installs := getPowerShellInstalls()
+	for _, install := range installs {
+		path := install.Path
+		choice, _ := gtk.CheckMenuItemNewWithLabel(install.Label())
+		choice.SetDrawAsRadio(true)
+		choice.SetActive(current != "" && translation.SameExecutable(path, current))
+		choice.Connect("activate", func() {
+			setTabPowerShellPath(tab, path)
+		})
+		submenu.Append(choice)
+	}
+
+	browseItem, _ := gtk.MenuItemNewWithLabel("Browse...")
+	browseItem.Connect("activate", func() {
+		choosePowerShellExecutable(func(path string) {
+			setTabPowerShellPath(tab, path)
+		})
+	})
+	submenu.Append(browseItem)
+
+	menu.Append(item)
+}
+
+// choosePowerShellExecutable lets the user pick a pwsh binary from disk
+// and calls onChosen with it once it is known to work. Checking runs the
+// binary, which can take seconds, so it is done in the background.
+func choosePowerShellExecutable(onChosen func(path string)) {
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons(
+		"Select PowerShell Executable",
+		mainWindow,
+		gtk.FILE_CHOOSER_ACTION_OPEN,
+		"Cancel", gtk.RESPONSE_CANCEL,
+		"Select", gtk.RESPONSE_ACCEPT,
+	)
+	defer dialog.Destroy()
+
+	if dialog.Run() != gtk.RESPONSE_ACCEPT {
+		return
+	}
+
+	path := dialog.GetFilename()
+	checking := "Checking " + path + "..."
+	if !isBusy() {
+		statusLabel.SetText(checking)
+	}
+
+	go func() {
+		version := translation.QueryExecutableVersion(path)
+
+		glib.IdleAdd(func() bool {
+			if text, _ := statusLabel.GetText(); text == checking {
+				statusLabel.SetText("Ready")
+			}
+			if version == "" {
+				showErrorDialog(mainWindow, "Invalid PowerShell",
+					fmt.Sprintf("%s does not appear to be a working PowerShell executable.", path))
+				return false
+			}
+			onChosen(path)
+			return false
+		})
+	}()
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+	"github.com/laurie/ps-ide-go/internal/psanalyzer"
+)
+
+// Problem is a row of the Problems pane: a diagnostic of an open tab or an
+// error raised by the last script or selection run
+type Problem struct {
+	Severity DiagnosticSeverity
+	Tab      *ScriptTab // Nil for runtime errors in files that are not open
+	File     string     // Path or untitled tab name
+	Line     int        // 1-based, 0 if unknown
+	Column   int        // 1-based, 0 if unknown
+	Message  string
+	Source   string          // "Parser", "Runtime" or an analyzer rule ID
+	Fix      *psanalyzer.Fix // Quick fix, nil if none
+}
+
+var (
+	// Problems pane
+	problemsPane           *gtk.Box
+	problemsStore          *gtk.ListStore
+	problemsView           *gtk.TreeView
+	problemsFixButton      *gtk.Button
+	problemsErrorsToggle   *gtk.ToggleButton
+	problemsWarningsToggle *gtk.ToggleButton
+	problems               []Problem
+
+	// Status bar indicator
+	problemsStatusButton *gtk.Button
+	problemsErrorCount   *gtk.Label
+	problemsWarningCount *gtk.Label
+
+	// Errors raised by the last F5/F8 run, including debugger steps in it
+	runErrors []translation.ErrorRecordInfo
+)
+
+// Problems pane columns
+const (
+	problemsColumnIcon = iota
+	problemsColumnSeverity
+	problemsColumnFile
+	problemsColumnLine
+	problemsColumnColumn
+	problemsColumnMessage
+	problemsColumnSource
+	problemsColumnSeverityRank // Sort key of the severity column
+	problemsColumnIndex        // Index into problems
+)
+
+// createProblemsPane creates the Problems tool panel page
+func createProblemsPane() *gtk.Box {
+	problemsStore, _ = gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_INT,
+		glib.TYPE_INT, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_INT, glib.TYPE_INT)
+
+	problemsView, _ = gtk.TreeViewNew()
+	problemsView.SetModel(problemsStore)
+	problemsView.SetHeadersVisible(true)
+
+	// Severity with its icon
+	severityColumn, _ := gtk.TreeViewColumnNew()
+	severityColumn.SetTitle("Severity")
+	iconRenderer, _ := gtk.CellRendererPixbufNew()
+	severityColumn.PackStart(iconRenderer, false)
+	severityColumn.AddAttribute(iconRenderer, "icon-name", problemsColumnIcon)
+	severityRenderer, _ := gtk.CellRendererTextNew()
+	severityColumn.PackStart(severityRenderer, true)
+	severityColumn.AddAttribute(severityRenderer, "text", problemsColumnSeverity)
+	severityColumn.SetSortColumnID(problemsColumnSeverityRank)
+	severityColumn.SetResizable(true)
+	problemsView.AppendColumn(severityColumn)
+
+	for _, c := range []struct {
+		title  string
+		column int
+		expand bool
+	}{
+		{"File", problemsColumnFile, false},
+		{"Line", problemsColumnLine, false},
+		{"Col", problemsColumnColumn, false},
+		{"Message", problemsColumnMessage, true},
+		{"Source", problemsColumnSource, false},
+	} {
+		renderer, _ := gtk.CellRendererTextNew()
+		column, _ := gtk.TreeViewColumnNewWithAttribute(c.title, renderer, "text", c.column)
+		column.SetSortColumnID(c.column)
+		column.SetResizable(true)
+		column.SetExpand(c.expand)
+		problemsView.AppendColumn(column)
+	}
+
+	// Double-click to show the problem
+	problemsView.Connect("row-activated", func() {
+		if p, ok := selectedProblem(); ok {
+			showProblemLocation(p)
+		}
+	})
+
+	selection, _ := problemsView.GetSelection()
+	selection.Connect("changed", func() {
+		p, ok := selectedProblem()
+		problemsFixButton.SetSensitive(ok && p.Fix != nil)
+		if ok && p.Fix != nil {
+			problemsFixButton.SetTooltipText(p.Fix.Description)
+		} else {
+			problemsFixButton.SetTooltipText("The selected problem has no quick fix")
+		}
+	})
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scroll.Add(problemsView)
+
+	// Severity filters
+	problemsErrorsToggle, _ = gtk.ToggleButtonNewWithLabel("Errors")
+	problemsErrorsToggle.SetActive(true)
+	problemsErrorsToggle.SetTooltipText("Show parse and runtime errors")
+	problemsErrorsToggle.Connect("toggled", updateProblemsList)
+	problemsWarningsToggle, _ = gtk.ToggleButtonNewWithLabel("Warnings")
+	problemsWarningsToggle.SetActive(true)
+	problemsWarningsToggle.SetTooltipText("Show script analyzer warnings")
+	problemsWarningsToggle.Connect("toggled", updateProblemsList)
+
+	problemsFixButton, _ = gtk.ButtonNewWithLabel("Quick Fix")
+	problemsFixButton.SetSensitive(false)
+	problemsFixButton.Connect("clicked", func() {
+		if p, ok := selectedProblem(); ok && p.Tab != nil && isTabOpen(p.Tab) {
+			if !applyQuickFix(p.Tab, p.Fix) {
+				statusLabel.SetText("The quick fix no longer applies; the script has changed")
+			}
+		}
+	})
+
+	buttonBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 4)
+	buttonBox.SetMarginStart(4)
+	buttonBox.SetMarginEnd(4)
+	buttonBox.SetMarginTop(4)
+	buttonBox.SetMarginBottom(4)
+	buttonBox.PackStart(problemsErrorsToggle, false, false, 0)
+	buttonBox.PackStart(problemsWarningsToggle, false, false, 0)
+	buttonBox.PackEnd(problemsFixButton, false, false, 0)
+
+	problemsPane, _ = gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	problemsPane.PackStart(buttonBox, false, false, 0)
+	problemsPane.PackStart(scroll, true, true, 0)
+
+	return problemsPane
+}
+
+// createProblemsIndicator creates the status bar error and warning counts
+// for all open tabs; clicking them shows the Problems pane
+func createProblemsIndicator() *gtk.Button {
+	errorIcon, _ := gtk.ImageNewFromIconName("dialog-error", gtk.ICON_SIZE_MENU)
+	problemsErrorCount, _ = gtk.LabelNew("0")
+	warningIcon, _ := gtk.ImageNewFromIconName("dialog-warning", gtk.ICON_SIZE_MENU)
+	problemsWarningCount, _ = gtk.LabelNew("0")
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 3)
+	box.PackStart(errorIcon, false, false, 0)
+	box.PackStart(problemsErrorCount, false, false, 0)
+	box.PackStart(warningIcon, false, false, 4)
+	box.PackStart(problemsWarningCount, false, false, 0)
+
+	problemsStatusButton, _ = gtk.ButtonNew()
+	problemsStatusButton.SetRelief(gtk.RELIEF_NONE)
+	problemsStatusButton.SetTooltipText("Problems in all open tabs - click to show the Problems pane")
+	problemsStatusButton.Add(box)
+	problemsStatusButton.Connect("clicked", showProblems)
+	return problemsStatusButton
+}
+
+// selectedProblem returns the problem of the selected row
+func selectedProblem() (Problem, bool) {
+	selection, _ := problemsView.GetSelection()
+	model, iter, ok := selection.GetSelected()
+	if !ok {
+		return Problem{}, false
+	}
+	value, _ := model.(*gtk.TreeModel).GetValue(iter, problemsColumnIndex)
+	goValue, _ := value.GoValue()
+	index, _ := goValue.(int)
+	if index < 0 || index >= len(problems) {
+		return Problem{}, false
+	}
+	return problems[index], true
+}
+
+// showProblemLocation shows a problem in its tab, opening the file if needed
+func showProblemLocation(p Problem) {
+	if p.Tab != nil && isTabOpen(p.Tab) {
+		for i, t := range openTabs {
+			if t == p.Tab {
+				setCurrentTab(i)
+			}
+		}
+		goToLine(p.Tab, p.Line, p.Column)
+		return
+	}
+	if p.File != "" && p.Line > 0 {
+		navigateToSource(SourceLocation{File: p.File, Line: p.Line, Column: p.Column})
+	}
+}
+
+// collectProblems gathers the diagnostics of every open tab and the errors
+// of the last run
+func collectProblems() []Problem {
+	var collected []Problem
+	for _, tab := range openTabs {
+		for _, d := range tab.diagnostics {
+			source := d.Rule
+			if source == "" {
+				source = "Parser"
+			}
+			collected = append(collected, Problem{
+				Severity: d.Severity,
+				Tab:      tab,
+				File:     tabSourceName(tab),
+				Line:     d.Line + 1,
+				Column:   d.Column + 1,
+				Message:  d.Message,
+				Source:   source,
+				Fix:      d.Fix,
+			})
+		}
+	}
+	for _, rec := range runErrors {
+		message := rec.Message
+		if i := strings.IndexByte(message, '\n'); i >= 0 {
+			message = message[:i]
+		}
+		p := Problem{
+			Severity: SeverityError,
+			File:     rec.ScriptName,
+			Line:     rec.Line,
+			Column:   rec.Column,
+			Message:  message,
+			Source:   "Runtime",
+		}
+		if rec.ScriptName != "" {
+			p.Tab = findTabForSource(rec.ScriptName)
+		}
+		collected = append(collected, p)
+	}
+	return collected
+}
+
+// updateProblemsList refreshes the Problems pane and the status bar counts
+func updateProblemsList() {
+	all := collectProblems()
+	errors, warnings := 0, 0
+	for _, p := range all {
+		if p.Severity == SeverityWarning {
+			warnings++
+		} else {
+			errors++
+		}
+	}
+	if problemsStatusButton != nil {
+		problemsErrorCount.SetText(fmt.Sprint(errors))
+		problemsWarningCount.SetText(fmt.Sprint(warnings))
+	}
+	if problemsStore == nil {
+		return
+	}
+	problemsErrorsToggle.SetLabel(fmt.Sprintf("Errors (%d)", errors))
+	problemsWarningsToggle.SetLabel(fmt.Sprintf("Warnings (%d)", warnings))
+
+	problems = problems[:0]
+	for _, p := range all {
+		if p.Severity == SeverityWarning && !problemsWarningsToggle.GetActive() ||
+			p.Severity != SeverityWarning && !problemsErrorsToggle.GetActive() {
+			continue
+		}
+		problems = append(problems, p)
+	}
+
+	problemsStore.Clear()
+	for i, p := range problems {
+		icon, severity := "dialog-error", "Error"
+		if p.Severity == SeverityWarning {
+			icon, severity = "dialog-warning", "Warning"
+		}
+		file := ""
+		if p.File != "" {
+			file = getBaseName(p.File)
+		}
+		iter := problemsStore.Append()
+		problemsStore.Set(iter,
+			[]int{problemsColumnIcon, problemsColumnSeverity, problemsColumnFile, problemsColumnLine, problemsColumnColumn,
+				problemsColumnMessage, problemsColumnSource, problemsColumnSeverityRank, problemsColumnIndex},
+			[]interface{}{icon, severity, file, p.Line, p.Column, p.Message, p.Source, int(p.Severity), i})
+	}
+	problemsFixButton.SetSensitive(false)
+}
+
+// setRunErrors replaces the runtime errors when F5 or F8 starts a new run
+func setRunErrors(records []translation.ErrorRecordInfo) {
+	runErrors = records
+	updateProblemsList()
+}
+
+// addRunErrors adds errors raised while debugging the current run
+func addRunErrors(records []translation.ErrorRecordInfo) {
+	if len(records) == 0 {
+		return
+	}
+	runErrors = append(runErrors, records...)
+	updateProblemsList()
+}
+
+func showProblems() {
+	showToolPanelPage(problemsPane)
+}
+
+// appendQuickFixItems adds a menu item for each quick fix at the clicked
+// position, returning whether there were any
+func appendQuickFixItems(menu *gtk.Menu, event *gdk.Event) bool {
+	tab := getCurrentTab()
+	if tab == nil {
+		return false
+	}
+	eventButton := gdk.EventButtonNewFromEvent(event)
+	x, y := tab.textView.WindowToBufferCoords(gtk.TEXT_WINDOW_TEXT, int(eventButton.X()), int(eventButton.Y()))
+	iter := tab.textView.GetIterAtLocation(x, y)
+
+	added := false
+	for _, d := range diagnosticsAt(tab, iter) {
+		if d.Fix == nil {
+			continue
+		}
+		fix := d.Fix
+		item, _ := gtk.MenuItemNewWithLabel(fmt.Sprintf("Quick Fix: %s", fix.Description))
+		item.Connect("activate", func() {
+			if !applyQuickFix(tab, fix) {
+				statusLabel.SetText("The quick fix no longer applies; the script has changed")
+			}
+		})
+		menu.Append(item)
+		added = true
+	}
+	return added
+}
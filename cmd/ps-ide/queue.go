@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// queuedCommand is work waiting for the current pipeline to finish
+type queuedCommand struct {
+	label string // Shown in the queue indicator
+	start func() // Starts the command; called on the UI thread when idle
+}
+
+var (
+	commandQueue []*queuedCommand
+
+	// Queue indicator in the status bar
+	queueButton  *gtk.Button
+	queuePopover *gtk.Popover
+	queueList    *gtk.ListBox
+
+	// Text typed ahead in the console, held while command output is inserted
+	typeAheadText string
+)
+
+// isBusy reports whether a command is running in the console session or its
+// output is still being rendered
+func isBusy() bool {
+	return isExecuting || consoleRenderPending() || (translationLayer != nil && translationLayer.IsExecuting())
+}
+
+// runOrQueue starts a command now, or queues it behind the running command
+// and anything already queued
+func runOrQueue(label string, start func()) {
+	if !isBusy() && len(commandQueue) == 0 {
+		start()
+		return
+	}
+	commandQueue = append(commandQueue, &queuedCommand{label: label, start: start})
+	updateQueueIndicator()
+	statusLabel.SetText("Queued: " + label)
+}
+
+// dispatchQueuedCommand starts the next queued command if the session is idle
+func dispatchQueuedCommand() {
+	if isBusy() || len(commandQueue) == 0 {
+		return
+	}
+	next := commandQueue[0]
+	commandQueue = commandQueue[1:]
+	updateQueueIndicator()
+	next.start()
+}
+
+func moveQueuedCommand(index, delta int) {
+	target := index + delta
+	if index < 0 || index >= len(commandQueue) || target < 0 || target >= len(commandQueue) {
+		return
+	}
+	commandQueue[index], commandQueue[target] = commandQueue[target], commandQueue[index]
+	updateQueueIndicator()
+}
+
+func dropQueuedCommand(index int) {
+	if index < 0 || index >= len(commandQueue) {
+		return
+	}
+	commandQueue = append(commandQueue[:index], commandQueue[index+1:]...)
+	updateQueueIndicator()
+}
+
+func clearCommandQueue() {
+	commandQueue = nil
+	updateQueueIndicator()
+}
+
+// createQueueIndicator creates the status bar button showing queued commands
+func createQueueIndicator() *gtk.Button {
+	queueButton, _ = gtk.ButtonNewWithLabel("")
+	queueButton.SetRelief(gtk.RELIEF_NONE)
+	queueButton.SetTooltipText("Queued commands - click to reorder or remove")
+	queueButton.SetNoShowAll(true)
+	queueButton.Connect("clicked", showQueuePopover)
+
+	queuePopover, _ = gtk.PopoverNew(queueButton)
+	queueList, _ = gtk.ListBoxNew()
+	queueList.SetSelectionMode(gtk.SELECTION_NONE)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 4)
+	box.SetMarginStart(6)
+	box.SetMarginEnd(6)
+	box.SetMarginTop(6)
+	box.SetMarginBottom(6)
+	box.PackStart(queueList, true, true, 0)
+	clearButton, _ := gtk.ButtonNewWithLabel("Clear Queue")
+	clearButton.Connect("clicked", func() {
+		clearCommandQueue()
+		queuePopover.Popdown()
+	})
+	box.PackStart(clearButton, false, false, 0)
+	queuePopover.Add(box)
+
+	return queueButton
+}
+
+// updateQueueIndicator refreshes the indicator and, if open, its list
+func updateQueueIndicator() {
+	if queueButton == nil {
+		return
+	}
+	if len(commandQueue) == 0 {
+		queueButton.Hide()
+		queuePopover.Popdown()
+		return
+	}
+	queueButton.SetLabel(fmt.Sprintf("Queued: %d", len(commandQueue)))
+	queueButton.Show()
+	rebuildQueueList()
+}
+
+func rebuildQueueList() {
+	queueList.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(*gtk.Widget); ok {
+			widget.Destroy()
+		}
+	})
+
+	for i, cmd := range commandQueue {
+		index := i
+		row, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 4)
+
+		label, _ := gtk.LabelNew(fmt.Sprintf("%d. %s", i+1, strings.ReplaceAll(cmd.label, "\n", " ")))
+		label.SetXAlign(0)
+		label.SetMaxWidthChars(50)
+		label.SetEllipsize(3) // PANGO_ELLIPSIZE_END
+		row.PackStart(label, true, true, 0)
+
+		upButton, _ := gtk.ButtonNewFromIconName("go-up-symbolic", gtk.ICON_SIZE_BUTTON)
+		upButton.SetTooltipText("Run earlier")
+		upButton.SetSensitive(i > 0)
+		upButton.Connect("clicked", func() { moveQueuedCommand(index, -1) })
+		row.PackStart(upButton, false, false, 0)
+
+		downButton, _ := gtk.ButtonNewFromIconName("go-down-symbolic", gtk.ICON_SIZE_BUTTON)
+		downButton.SetTooltipText("Run later")
+		downButton.SetSensitive(i < len(commandQueue)-1)
+		downButton.Connect("clicked", func() { moveQueuedCommand(index, 1) })
+		row.PackStart(downButton, false, false, 0)
+
+		removeButton, _ := gtk.ButtonNewFromIconName("window-close-symbolic", gtk.ICON_SIZE_BUTTON)
+		removeButton.SetTooltipText("Remove from queue")
+		removeButton.Connect("clicked", func() { dropQueuedCommand(index) })
+		row.PackStart(removeButton, false, false, 0)
+
+		queueList.Add(row)
+	}
+	queueList.ShowAll()
+}
+
+func showQueuePopover() {
+	if len(commandQueue) == 0 {
+		return
+	}
+	rebuildQueueList()
+	queuePopover.ShowAll()
+	queuePopover.Popup()
+}
+
+// stashTypeAhead removes text typed ahead at the console prompt so command
+// output can be inserted; displayPrompt puts it back after the new prompt.
+// Text typed between output frames is added to what was already stashed.
+func stashTypeAhead() {
+	if promptMark == nil || !isBusy() {
+		return
+	}
+	typeAheadText += getUserInput()
+	clearUserInput()
+}
+
+// restoreTypeAhead re-inserts stashed type-ahead text at the end of the console
+func restoreTypeAhead() {
+	if typeAheadText == "" {
+		return
+	}
+	consoleTextBuffer.Insert(consoleTextBuffer.GetEndIter(), typeAheadText)
+	typeAheadText = ""
+	consoleTextBuffer.PlaceCursor(consoleTextBuffer.GetEndIter())
+}
+
+// submitConsoleCommand runs a console command, or queues it while another
+// command runs. Queued commands are echoed after the prompt when they start,
+// keeping any partially typed input after them.
+func submitConsoleCommand(cmd string, echo bool) {
+	runOrQueue(cmd, func() {
+		if !echo {
+			startConsoleCommand(cmd)
+			return
+		}
+		typed := getUserInput()
+		clearUserInput()
+		consoleTextBuffer.Insert(consoleTextBuffer.GetEndIter(), cmd+"\n")
+		startConsoleCommand(cmd)
+		consoleTextBuffer.Insert(consoleTextBuffer.GetEndIter(), typed)
+	})
+}
+
+// startConsoleCommand executes a console command in the background
+func startConsoleCommand(cmd string) {
+	setExecuting(true)
+	// Anything typed from here on is type-ahead for the next command
+	movePromptMarkToEnd()
+	beginConsoleBlock(cmd)
+	go executeCommand(cmd)
+}
+
+func movePromptMarkToEnd() {
+	if promptMark != nil {
+		consoleTextBuffer.DeleteMark(promptMark)
+		promptMark = consoleTextBuffer.CreateMark("prompt", consoleTextBuffer.GetEndIter(), true)
+	}
+}
+
+// scheduleQueueDispatch starts the next queued command once the current
+// completion handler has finished
+func scheduleQueueDispatch() {
+	glib.IdleAdd(func() bool {
+		dispatchQueuedCommand()
+		return false
+	})
+}
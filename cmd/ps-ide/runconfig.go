@@ -0,0 +1,599 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+)
+
+// RunConfiguration is a named way of running a script (F5)
+type RunConfiguration struct {
+	Name            string              `json:"name"`
+	ParameterValues map[string]string   `json:"parameterValues,omitempty"` // Values for the script's param() block
+	Arguments       string              `json:"arguments,omitempty"`       // Extra raw arguments
+	WorkingDir      string              `json:"workingDir,omitempty"`
+	Env             map[string]string   `json:"env,omitempty"`
+	Mode            translation.RunMode `json:"mode,omitempty"`
+}
+
+// ScriptRunConfigs holds the configurations of one script file
+type ScriptRunConfigs struct {
+	Active         string              `json:"active,omitempty"` // Name of the active configuration, empty for Default
+	Configurations []*RunConfiguration `json:"configurations"`
+}
+
+const (
+	defaultRunConfigName = "Default"
+	editRunConfigsLabel  = "Edit Configurations..."
+)
+
+var (
+	// Run configurations keyed by script path, saved with the session
+	runConfigurations = make(map[string]*ScriptRunConfigs)
+
+	runConfigCombo         *gtk.ComboBoxText
+	runConfigComboFile     string
+	updatingRunConfigCombo bool
+)
+
+var runModeLabels = []struct {
+	mode  translation.RunMode
+	label string
+}{
+	{translation.RunShared, "Run in shared session"},
+	{translation.RunDotSource, "Dot-source into shared session"},
+	{translation.RunIsolated, "Run in new isolated pwsh process"},
+}
+
+// Options converts the configuration into translation run options
+func (rc *RunConfiguration) Options(params []ScriptParameter) translation.RunOptions {
+	args := buildParameterArguments(params, rc.ParameterValues)
+	if extra := strings.TrimSpace(rc.Arguments); extra != "" {
+		args = strings.TrimSpace(args + " " + extra)
+	}
+	mode := rc.Mode
+	if mode == "" {
+		mode = translation.RunShared
+	}
+	return translation.RunOptions{
+		Arguments:  args,
+		WorkingDir: rc.WorkingDir,
+		Env:        rc.Env,
+		Mode:       mode,
+	}
+}
+
+// clone returns a deep copy so dialogs can be cancelled
+func (rc *RunConfiguration) clone() *RunConfiguration {
+	c := *rc
+	c.ParameterValues = make(map[string]string, len(rc.ParameterValues))
+	for k, v := range rc.ParameterValues {
+		c.ParameterValues[k] = v
+	}
+	c.Env = make(map[string]string, len(rc.Env))
+	for k, v := range rc.Env {
+		c.Env[k] = v
+	}
+	return &c
+}
+
+// activeRunConfiguration returns the active configuration for a script,
+// or an empty Default configuration
+func activeRunConfiguration(filename string) *RunConfiguration {
+	if configs, ok := runConfigurations[filename]; ok {
+		for _, rc := range configs.Configurations {
+			if rc.Name == configs.Active {
+				return rc
+			}
+		}
+	}
+	return &RunConfiguration{Name: defaultRunConfigName, Mode: translation.RunShared}
+}
+
+// scriptParametersForTab parses the param() block of a tab's current text
+func scriptParametersForTab(tab *ScriptTab) []ScriptParameter {
+	start, end := tab.buffer.GetBounds()
+	text, _ := tab.buffer.GetText(start, end, true)
+	return parseScriptParameters(text)
+}
+
+// missingMandatoryParameters lists mandatory parameters without a value.
+// Running such a script over pipes would block on PowerShell's prompt.
+func missingMandatoryParameters(params []ScriptParameter, rc *RunConfiguration) []string {
+	var missing []string
+	for _, p := range params {
+		if p.Mandatory && strings.TrimSpace(rc.ParameterValues[p.Name]) == "" {
+			missing = append(missing, "-"+p.Name)
+		}
+	}
+	return missing
+}
+
+// createRunConfigToolItem builds the toolbar dropdown of run configurations
+func createRunConfigToolItem() *gtk.ToolItem {
+	item, _ := gtk.ToolItemNew()
+	runConfigCombo, _ = gtk.ComboBoxTextNew()
+	runConfigCombo.SetTooltipText("Run Configuration (F5)")
+	runConfigCombo.Connect("changed", onRunConfigComboChanged)
+	item.Add(runConfigCombo)
+	refreshRunConfigCombo()
+	return item
+}
+
+// syncRunConfigCombo refreshes the dropdown if the current script changed
+func syncRunConfigCombo() {
+	tab := getCurrentTab()
+	if tab == nil || runConfigCombo == nil || tab.filename == runConfigComboFile {
+		return
+	}
+	refreshRunConfigCombo()
+}
+
+// refreshRunConfigCombo fills the dropdown for the current tab's script
+func refreshRunConfigCombo() {
+	if runConfigCombo == nil {
+		return
+	}
+
+	updatingRunConfigCombo = true
+	defer func() { updatingRunConfigCombo = false }()
+
+	runConfigCombo.RemoveAll()
+
+	tab := getCurrentTab()
+	if tab == nil {
+		runConfigComboFile = ""
+		runConfigCombo.SetSensitive(false)
+		return
+	}
+	runConfigComboFile = tab.filename
+
+	runConfigCombo.AppendText(defaultRunConfigName)
+	active := 0
+	if configs, ok := runConfigurations[tab.filename]; ok && tab.filename != "" {
+		for i, rc := range configs.Configurations {
+			runConfigCombo.AppendText(rc.Name)
+			if rc.Name == configs.Active {
+				active = i + 1
+			}
+		}
+	}
+	runConfigCombo.AppendText(editRunConfigsLabel)
+	runConfigCombo.SetActive(active)
+	runConfigCombo.SetSensitive(true)
+}
+
+func onRunConfigComboChanged() {
+	if updatingRunConfigCombo {
+		return
+	}
+
+	tab := getCurrentTab()
+	if tab == nil {
+		return
+	}
+
+	name := runConfigCombo.GetActiveText()
+	if name == editRunConfigsLabel {
+		showRunConfigurationsDialog(tab)
+		refreshRunConfigCombo()
+		return
+	}
+	if tab.filename == "" {
+		return
+	}
+
+	configs := runConfigurations[tab.filename]
+	if configs == nil {
+		return
+	}
+	if name == defaultRunConfigName {
+		configs.Active = ""
+	} else {
+		configs.Active = name
+	}
+}
+
+// showRunConfigurationsDialog edits the run configurations of a tab's script
+func showRunConfigurationsDialog(tab *ScriptTab) {
+	if tab.filename == "" {
+		showInfoDialog(mainWindow, "Run Configurations",
+			"Please save the script before creating run configurations.")
+		return
+	}
+
+	params := scriptParametersForTab(tab)
+
+	// Work on copies so Cancel discards changes
+	var working []*RunConfiguration
+	activeName := ""
+	if existing, ok := runConfigurations[tab.filename]; ok {
+		for _, rc := range existing.Configurations {
+			working = append(working, rc.clone())
+		}
+		activeName = existing.Active
+	}
+
+	dialog, _ := gtk.DialogNew()
+	dialog.SetTitle("Run Configurations - " + getBaseName(tab.filename))
+	dialog.SetTransientFor(mainWindow)
+	dialog.SetModal(true)
+	dialog.SetDefaultSize(720, 480)
+	dialog.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	dialog.AddButton("OK", gtk.RESPONSE_OK)
+
+	contentArea, _ := dialog.GetContentArea()
+	hbox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 10)
+	hbox.SetMarginStart(10)
+	hbox.SetMarginEnd(10)
+	hbox.SetMarginTop(10)
+	hbox.SetMarginBottom(10)
+	contentArea.PackStart(hbox, true, true, 0)
+
+	// Left: configuration list and buttons
+	leftBox, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 5)
+	listScroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	listScroll.SetSizeRequest(180, -1)
+	listScroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	listBox, _ := gtk.ListBoxNew()
+	listScroll.Add(listBox)
+	leftBox.PackStart(listScroll, true, true, 0)
+
+	buttonBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	addBtn, _ := gtk.ButtonNewWithLabel("Add")
+	dupBtn, _ := gtk.ButtonNewWithLabel("Duplicate")
+	removeBtn, _ := gtk.ButtonNewWithLabel("Remove")
+	buttonBox.PackStart(addBtn, true, true, 0)
+	buttonBox.PackStart(dupBtn, true, true, 0)
+	buttonBox.PackStart(removeBtn, true, true, 0)
+	leftBox.PackStart(buttonBox, false, false, 0)
+	hbox.PackStart(leftBox, false, false, 0)
+
+	// Right: form for the selected configuration
+	formScroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	formScroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(6)
+	grid.SetColumnSpacing(8)
+	formScroll.Add(grid)
+	hbox.PackStart(formScroll, true, true, 0)
+
+	row := 0
+	addRow := func(label string, widget gtk.IWidget) {
+		l, _ := gtk.LabelNew(label)
+		l.SetHAlign(gtk.ALIGN_END)
+		l.SetVAlign(gtk.ALIGN_START)
+		grid.Attach(l, 0, row, 1, 1)
+		grid.Attach(widget, 1, row, 1, 1)
+		row++
+	}
+
+	nameEntry, _ := gtk.EntryNew()
+	nameEntry.SetHExpand(true)
+	addRow("Name:", nameEntry)
+
+	modeCombo, _ := gtk.ComboBoxTextNew()
+	for _, m := range runModeLabels {
+		modeCombo.AppendText(m.label)
+	}
+	addRow("Run mode:", modeCombo)
+
+	dirBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	dirEntry, _ := gtk.EntryNew()
+	dirEntry.SetPlaceholderText("Current location")
+	dirEntry.SetHExpand(true)
+	dirBrowse, _ := gtk.ButtonNewWithLabel("Browse...")
+	dirBox.PackStart(dirEntry, true, true, 0)
+	dirBox.PackStart(dirBrowse, false, false, 0)
+	addRow("Working directory:", dirBox)
+
+	dirBrowse.Connect("clicked", func() {
+		chooser, _ := gtk.FileChooserDialogNewWith2Buttons("Select Working Directory",
+			mainWindow, gtk.FILE_CHOOSER_ACTION_SELECT_FOLDER,
+			"Cancel", gtk.RESPONSE_CANCEL, "Select", gtk.RESPONSE_ACCEPT)
+		if current, _ := dirEntry.GetText(); current != "" {
+			chooser.SetCurrentFolder(current)
+		}
+		if chooser.Run() == gtk.RESPONSE_ACCEPT {
+			dirEntry.SetText(chooser.GetFilename())
+		}
+		chooser.Destroy()
+	})
+
+	envScroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	envScroll.SetSizeRequest(-1, 70)
+	envScroll.SetShadowType(gtk.SHADOW_IN)
+	envView, _ := gtk.TextViewNew()
+	envView.SetMonospace(true)
+	envView.SetTooltipText("One NAME=value per line")
+	envScroll.Add(envView)
+	envBuffer, _ := envView.GetBuffer()
+	addRow("Environment:", envScroll)
+
+	// Parameter form generated from the script's param() block
+	paramEntries := make(map[string]*gtk.Entry)
+	paramChecks := make(map[string]*gtk.CheckButton)
+	if len(params) > 0 {
+		header, _ := gtk.LabelNew("")
+		header.SetMarkup("<b>Script parameters</b>")
+		header.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(header, 0, row, 2, 1)
+		row++
+	}
+	for _, p := range params {
+		label := "-" + p.Name + ":"
+		if p.Mandatory {
+			label = "-" + p.Name + " *:"
+		}
+
+		tooltip := p.Type
+		if p.HelpText != "" {
+			tooltip = strings.TrimSpace(tooltip + " - " + p.HelpText)
+		}
+
+		if p.IsSwitch() {
+			check, _ := gtk.CheckButtonNew()
+			check.SetTooltipText("switch")
+			paramChecks[p.Name] = check
+			addRow(label, check)
+			continue
+		}
+
+		entry, _ := gtk.EntryNew()
+		if p.Default != "" {
+			entry.SetPlaceholderText("Default: " + p.Default)
+		}
+		if tooltip != "" {
+			entry.SetTooltipText(tooltip)
+		}
+		paramEntries[p.Name] = entry
+		addRow(label, entry)
+	}
+
+	argsEntry, _ := gtk.EntryNew()
+	argsEntry.SetPlaceholderText("Additional arguments, e.g. -Verbose")
+	addRow("Extra arguments:", argsEntry)
+
+	// Form <-> configuration
+	var selected *RunConfiguration
+	loadForm := func(rc *RunConfiguration) {
+		selected = rc
+		grid.SetSensitive(rc != nil)
+		if rc == nil {
+			return
+		}
+		nameEntry.SetText(rc.Name)
+		modeCombo.SetActive(0)
+		for i, m := range runModeLabels {
+			if m.mode == rc.Mode {
+				modeCombo.SetActive(i)
+			}
+		}
+		dirEntry.SetText(rc.WorkingDir)
+		envBuffer.SetText(formatEnvText(rc.Env))
+		for name, entry := range paramEntries {
+			entry.SetText(rc.ParameterValues[name])
+		}
+		for name, check := range paramChecks {
+			check.SetActive(rc.ParameterValues[name] == "true")
+		}
+		argsEntry.SetText(rc.Arguments)
+	}
+	saveForm := func() {
+		if selected == nil {
+			return
+		}
+		name, _ := nameEntry.GetText()
+		selected.Name = strings.TrimSpace(name)
+		if i := modeCombo.GetActive(); i >= 0 && i < len(runModeLabels) {
+			selected.Mode = runModeLabels[i].mode
+		}
+		selected.WorkingDir, _ = dirEntry.GetText()
+		start, end := envBuffer.GetBounds()
+		envText, _ := envBuffer.GetText(start, end, false)
+		selected.Env = parseEnvText(envText)
+		selected.ParameterValues = make(map[string]string)
+		for name, entry := range paramEntries {
+			if value, _ := entry.GetText(); value != "" {
+				selected.ParameterValues[name] = value
+			}
+		}
+		for name, check := range paramChecks {
+			if check.GetActive() {
+				selected.ParameterValues[name] = "true"
+			}
+		}
+		selected.Arguments, _ = argsEntry.GetText()
+	}
+
+	refreshList := func(selectIndex int) {
+		listBox.GetChildren().Foreach(func(item interface{}) {
+			if w, ok := item.(gtk.IWidget); ok {
+				listBox.Remove(w)
+			}
+		})
+		for _, rc := range working {
+			l, _ := gtk.LabelNew(rc.Name)
+			l.SetHAlign(gtk.ALIGN_START)
+			listBox.Add(l)
+		}
+		listBox.ShowAll()
+		if selectIndex >= 0 && selectIndex < len(working) {
+			listBox.SelectRow(listBox.GetRowAtIndex(selectIndex))
+		} else {
+			loadForm(nil)
+		}
+	}
+
+	listBox.Connect("row-selected", func(_ *gtk.ListBox, row *gtk.ListBoxRow) {
+		saveForm()
+		if row == nil {
+			loadForm(nil)
+			return
+		}
+		if i := row.GetIndex(); i >= 0 && i < len(working) {
+			loadForm(working[i])
+		}
+	})
+
+	nameEntry.Connect("changed", func() {
+		if selected == nil {
+			return
+		}
+		if row := listBox.GetSelectedRow(); row != nil {
+			if child, err := row.GetChild(); err == nil {
+				if label, ok := child.(*gtk.Label); ok {
+					text, _ := nameEntry.GetText()
+					label.SetText(text)
+				}
+			}
+		}
+	})
+
+	addBtn.Connect("clicked", func() {
+		saveForm()
+		rc := &RunConfiguration{
+			Name: uniqueRunConfigName(working, "Configuration"),
+			Mode: translation.RunShared,
+		}
+		working = append(working, rc)
+		refreshList(len(working) - 1)
+	})
+
+	dupBtn.Connect("clicked", func() {
+		if selected == nil {
+			return
+		}
+		saveForm()
+		rc := selected.clone()
+		rc.Name = uniqueRunConfigName(working, selected.Name+" copy")
+		working = append(working, rc)
+		refreshList(len(working) - 1)
+	})
+
+	removeBtn.Connect("clicked", func() {
+		row := listBox.GetSelectedRow()
+		if row == nil {
+			return
+		}
+		i := row.GetIndex()
+		selected = nil
+		working = append(working[:i], working[i+1:]...)
+		refreshList(max(0, min(i, len(working)-1)))
+	})
+
+	selectIndex := -1
+	for i, rc := range working {
+		if rc.Name == activeName {
+			selectIndex = i
+		}
+	}
+	if selectIndex < 0 && len(working) > 0 {
+		selectIndex = 0
+	}
+	refreshList(selectIndex)
+
+	dialog.ShowAll()
+	response := dialog.Run()
+	for response == gtk.RESPONSE_OK {
+		// Names are checked on save; a bad one keeps the dialog open
+		saveForm()
+		i, problem := invalidRunConfigName(working)
+		if i < 0 {
+			break
+		}
+		listBox.SelectRow(listBox.GetRowAtIndex(i))
+		nameEntry.GrabFocus()
+		showErrorDialog(&dialog.Window, "Run Configurations", problem)
+		response = dialog.Run()
+	}
+	if response == gtk.RESPONSE_OK {
+		if len(working) == 0 {
+			delete(runConfigurations, tab.filename)
+		} else {
+			configs := &ScriptRunConfigs{Configurations: working}
+			// Keep the previous active configuration if it still exists,
+			// otherwise activate the one being edited
+			for _, rc := range working {
+				if rc.Name == activeName {
+					configs.Active = activeName
+				}
+			}
+			if configs.Active == "" && selected != nil {
+				configs.Active = selected.Name
+			}
+			runConfigurations[tab.filename] = configs
+		}
+	}
+	dialog.Destroy()
+}
+
+// uniqueRunConfigName returns base, or base N if already taken
+func uniqueRunConfigName(configs []*RunConfiguration, base string) string {
+	taken := make(map[string]bool)
+	for _, rc := range configs {
+		taken[rc.Name] = true
+	}
+	taken[defaultRunConfigName] = true
+	taken[editRunConfigsLabel] = true
+
+	name := base
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s %d", base, i)
+	}
+	return name
+}
+
+// invalidRunConfigName returns the index of the first configuration whose
+// name is empty, reserved for the run combo or used twice, and why, or -1
+func invalidRunConfigName(configs []*RunConfiguration) (int, string) {
+	seen := make(map[string]bool)
+	for i, rc := range configs {
+		switch {
+		case rc.Name == "":
+			return i, "A configuration needs a name."
+		case rc.Name == defaultRunConfigName || rc.Name == editRunConfigsLabel:
+			return i, fmt.Sprintf("\"%s\" is reserved; choose another name.", rc.Name)
+		case seen[rc.Name]:
+			return i, fmt.Sprintf("There is already a configuration named \"%s\".", rc.Name)
+		}
+		seen[rc.Name] = true
+	}
+	return -1, ""
+}
+
+// formatEnvText renders environment variables as NAME=value lines
+func formatEnvText(env map[string]string) string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, name+"="+env[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseEnvText parses NAME=value lines, ignoring blanks and # comments
+func parseEnvText(text string) map[string]string {
+	env := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			continue
+		}
+		env[name] = value
+	}
+	return env
+}
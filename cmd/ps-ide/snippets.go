@@ -421,7 +421,7 @@ func insertSnippetAtCursor(code string) {
 	lineStart.SetLineOffset(0)
 	lineEnd := tab.buffer.GetIterAtMark(insertMark)
 
-	lineText, _ := tab.buffer.GetText(lineStart, lineEnd, false)
+	lineText, _ := tab.buffer.GetText(lineStart, lineEnd, true)
 	indentation := getIndentation(lineText)
 
 	// Indent all lines of the snippet
@@ -438,8 +438,10 @@ func insertSnippetAtCursor(code string) {
 		}
 	}
 
-	// Insert the snippet
+	// Insert the snippet as a single undo step
+	tab.buffer.BeginUserAction()
 	tab.buffer.InsertAtCursor(indentedCode)
+	tab.buffer.EndUserAction()
 
 	// Scroll to cursor
 	tab.textView.ScrollMarkOnscreen(insertMark)
@@ -19,6 +19,12 @@ const (
 	ColorCmdlet   = "#0000FF" // Blue - cmdlets (Verb-Noun pattern)
 	ColorType     = "#008080" // Teal - types [int], [string], etc.
 	ColorDefault  = "#000000" // Black - default text
+
+	// Token kinds only the native tokenizer distinguishes
+	ColorParameter = "#000080" // Navy - command parameters
+	ColorArgument  = "#8A2BE2" // Blue violet - bare command arguments
+	ColorAttribute = "#00BFFF" // Deep sky blue - attributes
+	ColorMember    = "#000000" // Black - properties, methods and keys
 )
 
 // PowerShell keywords
@@ -173,10 +179,10 @@ func (sh *SyntaxHighlighter) Highlight() {
 	end := sh.buffer.GetEndIter()
 
 	// Remove all existing tags
-	sh.buffer.RemoveAllTags(start, end)
+	removeSyntaxTags(sh.buffer, sh.tags, start, end)
 
 	// Get text
-	text, _ := sh.buffer.GetText(start, end, false)
+	text, _ := sh.buffer.GetText(start, end, true)
 	if text == "" {
 		return
 	}
@@ -204,12 +210,12 @@ func (sh *SyntaxHighlighter) HighlightRange(startLine, endLine int) {
 	}
 
 	// Remove tags in range
-	sh.buffer.RemoveAllTags(startIter, endIter)
+	removeSyntaxTags(sh.buffer, sh.tags, startIter, endIter)
 
 	// Get text for entire buffer (needed for context like multi-line comments)
 	bufStart := sh.buffer.GetStartIter()
 	bufEnd := sh.buffer.GetEndIter()
-	fullText, _ := sh.buffer.GetText(bufStart, bufEnd, false)
+	fullText, _ := sh.buffer.GetText(bufStart, bufEnd, true)
 
 	// Tokenize full text but only apply highlighting to visible range
 	tokens := sh.tokenize(fullText)
@@ -1,27 +1,104 @@
 package main
 
 import (
+	"hash/fnv"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 )
 
-// ChromaSyntaxHighlighter uses Chroma library for tokenization
+const (
+	highlightBatchLines = 300 // Lines tagged per idle callback outside the visible region
+
+	// lineStateUnknown marks lines that have not been lexed since they
+	// changed, or were lexed by a lexer whose state is not tracked
+	lineStateUnknown = ""
+	// lineStateRoot is the state of a line ending outside any construct
+	lineStateRoot = "root"
+	// lineStateInside is appended to the state of a line whose newline is
+	// inside a match other than whitespace, such as a single-quoted
+	// here-string
+	lineStateInside = "~"
+)
+
+// highlightSpan is a token on one line, in character columns
+type highlightSpan struct {
+	start, end int
+	tag        string
+}
+
+// highlightJob is a snapshot of the buffer for the lexing goroutine
+type highlightJob struct {
+	generation int64
+	text       string
+	startLine  int
+	dirtyTo    int      // Lines up to here are re-tagged even if unchanged
+	oldStates  []string // Per line, from the last lex
+	oldHashes  []uint64
+}
+
+// highlightResult holds the lexed lines from startLine on. If converged,
+// the lines after them are unchanged from the last lex.
+type highlightResult struct {
+	generation int64
+	startLine  int
+	spans      [][]highlightSpan
+	states     []string
+	hashes     []uint64
+	converged  bool
+}
+
+// ChromaSyntaxHighlighter uses Chroma library for tokenization. Lexing runs
+// in a goroutine; for each line it remembers the lexer's state stack after
+// its newline and a hash of its tokens. After an edit, lexing restarts at
+// the nearest line before it that ended in the root state, outside any
+// here-string, block comment or parenthesis, and stops once a line after
+// the edit ends in the same state with the same tokens as before. Only the
+// tagging of the lexed lines puts the visible lines first.
+//
+// The restart and convergence are exact for the state stack, but patterns
+// that look behind the start of a line and state kept by mutators in the
+// lexer's context are not tracked, so for lexers relying on those they are
+// approximate.
 type ChromaSyntaxHighlighter struct {
 	buffer        *gtk.TextBuffer
+	view          *gtk.TextView
 	tags          map[string]*gtk.TextTag
-	mutex         sync.Mutex
 	lexer         chroma.Lexer
 	fallbackLexer chroma.Lexer
+
+	// stack follows the state of lexer; lexMutex keeps one lex at a time
+	stack    *lexerStack
+	lexMutex sync.Mutex
+
+	// Main thread state
+	lineStates []string
+	lineHashes []uint64
+	dirtyFrom  int // First line changed since the last applied lex, -1 if none
+	dirtyTo    int
+	scheduled  bool
+
+	// Lexed lines still waiting for their tags, -1 if none
+	untaggedFrom int
+	untaggedTo   int
+
+	generation atomic.Int64 // Bumped on every edit; stale jobs stop early
 }
 
 // NewChromaSyntaxHighlighter creates a Chroma-based syntax highlighter
 func NewChromaSyntaxHighlighter(buffer *gtk.TextBuffer) *ChromaSyntaxHighlighter {
 	sh := &ChromaSyntaxHighlighter{
-		buffer: buffer,
-		tags:   make(map[string]*gtk.TextTag),
+		buffer:       buffer,
+		tags:         make(map[string]*gtk.TextTag),
+		dirtyFrom:    -1,
+		untaggedFrom: -1,
 	}
 
 	// Get PowerShell lexer
@@ -30,14 +107,28 @@ func NewChromaSyntaxHighlighter(buffer *gtk.TextBuffer) *ChromaSyntaxHighlighter
 		// Fallback to text lexer if PowerShell not available
 		sh.lexer = lexers.Fallback
 	}
+	sh.lexer, sh.stack = trackLexerStack(sh.lexer)
 
 	// Keep fallback lexer for error cases
 	sh.fallbackLexer = lexers.Fallback
 
 	sh.createTags()
+
+	// Track which lines change so only they are lexed again
+	buffer.Connect("insert-text", func(_ *gtk.TextBuffer, iter *gtk.TextIter, text string, _ int) {
+		sh.linesInserted(iter.GetLine(), strings.Count(text, "\n"))
+	})
+	buffer.Connect("delete-range", func(_ *gtk.TextBuffer, start, end *gtk.TextIter) {
+		sh.linesDeleted(start.GetLine(), end.GetLine()-start.GetLine())
+	})
 	return sh
 }
 
+// SetTextView lets the highlighter tag the visible lines first
+func (sh *ChromaSyntaxHighlighter) SetTextView(view *gtk.TextView) {
+	sh.view = view
+}
+
 // createTags creates all the text tags for syntax highlighting
 func (sh *ChromaSyntaxHighlighter) createTags() {
 	tagTable, _ := sh.buffer.GetTagTable()
@@ -71,122 +162,420 @@ func (sh *ChromaSyntaxHighlighter) createTags() {
 	createTag("builtin", ColorCmdlet)    // #0000FF - Blue (same as cmdlet)
 }
 
-// Highlight performs syntax highlighting on the entire buffer using Chroma
+// beginEdit cancels running work. Lines whose tags were still pending are
+// lexed again with the edit.
+func (sh *ChromaSyntaxHighlighter) beginEdit() {
+	sh.generation.Add(1)
+	if sh.untaggedFrom >= 0 {
+		sh.markDirty(sh.untaggedFrom, sh.untaggedTo)
+		sh.untaggedFrom = -1
+	}
+}
+
+// linesInserted shifts the per-line state for count new lines after line
+func (sh *ChromaSyntaxHighlighter) linesInserted(line, count int) {
+	sh.beginEdit()
+	if line < len(sh.lineStates) {
+		sh.lineStates[line] = lineStateUnknown
+	}
+	if count > 0 && line < len(sh.lineStates) {
+		states := make([]string, count)
+		hashes := make([]uint64, count)
+		for i := range states {
+			states[i] = lineStateUnknown
+		}
+		sh.lineStates = append(sh.lineStates[:line+1], append(states, sh.lineStates[line+1:]...)...)
+		sh.lineHashes = append(sh.lineHashes[:line+1], append(hashes, sh.lineHashes[line+1:]...)...)
+	}
+	if sh.dirtyFrom >= 0 && sh.dirtyTo > line {
+		sh.dirtyTo += count
+	}
+	sh.markDirty(line, line+count)
+}
+
+// linesDeleted drops the per-line state of count lines removed after line
+func (sh *ChromaSyntaxHighlighter) linesDeleted(line, count int) {
+	sh.beginEdit()
+	if line < len(sh.lineStates) {
+		sh.lineStates[line] = lineStateUnknown
+	}
+	if count > 0 && line+1 < len(sh.lineStates) {
+		end := line + 1 + count
+		if end > len(sh.lineStates) {
+			end = len(sh.lineStates)
+		}
+		sh.lineStates = append(sh.lineStates[:line+1], sh.lineStates[end:]...)
+		sh.lineHashes = append(sh.lineHashes[:line+1], sh.lineHashes[end:]...)
+	}
+	if sh.dirtyFrom >= 0 && sh.dirtyTo > line {
+		sh.dirtyTo -= count
+		if sh.dirtyTo < line {
+			sh.dirtyTo = line
+		}
+	}
+	sh.markDirty(line, line)
+}
+
+func (sh *ChromaSyntaxHighlighter) markDirty(from, to int) {
+	if sh.dirtyFrom < 0 || from < sh.dirtyFrom {
+		sh.dirtyFrom = from
+	}
+	if to > sh.dirtyTo {
+		sh.dirtyTo = to
+	}
+}
+
+// Highlight re-lexes and re-tags the entire buffer
 func (sh *ChromaSyntaxHighlighter) Highlight() {
-	sh.mutex.Lock()
-	defer sh.mutex.Unlock()
+	sh.beginEdit()
+	sh.lineStates = nil
+	sh.lineHashes = nil
+	sh.dirtyFrom = 0
+	sh.dirtyTo = sh.buffer.GetLineCount() - 1
+	sh.scheduleLex()
+}
 
-	start := sh.buffer.GetStartIter()
-	end := sh.buffer.GetEndIter()
+// HighlightRange re-tags the given lines, lexing from the nearest restart point
+func (sh *ChromaSyntaxHighlighter) HighlightRange(startLine, endLine int) {
+	sh.beginEdit()
+	sh.markDirty(startLine, endLine)
+	sh.scheduleLex()
+}
 
-	// Remove all existing tags
-	sh.buffer.RemoveAllTags(start, end)
+// OnBufferChanged is called when the buffer changes (incremental highlighting)
+func (sh *ChromaSyntaxHighlighter) OnBufferChanged(buffer *gtk.TextBuffer) {
+	sh.scheduleLex()
+}
 
-	// Get text
-	text, _ := sh.buffer.GetText(start, end, false)
-	if text == "" {
+// UpdateZoom updates syntax highlighting after zoom changes
+func (sh *ChromaSyntaxHighlighter) UpdateZoom() {
+	// Recreate tags with new font size (if needed)
+	// For now, just re-highlight everything
+	sh.Highlight()
+}
+
+// scheduleLex starts a lex once the current batch of edits is done
+func (sh *ChromaSyntaxHighlighter) scheduleLex() {
+	if sh.scheduled {
 		return
 	}
+	sh.scheduled = true
+	glib.IdleAdd(func() bool {
+		sh.scheduled = false
+		sh.startLex()
+		return false
+	})
+}
 
-	// Tokenize with Chroma
-	iterator, err := sh.lexer.Tokenise(nil, text)
-	if err != nil {
-		// If Chroma fails, try fallback lexer
-		iterator, err = sh.fallbackLexer.Tokenise(nil, text)
-		if err != nil {
-			return // Give up if even fallback fails
-		}
+// startLex snapshots the buffer and lexes it in a goroutine
+func (sh *ChromaSyntaxHighlighter) startLex() {
+	if sh.dirtyFrom < 0 {
+		return
 	}
+	start, end := sh.buffer.GetBounds()
+	text, _ := sh.buffer.GetText(start, end, true)
 
-	// Apply highlighting
-	offset := 0
-	for token := iterator(); token != chroma.EOF; token = iterator() {
-		if token.Value == "" {
-			continue
+	// Restart at a line the lexer entered in its root state
+	startLine := sh.dirtyFrom
+	if startLine > len(sh.lineStates) {
+		startLine = len(sh.lineStates)
+	}
+	for startLine > 0 && !isRestartState(sh.lineStates[startLine-1]) {
+		startLine--
+	}
+
+	job := &highlightJob{
+		generation: sh.generation.Load(),
+		text:       text,
+		startLine:  startLine,
+		dirtyTo:    sh.dirtyTo,
+		oldStates:  append([]string(nil), sh.lineStates...),
+		oldHashes:  append([]uint64(nil), sh.lineHashes...),
+	}
+	go func() {
+		result := sh.lex(job)
+		if result == nil {
+			return
 		}
+		glib.IdleAdd(func() bool {
+			sh.applyResult(result)
+			return false
+		})
+	}()
+}
 
-		// Map Chroma token type to GTK tag
-		tagName := sh.mapChromaTokenToGTK(token.Type)
-		if tagName != "" {
-			startIter := sh.buffer.GetIterAtOffset(offset)
-			endIter := sh.buffer.GetIterAtOffset(offset + len(token.Value))
+// isRestartState reports whether lexing can restart after a line that
+// ended in this state, i.e. with only the root state on the stack
+func isRestartState(state string) bool {
+	return state == lineStateRoot
+}
+
+// isExactState reports whether a line's state says all the lexer carries
+// into the next line, so equal states can end a lex
+func isExactState(state string) bool {
+	return state != lineStateUnknown && !strings.HasSuffix(state, lineStateInside)
+}
+
+// lexerStack follows the state stack of a regex lexer as it tokenises.
+// Mutators run as rules match, before their tokens are returned, so while
+// a match's tokens are read, stack is the state after it.
+type lexerStack struct {
+	stack []string
+	state string // stack joined with "/", lineStateUnknown before any match
+	end   int    // Rune offset the last match ended at
+}
+
+// trackLexerStack returns a copy of a regex lexer whose rules report the
+// state stack to the returned lexerStack. Other lexers are returned as they
+// are, with a lexerStack that never leaves lineStateUnknown.
+func trackLexerStack(lexer chroma.Lexer) (chroma.Lexer, *lexerStack) {
+	ls := &lexerStack{}
+	regexLexer, ok := lexer.(*chroma.RegexLexer)
+	if !ok {
+		return lexer, ls
+	}
+	rules, err := regexLexer.Rules()
+	if err != nil {
+		return lexer, ls
+	}
 
-			if tag, ok := sh.tags[tagName]; ok {
-				sh.buffer.ApplyTag(tag, startIter, endIter)
+	rules = rules.Clone()
+	for _, stateRules := range rules {
+		for i, rule := range stateRules {
+			// Includes are expanded when the rules are compiled
+			if _, ok := rule.Mutator.(chroma.LexerMutator); ok {
+				continue
 			}
+			stateRules[i].Mutator = ls.mutator(rule.Mutator)
 		}
-
-		offset += len(token.Value)
 	}
+	tracked, err := chroma.NewLexer(regexLexer.Config(), func() chroma.Rules { return rules })
+	if err != nil {
+		return lexer, ls
+	}
+	return tracked, ls
 }
 
-// HighlightRange performs syntax highlighting on a specific range
-func (sh *ChromaSyntaxHighlighter) HighlightRange(startLine, endLine int) {
-	sh.mutex.Lock()
-	defer sh.mutex.Unlock()
+// mutator wraps a rule's mutator, which may be nil, to record the stack
+func (ls *lexerStack) mutator(next chroma.Mutator) chroma.Mutator {
+	return chroma.MutatorFunc(func(state *chroma.LexerState) error {
+		if next != nil {
+			if err := next.Mutate(state); err != nil {
+				return err
+			}
+		}
+		ls.end = state.Pos
+		if ls.state == lineStateUnknown || !equalStrings(ls.stack, state.Stack) {
+			ls.stack = append(ls.stack[:0], state.Stack...)
+			ls.state = strings.Join(ls.stack, "/")
+		}
+		return nil
+	})
+}
+
+// reset forgets the stack before a new lex
+func (ls *lexerStack) reset() {
+	ls.stack = ls.stack[:0]
+	ls.state = lineStateUnknown
+	ls.end = 0
+}
+
+// lineState returns the state of a line whose newline ends at rune offset
+// pos, in a token ending at tokenEnd that is all whitespace if blank. If
+// the match ends with such a token, the whitespace after the newline lexes
+// the same from the next line.
+func (ls *lexerStack) lineState(pos, tokenEnd int, blank bool) string {
+	if ls.state == lineStateUnknown || pos == ls.end || (blank && tokenEnd == ls.end) {
+		return ls.state
+	}
+	return ls.state + lineStateInside
+}
 
-	// For Chroma, we need full context, so highlight entire buffer
-	// but only apply to the visible range
-	bufStart := sh.buffer.GetStartIter()
-	bufEnd := sh.buffer.GetEndIter()
-	fullText, _ := sh.buffer.GetText(bufStart, bufEnd, false)
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
 
-	// Get the range to apply highlighting
-	startIter := sh.buffer.GetIterAtLine(startLine)
-	endIter := sh.buffer.GetIterAtLine(endLine)
-	if !endIter.ForwardToLineEnd() {
-		endIter = sh.buffer.GetEndIter()
+// lex tokenises from job.startLine until the line states converge with
+// the previous lex. Runs in a goroutine; returns nil if the job is stale.
+func (sh *ChromaSyntaxHighlighter) lex(job *highlightJob) *highlightResult {
+	// Byte offset of the start line
+	offset := 0
+	for line := 0; line < job.startLine; line++ {
+		next := strings.IndexByte(job.text[offset:], '\n')
+		if next < 0 {
+			break
+		}
+		offset += next + 1
 	}
 
-	// Remove tags in range
-	sh.buffer.RemoveAllTags(startIter, endIter)
+	// The stack is shared by every lex with sh.lexer; stale lexes stop
+	// at their next token
+	sh.lexMutex.Lock()
+	defer sh.lexMutex.Unlock()
+	sh.stack.reset()
 
-	// Tokenize entire text for context
-	iterator, err := sh.lexer.Tokenise(nil, fullText)
+	iterator, err := sh.lexer.Tokenise(nil, job.text[offset:])
 	if err != nil {
-		iterator, err = sh.fallbackLexer.Tokenise(nil, fullText)
+		iterator, err = sh.fallbackLexer.Tokenise(nil, job.text[offset:])
 		if err != nil {
-			return
+			return nil
 		}
 	}
 
-	rangeStart := startIter.GetOffset()
-	rangeEnd := endIter.GetOffset()
+	result := &highlightResult{generation: job.generation, startLine: job.startLine}
+	var spans []highlightSpan
+	column := 0
+	pos := 0 // Rune offset from the start line
+	hash := fnv.New64a()
+
+	// endLine finishes the current line, whose newline left the lexer in state
+	endLine := func(state string) bool {
+		line := job.startLine + len(result.spans)
+		sum := hash.Sum64()
+		result.spans = append(result.spans, spans)
+		result.states = append(result.states, state)
+		result.hashes = append(result.hashes, sum)
+		spans, column = nil, 0
+		hash.Reset()
+
+		if line > job.dirtyTo && line < len(job.oldStates) && isExactState(state) &&
+			job.oldStates[line] == state && job.oldHashes[line] == sum {
+			result.converged = true
+			return true
+		}
+		return false
+	}
 
-	// Apply tokens that overlap with our range
-	offset := 0
 	for token := iterator(); token != chroma.EOF; token = iterator() {
-		if token.Value == "" {
-			continue
+		if sh.generation.Load() != job.generation {
+			return nil
 		}
+		tagName := sh.mapChromaTokenToGTK(token.Type)
 
-		tokenEnd := offset + len(token.Value)
-
-		// Only apply if token overlaps with visible range
-		if tokenEnd > rangeStart && offset < rangeEnd {
-			tagName := sh.mapChromaTokenToGTK(token.Type)
-			if tagName != "" {
-				startPos := offset
-				endPos := tokenEnd
-
-				// Clip to visible range
-				if startPos < rangeStart {
-					startPos = rangeStart
+		// Split tokens spanning lines; columns are in characters
+		parts := strings.Split(token.Value, "\n")
+		blank := strings.TrimSpace(token.Value) == ""
+		tokenEnd := pos + utf8.RuneCountInString(token.Value)
+		for i, part := range parts {
+			if i > 0 {
+				pos++
+				if endLine(sh.stack.lineState(pos, tokenEnd, blank)) {
+					return result
 				}
-				if endPos > rangeEnd {
-					endPos = rangeEnd
+			}
+			width := utf8.RuneCountInString(part)
+			pos += width
+			if width > 0 {
+				if tagName != "" {
+					spans = append(spans, highlightSpan{start: column, end: column + width, tag: tagName})
 				}
+				hash.Write([]byte(tagName + ":" + strconv.Itoa(width) + ";"))
+				column += width
+			}
+		}
+	}
+	endLine(lineStateUnknown)
+	result.converged = false
+	return result
+}
 
-				startIter := sh.buffer.GetIterAtOffset(startPos)
-				endIter := sh.buffer.GetIterAtOffset(endPos)
+// applyResult records the new line states and tags the lexed lines,
+// visible lines first and the rest in batches
+func (sh *ChromaSyntaxHighlighter) applyResult(result *highlightResult) {
+	if result.generation != sh.generation.Load() {
+		return
+	}
 
-				if tag, ok := sh.tags[tagName]; ok {
-					sh.buffer.ApplyTag(tag, startIter, endIter)
-				}
-			}
+	first := result.startLine
+	last := first + len(result.spans) // Exclusive
+	states := append([]string(nil), sh.lineStates[:min(first, len(sh.lineStates))]...)
+	hashes := append([]uint64(nil), sh.lineHashes[:min(first, len(sh.lineHashes))]...)
+	for len(states) < first {
+		states = append(states, lineStateUnknown)
+		hashes = append(hashes, 0)
+	}
+	states = append(states, result.states...)
+	hashes = append(hashes, result.hashes...)
+	if result.converged && last < len(sh.lineStates) {
+		states = append(states, sh.lineStates[last:]...)
+		hashes = append(hashes, sh.lineHashes[last:]...)
+	}
+	sh.lineStates, sh.lineHashes = states, hashes
+	sh.dirtyFrom = -1
+	sh.dirtyTo = 0
+
+	// Visible lines first
+	visibleFirst, visibleLast := first, last
+	if sh.view != nil {
+		rect := sh.view.GetVisibleRect()
+		top, _ := sh.view.GetLineAtY(rect.GetY())
+		bottom, _ := sh.view.GetLineAtY(rect.GetY() + rect.GetHeight())
+		visibleFirst = max(first, top.GetLine())
+		visibleLast = max(visibleFirst, min(last, bottom.GetLine()+1))
+	}
+	sh.tagLines(result, visibleFirst, visibleLast)
+	if visibleFirst == first && visibleLast == last {
+		return
+	}
+	sh.untaggedFrom, sh.untaggedTo = first, last-1
+
+	var pending [][2]int
+	for from := first; from < last; from += highlightBatchLines {
+		to := min(from+highlightBatchLines, last)
+		pending = append(pending, [2]int{from, to})
+	}
+	generation := result.generation
+	glib.IdleAdd(func() bool {
+		if len(pending) == 0 || sh.generation.Load() != generation {
+			return false
 		}
+		batch := pending[0]
+		pending = pending[1:]
+		// Skip the part already tagged as visible
+		if batch[0] < visibleLast && batch[1] > visibleFirst {
+			sh.tagLines(result, batch[0], visibleFirst)
+			sh.tagLines(result, visibleLast, batch[1])
+		} else {
+			sh.tagLines(result, batch[0], batch[1])
+		}
+		sh.untaggedFrom = batch[1]
+		if len(pending) == 0 {
+			sh.untaggedFrom = -1
+		}
+		return len(pending) > 0
+	})
+}
 
-		offset = tokenEnd
+// tagLines replaces the syntax tags of lines [from, to) with the lexed spans
+func (sh *ChromaSyntaxHighlighter) tagLines(result *highlightResult, from, to int) {
+	lineCount := sh.buffer.GetLineCount()
+	for line := from; line < to && line < lineCount; line++ {
+		start := sh.buffer.GetIterAtLine(line)
+		end := sh.buffer.GetIterAtLine(line)
+		if !end.ForwardLine() {
+			end = sh.buffer.GetEndIter()
+		}
+		removeSyntaxTags(sh.buffer, sh.tags, start, end)
+
+		chars := end.GetOffset() - start.GetOffset()
+		for _, span := range result.spans[line-result.startLine] {
+			if span.end > chars {
+				break
+			}
+			tag, ok := sh.tags[span.tag]
+			if !ok {
+				continue
+			}
+			sh.buffer.ApplyTag(tag, sh.buffer.GetIterAtLineOffset(line, span.start), sh.buffer.GetIterAtLineOffset(line, span.end))
+		}
 	}
 }
 
@@ -252,15 +641,3 @@ func (sh *ChromaSyntaxHighlighter) mapChromaTokenToGTK(tokenType chroma.TokenTyp
 		return ""
 	}
 }
-
-// OnBufferChanged is called when the buffer changes (incremental highlighting)
-func (sh *ChromaSyntaxHighlighter) OnBufferChanged(buffer *gtk.TextBuffer) {
-	sh.Highlight()
-}
-
-// UpdateZoom updates syntax highlighting after zoom changes
-func (sh *ChromaSyntaxHighlighter) UpdateZoom() {
-	// Recreate tags with new font size (if needed)
-	// For now, just re-highlight everything
-	sh.Highlight()
-}
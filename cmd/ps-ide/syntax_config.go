@@ -7,14 +7,16 @@ import (
 // Syntax highlighting engine selection
 const (
 	SyntaxEngineRegex  = "regex"  // Original regex-based highlighter
-	SyntaxEngineChroma = "chroma" // Chroma-based highlighter (recommended)
+	SyntaxEngineChroma = "chroma" // Chroma-based highlighter
+	SyntaxEngineNative = "native" // PowerShell tokenizer in internal/pstoken (recommended)
 )
 
 // CurrentSyntaxEngine specifies which highlighting engine to use
 // Change this to switch between engines:
+//   - SyntaxEngineNative: Uses the built-in PowerShell tokenizer (most accurate)
 //   - SyntaxEngineChroma: Uses Chroma library (professional, 150+ languages)
 //   - SyntaxEngineRegex:  Uses original regex-based highlighter (lightweight)
-var CurrentSyntaxEngine = SyntaxEngineChroma
+var CurrentSyntaxEngine = SyntaxEngineNative
 
 // SyntaxHighlighterInterface defines the common interface for all highlighters
 type SyntaxHighlighterInterface interface {
@@ -27,6 +29,8 @@ type SyntaxHighlighterInterface interface {
 // CreateSyntaxHighlighter creates the appropriate syntax highlighter based on CurrentSyntaxEngine
 func CreateSyntaxHighlighter(buffer *gtk.TextBuffer) SyntaxHighlighterInterface {
 	switch CurrentSyntaxEngine {
+	case SyntaxEngineNative:
+		return NewNativeSyntaxHighlighter(buffer)
 	case SyntaxEngineChroma:
 		return NewChromaSyntaxHighlighter(buffer)
 	case SyntaxEngineRegex:
@@ -36,3 +40,11 @@ func CreateSyntaxHighlighter(buffer *gtk.TextBuffer) SyntaxHighlighterInterface
 		return NewChromaSyntaxHighlighter(buffer)
 	}
 }
+
+// removeSyntaxTags removes only the given highlighter tags from a range,
+// leaving other tags (breakpoints, debugger line, search matches) in place
+func removeSyntaxTags(buffer *gtk.TextBuffer, tags map[string]*gtk.TextTag, start, end *gtk.TextIter) {
+	for _, tag := range tags {
+		buffer.RemoveTag(tag, start, end)
+	}
+}
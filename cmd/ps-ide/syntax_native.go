@@ -0,0 +1,295 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/internal/pstoken"
+)
+
+// nativeTokenTags maps token kinds to tag names
+var nativeTokenTags = map[pstoken.Kind]string{
+	pstoken.Comment:   "comment",
+	pstoken.Keyword:   "keyword",
+	pstoken.String:    "string",
+	pstoken.Variable:  "variable",
+	pstoken.Splat:     "variable",
+	pstoken.Number:    "number",
+	pstoken.Operator:  "operator",
+	pstoken.Command:   "cmdlet",
+	pstoken.Parameter: "parameter",
+	pstoken.Argument:  "argument",
+	pstoken.Type:      "type",
+	pstoken.Attribute: "attribute",
+	pstoken.Member:    "member",
+	pstoken.Label:     "keyword",
+}
+
+// NativeSyntaxHighlighter uses the pstoken tokenizer. It keeps the
+// tokenizer state at the end of every line, so after an edit it tokenizes
+// from the first changed line until a line ends in the same state as
+// before. Tokenizing is cheap enough to do on the main thread; tagging
+// lines outside the visible region is done in idle batches.
+type NativeSyntaxHighlighter struct {
+	buffer *gtk.TextBuffer
+	view   *gtk.TextView
+	tags   map[string]*gtk.TextTag
+
+	lineStates []pstoken.State // State at the end of each line
+	dirtyFrom  int             // First line changed since the last update, -1 if none
+	dirtyTo    int
+	scheduled  bool
+
+	// Tokenized lines still waiting for their tags, -1 if none
+	untaggedFrom int
+	untaggedTo   int
+	generation   int // Bumped on every edit; stale tag batches stop
+}
+
+// NewNativeSyntaxHighlighter creates a highlighter using the native tokenizer
+func NewNativeSyntaxHighlighter(buffer *gtk.TextBuffer) *NativeSyntaxHighlighter {
+	sh := &NativeSyntaxHighlighter{
+		buffer:       buffer,
+		tags:         make(map[string]*gtk.TextTag),
+		dirtyFrom:    -1,
+		untaggedFrom: -1,
+	}
+	sh.createTags()
+
+	buffer.Connect("insert-text", func(_ *gtk.TextBuffer, iter *gtk.TextIter, text string, _ int) {
+		sh.linesInserted(iter.GetLine(), strings.Count(text, "\n"))
+	})
+	buffer.Connect("delete-range", func(_ *gtk.TextBuffer, start, end *gtk.TextIter) {
+		sh.linesDeleted(start.GetLine(), end.GetLine()-start.GetLine())
+	})
+	return sh
+}
+
+// SetTextView lets the highlighter tag the visible lines first
+func (sh *NativeSyntaxHighlighter) SetTextView(view *gtk.TextView) {
+	sh.view = view
+}
+
+// createTags creates the text tags, with the ISE colours for the token
+// kinds the other highlighters don't distinguish
+func (sh *NativeSyntaxHighlighter) createTags() {
+	tagTable, _ := sh.buffer.GetTagTable()
+	for name, color := range map[string]string{
+		"keyword":   ColorKeyword,
+		"string":    ColorString,
+		"comment":   ColorComment,
+		"variable":  ColorVariable,
+		"operator":  ColorOperator,
+		"number":    ColorNumber,
+		"cmdlet":    ColorCmdlet,
+		"type":      ColorType,
+		"parameter": ColorParameter,
+		"argument":  ColorArgument,
+		"attribute": ColorAttribute,
+		"member":    ColorMember,
+	} {
+		if tag, err := tagTable.Lookup(name); err == nil && tag != nil {
+			tagTable.Remove(tag)
+		}
+		sh.tags[name] = sh.buffer.CreateTag(name, map[string]interface{}{
+			"foreground": color,
+		})
+	}
+}
+
+// beginEdit cancels pending tag batches; their lines are tokenized again
+func (sh *NativeSyntaxHighlighter) beginEdit() {
+	sh.generation++
+	if sh.untaggedFrom >= 0 {
+		sh.markDirty(sh.untaggedFrom, sh.untaggedTo)
+		sh.untaggedFrom = -1
+	}
+}
+
+// linesInserted shifts the line states for count new lines after line
+func (sh *NativeSyntaxHighlighter) linesInserted(line, count int) {
+	sh.beginEdit()
+	if count > 0 && line < len(sh.lineStates) {
+		states := make([]pstoken.State, count)
+		sh.lineStates = append(sh.lineStates[:line+1], append(states, sh.lineStates[line+1:]...)...)
+	}
+	if sh.dirtyFrom >= 0 && sh.dirtyTo > line {
+		sh.dirtyTo += count
+	}
+	sh.markDirty(line, line+count)
+}
+
+// linesDeleted drops the line states of count lines removed after line
+func (sh *NativeSyntaxHighlighter) linesDeleted(line, count int) {
+	sh.beginEdit()
+	if count > 0 && line+1 < len(sh.lineStates) {
+		end := min(line+1+count, len(sh.lineStates))
+		sh.lineStates = append(sh.lineStates[:line+1], sh.lineStates[end:]...)
+	}
+	if sh.dirtyFrom >= 0 && sh.dirtyTo > line {
+		sh.dirtyTo = max(sh.dirtyTo-count, line)
+	}
+	sh.markDirty(line, line)
+}
+
+func (sh *NativeSyntaxHighlighter) markDirty(from, to int) {
+	if sh.dirtyFrom < 0 || from < sh.dirtyFrom {
+		sh.dirtyFrom = from
+	}
+	if to > sh.dirtyTo {
+		sh.dirtyTo = to
+	}
+}
+
+// Highlight tokenizes and tags the entire buffer
+func (sh *NativeSyntaxHighlighter) Highlight() {
+	sh.beginEdit()
+	sh.lineStates = nil
+	sh.dirtyFrom = 0
+	sh.dirtyTo = sh.buffer.GetLineCount() - 1
+	sh.scheduleUpdate()
+}
+
+// HighlightRange re-tags the given lines
+func (sh *NativeSyntaxHighlighter) HighlightRange(startLine, endLine int) {
+	sh.beginEdit()
+	sh.markDirty(startLine, endLine)
+	sh.scheduleUpdate()
+}
+
+// OnBufferChanged is called when the buffer changes (incremental highlighting)
+func (sh *NativeSyntaxHighlighter) OnBufferChanged(buffer *gtk.TextBuffer) {
+	sh.scheduleUpdate()
+}
+
+// UpdateZoom updates syntax highlighting after zoom changes
+func (sh *NativeSyntaxHighlighter) UpdateZoom() {
+	sh.Highlight()
+}
+
+// scheduleUpdate tokenizes once the current batch of edits is done
+func (sh *NativeSyntaxHighlighter) scheduleUpdate() {
+	if sh.scheduled {
+		return
+	}
+	sh.scheduled = true
+	glib.IdleAdd(func() bool {
+		sh.scheduled = false
+		sh.update()
+		return false
+	})
+}
+
+// update tokenizes the changed lines and the lines after them whose
+// starting state changed, then tags them
+func (sh *NativeSyntaxHighlighter) update() {
+	if sh.dirtyFrom < 0 {
+		return
+	}
+	lineCount := sh.buffer.GetLineCount()
+	first := min(min(sh.dirtyFrom, len(sh.lineStates)), lineCount-1)
+	dirtyTo := sh.dirtyTo
+	sh.dirtyFrom = -1
+	sh.dirtyTo = 0
+
+	var state pstoken.State
+	if first > 0 {
+		state = sh.lineStates[first-1]
+	}
+	var lines [][]pstoken.Token
+	for line := first; line < lineCount; line++ {
+		var tokens []pstoken.Token
+		tokens, state = pstoken.TokenizeLine(sh.lineText(line), state)
+		lines = append(lines, tokens)
+
+		converged := line > dirtyTo && line < len(sh.lineStates) && sh.lineStates[line] == state
+		if line < len(sh.lineStates) {
+			sh.lineStates[line] = state
+		} else {
+			sh.lineStates = append(sh.lineStates, state)
+		}
+		if converged {
+			break
+		}
+	}
+	if len(sh.lineStates) > lineCount {
+		sh.lineStates = sh.lineStates[:lineCount]
+	}
+	last := first + len(lines) // Exclusive
+
+	// Visible lines first
+	visibleFirst, visibleLast := first, last
+	if sh.view != nil {
+		rect := sh.view.GetVisibleRect()
+		top, _ := sh.view.GetLineAtY(rect.GetY())
+		bottom, _ := sh.view.GetLineAtY(rect.GetY() + rect.GetHeight())
+		visibleFirst = max(first, top.GetLine())
+		visibleLast = max(visibleFirst, min(last, bottom.GetLine()+1))
+	}
+	sh.tagLines(lines, first, visibleFirst, visibleLast)
+	if visibleFirst == first && visibleLast == last {
+		return
+	}
+	sh.untaggedFrom, sh.untaggedTo = first, last-1
+
+	generation := sh.generation
+	from := first
+	glib.IdleAdd(func() bool {
+		if sh.generation != generation {
+			return false
+		}
+		to := min(from+highlightBatchLines, last)
+		// Skip the part already tagged as visible
+		if from < visibleLast && to > visibleFirst {
+			sh.tagLines(lines, first, from, visibleFirst)
+			sh.tagLines(lines, first, visibleLast, to)
+		} else {
+			sh.tagLines(lines, first, from, to)
+		}
+		from = to
+		sh.untaggedFrom = from
+		if from >= last {
+			sh.untaggedFrom = -1
+			return false
+		}
+		return true
+	})
+}
+
+// lineText returns the text of a line without its line ending
+func (sh *NativeSyntaxHighlighter) lineText(line int) string {
+	start := sh.buffer.GetIterAtLine(line)
+	end := sh.buffer.GetIterAtLine(line)
+	if !end.EndsLine() {
+		end.ForwardToLineEnd()
+	}
+	text, _ := sh.buffer.GetText(start, end, true)
+	return text
+}
+
+// tagLines replaces the syntax tags of lines [from, to) with the tokens in
+// lines, which start at line first
+func (sh *NativeSyntaxHighlighter) tagLines(lines [][]pstoken.Token, first, from, to int) {
+	lineCount := sh.buffer.GetLineCount()
+	for line := from; line < to && line < lineCount; line++ {
+		start := sh.buffer.GetIterAtLine(line)
+		end := sh.buffer.GetIterAtLine(line)
+		if !end.EndsLine() {
+			end.ForwardToLineEnd()
+		}
+		removeSyntaxTags(sh.buffer, sh.tags, start, end)
+
+		length := end.GetLineIndex()
+		for _, token := range lines[line-first] {
+			if token.End > length {
+				break
+			}
+			tag, ok := sh.tags[nativeTokenTags[token.Kind]]
+			if !ok {
+				continue
+			}
+			sh.buffer.ApplyTag(tag, sh.buffer.GetIterAtLineIndex(line, token.Start), sh.buffer.GetIterAtLineIndex(line, token.End))
+		}
+	}
+}
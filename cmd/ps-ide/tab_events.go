@@ -127,10 +127,7 @@ func getTabLabelFromButton(radioBtn *gtk.RadioButton) string {
 // findTabIndexByLabel finds the tab index by matching the label text
 func findTabIndexByLabel(label string) int {
 	for i, tab := range openTabs {
-		tabTitle := fmt.Sprintf("Untitled%d.ps1", tab.tabID)
-		if tab.filename != "" {
-			tabTitle = getBaseName(tab.filename)
-		}
+		tabTitle := tabDisplayName(tab)
 		if tab.modified {
 			tabTitle = "* " + tabTitle
 		}
@@ -206,6 +203,8 @@ func showTabContextMenu(event *gdk.Event, tabIndex int) {
 	separator2, _ := gtk.SeparatorMenuItemNew()
 	menu.Append(separator2)
 
+	appendTabPowerShellMenu(menu, tab)
+
 	// Copy Full Path (if file has been saved)
 	if tab.filename != "" {
 		copyPathItem, _ := gtk.MenuItemNewWithLabel("Copy Full Path")
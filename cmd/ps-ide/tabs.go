@@ -5,90 +5,36 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
 )
 
 type SessionData struct {
 	Tabs                []TabData `json:"tabs"`
 	CommandAddOnVisible bool      `json:"commandAddOnVisible"`
 	CommandAddOnWidth   int       `json:"commandAddOnWidth,omitempty"`
-}
 
-type TabData struct {
-	Filename string `json:"filename"`
-	Content  string `json:"content"`
-	Modified bool   `json:"modified"`
+	RunConfigurations map[string]*ScriptRunConfigs `json:"runConfigurations,omitempty"`
+	Breakpoints       []translation.Breakpoint     `json:"breakpoints,omitempty"` // Variable and command breakpoints
+	Watches           []string                     `json:"watches,omitempty"`
+	HiddenStreams     []string                     `json:"hiddenStreams,omitempty"` // Console stream filters
+	ConsoleScrollback int                          `json:"consoleScrollback,omitempty"`
+	AutoTranscript    bool                         `json:"autoTranscript,omitempty"`
+	TranscriptFormat  string                       `json:"transcriptFormat,omitempty"` // "text" or "jsonl"
+	CaptureObjects    bool                         `json:"captureObjects,omitempty"`   // Object inspector
 }
 
-// LineNumberView holds the line number TextView and related data
-type LineNumberView struct {
-	textView *gtk.TextView
-	buffer   *gtk.TextBuffer
-}
-
-func createLineNumberView() *LineNumberView {
-	textView, _ := gtk.TextViewNew()
-	textView.SetEditable(false)
-	textView.SetCursorVisible(false)
-	textView.SetWrapMode(gtk.WRAP_NONE)
-	textView.SetMonospace(true)
-	textView.SetLeftMargin(5)
-	textView.SetRightMargin(8) // More right margin for spacing
-	textView.SetBorderWindowSize(gtk.TEXT_WINDOW_LEFT, 0)
-	textView.SetBorderWindowSize(gtk.TEXT_WINDOW_RIGHT, 0)
-	textView.SetBorderWindowSize(gtk.TEXT_WINDOW_TOP, 0)
-	textView.SetBorderWindowSize(gtk.TEXT_WINDOW_BOTTOM, 0)
-
-	buffer, _ := textView.GetBuffer()
-	buffer.SetText("1")
-
-	// Style like Windows ISE - gray background, gray text
-	fontSize := DefaultFontSize * (currentZoom / 100.0)
-	provider, _ := gtk.CssProviderNew()
-	css := fmt.Sprintf(`textview { 
-		font-family: %s; 
-		font-size: %.1fpt; 
-		background-color: #F0F0F0;
-		padding: 3px;
-	}
-	textview text {
-		color: #2B91AF;
-	}`, DefaultFontFamily, fontSize)
-	provider.LoadFromData(css)
-
-	styleContext, _ := textView.GetStyleContext()
-	styleContext.AddProvider(provider, gtk.STYLE_PROVIDER_PRIORITY_USER)
-
-	// Prevent horizontal expansion
-	textView.SetSizeRequest(50, -1)
-
-	return &LineNumberView{
-		textView: textView,
-		buffer:   buffer,
-	}
-}
-
-func updateLineNumbers(lineNumView *LineNumberView, editorBuffer *gtk.TextBuffer) {
-	if lineNumView == nil {
-		return
-	}
-
-	lineCount := editorBuffer.GetLineCount()
-
-	// Build line number text
-	var numbers strings.Builder
-	for i := 1; i <= lineCount; i++ {
-		if i > 1 {
-			numbers.WriteString("\n")
-		}
-		numbers.WriteString(fmt.Sprintf("%d", i))
-	}
-
-	lineNumView.buffer.SetText(numbers.String())
+type TabData struct {
+	Filename       string `json:"filename"`
+	Content        string `json:"content"`
+	Modified       bool   `json:"modified"`
+	PowerShellPath string `json:"powerShellPath,omitempty"`
+	Breakpoints    []int  `json:"breakpoints,omitempty"` // 1-based line breakpoints
+	Title          string `json:"title,omitempty"`       // Name of an unsaved tab
+	Folds          []int  `json:"folds,omitempty"`       // 1-based first lines of folded blocks
 }
 
 func createNewTab() *ScriptTab {
@@ -132,13 +78,13 @@ func createNewTab() *ScriptTab {
 		styleContext.AddProvider(provider, gtk.STYLE_PROVIDER_PRIORITY_USER)
 	}
 
-	// Create line number view
-	lineNumView := createLineNumberView()
+	// Create the gutter with line numbers and markers
+	gutter := createGutter()
 
-	// Create a single ScrolledWindow containing both line numbers and editor
+	// Create a single ScrolledWindow containing both gutter and editor
 	// This eliminates the border between them entirely
 	innerHBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
-	innerHBox.PackStart(lineNumView.textView, false, false, 0)
+	innerHBox.PackStart(gutter.area, false, false, 0)
 	innerHBox.PackStart(textView, true, true, 0)
 
 	editorScroll, _ := gtk.ScrolledWindowNew(nil, nil)
@@ -166,11 +112,11 @@ func createNewTab() *ScriptTab {
 
 	container := wrapper
 
-	// Initialize line numbers
-	updateLineNumbers(lineNumView, buffer)
-
 	// Create syntax highlighter (using configured engine)
 	syntaxHighlighter := CreateSyntaxHighlighter(buffer)
+	if viewAware, ok := syntaxHighlighter.(interface{ SetTextView(*gtk.TextView) }); ok {
+		viewAware.SetTextView(textView)
+	}
 
 	// Generate unique stable tab ID
 	tabID := tabCounter
@@ -182,13 +128,21 @@ func createNewTab() *ScriptTab {
 		undoStack:         NewUndoStack(buffer, 100),
 		filename:          "",
 		modified:          false,
-		lineNumView:       lineNumView,
+		gutter:            gutter,
 		syntaxHighlighter: syntaxHighlighter,
 		tabID:             tabID,
 	}
 
 	openTabs = append(openTabs, tab)
 
+	// Folding, then breakpoint highlight tags. Tags created later draw
+	// over earlier ones.
+	setupFolding(tab)
+	createDebugTags(tab)
+	setupDiagnostics(tab)
+	setupGutter(tab)
+	setupCompletion(tab)
+
 	// Use stable tabID for page name (not array index!)
 	pageName := fmt.Sprintf("tab-%d", tabID)
 	tabTitle := fmt.Sprintf("Untitled%d.ps1", tabID)
@@ -201,12 +155,16 @@ func createNewTab() *ScriptTab {
 	buffer.Connect("notify::cursor-position", func() {
 		updateCursorPosition(buffer)
 		updateToolbarButtons()
+		syncOutlineToCursor(tab)
 	})
 
 	buffer.Connect("changed", func() {
 		tab.modified = true
 		updateTabTitle(tab)
-		updateLineNumbers(lineNumView, buffer)
+		queueGutterDraw(tab)
+		scheduleFolding(tab)
+		scheduleDiagnostics(tab)
+		scheduleOutline(tab)
 
 		// Perform incremental syntax highlighting
 		if tab.syntaxHighlighter != nil {
@@ -295,10 +253,13 @@ func closeTab(tabIndex int) {
 	// If only one tab, clear it instead of removing
 	if len(openTabs) == 1 {
 		if len(openTabs) > 0 {
+			clearTabBreakpoints(openTabs[0])
 			openTabs[0].buffer.SetText("")
+			openTabs[0].undoStack.Clear()
 			openTabs[0].filename = ""
 			openTabs[0].modified = false
 			updateTabTitle(openTabs[0])
+			scheduleDiagnostics(openTabs[0])
 		}
 		return
 	}
@@ -317,6 +278,8 @@ func closeTab(tabIndex int) {
 			break
 		}
 	}
+	updateBreakpointSnapshot()
+	updateProblemsList()
 
 	// Update tab click handlers after removing tab
 	updateTabClickHandlers()
@@ -336,6 +299,14 @@ func saveSession() {
 	sessionData := SessionData{
 		Tabs:                make([]TabData, 0),
 		CommandAddOnVisible: commandAddOnVisible,
+		RunConfigurations:   runConfigurations,
+		Breakpoints:         otherBreakpoints,
+		Watches:             currentWatchExpressions(),
+		HiddenStreams:       hiddenStreams(),
+		ConsoleScrollback:   consoleScrollback,
+		AutoTranscript:      autoTranscript,
+		TranscriptFormat:    autoTranscriptFormat,
+		CaptureObjects:      captureObjects,
 	}
 
 	// Save Command Add-On paned position (represents width allocation)
@@ -346,13 +317,17 @@ func saveSession() {
 	for _, tab := range openTabs {
 		start := tab.buffer.GetStartIter()
 		end := tab.buffer.GetEndIter()
-		content, _ := tab.buffer.GetText(start, end, false)
+		content, _ := tab.buffer.GetText(start, end, true)
 
 		if content != "" || tab.filename != "" {
 			sessionData.Tabs = append(sessionData.Tabs, TabData{
-				Filename: tab.filename,
-				Content:  content,
-				Modified: tab.modified,
+				Filename:       tab.filename,
+				Content:        content,
+				Modified:       tab.modified,
+				PowerShellPath: tab.powerShellPath,
+				Breakpoints:    tabBreakpointLines(tab),
+				Folds:          tabFoldLines(tab),
+				Title:          tab.title,
 			})
 		}
 	}
@@ -388,6 +363,20 @@ func loadSession() bool {
 		pendingCommandAddOnWidth = sessionData.CommandAddOnWidth
 	}
 
+	if sessionData.RunConfigurations != nil {
+		runConfigurations = sessionData.RunConfigurations
+	}
+
+	otherBreakpoints = sessionData.Breakpoints
+	setWatchExpressions(sessionData.Watches)
+	setHiddenStreams(sessionData.HiddenStreams)
+	setConsoleScrollback(sessionData.ConsoleScrollback)
+	autoTranscript = sessionData.AutoTranscript
+	captureObjects = sessionData.CaptureObjects
+	if sessionData.TranscriptFormat != "" {
+		autoTranscriptFormat = sessionData.TranscriptFormat
+	}
+
 	if len(sessionData.Tabs) == 0 {
 		return false
 	}
@@ -395,8 +384,13 @@ func loadSession() bool {
 	for _, tabData := range sessionData.Tabs {
 		tab := createNewTab()
 		tab.buffer.SetText(tabData.Content)
+		tab.undoStack.Clear()
 		tab.filename = tabData.Filename
 		tab.modified = tabData.Modified
+		tab.powerShellPath = tabData.PowerShellPath
+		tab.title = tabData.Title
+		setTabBreakpoints(tab, tabData.Breakpoints)
+		setTabFolds(tab, tabData.Folds)
 
 		// Force scroll to the beginning of the document
 		startIter := tab.buffer.GetStartIter()
@@ -422,6 +416,12 @@ func loadSession() bool {
 func showEditorContextMenu(event *gdk.Event) {
 	menu, _ := gtk.MenuNew()
 
+	// Quick fixes for the warnings under the pointer
+	if appendQuickFixItems(menu, event) {
+		fixSeparator, _ := gtk.SeparatorMenuItemNew()
+		menu.Append(fixSeparator)
+	}
+
 	// Cut
 	cutItem, _ := gtk.MenuItemNewWithLabel("Cut")
 	cutItem.Connect("activate", func() {
@@ -102,6 +102,9 @@ func createToolbar() *gtk.Toolbar {
 	runSelBtn.Connect("clicked", func() { runSelection() })
 	toolbar.Insert(runSelBtn, -1)
 
+	// Run configuration used by F5
+	toolbar.Insert(createRunConfigToolItem(), -1)
+
 	// Stop Operation (Ctrl+Break) - Red square
 	stopBtn, _ := gtk.ToolButtonNew(nil, "")
 	stopBtn.SetIconName("process-stop")
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Tool panel: a notebook beside the console holding panes such as the
+// debugger's Call Stack
+var (
+	toolPanel             *gtk.Notebook
+	toolPanelPane         *gtk.Paned
+	toolPanelVisible      bool
+	showToolPanelMenuItem *gtk.CheckMenuItem
+	updatingToolPanelMenu bool // Flag to prevent signal loops
+)
+
+// createToolPanelPane returns a horizontal paned with the console on the
+// left and the (initially hidden) tool panel on the right
+func createToolPanelPane(console gtk.IWidget) *gtk.Paned {
+	toolPanel, _ = gtk.NotebookNew()
+	toolPanel.SetScrollable(true)
+	toolPanel.SetSizeRequest(220, -1)
+
+	toolPanelPane, _ = gtk.PanedNew(gtk.ORIENTATION_HORIZONTAL)
+	toolPanelPane.SetWideHandle(true)
+	toolPanelPane.Pack1(console, true, false)
+	toolPanelPane.Pack2(toolPanel, false, true)
+
+	return toolPanelPane
+}
+
+// addToolPanelPage appends a page to the tool panel
+func addToolPanelPage(title string, child gtk.IWidget) {
+	label, _ := gtk.LabelNew(title)
+	toolPanel.AppendPage(child, label)
+}
+
+// showToolPanelPage shows the tool panel with child's page selected
+func showToolPanelPage(child gtk.IWidget) {
+	if toolPanel == nil {
+		return
+	}
+	if page := toolPanel.PageNum(child); page >= 0 {
+		toolPanel.SetCurrentPage(page)
+	}
+	setToolPanelVisible(true)
+}
+
+// setToolPanelVisible shows or hides the tool panel
+func setToolPanelVisible(visible bool) {
+	if toolPanel == nil {
+		return
+	}
+	toolPanelVisible = visible
+	if visible {
+		toolPanel.ShowAll()
+	} else {
+		toolPanel.Hide()
+	}
+
+	// Sync menu checkbox with actual state (without triggering signal)
+	if showToolPanelMenuItem != nil {
+		updatingToolPanelMenu = true
+		showToolPanelMenuItem.SetActive(visible)
+		updatingToolPanelMenu = false
+	}
+}
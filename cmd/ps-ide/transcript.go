@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+)
+
+// Transcript formats as saved in the session
+const (
+	transcriptFormatText  = "text"
+	transcriptFormatJSONL = "jsonl"
+)
+
+var (
+	activeTranscript *translation.Transcript
+
+	// Start a transcript in ~/.ps-ide/transcripts for every session
+	autoTranscript       bool
+	autoTranscriptFormat = transcriptFormatText
+
+	startTranscriptMenuItem *gtk.MenuItem
+	stopTranscriptMenuItem  *gtk.MenuItem
+)
+
+func parseTranscriptFormat(format string) translation.TranscriptFormat {
+	if format == transcriptFormatJSONL {
+		return translation.TranscriptJSONL
+	}
+	return translation.TranscriptText
+}
+
+// appendTranscriptMenuItems adds Start/Stop Transcript to the Tools menu
+func appendTranscriptMenuItems(menu *gtk.Menu) {
+	startTranscriptMenuItem, _ = gtk.MenuItemNewWithLabel("Start Transcript...")
+	startTranscriptMenuItem.Connect("activate", func() { showStartTranscriptDialog() })
+	menu.Append(startTranscriptMenuItem)
+
+	stopTranscriptMenuItem, _ = gtk.MenuItemNewWithLabel("Stop Transcript")
+	stopTranscriptMenuItem.Connect("activate", func() { stopTranscript() })
+	menu.Append(stopTranscriptMenuItem)
+
+	updateTranscriptMenu()
+}
+
+func updateTranscriptMenu() {
+	if startTranscriptMenuItem == nil {
+		return
+	}
+	startTranscriptMenuItem.SetSensitive(activeTranscript == nil)
+	stopTranscriptMenuItem.SetSensitive(activeTranscript != nil)
+}
+
+// showStartTranscriptDialog asks where to save a transcript. Files ending
+// in .jsonl are written as JSON Lines, anything else as text.
+func showStartTranscriptDialog() {
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons(
+		"Start Transcript",
+		mainWindow,
+		gtk.FILE_CHOOSER_ACTION_SAVE,
+		"Cancel", gtk.RESPONSE_CANCEL,
+		"Start", gtk.RESPONSE_ACCEPT)
+	dialog.SetDoOverwriteConfirmation(true)
+
+	textFilter, _ := gtk.FileFilterNew()
+	textFilter.SetName("Text transcript (*.txt)")
+	textFilter.AddPattern("*.txt")
+	dialog.AddFilter(textFilter)
+
+	jsonFilter, _ := gtk.FileFilterNew()
+	jsonFilter.SetName("JSON Lines transcript (*.jsonl)")
+	jsonFilter.AddPattern("*.jsonl")
+	dialog.AddFilter(jsonFilter)
+
+	defaultPath := translation.DefaultTranscriptPath(translation.TranscriptText)
+	dialog.SetCurrentFolder(filepath.Dir(defaultPath))
+	dialog.SetCurrentName(filepath.Base(defaultPath))
+
+	if dialog.Run() == gtk.RESPONSE_ACCEPT {
+		filename := dialog.GetFilename()
+		format := translation.TranscriptText
+		if strings.HasSuffix(filename, ".jsonl") {
+			format = translation.TranscriptJSONL
+		}
+		startTranscript(filename, format)
+	}
+	dialog.Destroy()
+}
+
+// startTranscript starts recording all sessions' commands to path
+func startTranscript(path string, format translation.TranscriptFormat) {
+	if activeTranscript != nil {
+		stopTranscript()
+	}
+
+	executable, version := "", ""
+	if translationLayer != nil {
+		executable = translationLayer.GetExecutable()
+		version = translationLayer.GetPSVersion()
+	}
+
+	t, err := translation.StartTranscript(path, format, executable, version)
+	if err != nil {
+		showErrorDialog(mainWindow, "Start Transcript", fmt.Sprintf("Could not start transcript: %v", err))
+		return
+	}
+
+	activeTranscript = t
+	for _, tl := range translationLayers {
+		tl.SetTranscript(t)
+	}
+	updateTranscriptMenu()
+	statusLabel.SetText("Transcript started: " + path)
+}
+
+// stopTranscript stops the active transcript, if any
+func stopTranscript() {
+	if activeTranscript == nil {
+		return
+	}
+	for _, tl := range translationLayers {
+		tl.SetTranscript(nil)
+	}
+	path := activeTranscript.Path()
+	if err := activeTranscript.Stop(); err != nil {
+		statusLabel.SetText(fmt.Sprintf("Error closing transcript: %v", err))
+	} else {
+		statusLabel.SetText("Transcript stopped: " + path)
+	}
+	activeTranscript = nil
+	updateTranscriptMenu()
+}
+
+// startAutoTranscript starts the automatic session transcript, once the
+// session has reported its PowerShell version
+func startAutoTranscript() {
+	if !autoTranscript {
+		return
+	}
+	glib.TimeoutAdd(1000, func() bool {
+		if activeTranscript == nil {
+			format := parseTranscriptFormat(autoTranscriptFormat)
+			startTranscript(translation.DefaultTranscriptPath(format), format)
+		}
+		return false
+	})
+}
@@ -0,0 +1,49 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const analyzerDataTimeout = 30 * time.Second
+
+// analyzerDataScript lists the approved verbs and the aliases, one per line
+var analyzerDataScript = `Get-Verb | ForEach-Object { "verb` + "`t" + `$($_.Verb)" }; ` +
+	`Get-Alias | ForEach-Object { "alias` + "`t" + `$($_.Name)` + "`t" + `$($_.Definition)" }`
+
+// AnalyzerData is what the script analyzer checks scripts against
+type AnalyzerData struct {
+	Verbs   []string
+	Aliases map[string]string // Alias -> command name
+}
+
+// LoadAnalyzerData reads the approved verbs and the aliases from a new
+// PowerShell process, without the user's profile
+func LoadAnalyzerData(executable string) (AnalyzerData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), analyzerDataTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, ResolveExecutable(executable),
+		"-NoLogo", "-NoProfile", "-NonInteractive", "-Command", analyzerDataScript).Output()
+	if err != nil {
+		return AnalyzerData{}, fmt.Errorf("failed to read verbs and aliases: %w", err)
+	}
+
+	data := AnalyzerData{Aliases: make(map[string]string)}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		switch {
+		case len(fields) == 2 && fields[0] == "verb" && fields[1] != "":
+			data.Verbs = append(data.Verbs, fields[1])
+		case len(fields) == 3 && fields[0] == "alias" && fields[1] != "" && fields[2] != "":
+			data.Aliases[fields[1]] = fields[2]
+		}
+	}
+	if len(data.Verbs) == 0 {
+		return AnalyzerData{}, fmt.Errorf("no verbs in PowerShell output")
+	}
+	return data, nil
+}
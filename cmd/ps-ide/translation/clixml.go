@@ -0,0 +1,607 @@
+package translation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+// CollectionKind identifies the collection held by a PSObject
+type CollectionKind string
+
+const (
+	NoCollection         CollectionKind = ""
+	ListCollection       CollectionKind = "LST"
+	EnumerableCollection CollectionKind = "IE"
+	StackCollection      CollectionKind = "STK"
+	QueueCollection      CollectionKind = "QUE"
+	DictionaryCollection CollectionKind = "DCT"
+)
+
+// PSObject is a deserialized CLIXML <Obj>. Objects referenced more than
+// once with <Ref> are shared, so the graph may contain cycles.
+type PSObject struct {
+	TypeNames  []string    // Most derived type first
+	ToString   string      // Empty if the object was serialized without one
+	Value      interface{} // Wrapped primitive, e.g. the <I32> of a boxed int
+	Properties []PSProperty
+	Members    []PSProperty // Extended members (<MS>)
+	Kind       CollectionKind
+	Items      []interface{} // Elements of LST, IE, STK and QUE collections
+	Entries    []PSDictEntry // Entries of DCT dictionaries
+}
+
+// PSProperty is a named property of a PSObject
+type PSProperty struct {
+	Name  string
+	Value interface{}
+}
+
+// PSDictEntry is an entry of a deserialized dictionary
+type PSDictEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Primitive types without a close Go equivalent
+type (
+	Char         rune   // Value of a <C> element, distinct from <I32>
+	SecureString string // Encrypted payload of an <SS> element
+	ScriptBlock  string // Source of an <SBK> element
+	Decimal      string // Value of a <D> element, kept exact
+	XMLDocument  string // Text of an <XD> element
+)
+
+// CLIXMLRecord is a top-level element of a CLIXML document
+type CLIXMLRecord struct {
+	Stream string // S attribute, e.g. "Error"; empty for output
+	Value  interface{}
+}
+
+// TypeName returns the most derived type name, or "" if unknown
+func (o *PSObject) TypeName() string {
+	if len(o.TypeNames) == 0 {
+		return ""
+	}
+	return o.TypeNames[0]
+}
+
+// IsType reports whether the object is or derives from the named type.
+// Deserialized type names carry a "Deserialized." prefix, which is ignored.
+func (o *PSObject) IsType(name string) bool {
+	for _, tn := range o.TypeNames {
+		if strings.EqualFold(strings.TrimPrefix(tn, "Deserialized."), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Property returns a property or extended member by name, ignoring case
+// as PowerShell does
+func (o *PSObject) Property(name string) (interface{}, bool) {
+	for _, props := range [][]PSProperty{o.Properties, o.Members} {
+		for _, p := range props {
+			if strings.EqualFold(p.Name, name) {
+				return p.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// String returns the object's ToString, or its wrapped primitive
+func (o *PSObject) String() string {
+	if o.ToString != "" || o.Value == nil {
+		return o.ToString
+	}
+	return FormatCLIXMLValue(o.Value)
+}
+
+// FormatCLIXMLValue formats a deserialized value as PowerShell would show
+// it in a single cell
+func FormatCLIXMLValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		if value {
+			return "True"
+		}
+		return "False"
+	case Char:
+		return string(rune(value))
+	case time.Time:
+		return value.Format("2006-01-02 15:04:05")
+	case time.Duration:
+		return formatTimeSpan(value)
+	case []byte:
+		return fmt.Sprintf("Byte[%d]", len(value))
+	case *url.URL:
+		return value.String()
+	case SecureString:
+		return "System.Security.SecureString"
+	case *PSObject:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// formatTimeSpan formats a duration like .NET's TimeSpan.ToString()
+func formatTimeSpan(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	s := fmt.Sprintf("%02d:%02d:%02d", d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second)
+	if days > 0 {
+		s = fmt.Sprintf("%d.%s", days, s)
+	}
+	if frac := d % time.Second; frac != 0 {
+		s += fmt.Sprintf(".%07d", frac/100)
+	}
+	return sign + s
+}
+
+// DeserializeCLIXML decodes a CLIXML document such as the output of
+// Export-Clixml. Each top-level element of <Objs> becomes one record. The
+// "#< CLIXML" header of pwsh's serialized streams is skipped.
+func DeserializeCLIXML(data []byte) ([]CLIXMLRecord, error) {
+	data = bytes.TrimSpace(data)
+	if header := []byte("#< CLIXML"); bytes.HasPrefix(data, header) {
+		data = data[len(header):]
+	}
+	d := &clixmlDecoder{
+		dec:       xml.NewDecoder(bytes.NewReader(data)),
+		objects:   make(map[string]*PSObject),
+		typeNames: make(map[string][]string),
+	}
+
+	var root *xml.StartElement
+	for root == nil {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("not a CLIXML document: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			root = &start
+		}
+	}
+	if root.Name.Local != "Objs" {
+		return nil, fmt.Errorf("not a CLIXML document: root element is <%s>", root.Name.Local)
+	}
+
+	var records []CLIXMLRecord
+	for {
+		child, err := d.nextChild()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return records, nil
+		}
+		value, err := d.decodeValue(*child)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, CLIXMLRecord{Stream: clixmlAttr(*child, "S"), Value: value})
+	}
+}
+
+type clixmlDecoder struct {
+	dec       *xml.Decoder
+	objects   map[string]*PSObject // By RefId, for <Ref>
+	typeNames map[string][]string  // By RefId, for <TNRef>
+}
+
+// nextChild returns the next child element of the current element, or nil
+// once its end tag is read
+func (d *clixmlDecoder) nextChild() (*xml.StartElement, error) {
+	for {
+		tok, err := d.dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("unexpected end of CLIXML")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return &t, nil
+		case xml.EndElement:
+			return nil, nil
+		}
+	}
+}
+
+// readText returns the character data of the current element, skipping
+// any child elements
+func (d *clixmlDecoder) readText() (string, error) {
+	var b strings.Builder
+	for {
+		tok, err := d.dec.Token()
+		if err == io.EOF {
+			return "", fmt.Errorf("unexpected end of CLIXML")
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case xml.StartElement:
+			if err := d.dec.Skip(); err != nil {
+				return "", err
+			}
+		case xml.EndElement:
+			return b.String(), nil
+		}
+	}
+}
+
+// decodeValue decodes the element that start opens
+func (d *clixmlDecoder) decodeValue(start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "Obj":
+		return d.decodeObject(start)
+	case "Ref":
+		id := clixmlAttr(start, "RefId")
+		if err := d.dec.Skip(); err != nil {
+			return nil, err
+		}
+		obj, ok := d.objects[id]
+		if !ok {
+			return nil, fmt.Errorf("CLIXML reference to unknown object %q", id)
+		}
+		return obj, nil
+	case "Nil":
+		return nil, d.dec.Skip()
+	}
+
+	text, err := d.readText()
+	if err != nil {
+		return nil, err
+	}
+	return parseCLIXMLPrimitive(start.Name.Local, text), nil
+}
+
+// decodeObject decodes an <Obj>, registering it first so that references
+// from inside the object resolve to it
+func (d *clixmlDecoder) decodeObject(start xml.StartElement) (*PSObject, error) {
+	obj := &PSObject{}
+	if id := clixmlAttr(start, "RefId"); id != "" {
+		d.objects[id] = obj
+	}
+
+	for {
+		child, err := d.nextChild()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return obj, nil
+		}
+
+		switch child.Name.Local {
+		case "TN":
+			obj.TypeNames, err = d.decodeTypeNames(*child)
+		case "TNRef":
+			obj.TypeNames = d.typeNames[clixmlAttr(*child, "RefId")]
+			err = d.dec.Skip()
+		case "ToString":
+			var text string
+			text, err = d.readText()
+			obj.ToString = decodeCLIXMLString(text)
+		case "Props":
+			obj.Properties, err = d.decodeProperties()
+		case "MS":
+			obj.Members, err = d.decodeProperties()
+		case "LST", "IE", "STK", "QUE":
+			obj.Kind = CollectionKind(child.Name.Local)
+			obj.Items, err = d.decodeItems()
+		case "DCT":
+			obj.Kind = DictionaryCollection
+			obj.Entries, err = d.decodeEntries()
+		default:
+			obj.Value, err = d.decodeValue(*child)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decodeTypeNames decodes a <TN> list and remembers it for <TNRef>
+func (d *clixmlDecoder) decodeTypeNames(start xml.StartElement) ([]string, error) {
+	var names []string
+	for {
+		child, err := d.nextChild()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			break
+		}
+		text, err := d.readText()
+		if err != nil {
+			return nil, err
+		}
+		if child.Name.Local == "T" {
+			names = append(names, decodeCLIXMLString(text))
+		}
+	}
+	if id := clixmlAttr(start, "RefId"); id != "" {
+		d.typeNames[id] = names
+	}
+	return names, nil
+}
+
+// decodeProperties decodes the named children of <Props> or <MS>
+func (d *clixmlDecoder) decodeProperties() ([]PSProperty, error) {
+	var props []PSProperty
+	for {
+		child, err := d.nextChild()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return props, nil
+		}
+		value, err := d.decodeValue(*child)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, PSProperty{Name: decodeCLIXMLString(clixmlAttr(*child, "N")), Value: value})
+	}
+}
+
+// decodeItems decodes the elements of a list, stack or queue
+func (d *clixmlDecoder) decodeItems() ([]interface{}, error) {
+	items := []interface{}{}
+	for {
+		child, err := d.nextChild()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return items, nil
+		}
+		value, err := d.decodeValue(*child)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+}
+
+// decodeEntries decodes the <En> entries of a dictionary, each holding
+// children named Key and Value
+func (d *clixmlDecoder) decodeEntries() ([]PSDictEntry, error) {
+	entries := []PSDictEntry{}
+	for {
+		en, err := d.nextChild()
+		if err != nil {
+			return nil, err
+		}
+		if en == nil {
+			return entries, nil
+		}
+
+		var entry PSDictEntry
+		for {
+			child, err := d.nextChild()
+			if err != nil {
+				return nil, err
+			}
+			if child == nil {
+				break
+			}
+			value, err := d.decodeValue(*child)
+			if err != nil {
+				return nil, err
+			}
+			if clixmlAttr(*child, "N") == "Key" {
+				entry.Key = value
+			} else {
+				entry.Value = value
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func clixmlAttr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// parseCLIXMLPrimitive converts the text of a primitive element. Values
+// that do not parse are kept as strings rather than failing the document.
+func parseCLIXMLPrimitive(tag, text string) interface{} {
+	var (
+		value interface{}
+		err   error
+	)
+	switch tag {
+	case "S":
+		return decodeCLIXMLString(text)
+	case "C":
+		var n uint64
+		n, err = strconv.ParseUint(text, 10, 16)
+		value = Char(n)
+	case "B":
+		value, err = strconv.ParseBool(text)
+	case "DT":
+		value, err = parseCLIXMLDateTime(text)
+	case "TS":
+		value, err = parseCLIXMLDuration(text)
+	case "By":
+		var n uint64
+		n, err = strconv.ParseUint(text, 10, 8)
+		value = uint8(n)
+	case "SB":
+		var n int64
+		n, err = strconv.ParseInt(text, 10, 8)
+		value = int8(n)
+	case "U16":
+		var n uint64
+		n, err = strconv.ParseUint(text, 10, 16)
+		value = uint16(n)
+	case "I16":
+		var n int64
+		n, err = strconv.ParseInt(text, 10, 16)
+		value = int16(n)
+	case "U32":
+		var n uint64
+		n, err = strconv.ParseUint(text, 10, 32)
+		value = uint32(n)
+	case "I32":
+		var n int64
+		n, err = strconv.ParseInt(text, 10, 32)
+		value = int32(n)
+	case "U64":
+		value, err = strconv.ParseUint(text, 10, 64)
+	case "I64":
+		value, err = strconv.ParseInt(text, 10, 64)
+	case "Sg":
+		var f float64
+		f, err = parseCLIXMLFloat(text, 32)
+		value = float32(f)
+	case "Db":
+		value, err = parseCLIXMLFloat(text, 64)
+	case "D":
+		return Decimal(text)
+	case "BA":
+		value, err = base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	case "G", "Version":
+		return text
+	case "URI":
+		value, err = url.Parse(decodeCLIXMLString(text))
+	case "SS":
+		return SecureString(text)
+	case "SBK":
+		return ScriptBlock(decodeCLIXMLString(text))
+	case "XD":
+		return XMLDocument(decodeCLIXMLString(text))
+	default:
+		DebugLog("CLIXML: unknown element <%s>, keeping text", tag)
+		return decodeCLIXMLString(text)
+	}
+	if err != nil {
+		DebugLog("CLIXML: invalid <%s> value %q: %v", tag, text, err)
+		return text
+	}
+	return value
+}
+
+// parseCLIXMLFloat parses a float, including .NET's names for infinities
+func parseCLIXMLFloat(text string, bitSize int) (float64, error) {
+	switch text {
+	case "INF":
+		text = "+Inf"
+	case "-INF":
+		text = "-Inf"
+	}
+	return strconv.ParseFloat(text, bitSize)
+}
+
+// parseCLIXMLDateTime parses an xs:dateTime, with or without an offset
+func parseCLIXMLDateTime(text string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, text); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05.999999999", text, time.Local)
+}
+
+var clixmlDurationRegex = regexp.MustCompile(`^(-)?P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseCLIXMLDuration parses an xs:duration such as "P1DT2H3M4.5S"
+func parseCLIXMLDuration(text string) (time.Duration, error) {
+	m := clixmlDurationRegex.FindStringSubmatch(text)
+	if m == nil || text == "P" || strings.HasSuffix(text, "T") {
+		return 0, fmt.Errorf("invalid duration %q", text)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute} {
+		if m[i+2] != "" {
+			n, err := strconv.ParseInt(m[i+2], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[5] != "" {
+		whole, frac, _ := strings.Cut(m[5], ".")
+		seconds, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(seconds) * time.Second
+		if frac != "" {
+			frac = (frac + "000000000")[:9]
+			nanos, _ := strconv.ParseInt(frac, 10, 64)
+			d += time.Duration(nanos)
+		}
+	}
+	if m[1] != "" {
+		d = -d
+	}
+	return d, nil
+}
+
+var clixmlEscapeRegex = regexp.MustCompile(`_x([0-9A-Fa-f]{4})_`)
+
+// decodeCLIXMLString decodes the _xHHHH_ escapes CLIXML uses for control
+// characters, surrogates and literal underscores
+func decodeCLIXMLString(text string) string {
+	if !strings.Contains(text, "_x") {
+		return text
+	}
+	matches := clixmlEscapeRegex.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(matches); i++ {
+		m := matches[i]
+		b.WriteString(text[last:m[0]])
+		code, _ := strconv.ParseUint(text[m[2]:m[3]], 16, 16)
+		r := rune(code)
+		// Characters outside the BMP are escaped as a surrogate pair
+		if utf16.IsSurrogate(r) && i+1 < len(matches) && matches[i+1][0] == m[1] {
+			next := matches[i+1]
+			low, _ := strconv.ParseUint(text[next[2]:next[3]], 16, 16)
+			if pair := utf16.DecodeRune(r, rune(low)); pair != unicode.ReplacementChar {
+				r = pair
+				m = next
+				i++
+			}
+		}
+		b.WriteRune(r)
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,300 @@
+package translation
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func readCLIXML(t *testing.T, name string) []CLIXMLRecord {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := DeserializeCLIXML(data)
+	if err != nil {
+		t.Fatalf("DeserializeCLIXML(%s): %v", name, err)
+	}
+	return records
+}
+
+func TestDeserializeCLIXML(t *testing.T) {
+	customObject := []string{"System.Management.Automation.PSCustomObject", "System.Object"}
+	objectArray := []string{"System.Object[]", "System.Array", "System.Object"}
+
+	tests := []struct {
+		file string
+		want []CLIXMLRecord
+	}{
+		{
+			file: "nested.clixml",
+			want: []CLIXMLRecord{{Value: &PSObject{
+				TypeNames: customObject,
+				Members: []PSProperty{
+					{Name: "Name", Value: "parent"},
+					{Name: "Count", Value: int32(2)},
+					{Name: "Child", Value: &PSObject{
+						TypeNames: customObject,
+						Members: []PSProperty{
+							{Name: "Name", Value: "child"},
+							{Name: "Enabled", Value: true},
+							{Name: "Missing", Value: nil},
+						},
+					}},
+				},
+			}}},
+		},
+		{
+			file: "strings.clixml",
+			want: []CLIXMLRecord{
+				{Value: "line one\r\nline two"},
+				{Value: "tab\tseparated"},
+				{Value: "literal _x000A_ escape"},
+				{Value: "snake_case_name"},
+				{Value: "emoji \U0001F600"},
+				{Value: `<tag attr="x"> & more`},
+				{Value: ""},
+				{Value: Char('A')},
+			},
+		},
+		{
+			file: "credential.clixml",
+			want: []CLIXMLRecord{{Value: &PSObject{
+				TypeNames: []string{"System.Management.Automation.PSCredential", "System.Object"},
+				ToString:  "System.Management.Automation.PSCredential",
+				Properties: []PSProperty{
+					{Name: "UserName", Value: `CONTOSO\admin`},
+					{Name: "Password", Value: SecureString("50004000730073007700300072006400")},
+				},
+			}}},
+		},
+		{
+			file: "collections.clixml",
+			want: []CLIXMLRecord{
+				{Value: &PSObject{
+					TypeNames: objectArray,
+					Kind:      ListCollection,
+					Items: []interface{}{
+						int32(1),
+						"two",
+						nil,
+						&PSObject{
+							TypeNames: []string{"System.Collections.Specialized.OrderedDictionary", "System.Object"},
+							Kind:      DictionaryCollection,
+							Entries: []PSDictEntry{
+								{Key: "Name", Value: "pwsh"},
+								{Key: int32(2), Value: 1.5},
+							},
+						},
+					},
+				}},
+				{Value: &PSObject{
+					TypeNames: []string{"System.Collections.Queue", "System.Object"},
+					Kind:      QueueCollection,
+					Items:     []interface{}{"a", "b"},
+				}},
+				{Value: &PSObject{
+					TypeNames: objectArray,
+					Kind:      ListCollection,
+					Items:     []interface{}{},
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got := readCLIXML(t, tt.file)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeserializeCLIXML() =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeserializeCLIXMLReferences(t *testing.T) {
+	records := readCLIXML(t, "references.clixml")
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	root := records[0].Value.(*PSObject)
+	first, _ := root.Property("First")
+	second, _ := root.Property("Second")
+	if first.(*PSObject) != second.(*PSObject) {
+		t.Errorf("Second is not the object First refers to")
+	}
+	if records[1].Value.(*PSObject) != first.(*PSObject) {
+		t.Errorf("top-level <Ref> is not the object First refers to")
+	}
+	if id, _ := first.(*PSObject).Property("Id"); id != int32(7) {
+		t.Errorf("First.Id = %#v, want 7", id)
+	}
+	if !reflect.DeepEqual(first.(*PSObject).TypeNames, root.TypeNames) {
+		t.Errorf("<TNRef> type names = %v, want %v", first.(*PSObject).TypeNames, root.TypeNames)
+	}
+}
+
+func TestDeserializeCLIXMLStreams(t *testing.T) {
+	records := readCLIXML(t, "streams.clixml")
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+
+	progress, ok := records[0].Value.(*PSObject)
+	if records[0].Stream != "progress" || !ok {
+		t.Fatalf("record 0 = %#v, want a progress object", records[0])
+	}
+	if id, _ := progress.Property("SourceId"); id != int64(1) {
+		t.Errorf("SourceId = %#v, want 1", id)
+	}
+	want := []CLIXMLRecord{
+		{Stream: "warning", Value: "careful\n"},
+		{Stream: "Error", Value: "Cannot find path\r\n"},
+	}
+	if !reflect.DeepEqual(records[1:], want) {
+		t.Errorf("records = %#v, want %#v", records[1:], want)
+	}
+}
+
+func TestDeserializeCLIXMLDateTime(t *testing.T) {
+	records := readCLIXML(t, "datetime.clixml")
+
+	utc := time.Date(2024, 3, 5, 13, 30, 15, 0, time.UTC)
+	tests := []struct {
+		want       interface{}
+		wantOffset int // Seconds east of UTC, for times
+	}{
+		{want: utc.Add(123456700 * time.Nanosecond), wantOffset: 3600},
+		{want: utc, wantOffset: 0},
+		{want: time.Date(2024, 3, 5, 14, 30, 15, 0, time.Local)},
+		{want: 26*time.Hour + 3*time.Minute + 4500*time.Millisecond},
+		{want: -100 * time.Nanosecond},
+	}
+	if len(records) != len(tests) {
+		t.Fatalf("got %d records, want %d", len(records), len(tests))
+	}
+
+	for i, tt := range tests {
+		got := records[i].Value
+		want, isTime := tt.want.(time.Time)
+		if !isTime {
+			if got != tt.want {
+				t.Errorf("record %d = %#v, want %#v", i, got, tt.want)
+			}
+			continue
+		}
+		gotTime, ok := got.(time.Time)
+		if !ok || !gotTime.Equal(want) {
+			t.Errorf("record %d = %v, want %v", i, got, want)
+			continue
+		}
+		if want.Location() == time.Local {
+			if gotTime.Location() != time.Local {
+				t.Errorf("record %d is in %v, want local time", i, gotTime.Location())
+			}
+		} else if _, offset := gotTime.Zone(); offset != tt.wantOffset {
+			t.Errorf("record %d offset = %d, want %d", i, offset, tt.wantOffset)
+		}
+	}
+}
+
+func TestDeserializeCLIXMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"not xml", "Get-ChildItem"},
+		{"wrong root", `<Obj RefId="0"><S>x</S></Obj>`},
+		{"truncated", `<Objs Version="1.1.0.1"><Obj RefId="0"><MS><S N="A">x</S>`},
+		{"unknown reference", `<Objs Version="1.1.0.1"><Ref RefId="4" /></Objs>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if records, err := DeserializeCLIXML([]byte(tt.data)); err == nil {
+				t.Errorf("DeserializeCLIXML() = %#v, want an error", records)
+			}
+		})
+	}
+}
+
+func TestParseCLIXMLPrimitive(t *testing.T) {
+	tests := []struct {
+		tag  string
+		text string
+		want interface{}
+	}{
+		{"I32", "-5", int32(-5)},
+		{"I64", "9000000000", int64(9000000000)},
+		{"U16", "65535", uint16(65535)},
+		{"By", "255", uint8(255)},
+		{"SB", "-128", int8(-128)},
+		{"B", "false", false},
+		{"Db", "INF", math.Inf(1)},
+		{"Sg", "0.5", float32(0.5)},
+		{"D", "79228162514264337593543950335", Decimal("79228162514264337593543950335")},
+		{"BA", "AQID", []byte{1, 2, 3}},
+		{"G", "0d5e2a4c-3b1f-4c8e-9a7d-2f6b8e1c4a90", "0d5e2a4c-3b1f-4c8e-9a7d-2f6b8e1c4a90"},
+		{"Version", "7.4.1", "7.4.1"},
+		{"SBK", "Get-Item_x0020_x", ScriptBlock("Get-Item x")},
+		{"I32", "not a number", "not a number"},
+		{"Unknown", "kept_x000A_", "kept\n"},
+	}
+	for _, tt := range tests {
+		got := parseCLIXMLPrimitive(tt.tag, tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCLIXMLPrimitive(%q, %q) = %#v, want %#v", tt.tag, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseCLIXMLDuration(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{text: "PT0S", want: 0},
+		{text: "PT1.5S", want: 1500 * time.Millisecond},
+		{text: "P2D", want: 48 * time.Hour},
+		{text: "PT1H30M", want: 90 * time.Minute},
+		{text: "-P1DT1S", want: -(24*time.Hour + time.Second)},
+		{text: "P", wantErr: true},
+		{text: "P1DT", wantErr: true},
+		{text: "1:00:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCLIXMLDuration(tt.text)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseCLIXMLDuration(%q) = %v, %v; want %v, error %v", tt.text, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFormatCLIXMLValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{true, "True"},
+		{Char('x'), "x"},
+		{int32(42), "42"},
+		{26*time.Hour + 3*time.Minute + 4*time.Second, "1.02:03:04"},
+		{-1500 * time.Millisecond, "-00:00:01.5000000"},
+		{[]byte{1, 2}, "Byte[2]"},
+		{SecureString("0100"), "System.Security.SecureString"},
+		{&PSObject{ToString: "C:\\"}, "C:\\"},
+		{&PSObject{Value: int32(3)}, "3"},
+	}
+	for _, tt := range tests {
+		if got := FormatCLIXMLValue(tt.value); got != tt.want {
+			t.Errorf("FormatCLIXMLValue(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
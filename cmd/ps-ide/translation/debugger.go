@@ -0,0 +1,311 @@
+package translation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BreakpointKind identifies what a breakpoint triggers on
+type BreakpointKind int
+
+const (
+	LineBreakpoint BreakpointKind = iota
+	VariableBreakpoint
+	CommandBreakpoint
+)
+
+// String returns the breakpoint kind as PowerShell names it
+func (k BreakpointKind) String() string {
+	switch k {
+	case VariableBreakpoint:
+		return "Variable"
+	case CommandBreakpoint:
+		return "Command"
+	default:
+		return "Line"
+	}
+}
+
+// Breakpoint is a debugger breakpoint to be set with Set-PSBreakpoint
+type Breakpoint struct {
+	Kind     BreakpointKind `json:"kind"`
+	Script   string         `json:"script,omitempty"`   // Script path (line breakpoints; optional otherwise)
+	Line     int            `json:"line,omitempty"`     // 1-based line (line breakpoints)
+	Variable string         `json:"variable,omitempty"` // Variable name without $ (variable breakpoints)
+	Mode     string         `json:"mode,omitempty"`     // Read, Write or ReadWrite (variable breakpoints)
+	Command  string         `json:"command,omitempty"`  // Command name (command breakpoints)
+}
+
+// SetCommand returns the Set-PSBreakpoint invocation for this breakpoint
+func (bp Breakpoint) SetCommand() string {
+	var args []string
+	switch bp.Kind {
+	case LineBreakpoint:
+		args = append(args, "-Script", QuotePS(bp.Script), "-Line", strconv.Itoa(bp.Line))
+	case VariableBreakpoint:
+		args = append(args, "-Variable", QuotePS(strings.TrimPrefix(bp.Variable, "$")))
+		mode := bp.Mode
+		if mode == "" {
+			mode = "Write"
+		}
+		args = append(args, "-Mode", mode)
+		if bp.Script != "" {
+			args = append(args, "-Script", QuotePS(bp.Script))
+		}
+	case CommandBreakpoint:
+		args = append(args, "-Command", QuotePS(bp.Command))
+		if bp.Script != "" {
+			args = append(args, "-Script", QuotePS(bp.Script))
+		}
+	}
+	return "Set-PSBreakpoint " + strings.Join(args, " ")
+}
+
+// Description returns a short human readable description
+func (bp Breakpoint) Description() string {
+	switch bp.Kind {
+	case VariableBreakpoint:
+		mode := bp.Mode
+		if mode == "" {
+			mode = "Write"
+		}
+		return fmt.Sprintf("$%s (%s)", strings.TrimPrefix(bp.Variable, "$"), mode)
+	case CommandBreakpoint:
+		return bp.Command
+	default:
+		return fmt.Sprintf("%s:%d", bp.Script, bp.Line)
+	}
+}
+
+// StackFrame is one entry of Get-PSCallStack
+type StackFrame struct {
+	FunctionName string
+	ScriptName   string
+	Line         int
+	Location     string
+}
+
+// DebugStop describes where the debugger is stopped
+type DebugStop struct {
+	ScriptName string
+	Line       int // 1-based
+	Column     int // 1-based
+}
+
+// DebugAction is a debugger command typed at the [DBG] prompt
+type DebugAction string
+
+const (
+	DebugStepInto DebugAction = "s"
+	DebugStepOver DebugAction = "v"
+	DebugStepOut  DebugAction = "o"
+	DebugContinue DebugAction = "c"
+	DebugQuit     DebugAction = "q"
+)
+
+const (
+	queryBeginMarker = "<<PSIDE-QUERY-BEGIN>>"
+	queryEndMarker   = "<<PSIDE-QUERY-END>>"
+)
+
+// debugProbeScript asks the session where the debugger is stopped.
+// Commands typed at the [DBG] prompt run in the breakpoint's scope, so
+// $PSDebugContext is only set while stopped. It also removes the one-shot
+// run-to-cursor breakpoint.
+const debugProbeScript = `& { if ($PSDebugContext) { $i = $PSDebugContext.InvocationInfo; ` +
+	`if ($global:__psideRunToCursor) { Remove-PSBreakpoint -Breakpoint $global:__psideRunToCursor; Remove-Variable -Name __psideRunToCursor -Scope Global }; ` +
+	`'STOPPED|{0}|{1}|{2}' -f $i.ScriptName, $i.ScriptLineNumber, $i.OffsetInLine } else { 'RUNNING' } }`
+
+// debuggerResumeCommands are the [DBG] prompt commands that leave a stop
+var debuggerResumeCommands = map[string]bool{
+	"s": true, "stepinto": true, "v": true, "stepover": true, "o": true, "stepout": true,
+	"c": true, "continue": true, "q": true, "quit": true, "d": true, "detach": true,
+}
+
+// debugState tracks the debugger for a TranslationLayer
+type debugState struct {
+	mutex    sync.Mutex
+	stopped  *DebugStop
+	prompted bool   // A [DBG] prompt appeared that no probe has looked at yet
+	onPrompt func() // Called from the pipe reader when the debugger stops
+}
+
+// SetDebugStopHandler sets a function called, from the pipe reader, when
+// a [DBG] prompt shows the debugger stopped while it was not known to be.
+// The handler should call RefreshDebugState to find out where.
+func (tl *TranslationLayer) SetDebugStopHandler(handler func()) {
+	tl.debug.mutex.Lock()
+	defer tl.debug.mutex.Unlock()
+	tl.debug.onPrompt = handler
+}
+
+// debugPromptSeen is called for each [DBG] prompt the session prints. The
+// prompts of commands run while stopped are not reported again.
+func (tl *TranslationLayer) debugPromptSeen() {
+	tl.debug.mutex.Lock()
+	if tl.debug.stopped != nil || tl.debug.prompted {
+		tl.debug.mutex.Unlock()
+		return
+	}
+	tl.debug.prompted = true
+	handler := tl.debug.onPrompt
+	tl.debug.mutex.Unlock()
+	if handler != nil {
+		handler()
+	}
+}
+
+// resumeDebugger forgets the stop before a command that leaves it is sent;
+// the next [DBG] prompt reports the next stop, if any
+func (tl *TranslationLayer) resumeDebugger(command string) {
+	tl.debug.mutex.Lock()
+	defer tl.debug.mutex.Unlock()
+	if debuggerResumeCommands[strings.ToLower(strings.TrimSpace(command))] {
+		tl.debug.stopped = nil
+		tl.debug.prompted = false
+	}
+}
+
+// SyncBreakpoints replaces all breakpoints in the session with bps
+func (tl *TranslationLayer) SyncBreakpoints(bps []Breakpoint) error {
+	commands := []string{"Get-PSBreakpoint | Remove-PSBreakpoint"}
+	for _, bp := range bps {
+		commands = append(commands, bp.SetCommand()+" | Out-Null")
+	}
+	_, err := tl.pipes.SendCommand(strings.Join(commands, "; "), Internal)
+	return err
+}
+
+// DebugStep sends a stepping command while stopped in the debugger and
+// returns the output produced until the next stop or completion
+func (tl *TranslationLayer) DebugStep(action DebugAction) (string, error) {
+	if !tl.IsDebugStopped() {
+		return "", fmt.Errorf("debugger is not stopped")
+	}
+	return tl.executeTracked(string(action), string(action), Interactive)
+}
+
+// RunToLine sets a one-shot line breakpoint and continues. The breakpoint
+// is removed the next time the debugger stops.
+func (tl *TranslationLayer) RunToLine(script string, line int) (string, error) {
+	if err := tl.PrepareRunToLine(script, line); err != nil {
+		return "", err
+	}
+	return tl.DebugStep(DebugContinue)
+}
+
+// PrepareRunToLine sets a one-shot line breakpoint before a script is run
+func (tl *TranslationLayer) PrepareRunToLine(script string, line int) error {
+	bp := Breakpoint{Kind: LineBreakpoint, Script: script, Line: line}
+	_, err := tl.pipes.SendCommand("$global:__psideRunToCursor = "+bp.SetCommand(), Internal)
+	return err
+}
+
+// IsDebugStopped reports whether the session is stopped at a breakpoint
+func (tl *TranslationLayer) IsDebugStopped() bool {
+	tl.debug.mutex.Lock()
+	defer tl.debug.mutex.Unlock()
+	return tl.debug.stopped != nil
+}
+
+// DebugPromptPending reports whether a [DBG] prompt appeared since the
+// debugger state was last refreshed
+func (tl *TranslationLayer) DebugPromptPending() bool {
+	tl.debug.mutex.Lock()
+	defer tl.debug.mutex.Unlock()
+	return tl.debug.prompted
+}
+
+// GetDebugStop returns where the debugger is stopped, or nil
+func (tl *TranslationLayer) GetDebugStop() *DebugStop {
+	tl.debug.mutex.Lock()
+	defer tl.debug.mutex.Unlock()
+	if tl.debug.stopped == nil {
+		return nil
+	}
+	stop := *tl.debug.stopped
+	return &stop
+}
+
+// RefreshDebugState asks the session whether, and where, the debugger is
+// stopped
+func (tl *TranslationLayer) RefreshDebugState() (*DebugStop, error) {
+	result, err := tl.queryMarked(debugProbeScript)
+	if err != nil {
+		return nil, err
+	}
+
+	var stop *DebugStop
+	for _, line := range strings.Split(result, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "STOPPED|") {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		if len(parts) < 4 {
+			continue
+		}
+		stop = &DebugStop{ScriptName: parts[1]}
+		stop.Line, _ = strconv.Atoi(parts[2])
+		stop.Column, _ = strconv.Atoi(parts[3])
+	}
+
+	tl.debug.mutex.Lock()
+	tl.debug.stopped = stop
+	tl.debug.prompted = false
+	tl.debug.mutex.Unlock()
+
+	return stop, nil
+}
+
+// GetCallStack returns Get-PSCallStack while stopped in the debugger
+func (tl *TranslationLayer) GetCallStack() ([]StackFrame, error) {
+	result, err := tl.queryMarked(
+		`Get-PSCallStack | Select-Object -Skip 1 | ForEach-Object { '{0}|{1}|{2}|{3}' -f $_.FunctionName, $_.ScriptName, $_.ScriptLineNumber, $_.Location }`)
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []StackFrame
+	for _, line := range strings.Split(result, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "|", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		frame := StackFrame{FunctionName: parts[0], ScriptName: parts[1], Location: parts[3]}
+		frame.Line, _ = strconv.Atoi(parts[2])
+		// Skip the interactive prompt frame
+		if frame.ScriptName == "" && frame.FunctionName == "<ScriptBlock>" && frame.Line <= 1 {
+			continue
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// queryMarked runs script and returns only the lines it printed, using
+// marker lines to separate them from prompts and echoes
+func (tl *TranslationLayer) queryMarked(script string) (string, error) {
+	command := fmt.Sprintf("'%s'; %s; '%s'", queryBeginMarker, script, queryEndMarker)
+	output, err := tl.pipes.SendCommand(command, Internal)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	inside := false
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == queryBeginMarker:
+			inside = true
+		case trimmed == queryEndMarker:
+			inside = false
+		case inside:
+			lines = append(lines, trimmed)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
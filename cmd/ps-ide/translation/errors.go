@@ -0,0 +1,114 @@
+package translation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxErrorRecords limits how many new errors are reported per command
+const maxErrorRecords = 20
+
+// ErrorRecordInfo is the structured form of a PowerShell ErrorRecord
+type ErrorRecordInfo struct {
+	Message               string `json:"message"`
+	FullyQualifiedErrorID string `json:"fqid"`
+	Category              string `json:"category"` // CategoryInfo.ToString()
+	TargetObject          string `json:"target"`
+	ExceptionType         string `json:"exceptionType"`
+	ScriptName            string `json:"script"` // Empty for errors at the prompt
+	Line                  int    `json:"line"`   // 1-based, 0 if unknown
+	Column                int    `json:"column"` // 1-based, 0 if unknown
+	LineText              string `json:"lineText"`
+	ScriptStackTrace      string `json:"stack"`
+}
+
+// CaretLine returns a line that points at Column under LineText, keeping
+// tabs so the caret lines up
+func (e ErrorRecordInfo) CaretLine() string {
+	if e.Column <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, r := range []rune(e.LineText) {
+		if i >= e.Column-1 {
+			break
+		}
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	b.WriteRune('^')
+	return b.String()
+}
+
+// Details returns the error as plain text, for copying
+func (e ErrorRecordInfo) Details() string {
+	var b strings.Builder
+	b.WriteString(e.Message + "\n")
+	if e.ExceptionType != "" {
+		b.WriteString("Exception: " + e.ExceptionType + "\n")
+	}
+	b.WriteString("FullyQualifiedErrorId: " + e.FullyQualifiedErrorID + "\n")
+	b.WriteString("CategoryInfo: " + e.Category + "\n")
+	if e.TargetObject != "" {
+		b.WriteString("TargetObject: " + e.TargetObject + "\n")
+	}
+	if e.Line > 0 {
+		location := e.ScriptName
+		if location == "" {
+			location = "<prompt>"
+		}
+		b.WriteString(fmt.Sprintf("At %s:%d char:%d\n", location, e.Line, e.Column))
+		if e.LineText != "" {
+			b.WriteString("+ " + strings.TrimRight(e.LineText, "\r\n") + "\n")
+			if caret := e.CaretLine(); caret != "" {
+				b.WriteString("+ " + caret + "\n")
+			}
+		}
+	}
+	if e.ScriptStackTrace != "" {
+		b.WriteString("ScriptStackTrace:\n" + e.ScriptStackTrace + "\n")
+	}
+	return b.String()
+}
+
+// GetNewErrors returns the errors added to $Error since the last call,
+// oldest first. Seen errors are marked with a note property so they are
+// not reported again.
+func (tl *TranslationLayer) GetNewErrors() ([]ErrorRecordInfo, error) {
+	script := `$__psideNew = @(foreach ($__psideE in @($global:Error)) { ` +
+		`if ($__psideE.PSObject.Properties['__psideSeen']) { break }; ` +
+		`Add-Member -InputObject $__psideE -NotePropertyName __psideSeen -NotePropertyValue $true -Force; $__psideE }); ` +
+		`[array]::Reverse($__psideNew); ` +
+		`foreach ($__psideE in @($__psideNew | Select-Object -Last ` + strconv.Itoa(maxErrorRecords) + `)) { ` +
+		`$__psideR = if ($__psideE -is [System.Management.Automation.ErrorRecord]) { $__psideE } elseif ($__psideE.ErrorRecord) { $__psideE.ErrorRecord } else { $null }; ` +
+		`if ($null -eq $__psideR) { continue }; ` +
+		`$__psideI = $__psideR.InvocationInfo; ` +
+		`@{ message = "$($__psideR.Exception.Message)"; fqid = "$($__psideR.FullyQualifiedErrorId)"; category = "$($__psideR.CategoryInfo)"; ` +
+		`target = "$($__psideR.TargetObject)"; exceptionType = $__psideR.Exception.GetType().FullName; ` +
+		`script = "$($__psideI.ScriptName)"; line = [int]$__psideI.ScriptLineNumber; column = [int]$__psideI.OffsetInLine; ` +
+		`lineText = "$($__psideI.Line)"; stack = "$($__psideR.ScriptStackTrace)" } | ConvertTo-Json -Compress }`
+
+	output, err := tl.queryMarked(script)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ErrorRecordInfo
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var record ErrorRecordInfo
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			DebugLog("Failed to parse error record %q: %v", line, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,131 @@
+package translation
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultExecutable is the PowerShell executable used when none is configured
+const DefaultExecutable = "pwsh"
+
+// PowerShellInstall describes a pwsh binary found on this machine
+type PowerShellInstall struct {
+	Path    string // Absolute path to the executable
+	Version string // $PSVersionTable.PSVersion, empty if it could not be queried
+	Source  string // Where it was found: PATH, opt, snap or dotnet
+}
+
+// Label returns a human readable description for menus
+func (pi PowerShellInstall) Label() string {
+	version := pi.Version
+	if version == "" {
+		version = "unknown version"
+	}
+	return "PowerShell " + version + " (" + pi.Path + ")"
+}
+
+// DiscoverPowerShell finds installed pwsh binaries on PATH, under
+// /opt/microsoft/powershell, in snap and as a dotnet global tool.
+// Each binary is asked for its version; results are de-duplicated by
+// resolved path and sorted by path.
+func DiscoverPowerShell() []PowerShellInstall {
+	var candidates []PowerShellInstall
+
+	for _, name := range []string{"pwsh", "pwsh-preview", "pwsh-lts"} {
+		if path, err := exec.LookPath(name); err == nil {
+			candidates = append(candidates, PowerShellInstall{Path: path, Source: "PATH"})
+		}
+	}
+
+	if matches, err := filepath.Glob("/opt/microsoft/powershell/*/pwsh"); err == nil {
+		for _, path := range matches {
+			candidates = append(candidates, PowerShellInstall{Path: path, Source: "opt"})
+		}
+	}
+
+	if matches, err := filepath.Glob("/snap/bin/pwsh*"); err == nil {
+		for _, path := range matches {
+			candidates = append(candidates, PowerShellInstall{Path: path, Source: "snap"})
+		}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, PowerShellInstall{
+			Path:   filepath.Join(home, ".dotnet", "tools", "pwsh"),
+			Source: "dotnet",
+		})
+	}
+
+	seen := make(map[string]bool)
+	var installs []PowerShellInstall
+	for _, c := range candidates {
+		info, err := os.Stat(c.Path)
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			continue
+		}
+
+		key := CanonicalExecutable(c.Path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		c.Version = QueryExecutableVersion(c.Path)
+		installs = append(installs, c)
+	}
+
+	sort.Slice(installs, func(i, j int) bool {
+		return installs[i].Path < installs[j].Path
+	})
+
+	DebugLog("Discovered %d PowerShell installation(s)", len(installs))
+	return installs
+}
+
+// QueryExecutableVersion runs the given pwsh once and returns its
+// $PSVersionTable.PSVersion, or an empty string on failure
+func QueryExecutableVersion(path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path,
+		"-NoLogo", "-NoProfile", "-NonInteractive",
+		"-Command", "$PSVersionTable.PSVersion.ToString()").Output()
+	if err != nil {
+		DebugLog("Failed to query version of %s: %v", path, err)
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// CanonicalExecutable looks a bare name such as pwsh up on PATH and
+// resolves symlinks, so different spellings of one binary compare equal
+func CanonicalExecutable(path string) string {
+	if found, err := exec.LookPath(path); err == nil {
+		path = found
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+// SameExecutable reports whether two paths name the same binary, e.g. the
+// default pwsh and the /usr/bin/pwsh it is found as
+func SameExecutable(a, b string) bool {
+	return a == b || CanonicalExecutable(a) == CanonicalExecutable(b)
+}
+
+// ResolveExecutable returns path, or DefaultExecutable if path is empty
+func ResolveExecutable(path string) string {
+	if strings.TrimSpace(path) == "" {
+		return DefaultExecutable
+	}
+	return path
+}
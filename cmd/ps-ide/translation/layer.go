@@ -2,6 +2,7 @@ package translation
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -17,12 +18,32 @@ type TranslationLayer struct {
 	mutex       sync.Mutex
 	isExecuting bool
 	stopChan    chan bool
+
+	// Process of a script running in RunIsolated mode, if any
+	isolatedProcess *os.Process
+
+	debug debugState
+
+	objectCapture bool // Out-Default proxy wanted, see SetObjectCapture
+	sessionReady  bool // initializeSession has finished
+
+	// Transcript recording this session's commands, if any
+	transcript *Transcript
+
+	// Receives command output as it is read, see SetOutputHandler
+	onOutput func(text string)
 }
 
-// New creates a new Translation Layer instance
+// New creates a new Translation Layer instance using the default pwsh
 func New() (*TranslationLayer, error) {
+	return NewWithExecutable(DefaultExecutable)
+}
+
+// NewWithExecutable creates a new Translation Layer instance that runs
+// the given PowerShell executable
+func NewWithExecutable(executable string) (*TranslationLayer, error) {
 	tl := &TranslationLayer{
-		pipes:       NewPipeCommunicator(),
+		pipes:       NewPipeCommunicator(executable),
 		queue:       NewCommandQueue(1000), // Max 1000 history entries
 		session:     NewSessionStateManager(),
 		prompt:      NewPromptGenerator(),
@@ -31,6 +52,8 @@ func New() (*TranslationLayer, error) {
 		stopChan:    make(chan bool, 1),
 	}
 
+	tl.pipes.onDebugPrompt = tl.debugPromptSeen
+
 	// Start the pipe communicator
 	if err := tl.pipes.Start(); err != nil {
 		return nil, fmt.Errorf("failed to start pipe communicator: %w", err)
@@ -56,6 +79,39 @@ func (tl *TranslationLayer) initializeSession() {
 	if result, err := tl.pipes.QueryState("(Get-Location).Path"); err == nil {
 		tl.session.SetCurrentDirectory(strings.TrimSpace(result))
 	}
+
+	// Keep output objects for the object inspector, if asked to already
+	tl.mutex.Lock()
+	tl.sessionReady = true
+	capture := tl.objectCapture
+	tl.mutex.Unlock()
+	if capture {
+		if _, err := tl.queryMarked(objectCaptureScript); err != nil {
+			DebugLog("Failed to enable object capture: %v", err)
+		}
+	}
+}
+
+// SetOutputHandler sets a function called, from the goroutine running a
+// command, with its output as it is read: one or more complete lines, each
+// ending in a newline. The output is still returned once the command
+// finishes. Internal queries are not reported.
+func (tl *TranslationLayer) SetOutputHandler(handler func(text string)) {
+	tl.mutex.Lock()
+	tl.onOutput = handler
+	tl.mutex.Unlock()
+}
+
+// outputLineHandler returns the output handler as a SendCommandStreaming
+// line callback, or nil if there is none
+func (tl *TranslationLayer) outputLineHandler() func(line string) {
+	tl.mutex.Lock()
+	handler := tl.onOutput
+	tl.mutex.Unlock()
+	if handler == nil {
+		return nil
+	}
+	return func(line string) { handler(line + "\n") }
 }
 
 // ExecuteCommand executes a user-typed command and returns the output
@@ -79,11 +135,13 @@ func (tl *TranslationLayer) ExecuteCommand(cmd string) (string, error) {
 		return "", err
 	}
 
-	// Record start time
+	// Record start time and the prompt the command runs at
+	prompt := tl.GetPrompt()
 	startTime := time.Now()
 
 	// Execute command
-	result, err := tl.pipes.SendCommand(cmd, Interactive)
+	tl.resumeDebugger(cmd)
+	result, err := tl.pipes.SendCommandStreaming(cmd, Interactive, tl.outputLineHandler())
 
 	// Record execution time and result
 	duration := time.Since(startTime)
@@ -94,6 +152,7 @@ func (tl *TranslationLayer) ExecuteCommand(cmd string) (string, error) {
 	}
 
 	tl.queue.UpdateLastEntry(duration, success, exitCode)
+	tl.recordTranscript(prompt, result, err)
 
 	// Update session state (synchronous to ensure prompt shows correct directory)
 	tl.updateDirectory()
@@ -122,12 +181,13 @@ func (tl *TranslationLayer) ExecuteScript(path string) (string, error) {
 	}()
 
 	// Add to history
-	scriptCmd := fmt.Sprintf("& '%s'", path)
+	scriptCmd := "& " + QuotePS(path)
 	if err := tl.queue.Add(scriptCmd, Script); err != nil {
 		return "", err
 	}
 
-	// Record start time
+	// Record start time and the prompt the command runs at
+	prompt := tl.GetPrompt()
 	startTime := time.Now()
 
 	// Execute script
@@ -142,6 +202,7 @@ func (tl *TranslationLayer) ExecuteScript(path string) (string, error) {
 	}
 
 	tl.queue.UpdateLastEntry(duration, success, exitCode)
+	tl.recordTranscript(prompt, result, err)
 
 	// Update session state (synchronous to ensure prompt shows correct directory)
 	tl.updateDirectory()
@@ -174,7 +235,8 @@ func (tl *TranslationLayer) ExecuteSelection(code string) (string, error) {
 		return "", err
 	}
 
-	// Record start time
+	// Record start time and the prompt the command runs at
+	prompt := tl.GetPrompt()
 	startTime := time.Now()
 
 	// Execute selection
@@ -189,6 +251,7 @@ func (tl *TranslationLayer) ExecuteSelection(code string) (string, error) {
 	}
 
 	tl.queue.UpdateLastEntry(duration, success, exitCode)
+	tl.recordTranscript(prompt, result, err)
 
 	// Update session state (synchronous to ensure prompt shows correct directory)
 	tl.updateDirectory()
@@ -200,6 +263,13 @@ func (tl *TranslationLayer) ExecuteSelection(code string) (string, error) {
 	return result, nil
 }
 
+// ExecuteSelectionAt executes text selected in an editor so that error
+// positions, $MyInvocation and stack traces refer to fileName and to the
+// absolute line and column (1-based) where the selection starts
+func (tl *TranslationLayer) ExecuteSelectionAt(code, fileName string, line, column int) (string, error) {
+	return tl.executeTracked(code, BuildSelectionCommand(code, fileName, line, column), Selection)
+}
+
 // ParseOutput parses raw output using the parser
 func (tl *TranslationLayer) ParseOutput(rawOutput string) ([]PSOutput, error) {
 	return tl.parser.Parse([]byte(rawOutput))
@@ -223,7 +293,12 @@ func (tl *TranslationLayer) GetParser() *OutputParser {
 // GetPrompt returns the current prompt string
 func (tl *TranslationLayer) GetPrompt() string {
 	currentDir := tl.session.GetCurrentDirectory()
-	return tl.prompt.Generate(currentDir)
+	prompt := tl.prompt.Generate(currentDir)
+	if tl.IsDebugStopped() {
+		// Match PowerShell's nested debugger prompt
+		prompt = "[DBG]: " + strings.TrimSuffix(prompt, "> ") + ">> "
+	}
+	return prompt
 }
 
 // GetPromptANSI returns the prompt with ANSI color codes
@@ -251,6 +326,13 @@ func (tl *TranslationLayer) ResetHistoryIndex() {
 
 // StopExecution interrupts the current execution
 func (tl *TranslationLayer) StopExecution() error {
+	tl.mutex.Lock()
+	isolated := tl.isolatedProcess
+	tl.mutex.Unlock()
+	if isolated != nil {
+		return isolated.Kill()
+	}
+
 	return tl.pipes.SendInterrupt()
 }
 
@@ -308,6 +390,11 @@ func (tl *TranslationLayer) GetPSVersion() string {
 	return tl.session.GetPSVersion()
 }
 
+// GetExecutable returns the path of the PowerShell binary backing this layer
+func (tl *TranslationLayer) GetExecutable() string {
+	return tl.pipes.Executable()
+}
+
 // GetVariables returns all tracked variables
 func (tl *TranslationLayer) GetVariables() map[string]VariableInfo {
 	return tl.session.GetAllVariables()
@@ -26,7 +26,8 @@ func (tl *TranslationLayer) ExecuteCommandWithOutput(cmd string) (string, error)
 		return "", err
 	}
 
-	// Record start time
+	// Record start time and the prompt the command runs at
+	prompt := tl.GetPrompt()
 	startTime := time.Now()
 
 	// Execute command
@@ -41,6 +42,7 @@ func (tl *TranslationLayer) ExecuteCommandWithOutput(cmd string) (string, error)
 	}
 
 	tl.queue.UpdateLastEntry(duration, success, exitCode)
+	tl.recordTranscript(prompt, result, err)
 
 	// Update session state (query current directory)
 	go tl.updateDirectory()
@@ -0,0 +1,163 @@
+package translation
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxCapturedObjects  = 1000   // Objects kept per command
+	maxCaptureSummaries = 200    // Objects listed by TakeObjectCapture
+	keptCaptures        = 20     // Commands whose objects stay inspectable
+	maxSerializedObject = 400000 // Characters of CLIXML before falling back to depth 1
+)
+
+// objectCaptureScript replaces Out-Default with a proxy that keeps the
+// objects each pipeline writes, other than strings, value types, error
+// records and formatting directives, so they can be inspected afterwards.
+// While it is installed, Get-Command Out-Default finds the proxy function
+// rather than the cmdlet.
+var objectCaptureScript = `$global:__psideCaptures = @{}; $global:__psideCaptureId = 0; $global:__psideLastCapture = 0; ` +
+	`function global:Out-Default { [CmdletBinding()] param([switch]$Transcript, [Parameter(ValueFromPipeline = $true)][psobject]$InputObject) ` +
+	`begin { $__psideList = [System.Collections.Generic.List[object]]::new(); ` +
+	`$__psidePipe = { Microsoft.PowerShell.Core\Out-Default @PSBoundParameters }.GetSteppablePipeline($MyInvocation.CommandOrigin); $__psidePipe.Begin($PSCmdlet) } ` +
+	`process { if ($null -ne $InputObject -and $__psideList.Count -lt ` + strconv.Itoa(maxCapturedObjects) + `) { $__psideB = $InputObject.PSObject.BaseObject; ` +
+	`if (-not ($__psideB -is [string] -or $__psideB -is [ValueType] -or $__psideB -is [System.Management.Automation.ErrorRecord] -or ` +
+	`$__psideB.GetType().FullName -like 'Microsoft.PowerShell.Commands.Internal.Format.*')) { $__psideList.Add($InputObject) } }; $__psidePipe.Process($_) } ` +
+	`end { $__psidePipe.End(); if ($__psideList.Count) { $global:__psideCaptureId++; $global:__psideCaptures[$global:__psideCaptureId] = $__psideList; ` +
+	`$global:__psideLastCapture = $global:__psideCaptureId; $global:__psideCaptures.Remove($global:__psideCaptureId - ` + strconv.Itoa(keptCaptures) + `) } } }`
+
+// objectCaptureRemoveScript restores the Out-Default cmdlet and drops the
+// objects kept so far
+const objectCaptureRemoveScript = `Remove-Item function:global:Out-Default -ErrorAction Ignore; ` +
+	`Remove-Variable __psideCaptures, __psideCaptureId, __psideLastCapture -Scope Global -ErrorAction Ignore`
+
+// ObjectCapture lists the objects a command wrote to the output stream
+type ObjectCapture struct {
+	ID      int
+	Count   int
+	Objects []CapturedObject // At most maxCaptureSummaries
+}
+
+// CapturedObject summarises a captured object without deserializing it
+type CapturedObject struct {
+	TypeName string
+	Text     string // ToString() of the object
+}
+
+// SetObjectCapture installs or removes the Out-Default proxy. A session
+// that is still starting installs it once it is ready.
+func (tl *TranslationLayer) SetObjectCapture(enabled bool) error {
+	tl.mutex.Lock()
+	tl.objectCapture = enabled
+	ready := tl.sessionReady
+	tl.mutex.Unlock()
+	if !ready {
+		return nil
+	}
+
+	script := objectCaptureRemoveScript
+	if enabled {
+		script = objectCaptureScript
+	}
+	_, err := tl.queryMarked(script)
+	return err
+}
+
+// ObjectCaptureEnabled reports whether output objects are being kept
+func (tl *TranslationLayer) ObjectCaptureEnabled() bool {
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+	return tl.objectCapture
+}
+
+// TakeObjectCapture returns the objects written by the last command, or
+// nil if it wrote none or capture is off. Must be called before any other
+// query that could write objects.
+func (tl *TranslationLayer) TakeObjectCapture() (*ObjectCapture, error) {
+	if !tl.ObjectCaptureEnabled() {
+		return nil, nil
+	}
+	script := `& { $id = [int]$global:__psideLastCapture; $global:__psideLastCapture = 0; ` +
+		`$c = if ($global:__psideCaptures) { $global:__psideCaptures[$id] }; ` +
+		`if ($c) { "$id|$($c.Count)"; ` +
+		`foreach ($o in @($c | Select-Object -First ` + strconv.Itoa(maxCaptureSummaries) + `)) { ` +
+		`[Convert]::ToBase64String([Text.Encoding]::UTF8.GetBytes("$($o.PSObject.TypeNames[0])` + "`t" + `$o")) } } }`
+
+	output, err := tl.queryMarked(script)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, nil
+	}
+
+	idText, countText, ok := strings.Cut(lines[0], "|")
+	if !ok {
+		return nil, fmt.Errorf("unexpected capture output: %q", lines[0])
+	}
+	capture := &ObjectCapture{}
+	capture.ID, _ = strconv.Atoi(idText)
+	capture.Count, _ = strconv.Atoi(countText)
+	for _, line := range lines[1:] {
+		data, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			continue
+		}
+		typeName, text, _ := strings.Cut(string(data), "\t")
+		capture.Objects = append(capture.Objects, CapturedObject{TypeName: typeName, Text: text})
+	}
+	return capture, nil
+}
+
+// ReleaseObjectCaptures drops the objects kept for the given captures, so
+// the session no longer holds on to them
+func (tl *TranslationLayer) ReleaseObjectCaptures(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = strconv.Itoa(id)
+	}
+	script := `& { if ($global:__psideCaptures) { foreach ($id in @(` + strings.Join(list, ", ") + `)) { $global:__psideCaptures.Remove($id) } } }`
+	_, err := tl.queryMarked(script)
+	return err
+}
+
+// InspectObject serializes a captured object to the given depth and returns
+// it parsed, with the object graph in ObjectData. Large objects are
+// serialized to depth 1 instead.
+func (tl *TranslationLayer) InspectObject(captureID, index, depth int) (PSOutput, error) {
+	script := fmt.Sprintf(`& { $c = if ($global:__psideCaptures) { $global:__psideCaptures[%d] }; `+
+		`if ($c -and %d -lt $c.Count) { $o = $c[%d]; `+
+		`$x = [System.Management.Automation.PSSerializer]::Serialize($o, %d); `+
+		`if ($x.Length -gt %d) { $x = [System.Management.Automation.PSSerializer]::Serialize($o, 1) }; `+
+		`[Convert]::ToBase64String([Text.Encoding]::UTF8.GetBytes($x)) } }`,
+		captureID, index, index, depth, maxSerializedObject)
+
+	output, err := tl.queryMarked(script)
+	if err != nil {
+		return PSOutput{}, err
+	}
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return PSOutput{}, fmt.Errorf("object is no longer available")
+	}
+	data, err := base64.StdEncoding.DecodeString(output)
+	if err != nil {
+		return PSOutput{}, fmt.Errorf("invalid serialized object: %w", err)
+	}
+
+	outputs, err := tl.parser.Parse(data)
+	if err != nil {
+		return PSOutput{}, err
+	}
+	if len(outputs) == 0 || outputs[0].ObjectData == nil {
+		return PSOutput{}, fmt.Errorf("object could not be deserialized")
+	}
+	return outputs[0], nil
+}
@@ -1,7 +1,6 @@
 package translation
 
 import (
-	"encoding/xml"
 	"fmt"
 	"regexp"
 	"strings"
@@ -21,28 +20,6 @@ func NewOutputParser() *OutputParser {
 	}
 }
 
-// CLIXML structure definitions
-type CLIXMLObjs struct {
-	XMLName xml.Name    `xml:"Objs"`
-	Version string      `xml:"Version,attr"`
-	Objects []CLIXMLObj `xml:"Obj"`
-}
-
-type CLIXMLObj struct {
-	RefID    string       `xml:"RefId,attr"`
-	TypeName []string     `xml:"TN>T"`
-	ToString string       `xml:"ToString"`
-	Props    []CLIXMLProp `xml:"Props>*"`
-	MS       []CLIXMLProp `xml:"MS>*"`
-	S        string       `xml:"S,attr"` // Stream attribute
-}
-
-type CLIXMLProp struct {
-	XMLName xml.Name
-	Name    string `xml:"N,attr"`
-	Value   string `xml:",chardata"`
-}
-
 // Parse parses CLIXML data and returns structured output
 func (op *OutputParser) Parse(xmlData []byte) ([]PSOutput, error) {
 	DebugLog("Parser.Parse called with %d bytes of data", len(xmlData))
@@ -56,8 +33,8 @@ func (op *OutputParser) Parse(xmlData []byte) ([]PSOutput, error) {
 	DebugLogRaw("XML INPUT", string(xmlData))
 
 	// Try to parse as CLIXML
-	var objs CLIXMLObjs
-	if err := xml.Unmarshal(xmlData, &objs); err != nil {
+	records, err := DeserializeCLIXML(xmlData)
+	if err != nil {
 		DebugLog("Parser.Parse: XML parsing failed: %v, falling back to plain text", err)
 		// If not valid XML, treat as plain text
 		results := op.parsePlainText(string(xmlData))
@@ -65,13 +42,13 @@ func (op *OutputParser) Parse(xmlData []byte) ([]PSOutput, error) {
 		return results, nil
 	}
 
-	DebugLog("Parser.Parse: successfully parsed XML with %d objects", len(objs.Objects))
+	DebugLog("Parser.Parse: successfully parsed XML with %d objects", len(records))
 
-	// Convert CLIXML objects to PSOutput
+	// Convert CLIXML records to PSOutput
 	var results []PSOutput
-	for i, obj := range objs.Objects {
-		DebugLog("Parser.Parse: converting object %d, Stream=%q, ToString=%q", i, obj.S, obj.ToString)
-		output := op.convertObject(obj)
+	for i, record := range records {
+		DebugLog("Parser.Parse: converting object %d, Stream=%q", i, record.Stream)
+		output := op.convertRecord(record)
 		results = append(results, output)
 	}
 
@@ -79,26 +56,28 @@ func (op *OutputParser) Parse(xmlData []byte) ([]PSOutput, error) {
 	return results, nil
 }
 
-// convertObject converts a CLIXML object to PSOutput
-func (op *OutputParser) convertObject(obj CLIXMLObj) PSOutput {
-	stream := op.determineStream(obj)
-	DebugLog("convertObject: determined stream type: %v for S attribute: %q", stream, obj.S)
+// convertRecord converts a deserialized CLIXML record to PSOutput, keeping
+// the object graph in ObjectData
+func (op *OutputParser) convertRecord(record CLIXMLRecord) PSOutput {
+	stream := op.determineStream(record.Stream)
+	DebugLog("convertRecord: determined stream type: %v for S attribute: %q", stream, record.Stream)
 
+	content := FormatCLIXMLValue(record.Value)
 	output := PSOutput{
 		Stream:       stream,
-		Content:      obj.ToString,
+		Content:      content,
 		ANSISegments: []ANSISegment{},
-		ObjectData:   nil,
+		ObjectData:   record.Value,
 		IsFormatted:  false,
 		Timestamp:    time.Now(),
 	}
 
-	// Parse ANSI codes if present in ToString
-	if obj.ToString != "" {
-		output.ANSISegments = op.ParseANSI(obj.ToString)
+	// Parse ANSI codes if present in the content
+	if content != "" {
+		output.ANSISegments = op.ParseANSI(content)
 		output.IsFormatted = len(output.ANSISegments) > 0
 		if output.IsFormatted {
-			DebugLog("convertObject: found %d ANSI segments in content", len(output.ANSISegments))
+			DebugLog("convertRecord: found %d ANSI segments in content", len(output.ANSISegments))
 		}
 	}
 
@@ -106,9 +85,9 @@ func (op *OutputParser) convertObject(obj CLIXMLObj) PSOutput {
 }
 
 // determineStream determines which stream the output belongs to
-func (op *OutputParser) determineStream(obj CLIXMLObj) StreamType {
+func (op *OutputParser) determineStream(s string) StreamType {
 	// Check the S attribute (stream indicator)
-	switch strings.ToLower(obj.S) {
+	switch strings.ToLower(s) {
 	case "error":
 		return ErrorStream
 	case "warning":
@@ -371,6 +350,9 @@ func (op *OutputParser) FormatWithANSI(output PSOutput) string {
 
 // ExtractErrorMessage extracts error message from output
 func (op *OutputParser) ExtractErrorMessage(output PSOutput) string {
+	if record, ok := output.ObjectData.(*ErrorRecordInfo); ok {
+		return record.Message
+	}
 	if output.Stream == ErrorStream {
 		DebugLog("ExtractErrorMessage: extracted error: %q", output.Content)
 		return output.Content
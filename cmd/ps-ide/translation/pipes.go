@@ -2,6 +2,7 @@ package translation
 
 import (
 	"bufio"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
@@ -14,21 +15,29 @@ import (
 
 // PipeCommunicator handles bidirectional communication with PowerShell
 type PipeCommunicator struct {
+	executable   string
 	psProcess    *exec.Cmd
 	stdin        io.WriteCloser
 	stdout       io.ReadCloser
 	stderr       io.ReadCloser
 	mutex        sync.Mutex
+	commandMutex sync.Mutex // Held while a command is sent and its output collected
 	responseChan chan string
 	isRunning    bool
 	stopChan     chan bool
 	escapeRegex  *regexp.Regexp
 	promptRegex  *regexp.Regexp
+
+	// onDebugPrompt is set before Start and called from the stdout reader
+	// for each [DBG] prompt
+	onDebugPrompt func()
 }
 
-// NewPipeCommunicator creates a new pipe communicator
-func NewPipeCommunicator() *PipeCommunicator {
+// NewPipeCommunicator creates a new pipe communicator for the given
+// PowerShell executable (DefaultExecutable if empty)
+func NewPipeCommunicator(executable string) *PipeCommunicator {
 	return &PipeCommunicator{
+		executable:   ResolveExecutable(executable),
 		responseChan: make(chan string, 100),
 		stopChan:     make(chan bool, 1),
 		isRunning:    false,
@@ -48,10 +57,10 @@ func (pc *PipeCommunicator) Start() error {
 		return fmt.Errorf("pipe communicator already running")
 	}
 
-	DebugLog("Starting PowerShell process...")
+	DebugLog("Starting PowerShell process: %s", pc.executable)
 
 	// Start PowerShell in interactive mode
-	pc.psProcess = exec.Command("pwsh",
+	pc.psProcess = exec.Command(pc.executable,
 		"-NoLogo",
 		"-NoProfile",
 		"-Interactive")
@@ -112,6 +121,11 @@ func (pc *PipeCommunicator) Start() error {
 	return nil
 }
 
+// Executable returns the path of the PowerShell binary this communicator runs
+func (pc *PipeCommunicator) Executable() string {
+	return pc.executable
+}
+
 // Stop terminates the PowerShell process
 func (pc *PipeCommunicator) Stop() error {
 	pc.mutex.Lock()
@@ -151,8 +165,19 @@ func (pc *PipeCommunicator) Stop() error {
 	return nil
 }
 
-// SendCommand sends a command to PowerShell and waits for output
+// SendCommand sends a command to PowerShell and waits for output. A
+// command sent from another goroutine meanwhile waits its turn, so the
+// outputs are not mixed.
 func (pc *PipeCommunicator) SendCommand(command string, cmdType CommandType) (string, error) {
+	return pc.SendCommandStreaming(command, cmdType, nil)
+}
+
+// SendCommandStreaming is SendCommand, also calling onLine (if not nil)
+// with each line of output as it is read
+func (pc *PipeCommunicator) SendCommandStreaming(command string, cmdType CommandType, onLine func(line string)) (string, error) {
+	pc.commandMutex.Lock()
+	defer pc.commandMutex.Unlock()
+
 	pc.mutex.Lock()
 	if !pc.isRunning {
 		pc.mutex.Unlock()
@@ -168,7 +193,6 @@ func (pc *PipeCommunicator) SendCommand(command string, cmdType CommandType) (st
 	if flushed > 0 {
 		DebugLog("Flushed %d pending responses", flushed)
 	}
-
 	// Write command to stdin
 	_, err := pc.stdin.Write([]byte(command + "\n"))
 	if err != nil {
@@ -208,7 +232,7 @@ func (pc *PipeCommunicator) SendCommand(command string, cmdType CommandType) (st
 
 			// Skip the first line that contains prompt + command (echo)
 			// This is the "PS /path> command" line
-			if !echoSkipped && strings.HasPrefix(cleanLine, "PS ") && strings.Contains(cleanLine, ">") {
+			if !echoSkipped && isPromptPrefixed(cleanLine) && strings.Contains(cleanLine, ">") {
 				DebugLog("Skipping command echo line (prompt + command)")
 				echoSkipped = true
 				continue
@@ -225,6 +249,9 @@ func (pc *PipeCommunicator) SendCommand(command string, cmdType CommandType) (st
 			output.WriteString(cleanLine)
 			output.WriteString("\n")
 			noOutputCount = 0
+			if onLine != nil {
+				onLine(cleanLine)
+			}
 
 		case <-time.After(100 * time.Millisecond):
 			noOutputCount++
@@ -255,7 +282,7 @@ func (pc *PipeCommunicator) isPromptLine(line string) bool {
 	trimmed := strings.TrimSpace(line)
 
 	// Match "PS path>" or just ">" at end (but not if it has text after the >)
-	if strings.HasPrefix(trimmed, "PS ") && strings.HasSuffix(trimmed, ">") {
+	if isPromptPrefixed(trimmed) && strings.HasSuffix(trimmed, ">") {
 		DebugLog("isPromptLine: matched PS prefix: %q", trimmed)
 		return true
 	}
@@ -269,6 +296,18 @@ func (pc *PipeCommunicator) isPromptLine(line string) bool {
 	return false
 }
 
+// isPromptPrefixed reports whether a line starts with a normal
+// "PS path>" prompt or the "[DBG]: PS path>>" debugger prompt
+func isPromptPrefixed(line string) bool {
+	return strings.HasPrefix(line, "PS ") || strings.HasPrefix(line, "[DBG]: PS ")
+}
+
+// isDebugPrompt reports whether a line is a bare "[DBG]: PS path>>"
+// prompt, which the console host prints each time the debugger stops
+func isDebugPrompt(line string) bool {
+	return strings.HasPrefix(line, "[DBG]: PS ") && strings.HasSuffix(line, ">>")
+}
+
 // cleanLine removes unwanted escape sequences but keeps ANSI color codes
 func (pc *PipeCommunicator) cleanLine(line string) string {
 	// Remove cursor movement and screen clearing sequences
@@ -300,6 +339,10 @@ func (pc *PipeCommunicator) readOutputLoop(reader io.Reader, isError bool) {
 			line := scanner.Text()
 			DebugLog("%s received: %q", streamName, line)
 
+			if !isError && pc.onDebugPrompt != nil && isDebugPrompt(pc.cleanLine(line)) {
+				pc.onDebugPrompt()
+			}
+
 			// Send to response channel (non-blocking)
 			select {
 			case pc.responseChan <- line:
@@ -357,7 +400,7 @@ func (pc *PipeCommunicator) SendInterrupt() error {
 // ExecuteScript executes a script file
 func (pc *PipeCommunicator) ExecuteScript(scriptPath string) (string, error) {
 	// Use PowerShell's script execution syntax
-	command := fmt.Sprintf("& '%s'", scriptPath)
+	command := "& " + QuotePS(scriptPath)
 	return pc.SendCommand(command, Script)
 }
 
@@ -378,6 +421,28 @@ func (pc *PipeCommunicator) ExecuteScriptText(scriptText string) (string, error)
 	return pc.ExecuteScript(tmpFile.Name())
 }
 
+// BuildSelectionCommand returns a single-line command that parses code as
+// if it were located in fileName at the given line and column, then
+// dot-sources it into the session. The source is padded with blank lines
+// and spaces so the parser reports absolute positions.
+func BuildSelectionCommand(code, fileName string, line, column int) string {
+	if line < 1 {
+		line = 1
+	}
+	if column < 1 {
+		column = 1
+	}
+	padded := strings.Repeat("\n", line-1) + strings.Repeat(" ", column-1) + code
+	encoded := base64.StdEncoding.EncodeToString([]byte(padded))
+
+	return fmt.Sprintf(
+		". (& { param($s, $f) $t = $null; $e = $null; "+
+			"$a = [System.Management.Automation.Language.Parser]::ParseInput($s, $f, [ref]$t, [ref]$e); "+
+			"if ($e) { throw [System.Management.Automation.ParseException]::new($e) }; $a.GetScriptBlock() } "+
+			"([System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String('%s'))) %s)",
+		encoded, QuotePS(fileName))
+}
+
 // QueryState queries PowerShell state (silent, no output to console)
 func (pc *PipeCommunicator) QueryState(query string) (string, error) {
 	DebugLog("QueryState called: %s", query)
@@ -0,0 +1,59 @@
+package translation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StreamPreferences mirrors the session preference variables that decide
+// whether verbose, debug and information records are written at all
+type StreamPreferences struct {
+	Verbose     bool // $VerbosePreference is Continue
+	Debug       bool // $DebugPreference is Continue
+	Information bool // $InformationPreference is Continue
+}
+
+// preferenceVariable returns the preference variable controlling a stream
+func preferenceVariable(stream StreamType) (string, error) {
+	switch stream {
+	case VerboseStream:
+		return "VerbosePreference", nil
+	case DebugStream:
+		return "DebugPreference", nil
+	case InformationStream:
+		return "InformationPreference", nil
+	}
+	return "", fmt.Errorf("no preference variable for the %s stream", stream)
+}
+
+// SetStreamPreference sets the stream's preference variable to Continue or
+// SilentlyContinue in the global scope of the session
+func (tl *TranslationLayer) SetStreamPreference(stream StreamType, enabled bool) error {
+	name, err := preferenceVariable(stream)
+	if err != nil {
+		return err
+	}
+	value := "SilentlyContinue"
+	if enabled {
+		value = "Continue"
+	}
+	_, err = tl.queryMarked(fmt.Sprintf("$global:%s = '%s'", name, value))
+	return err
+}
+
+// GetStreamPreferences reads the session's preference variables
+func (tl *TranslationLayer) GetStreamPreferences() (StreamPreferences, error) {
+	output, err := tl.queryMarked(`"$VerbosePreference|$DebugPreference|$InformationPreference"`)
+	if err != nil {
+		return StreamPreferences{}, err
+	}
+	values := strings.Split(strings.TrimSpace(output), "|")
+	if len(values) != 3 {
+		return StreamPreferences{}, fmt.Errorf("unexpected preference output: %q", output)
+	}
+	return StreamPreferences{
+		Verbose:     values[0] == "Continue",
+		Debug:       values[1] == "Continue",
+		Information: values[2] == "Continue",
+	}, nil
+}
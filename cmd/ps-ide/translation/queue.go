@@ -169,6 +169,17 @@ func (cq *CommandQueue) ResetIndex() {
 	cq.currentIndex = len(cq.history)
 }
 
+// LastEntry returns the most recent history entry
+func (cq *CommandQueue) LastEntry() (CommandEntry, bool) {
+	cq.mutex.RLock()
+	defer cq.mutex.RUnlock()
+
+	if len(cq.history) == 0 {
+		return CommandEntry{}, false
+	}
+	return cq.history[len(cq.history)-1], true
+}
+
 // UpdateLastEntry updates the last entry with execution results
 func (cq *CommandQueue) UpdateLastEntry(duration time.Duration, success bool, exitCode int) {
 	cq.mutex.Lock()
@@ -0,0 +1,270 @@
+package translation
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunMode controls where a script runs
+type RunMode string
+
+const (
+	// RunShared invokes the script with & in the shared session
+	RunShared RunMode = "shared"
+	// RunDotSource dot-sources the script so its variables and functions
+	// remain in the shared session afterwards
+	RunDotSource RunMode = "dotsource"
+	// RunIsolated starts a fresh pwsh process for the script
+	RunIsolated RunMode = "isolated"
+)
+
+// RunOptions describes how to run a script file
+type RunOptions struct {
+	Arguments  string            // Raw PowerShell argument text appended to the invocation
+	WorkingDir string            // Location to run in; empty keeps the current location
+	Env        map[string]string // Extra environment variables for the run
+	Mode       RunMode           // Shared (default), dot-source or isolated
+}
+
+// QuotePS returns s as a PowerShell single-quoted string literal
+func QuotePS(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// BuildScriptCommand returns the single-line command that runs path in the
+// shared session with the given options. Environment variables and the
+// location are restored afterwards, and the variables used to restore them
+// removed from the session. If the working directory can't be entered the
+// script is not run.
+func BuildScriptCommand(path string, opts RunOptions) string {
+	operator := "&"
+	if opts.Mode == RunDotSource {
+		operator = "."
+	}
+
+	invoke := fmt.Sprintf("%s %s", operator, QuotePS(path))
+	if args := strings.TrimSpace(opts.Arguments); args != "" {
+		invoke += " " + args
+	}
+
+	if opts.WorkingDir == "" && len(opts.Env) == 0 {
+		return invoke
+	}
+
+	var setup, cleanup []string
+	if len(opts.Env) > 0 {
+		setup = append(setup, "$__psideSavedEnv = @{}")
+		for _, name := range sortedEnvNames(opts.Env) {
+			setup = append(setup,
+				fmt.Sprintf("$__psideSavedEnv[%s] = [Environment]::GetEnvironmentVariable(%s)", QuotePS(name), QuotePS(name)),
+				fmt.Sprintf("[Environment]::SetEnvironmentVariable(%s, %s)", QuotePS(name), QuotePS(opts.Env[name])))
+		}
+		cleanup = append(cleanup,
+			"foreach ($__psideKey in $__psideSavedEnv.Keys) { [Environment]::SetEnvironmentVariable($__psideKey, $__psideSavedEnv[$__psideKey]) }",
+			"Remove-Variable __psideSavedEnv, __psideKey -ErrorAction Ignore")
+	}
+	if opts.WorkingDir != "" {
+		// Push-Location's errors are not terminating by default, and Pop-Location
+		// must not pop an entry of the user's if the push failed
+		setup = append(setup, "$__psidePushed = $false")
+		invoke = fmt.Sprintf("Push-Location -LiteralPath %s -ErrorAction Stop; $__psidePushed = $true; %s",
+			QuotePS(opts.WorkingDir), invoke)
+		cleanup = append(cleanup,
+			"if ($__psidePushed) { Pop-Location }",
+			"Remove-Variable __psidePushed -ErrorAction Ignore")
+	}
+
+	// try/finally does not introduce a scope, so dot-sourcing still works
+	return fmt.Sprintf("%s; try { %s } finally { %s }",
+		strings.Join(setup, "; "), invoke, strings.Join(cleanup, "; "))
+}
+
+// checkWorkingDir reports a working directory an isolated run could not
+// start in; exec would only fail with a bare chdir error
+func checkWorkingDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("working directory %s does not exist", dir)
+	} else if err != nil {
+		return fmt.Errorf("working directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("working directory %s is not a directory", dir)
+	}
+	return nil
+}
+
+func sortedEnvNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExecuteScriptWithOptions runs a script file according to opts
+func (tl *TranslationLayer) ExecuteScriptWithOptions(path string, opts RunOptions) (string, error) {
+	if opts.Mode != RunIsolated {
+		command := BuildScriptCommand(path, opts)
+		return tl.executeTracked(command, command, Script)
+	}
+
+	tl.mutex.Lock()
+	if tl.isExecuting {
+		tl.mutex.Unlock()
+		return "", fmt.Errorf("another command is executing")
+	}
+	tl.isExecuting = true
+	tl.mutex.Unlock()
+
+	defer func() {
+		tl.mutex.Lock()
+		tl.isExecuting = false
+		tl.isolatedProcess = nil
+		tl.mutex.Unlock()
+	}()
+
+	invoke := "& " + QuotePS(path)
+	if args := strings.TrimSpace(opts.Arguments); args != "" {
+		invoke += " " + args
+	}
+
+	if err := checkWorkingDir(opts.WorkingDir); err != nil {
+		return "", err
+	}
+	if err := tl.queue.Add(invoke, Script); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command(tl.pipes.Executable(), "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", invoke)
+	cmd.Dir = opts.WorkingDir
+	cmd.Env = os.Environ()
+	for _, name := range sortedEnvNames(opts.Env) {
+		cmd.Env = append(cmd.Env, name+"="+opts.Env[name])
+	}
+
+	DebugLog("Running isolated: %s %v (dir=%q)", cmd.Path, cmd.Args, cmd.Dir)
+
+	tl.mutex.Lock()
+	stream := &lineWriter{onOutput: tl.onOutput}
+	tl.mutex.Unlock()
+	var output strings.Builder
+	writer := io.MultiWriter(&output, stream)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	prompt := tl.GetPrompt()
+	startTime := time.Now()
+	err := cmd.Start()
+	if err == nil {
+		tl.mutex.Lock()
+		tl.isolatedProcess = cmd.Process
+		tl.mutex.Unlock()
+		err = cmd.Wait()
+	}
+	duration := time.Since(startTime)
+	stream.Flush()
+
+	exitCode := 0
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	} else if err != nil {
+		exitCode = 1
+	}
+	tl.queue.UpdateLastEntry(duration, err == nil, exitCode)
+
+	result := strings.TrimRight(output.String(), "\r\n")
+	tl.recordTranscript(prompt, result, err)
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			// Script ran but failed; its output already explains why
+			exited := fmt.Sprintf("Process exited with code %d", exitCode)
+			stream.Write([]byte(exited + "\n"))
+			return result + "\n" + exited, nil
+		}
+		return "", fmt.Errorf("script execution failed: %w", err)
+	}
+
+	return result, nil
+}
+
+// lineWriter passes what is written to an output handler a line at a
+// time, keeping a partial line until it is finished or flushed
+type lineWriter struct {
+	onOutput func(text string)
+	partial  []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if w.onOutput == nil {
+		return len(p), nil
+	}
+	w.partial = append(w.partial, p...)
+	if end := bytes.LastIndexByte(w.partial, '\n') + 1; end > 0 {
+		w.onOutput(string(w.partial[:end]))
+		w.partial = append(w.partial[:0], w.partial[end:]...)
+	}
+	return len(p), nil
+}
+
+// Flush passes on a last line that has no newline
+func (w *lineWriter) Flush() {
+	if w.onOutput != nil && len(w.partial) > 0 {
+		w.onOutput(string(w.partial) + "\n")
+		w.partial = w.partial[:0]
+	}
+}
+
+// executeTracked runs a command in the shared session, recording
+// historyText in history
+func (tl *TranslationLayer) executeTracked(historyText, command string, cmdType CommandType) (string, error) {
+	tl.mutex.Lock()
+	if tl.isExecuting {
+		tl.mutex.Unlock()
+		return "", fmt.Errorf("another command is executing")
+	}
+	tl.isExecuting = true
+	tl.mutex.Unlock()
+
+	defer func() {
+		tl.mutex.Lock()
+		tl.isExecuting = false
+		tl.mutex.Unlock()
+	}()
+
+	if err := tl.queue.Add(historyText, cmdType); err != nil {
+		return "", err
+	}
+
+	prompt := tl.GetPrompt()
+	startTime := time.Now()
+	tl.resumeDebugger(command)
+	result, err := tl.pipes.SendCommandStreaming(command, cmdType, tl.outputLineHandler())
+	duration := time.Since(startTime)
+
+	exitCode := 0
+	if err != nil {
+		exitCode = 1
+	}
+	tl.queue.UpdateLastEntry(duration, err == nil, exitCode)
+	tl.recordTranscript(prompt, result, err)
+
+	// Update session state (synchronous to ensure prompt shows correct directory)
+	tl.updateDirectory()
+
+	if err != nil {
+		return "", fmt.Errorf("script execution failed: %w", err)
+	}
+
+	return result, nil
+}
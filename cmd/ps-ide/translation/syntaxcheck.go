@@ -0,0 +1,255 @@
+package translation
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	syntaxCheckTimeout = 15 * time.Second // Includes starting the helper process
+	syntaxCheckEnd     = "__PSIDE_PARSE_END__"
+)
+
+// syntaxCheckScript reads base64 scripts from stdin, one per line, and
+// writes each parse error as a base64 line of tab-separated fields,
+// followed by syntaxCheckEnd. A line of "complete", a cursor offset and a
+// base64 script, separated by tabs, gets the script's completions at the
+// cursor from TabExpansion2 instead, one base64 line each.
+var syntaxCheckScript = `while ($null -ne ($__psideLine = [Console]::In.ReadLine())) { ` +
+	`if ($__psideLine.StartsWith('complete' + [char]9)) { $__psideParts = $__psideLine.Split([char]9); ` +
+	`try { $__psideScript = [Text.Encoding]::UTF8.GetString([Convert]::FromBase64String($__psideParts[2])); ` +
+	`$__psideResult = TabExpansion2 -inputScript $__psideScript -cursorColumn ([int]$__psideParts[1]); ` +
+	`foreach ($__psideMatch in $__psideResult.CompletionMatches) { ` +
+	`$__psideFields = @($__psideMatch.ResultType, $__psideMatch.CompletionText, $__psideMatch.ToolTip) -join [char]9; ` +
+	`[Console]::Out.WriteLine([Convert]::ToBase64String([Text.Encoding]::UTF8.GetBytes($__psideFields))) } } catch { } ` +
+	`[Console]::Out.WriteLine('` + syntaxCheckEnd + `'); [Console]::Out.Flush(); continue } ` +
+	`$__psideTokens = $null; $__psideErrors = @(); ` +
+	`try { $__psideScript = [Text.Encoding]::UTF8.GetString([Convert]::FromBase64String($__psideLine)); ` +
+	`[void][System.Management.Automation.Language.Parser]::ParseInput($__psideScript, [ref]$__psideTokens, [ref]$__psideErrors) } catch { } ` +
+	`foreach ($__psideError in $__psideErrors) { $__psideExtent = $__psideError.Extent; ` +
+	`$__psideFields = @($__psideExtent.StartLineNumber, $__psideExtent.StartColumnNumber, $__psideExtent.EndLineNumber, ` +
+	`$__psideExtent.EndColumnNumber, $__psideError.ErrorId, $__psideError.IncompleteInput, $__psideError.Message) -join [char]9; ` +
+	`[Console]::Out.WriteLine([Convert]::ToBase64String([Text.Encoding]::UTF8.GetBytes($__psideFields))) }; ` +
+	`[Console]::Out.WriteLine('` + syntaxCheckEnd + `'); [Console]::Out.Flush() }`
+
+// ParseError is a syntax error reported by PowerShell's parser. Lines and
+// columns are 1-based and the end is exclusive, as in a script extent.
+type ParseError struct {
+	StartLine       int
+	StartColumn     int
+	EndLine         int
+	EndColumn       int
+	ErrorID         string
+	Message         string
+	IncompleteInput bool // The script ends too early, e.g. a missing }
+}
+
+// SyntaxChecker parses scripts with PowerShell's own parser in a helper
+// pwsh process, and completes types and members with TabExpansion2 there.
+// It is separate from the session, so checking never waits for a running
+// command and never touches session state.
+type SyntaxChecker struct {
+	executable string
+	mutex      sync.Mutex
+	process    *exec.Cmd
+	stdin      io.WriteCloser
+	lines      chan string
+}
+
+// NewSyntaxChecker creates a checker for the given PowerShell executable
+// (DefaultExecutable if empty). The helper process starts on first use.
+func NewSyntaxChecker(executable string) *SyntaxChecker {
+	return &SyntaxChecker{executable: ResolveExecutable(executable)}
+}
+
+// Executable returns the path of the PowerShell binary the checker runs
+func (sc *SyntaxChecker) Executable() string {
+	return sc.executable
+}
+
+func (sc *SyntaxChecker) start() error {
+	process := exec.Command(sc.executable, "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", syntaxCheckScript)
+	stdin, err := process.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdin pipe: %w", err)
+	}
+	stdout, err := process.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+	if err := process.Start(); err != nil {
+		return fmt.Errorf("failed to start syntax checker: %w", err)
+	}
+	DebugLog("Syntax checker started, PID: %d", process.Process.Pid)
+
+	lines := make(chan string, 64)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+		process.Wait()
+	}()
+
+	sc.process = process
+	sc.stdin = stdin
+	sc.lines = lines
+	return nil
+}
+
+// Check parses script and returns its syntax errors. Checks run one at a
+// time; the helper process is restarted if it exits or stops responding.
+func (sc *SyntaxChecker) Check(script string) ([]ParseError, error) {
+	lines, err := sc.request(base64.StdEncoding.EncodeToString([]byte(script)))
+	if err != nil {
+		return nil, err
+	}
+	var errors []ParseError
+	for _, line := range lines {
+		if parseError, ok := decodeParseError(line); ok {
+			errors = append(errors, parseError)
+		}
+	}
+	return errors, nil
+}
+
+// Complete returns TabExpansion2's completions of script at a byte offset.
+// Results other than commands, variables, parameters, paths, types and
+// members are left out.
+func (sc *SyntaxChecker) Complete(script string, offset int) ([]CompletionItem, error) {
+	// PowerShell counts the cursor column in UTF-16 code units
+	cursor := len(utf16.Encode([]rune(script[:offset])))
+	lines, err := sc.request(fmt.Sprintf("complete\t%d\t%s", cursor, base64.StdEncoding.EncodeToString([]byte(script))))
+	if err != nil {
+		return nil, err
+	}
+	var items []CompletionItem
+	for _, line := range lines {
+		if item, ok := decodeCompletion(line); ok {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// request sends a line to the helper process and returns the lines it
+// writes back, up to syntaxCheckEnd
+func (sc *SyntaxChecker) request(payload string) ([]string, error) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if sc.process == nil {
+		if err := sc.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := io.WriteString(sc.stdin, payload+"\n"); err != nil {
+		sc.stopLocked()
+		return nil, fmt.Errorf("failed to send script to syntax checker: %w", err)
+	}
+
+	var lines []string
+	timeout := time.After(syntaxCheckTimeout)
+	for {
+		select {
+		case line, ok := <-sc.lines:
+			if !ok {
+				sc.stopLocked()
+				return nil, fmt.Errorf("syntax checker exited")
+			}
+			if line == syntaxCheckEnd {
+				return lines, nil
+			}
+			lines = append(lines, line)
+		case <-timeout:
+			sc.stopLocked()
+			return nil, fmt.Errorf("syntax check timed out")
+		}
+	}
+}
+
+// decodeParseError decodes one error line written by syntaxCheckScript
+func decodeParseError(line string) (ParseError, bool) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+	if err != nil {
+		return ParseError{}, false
+	}
+	fields := strings.SplitN(string(data), "\t", 7)
+	if len(fields) != 7 {
+		return ParseError{}, false
+	}
+
+	var pe ParseError
+	pe.StartLine, _ = strconv.Atoi(fields[0])
+	pe.StartColumn, _ = strconv.Atoi(fields[1])
+	pe.EndLine, _ = strconv.Atoi(fields[2])
+	pe.EndColumn, _ = strconv.Atoi(fields[3])
+	pe.ErrorID = fields[4]
+	pe.IncompleteInput = strings.EqualFold(fields[5], "True")
+	pe.Message = fields[6]
+	if pe.StartLine <= 0 {
+		return ParseError{}, false
+	}
+	return pe, true
+}
+
+// completionTypes maps TabExpansion2 result types to completion types
+var completionTypes = map[string]CompletionType{
+	"Command":           CommandCompletion,
+	"Variable":          VariableCompletion,
+	"ParameterName":     ParameterCompletion,
+	"ProviderItem":      PathCompletion,
+	"ProviderContainer": PathCompletion,
+	"Property":          MemberCompletion,
+	"Method":            MemberCompletion,
+	"Type":              TypeCompletion,
+	"Namespace":         TypeCompletion,
+}
+
+// decodeCompletion decodes one completion line written by syntaxCheckScript
+func decodeCompletion(line string) (CompletionItem, bool) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+	if err != nil {
+		return CompletionItem{}, false
+	}
+	fields := strings.SplitN(string(data), "\t", 3)
+	if len(fields) != 3 || fields[1] == "" {
+		return CompletionItem{}, false
+	}
+	kind, ok := completionTypes[fields[0]]
+	if !ok {
+		return CompletionItem{}, false
+	}
+	return CompletionItem{Text: fields[1], Type: kind, Description: strings.TrimSpace(fields[2])}, true
+}
+
+// Stop terminates the helper process
+func (sc *SyntaxChecker) Stop() {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	sc.stopLocked()
+}
+
+func (sc *SyntaxChecker) stopLocked() {
+	if sc.process == nil {
+		return
+	}
+	sc.stdin.Close()
+	if sc.process.Process != nil {
+		sc.process.Process.Kill()
+	}
+	sc.process = nil
+	sc.stdin = nil
+	sc.lines = nil
+}
@@ -0,0 +1,84 @@
+#!/usr/bin/env pwsh
+# Regenerates the CLIXML fixtures of clixml_test.go. Run it on Linux or
+# macOS with PowerShell 7.4, in a time zone one hour ahead of UTC in March:
+#
+#   TZ=Europe/Paris pwsh -NoProfile ./generate.ps1
+#
+# On Windows the SecureString payload would be a DPAPI blob tied to the
+# user; elsewhere Export-Clixml writes the password's UTF-16 code units.
+# The tests assert the values below; change both together.
+#Requires -Version 7.4
+
+$ErrorActionPreference = 'Stop'
+Set-Location -LiteralPath $PSScriptRoot
+
+# Export-Fixture writes the objects from $Objects as one file. Each object
+# is a top-level record; Write-Output -NoEnumerate keeps a collection as
+# one record. Line endings are made the same on every platform.
+function Export-Fixture([string]$Name, [scriptblock]$Objects, [string]$NewLine = "`n") {
+    & $Objects | Export-Clixml -LiteralPath $Name -Depth 3
+    $text = [IO.File]::ReadAllText("$PSScriptRoot/$Name") -replace "`r?`n", $NewLine
+    [IO.File]::WriteAllText("$PSScriptRoot/$Name", $text)
+}
+
+Export-Fixture nested.clixml {
+    [pscustomobject]@{
+        Name  = 'parent'
+        Count = 2
+        Child = [pscustomobject]@{ Name = 'child'; Enabled = $true; Missing = $null }
+    }
+}
+
+Export-Fixture strings.clixml {
+    "line one`r`nline two"
+    "tab`tseparated"
+    'literal _x000A_ escape'
+    'snake_case_name'
+    "emoji $([char]::ConvertFromUtf32(0x1F600))"
+    '<tag attr="x"> & more'
+    ''
+    [char]'A'
+}
+
+# Written with Windows line endings, as files exported there are
+Export-Fixture credential.clixml -NewLine "`r`n" {
+    [pscredential]::new('CONTOSO\admin', (ConvertTo-SecureString 'P@ssw0rd' -AsPlainText -Force))
+}
+
+Export-Fixture datetime.clixml {
+    [datetime]::new(2024, 3, 5, 14, 30, 15, 123, [DateTimeKind]::Local).AddTicks(4567)
+    [datetime]::new(2024, 3, 5, 13, 30, 15, [DateTimeKind]::Utc)
+    [datetime]::new(2024, 3, 5, 14, 30, 15, [DateTimeKind]::Unspecified)
+    [timespan]::new(1, 2, 3, 4, 500)
+    [timespan]::new(-1)
+}
+
+Export-Fixture collections.clixml {
+    Write-Output -NoEnumerate @(1, 'two', $null, [ordered]@{ Name = 'pwsh'; 2 = 1.5 })
+    $queue = [System.Collections.Queue]::new()
+    $queue.Enqueue('a')
+    $queue.Enqueue('b')
+    Write-Output -NoEnumerate $queue
+    Write-Output -NoEnumerate @()
+}
+
+# References within a record and to an object of an earlier record
+Export-Fixture references.clixml {
+    $first = [pscustomobject]@{ Id = 7 }
+    [pscustomobject]@{ First = $first; Second = $first }
+    $first
+}
+
+# Not an Export-Clixml file: what pwsh -OutputFormat xml writes to stderr
+# for the progress, warning and error streams
+$command = "Write-Progress -Activity Working -Status Done -Completed; Write-Warning careful; Write-Error 'Cannot find path'"
+$psi = [Diagnostics.ProcessStartInfo]::new((Get-Process -Id $PID).Path)
+foreach ($arg in '-NoProfile', '-NonInteractive', '-OutputFormat', 'xml', '-Command', $command) {
+    $psi.ArgumentList.Add($arg)
+}
+$psi.RedirectStandardOutput = $true
+$psi.RedirectStandardError = $true
+$process = [Diagnostics.Process]::Start($psi)
+$streams = $process.StandardError.ReadToEnd()
+$process.WaitForExit()
+[IO.File]::WriteAllText("$PSScriptRoot/streams.clixml", $streams.TrimEnd() -replace "`r?`n", "`n")
@@ -0,0 +1,281 @@
+package translation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TranscriptFormat selects how a transcript is written
+type TranscriptFormat int
+
+const (
+	// TranscriptText matches the format written by Start-Transcript
+	TranscriptText TranscriptFormat = iota
+	// TranscriptJSONL writes one JSON record per line for auditing
+	TranscriptJSONL
+)
+
+// transcriptTimeFormat is Start-Transcript's yyyyMMddHHmmss timestamp
+const transcriptTimeFormat = "20060102150405"
+
+const transcriptRule = "**********************"
+
+// Transcript records console commands and their output to a file
+type Transcript struct {
+	mutex   sync.Mutex
+	file    *os.File
+	path    string
+	format  TranscriptFormat
+	started time.Time
+}
+
+// transcriptRecord is a line of a JSON Lines transcript
+type transcriptRecord struct {
+	Type        string             `json:"type"` // start, command or end
+	Time        time.Time          `json:"time"`
+	User        string             `json:"user,omitempty"`
+	Machine     string             `json:"machine,omitempty"`
+	Executable  string             `json:"executable,omitempty"`
+	PSVersion   string             `json:"psVersion,omitempty"`
+	Prompt      string             `json:"prompt,omitempty"`
+	Command     string             `json:"command,omitempty"`
+	CommandType string             `json:"commandType,omitempty"`
+	WorkingDir  string             `json:"workingDir,omitempty"`
+	End         *time.Time         `json:"end,omitempty"`
+	DurationMs  *int64             `json:"durationMs,omitempty"`
+	Success     *bool              `json:"success,omitempty"`
+	ExitCode    *int               `json:"exitCode,omitempty"`
+	Output      []transcriptOutput `json:"output,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+type transcriptOutput struct {
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+// DefaultTranscriptDir returns ~/.ps-ide/transcripts
+func DefaultTranscriptDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = os.TempDir()
+	}
+	return filepath.Join(homeDir, ".ps-ide", "transcripts")
+}
+
+// DefaultTranscriptPath returns a new file name in the default directory,
+// named like Start-Transcript's PowerShell_transcript.HOST.XXXXXXXX.yyyyMMddHHmmss.txt
+func DefaultTranscriptPath(format TranscriptFormat) string {
+	host, _ := os.Hostname()
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	random := make([]byte, 8)
+	for i := range random {
+		random[i] = letters[rand.Intn(len(letters))]
+	}
+	ext := ".txt"
+	if format == TranscriptJSONL {
+		ext = ".jsonl"
+	}
+	name := fmt.Sprintf("PowerShell_transcript.%s.%s.%s%s", host, random, time.Now().Format(transcriptTimeFormat), ext)
+	return filepath.Join(DefaultTranscriptDir(), name)
+}
+
+// StartTranscript creates the transcript file and writes its header.
+// executable and psVersion describe the session being recorded.
+func StartTranscript(path string, format TranscriptFormat, executable, psVersion string) (*Transcript, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Transcript{file: file, path: path, format: format, started: time.Now()}
+
+	userName := ""
+	if u, err := user.Current(); err == nil {
+		userName = u.Username
+	}
+	host, _ := os.Hostname()
+
+	if format == TranscriptJSONL {
+		err = t.writeRecord(transcriptRecord{
+			Type:       "start",
+			Time:       t.started,
+			User:       userName,
+			Machine:    host,
+			Executable: executable,
+			PSVersion:  psVersion,
+		})
+	} else {
+		err = t.writeText(strings.Join([]string{
+			transcriptRule,
+			"PowerShell transcript start",
+			"Start time: " + t.started.Format(transcriptTimeFormat),
+			"Username: " + userName,
+			"RunAs User: " + userName,
+			"Machine: " + host,
+			"Host Application: " + executable,
+			fmt.Sprintf("Process ID: %d", os.Getpid()),
+			"PSVersion: " + psVersion,
+			transcriptRule,
+			"Transcript started, output file is " + path,
+		}, "\n") + "\n")
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Path returns the transcript file path
+func (t *Transcript) Path() string {
+	return t.path
+}
+
+// WriteCommand records a command with the prompt it ran at, its timing from
+// entry, and its output split by stream
+func (t *Transcript) WriteCommand(prompt string, entry CommandEntry, outputs []PSOutput, cmdErr error) error {
+	if t.format == TranscriptJSONL {
+		end := entry.Timestamp.Add(entry.Duration)
+		durationMs := entry.Duration.Milliseconds()
+		record := transcriptRecord{
+			Type:        "command",
+			Time:        entry.Timestamp,
+			Prompt:      prompt,
+			Command:     entry.Command,
+			CommandType: entry.Type.String(),
+			WorkingDir:  entry.WorkingDir,
+			End:         &end,
+			DurationMs:  &durationMs,
+			Success:     &entry.Success,
+			ExitCode:    &entry.ExitCode,
+		}
+		for _, output := range outputs {
+			record.Output = append(record.Output, transcriptOutput{
+				Stream: output.Stream.String(),
+				Text:   output.Content,
+			})
+		}
+		if cmdErr != nil {
+			record.Error = cmdErr.Error()
+		}
+		return t.writeRecord(record)
+	}
+
+	var b strings.Builder
+	b.WriteString(transcriptRule + "\n")
+	b.WriteString("Command start time: " + entry.Timestamp.Format(transcriptTimeFormat) + "\n")
+	b.WriteString("Command end time: " + entry.Timestamp.Add(entry.Duration).Format(transcriptTimeFormat) + "\n")
+	b.WriteString(fmt.Sprintf("Duration: %s\n", entry.Duration.Round(time.Millisecond)))
+	b.WriteString(transcriptRule + "\n")
+	b.WriteString(prompt + entry.Command + "\n")
+	for _, output := range outputs {
+		b.WriteString(streamPrefix(output) + output.Content + "\n")
+	}
+	if cmdErr != nil {
+		b.WriteString(cmdErr.Error() + "\n")
+	}
+	return t.writeText(b.String())
+}
+
+// streamPrefix returns the prefix PowerShell writes before a stream's
+// records, unless the record already has it
+func streamPrefix(output PSOutput) string {
+	prefix := ""
+	switch output.Stream {
+	case WarningStream:
+		prefix = "WARNING: "
+	case VerboseStream:
+		prefix = "VERBOSE: "
+	case DebugStream:
+		prefix = "DEBUG: "
+	}
+	if strings.HasPrefix(output.Content, prefix) {
+		return ""
+	}
+	return prefix
+}
+
+// Stop writes the transcript footer and closes the file
+func (t *Transcript) Stop() error {
+	end := time.Now()
+	var err error
+	if t.format == TranscriptJSONL {
+		durationMs := end.Sub(t.started).Milliseconds()
+		err = t.writeRecord(transcriptRecord{Type: "end", Time: end, DurationMs: &durationMs})
+	} else {
+		err = t.writeText(strings.Join([]string{
+			transcriptRule,
+			"PowerShell transcript end",
+			"End time: " + end.Format(transcriptTimeFormat),
+			transcriptRule,
+		}, "\n") + "\n")
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if closeErr := t.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (t *Transcript) writeRecord(record transcriptRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return t.writeText(string(data) + "\n")
+}
+
+func (t *Transcript) writeText(text string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, err := t.file.WriteString(text)
+	return err
+}
+
+// SetTranscript starts or, with nil, stops recording this session's
+// commands to t. The caller owns t and stops it.
+func (tl *TranslationLayer) SetTranscript(t *Transcript) {
+	tl.mutex.Lock()
+	tl.transcript = t
+	tl.mutex.Unlock()
+}
+
+// recordTranscript writes the last history entry and its output to the
+// transcript, if one is active
+func (tl *TranslationLayer) recordTranscript(prompt, output string, cmdErr error) {
+	tl.mutex.Lock()
+	t := tl.transcript
+	tl.mutex.Unlock()
+	if t == nil {
+		return
+	}
+
+	entry, ok := tl.queue.LastEntry()
+	if !ok {
+		return
+	}
+
+	var outputs []PSOutput
+	if parsed, err := tl.parser.Parse([]byte(output)); err == nil {
+		for _, o := range parsed {
+			o.Content = tl.parser.StripANSI(o.Content)
+			outputs = append(outputs, o)
+		}
+	}
+	if err := t.WriteCommand(prompt, entry, outputs, cmdErr); err != nil {
+		DebugLog("Failed to write transcript %s: %v", t.Path(), err)
+	}
+}
@@ -18,6 +18,22 @@ const (
 	Internal
 )
 
+// String returns the string representation of CommandType
+func (ct CommandType) String() string {
+	switch ct {
+	case Interactive:
+		return "Interactive"
+	case Script:
+		return "Script"
+	case Selection:
+		return "Selection"
+	case Internal:
+		return "Internal"
+	default:
+		return "Unknown"
+	}
+}
+
 // CommandEntry represents a single command in history
 type CommandEntry struct {
 	Command    string
@@ -149,6 +165,7 @@ const (
 	ParameterCompletion
 	PathCompletion
 	MemberCompletion
+	TypeCompletion
 )
 
 // PromptStyle represents different prompt styles
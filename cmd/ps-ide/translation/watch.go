@@ -0,0 +1,77 @@
+package translation
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// maxWatchMembers limits how many members are listed under a watch
+const maxWatchMembers = 50
+
+// WatchMember is a property (or collection item) of a watched value
+type WatchMember struct {
+	Name  string `json:"n"`
+	Value string `json:"v"`
+	Type  string `json:"t"`
+}
+
+// WatchResult is the evaluated value of a watch expression
+type WatchResult struct {
+	Expression string        `json:"e"`
+	Value      string        `json:"v"`
+	Type       string        `json:"t"`
+	Error      string        `json:"x"` // Error message if evaluation failed
+	Members    []WatchMember `json:"m"`
+}
+
+// EvaluateWatches evaluates expressions in the session. While stopped in
+// the debugger they see the breakpoint's scope.
+func (tl *TranslationLayer) EvaluateWatches(expressions []string) ([]WatchResult, error) {
+	if len(expressions) == 0 {
+		return nil, nil
+	}
+
+	quoted := make([]string, len(expressions))
+	for i, expr := range expressions {
+		quoted[i] = QuotePS(expr)
+	}
+
+	// Collections show their items as members, other objects their
+	// properties. Failed evaluations are removed from the user's $Error.
+	// The script runs in a child scope, which still sees the breakpoint's
+	// variables but keeps its own out of the session.
+	script := `& { foreach ($__psideW in @(` + strings.Join(quoted, ", ") + `)) { ` +
+		`$__psideR = @{ e = $__psideW; v = ''; t = ''; x = ''; m = @() }; ` +
+		`try { $__psideV = . ([scriptblock]::Create($__psideW)); ` +
+		`if ($null -eq $__psideV) { $__psideR.v = '$null' } ` +
+		`elseif ($__psideV -is [System.Collections.IEnumerable] -and $__psideV -isnot [string]) { ` +
+		`$__psideItems = @($__psideV); $__psideR.t = $__psideV.GetType().FullName; $__psideR.v = '{Count = ' + $__psideItems.Count + '}'; ` +
+		`$__psideR.m = @(for ($__psideI = 0; $__psideI -lt [Math]::Min($__psideItems.Count, ` + strconv.Itoa(maxWatchMembers) + `); $__psideI++) { ` +
+		`$__psideItem = $__psideItems[$__psideI]; @{ n = "[$__psideI]"; v = "$__psideItem"; t = $(if ($null -ne $__psideItem) { $__psideItem.GetType().Name } else { '' }) } }) } ` +
+		`else { $__psideR.t = $__psideV.GetType().FullName; $__psideR.v = "$__psideV"; ` +
+		`$__psideR.m = @(foreach ($__psideP in @($__psideV.PSObject.Properties | Select-Object -First ` + strconv.Itoa(maxWatchMembers) + `)) { ` +
+		`try { $__psidePV = $__psideP.Value; @{ n = $__psideP.Name; v = "$__psidePV"; t = $(if ($null -ne $__psidePV) { $__psidePV.GetType().Name } else { '' }) } } ` +
+		`catch { if ($global:Error.Count) { $global:Error.RemoveAt(0) }; @{ n = $__psideP.Name; v = $_.Exception.Message; t = '' } } }) } } ` +
+		`catch { $__psideR.x = $_.Exception.Message; if ($global:Error.Count) { $global:Error.RemoveAt(0) } }; ` +
+		`$__psideR | ConvertTo-Json -Compress -Depth 3 } }`
+
+	output, err := tl.queryMarked(script)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []WatchResult
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var result WatchResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			DebugLog("Failed to parse watch result %q: %v", line, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -1,66 +1,170 @@
 package main
 
 import (
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/gotk3/gotk3/gtk"
 )
 
-// UndoStack manages undo/redo operations for a text buffer
+// undoDelta is a single insertion or deletion at a character offset
+type undoDelta struct {
+	offset int
+	text   string
+	insert bool
+}
+
+// undoAction is one undo step: the deltas it made, in order, and the
+// cursor and selection before and after it
+type undoAction struct {
+	deltas       []undoDelta
+	beforeInsert int
+	beforeBound  int
+	afterInsert  int
+	afterBound   int
+	typing       bool // Typed or deleted a single character; may absorb more
+}
+
+// UndoStack manages undo/redo operations for a text buffer. It records
+// insertions and deletions rather than snapshots of the text. Everything
+// done inside one GTK user action (a paste, a cut, typing over a
+// selection) is one step, and consecutive typing is grouped by word.
 type UndoStack struct {
-	buffer    *gtk.TextBuffer
-	undoStack []string
-	redoStack []string
-	maxLevels int
-	isUndoing bool
-	lastText  string
+	buffer     *gtk.TextBuffer
+	undoStack  []*undoAction
+	redoStack  []*undoAction
+	maxLevels  int
+	isUndoing  bool
+	userAction int         // Nesting depth of begin-user-action
+	current    *undoAction // Action being recorded
 }
 
 func NewUndoStack(buffer *gtk.TextBuffer, maxLevels int) *UndoStack {
 	us := &UndoStack{
 		buffer:    buffer,
-		undoStack: make([]string, 0),
-		redoStack: make([]string, 0),
 		maxLevels: maxLevels,
-		isUndoing: false,
-		lastText:  "",
 	}
 
-	// Get initial text
-	start := buffer.GetStartIter()
-	end := buffer.GetEndIter()
-	us.lastText, _ = buffer.GetText(start, end, false)
-
-	// Connect to buffer changes
-	buffer.Connect("changed", func() {
-		if !us.isUndoing {
-			us.recordChange()
+	buffer.Connect("begin-user-action", func() {
+		if us.isUndoing {
+			return
+		}
+		if us.userAction == 0 {
+			us.begin()
 		}
+		us.userAction++
 	})
+	buffer.Connect("end-user-action", func() {
+		if us.isUndoing || us.userAction == 0 {
+			return
+		}
+		us.userAction--
+		if us.userAction == 0 {
+			us.finish(true)
+		}
+	})
+
+	// Handlers run before the default handler, so deleted text is still there
+	buffer.Connect("insert-text", func(_ *gtk.TextBuffer, iter *gtk.TextIter, text string, _ int) {
+		us.record(undoDelta{offset: iter.GetOffset(), text: text, insert: true})
+	})
+	buffer.Connect("delete-range", func(_ *gtk.TextBuffer, start, end *gtk.TextIter) {
+		text, _ := buffer.GetText(start, end, true)
+		us.record(undoDelta{offset: start.GetOffset(), text: text})
+	})
+
+	// Changes made outside a user action are steps of their own
+	buffer.ConnectAfter("insert-text", func() { us.finishStandalone() })
+	buffer.ConnectAfter("delete-range", func() { us.finishStandalone() })
 
 	return us
 }
 
-func (us *UndoStack) recordChange() {
-	start := us.buffer.GetStartIter()
-	end := us.buffer.GetEndIter()
-	currentText, _ := us.buffer.GetText(start, end, false)
+// selection returns the offsets of the insert and selection-bound marks
+func (us *UndoStack) selection() (int, int) {
+	insert := us.buffer.GetIterAtMark(us.buffer.GetInsert()).GetOffset()
+	bound := us.buffer.GetIterAtMark(us.buffer.GetSelectionBound()).GetOffset()
+	return insert, bound
+}
 
-	// Only record if text actually changed
-	if currentText != us.lastText {
-		// Add last text to undo stack
-		us.undoStack = append(us.undoStack, us.lastText)
+func (us *UndoStack) begin() {
+	us.current = &undoAction{}
+	us.current.beforeInsert, us.current.beforeBound = us.selection()
+}
 
-		// Limit stack size
-		if len(us.undoStack) > us.maxLevels {
-			us.undoStack = us.undoStack[1:]
-		}
+func (us *UndoStack) record(delta undoDelta) {
+	if us.isUndoing || delta.text == "" {
+		return
+	}
+	if us.current == nil {
+		us.begin()
+	}
+	us.current.deltas = append(us.current.deltas, delta)
+}
 
-		// Clear redo stack on new change
-		us.redoStack = make([]string, 0)
+func (us *UndoStack) finishStandalone() {
+	if !us.isUndoing && us.userAction == 0 && us.current != nil {
+		us.finish(false)
+	}
+}
 
-		us.lastText = currentText
+// finish pushes the current action, merging typing into the previous step
+func (us *UndoStack) finish(interactive bool) {
+	action := us.current
+	us.current = nil
+	if action == nil || len(action.deltas) == 0 {
+		return
+	}
+	action.afterInsert, action.afterBound = us.selection()
+	action.typing = interactive && len(action.deltas) == 1 &&
+		utf8.RuneCountInString(action.deltas[0].text) == 1
+
+	us.redoStack = nil
+	if n := len(us.undoStack); n > 0 && action.typing && continuesTyping(us.undoStack[n-1], action.deltas[0]) {
+		prev := us.undoStack[n-1]
+		prev.deltas = append(prev.deltas, action.deltas[0])
+		prev.afterInsert, prev.afterBound = action.afterInsert, action.afterBound
+		return
+	}
+
+	us.undoStack = append(us.undoStack, action)
+	if len(us.undoStack) > us.maxLevels {
+		us.undoStack = us.undoStack[1:]
 	}
 }
 
+// continuesTyping reports whether a typed or deleted character belongs to
+// the same word-level step as prev
+func continuesTyping(prev *undoAction, delta undoDelta) bool {
+	if !prev.typing {
+		return false
+	}
+	last := prev.deltas[len(prev.deltas)-1]
+	if last.insert != delta.insert {
+		return false
+	}
+
+	lastChar, _ := utf8.DecodeLastRuneInString(last.text)
+	char, _ := utf8.DecodeRuneInString(delta.text)
+	if lastChar == '\n' || char == '\n' {
+		return false
+	}
+
+	if delta.insert {
+		// Contiguous, and not starting a new word after whitespace
+		if delta.offset != last.offset+utf8.RuneCountInString(last.text) {
+			return false
+		}
+		return !(unicode.IsSpace(lastChar) && !unicode.IsSpace(char))
+	}
+
+	// Backspace moves left, Delete stays put
+	if delta.offset+1 != last.offset && delta.offset != last.offset {
+		return false
+	}
+	return unicode.IsSpace(lastChar) == unicode.IsSpace(char)
+}
+
 func (us *UndoStack) CanUndo() bool {
 	return len(us.undoStack) > 0
 }
@@ -69,29 +173,33 @@ func (us *UndoStack) CanRedo() bool {
 	return len(us.redoStack) > 0
 }
 
+// Clear forgets all steps, e.g. after loading a file
+func (us *UndoStack) Clear() {
+	us.undoStack = nil
+	us.redoStack = nil
+	us.current = nil
+}
+
 func (us *UndoStack) Undo() {
 	if !us.CanUndo() {
 		return
 	}
 
-	// Get current text before undo
-	start := us.buffer.GetStartIter()
-	end := us.buffer.GetEndIter()
-	currentText, _ := us.buffer.GetText(start, end, false)
-
-	// Pop from undo stack
 	lastIndex := len(us.undoStack) - 1
-	textToRestore := us.undoStack[lastIndex]
+	action := us.undoStack[lastIndex]
 	us.undoStack = us.undoStack[:lastIndex]
+	us.redoStack = append(us.redoStack, action)
 
-	// Push current text to redo stack
-	us.redoStack = append(us.redoStack, currentText)
-
-	// Restore text
+	// Reverse each delta, last first
 	us.isUndoing = true
-	us.buffer.SetText(textToRestore)
-	us.lastText = textToRestore
+	for i := len(action.deltas) - 1; i >= 0; i-- {
+		d := action.deltas[i]
+		us.apply(undoDelta{offset: d.offset, text: d.text, insert: !d.insert})
+	}
 	us.isUndoing = false
+	us.restoreSelection(action.beforeInsert, action.beforeBound)
+	// Typing after an undo starts a new step
+	action.typing = false
 }
 
 func (us *UndoStack) Redo() {
@@ -99,22 +207,29 @@ func (us *UndoStack) Redo() {
 		return
 	}
 
-	// Get current text before redo
-	start := us.buffer.GetStartIter()
-	end := us.buffer.GetEndIter()
-	currentText, _ := us.buffer.GetText(start, end, false)
-
-	// Pop from redo stack
 	lastIndex := len(us.redoStack) - 1
-	textToRestore := us.redoStack[lastIndex]
+	action := us.redoStack[lastIndex]
 	us.redoStack = us.redoStack[:lastIndex]
+	us.undoStack = append(us.undoStack, action)
 
-	// Push current text to undo stack
-	us.undoStack = append(us.undoStack, currentText)
-
-	// Restore text
 	us.isUndoing = true
-	us.buffer.SetText(textToRestore)
-	us.lastText = textToRestore
+	for _, d := range action.deltas {
+		us.apply(d)
+	}
 	us.isUndoing = false
+	us.restoreSelection(action.afterInsert, action.afterBound)
+}
+
+func (us *UndoStack) apply(d undoDelta) {
+	start := us.buffer.GetIterAtOffset(d.offset)
+	if d.insert {
+		us.buffer.Insert(start, d.text)
+		return
+	}
+	end := us.buffer.GetIterAtOffset(d.offset + utf8.RuneCountInString(d.text))
+	us.buffer.Delete(start, end)
+}
+
+func (us *UndoStack) restoreSelection(insert, bound int) {
+	us.buffer.SelectRange(us.buffer.GetIterAtOffset(insert), us.buffer.GetIterAtOffset(bound))
 }
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/laurie/ps-ide-go/cmd/ps-ide/translation"
+)
+
+var (
+	watchExpressions      []string
+	watchExpressionsMutex sync.Mutex // Read by worker goroutines
+
+	// Watch pane
+	watchPane    *gtk.Box
+	watchStore   *gtk.TreeStore
+	watchView    *gtk.TreeView
+	watchResults []translation.WatchResult
+)
+
+// Watch pane columns
+const (
+	watchColumnName = iota
+	watchColumnValue
+	watchColumnType
+	watchColumnIcon
+	watchColumnColor
+	watchColumnIndex // Index into watchExpressions for top-level rows, -1 for members
+)
+
+// createWatchPane creates the Watch tool panel page
+func createWatchPane() *gtk.Box {
+	watchStore, _ = gtk.TreeStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_INT)
+
+	watchView, _ = gtk.TreeViewNew()
+	watchView.SetModel(watchStore)
+	watchView.SetHeadersVisible(true)
+
+	// Expression with error indicator
+	nameColumn, _ := gtk.TreeViewColumnNew()
+	nameColumn.SetTitle("Expression")
+	nameColumn.SetResizable(true)
+	iconRenderer, _ := gtk.CellRendererPixbufNew()
+	nameColumn.PackStart(iconRenderer, false)
+	nameColumn.AddAttribute(iconRenderer, "icon-name", watchColumnIcon)
+	nameRenderer, _ := gtk.CellRendererTextNew()
+	nameColumn.PackStart(nameRenderer, true)
+	nameColumn.AddAttribute(nameRenderer, "text", watchColumnName)
+	watchView.AppendColumn(nameColumn)
+
+	valueRenderer, _ := gtk.CellRendererTextNew()
+	valueColumn, _ := gtk.TreeViewColumnNewWithAttribute("Value", valueRenderer, "text", watchColumnValue)
+	valueColumn.AddAttribute(valueRenderer, "foreground", watchColumnColor)
+	valueColumn.SetResizable(true)
+	valueColumn.SetExpand(true)
+	watchView.AppendColumn(valueColumn)
+
+	typeRenderer, _ := gtk.CellRendererTextNew()
+	typeColumn, _ := gtk.TreeViewColumnNewWithAttribute("Type", typeRenderer, "text", watchColumnType)
+	typeColumn.SetResizable(true)
+	watchView.AppendColumn(typeColumn)
+
+	// Delete removes the selected watch
+	watchView.Connect("key-press-event", func(_ *gtk.TreeView, event *gdk.Event) bool {
+		if gdk.EventKeyNewFromEvent(event).KeyVal() == gdk.KEY_Delete {
+			removeSelectedWatch()
+			return true
+		}
+		return false
+	})
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scroll.Add(watchView)
+
+	// Entry for new expressions
+	entry, _ := gtk.EntryNew()
+	entry.SetPlaceholderText("Add watch, e.g. $results.Count")
+	addButton, _ := gtk.ButtonNewWithLabel("Add")
+	removeButton, _ := gtk.ButtonNewWithLabel("Remove")
+
+	addWatchFromEntry := func() {
+		text, _ := entry.GetText()
+		if addWatchExpression(text) {
+			entry.SetText("")
+		}
+	}
+	entry.Connect("activate", addWatchFromEntry)
+	addButton.Connect("clicked", addWatchFromEntry)
+	removeButton.Connect("clicked", removeSelectedWatch)
+
+	entryBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 4)
+	entryBox.PackStart(entry, true, true, 0)
+	entryBox.PackStart(addButton, false, false, 0)
+	entryBox.PackStart(removeButton, false, false, 0)
+
+	watchPane, _ = gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 4)
+	watchPane.PackStart(scroll, true, true, 0)
+	watchPane.PackStart(entryBox, false, false, 0)
+
+	refreshWatchPane()
+	return watchPane
+}
+
+// addWatchExpression adds a watch and evaluates it if the session is idle
+func addWatchExpression(expression string) bool {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return false
+	}
+
+	watchExpressionsMutex.Lock()
+	watchExpressions = append(watchExpressions, expression)
+	watchExpressionsMutex.Unlock()
+
+	refreshWatchPane()
+	evaluateWatchesNow()
+	return true
+}
+
+func removeSelectedWatch() {
+	selection, _ := watchView.GetSelection()
+	model, iter, ok := selection.GetSelected()
+	if !ok {
+		return
+	}
+	value, _ := model.(*gtk.TreeModel).GetValue(iter, watchColumnIndex)
+	goValue, _ := value.GoValue()
+	index, _ := goValue.(int)
+
+	watchExpressionsMutex.Lock()
+	if index >= 0 && index < len(watchExpressions) {
+		watchExpressions = append(watchExpressions[:index], watchExpressions[index+1:]...)
+	}
+	watchExpressionsMutex.Unlock()
+
+	refreshWatchPane()
+}
+
+// currentWatchExpressions returns a copy of the watch list
+func currentWatchExpressions() []string {
+	watchExpressionsMutex.Lock()
+	defer watchExpressionsMutex.Unlock()
+	return append([]string(nil), watchExpressions...)
+}
+
+func setWatchExpressions(expressions []string) {
+	watchExpressionsMutex.Lock()
+	watchExpressions = expressions
+	watchExpressionsMutex.Unlock()
+	refreshWatchPane()
+}
+
+// evaluateWatches evaluates the watch list after a command. Runs on worker
+// goroutines; returns nil if there are no watches.
+func evaluateWatches(tl *translation.TranslationLayer) []translation.WatchResult {
+	expressions := currentWatchExpressions()
+	if len(expressions) == 0 {
+		return nil
+	}
+	results, err := tl.EvaluateWatches(expressions)
+	if err != nil {
+		return nil
+	}
+	return results
+}
+
+// evaluateWatchesNow evaluates the watches immediately if nothing is running
+func evaluateWatchesNow() {
+	tl := translationLayer
+	if tl == nil || isBusy() {
+		return
+	}
+	go func() {
+		results := evaluateWatches(tl)
+		glib.IdleAdd(func() bool {
+			updateWatchResults(results)
+			return false
+		})
+	}()
+}
+
+// updateWatchResults shows newly evaluated values
+func updateWatchResults(results []translation.WatchResult) {
+	if results == nil {
+		return
+	}
+	watchResults = results
+	refreshWatchPane()
+}
+
+// refreshWatchPane rebuilds the watch tree, keeping expanded rows expanded
+func refreshWatchPane() {
+	if watchStore == nil {
+		return
+	}
+
+	expanded := make(map[string]bool)
+	for i := 0; ; i++ {
+		path, err := gtk.TreePathNewFromIndicesv([]int{i})
+		if err != nil {
+			break
+		}
+		iter, err := watchStore.GetIter(path)
+		if err != nil {
+			break
+		}
+		if watchView.RowExpanded(path) {
+			value, _ := watchStore.GetValue(iter, watchColumnName)
+			name, _ := value.GetString()
+			expanded[name] = true
+		}
+	}
+
+	results := make(map[string]translation.WatchResult)
+	for _, result := range watchResults {
+		results[result.Expression] = result
+	}
+
+	watchStore.Clear()
+	for i, expression := range currentWatchExpressions() {
+		iter := watchStore.Append(nil)
+		result, evaluated := results[expression]
+
+		value, typeName, icon, color := "", "", "", ""
+		switch {
+		case !evaluated:
+			value = "(not evaluated)"
+			color = "#808080"
+		case result.Error != "":
+			value = result.Error
+			icon = "dialog-error"
+			color = "#C00000"
+		default:
+			value = result.Value
+			typeName = result.Type
+		}
+		watchStore.SetValue(iter, watchColumnName, expression)
+		watchStore.SetValue(iter, watchColumnValue, value)
+		watchStore.SetValue(iter, watchColumnType, typeName)
+		if icon != "" {
+			watchStore.SetValue(iter, watchColumnIcon, icon)
+		}
+		if color != "" {
+			watchStore.SetValue(iter, watchColumnColor, color)
+		}
+		watchStore.SetValue(iter, watchColumnIndex, i)
+
+		for _, member := range result.Members {
+			child := watchStore.Append(iter)
+			watchStore.SetValue(child, watchColumnName, member.Name)
+			watchStore.SetValue(child, watchColumnValue, member.Value)
+			watchStore.SetValue(child, watchColumnType, member.Type)
+			watchStore.SetValue(child, watchColumnIndex, -1)
+		}
+
+		if expanded[expression] {
+			path, err := watchStore.GetPath(iter)
+			if err == nil {
+				watchView.ExpandRow(path, false)
+			}
+		}
+	}
+}
+
+// addWatchAtCursor watches the editor selection or the variable at the cursor
+func addWatchAtCursor() {
+	tab := getCurrentTab()
+	if tab == nil {
+		return
+	}
+	expression := wordAtCursor()
+	if start, end, ok := tab.buffer.GetSelectionBounds(); ok {
+		expression, _ = tab.buffer.GetText(start, end, true)
+	}
+	if strings.Contains(expression, "\n") {
+		statusLabel.SetText("Watch expressions must be a single line")
+		return
+	}
+	if addWatchExpression(expression) {
+		showWatchPane()
+	}
+}
+
+func showWatchPane() {
+	showToolPanelPage(watchPane)
+}
@@ -2,8 +2,6 @@ package highlighter
 
 import (
 	"bytes"
-	"fmt"
-	"strings"
 
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/formatters"
@@ -68,42 +66,3 @@ func GetAvailableStyles() []string {
 func (h *Highlighter) SetStyle(styleName string) {
 	h.style = styleName
 }
-
-// ValidateSyntax performs basic PowerShell syntax validation
-func ValidateSyntax(code string) []string {
-	var errors []string
-
-	// Basic validation checks
-	lines := strings.Split(code, "\n")
-	openBraces := 0
-	openParens := 0
-
-	for i, line := range lines {
-		lineNum := i + 1
-		trimmed := strings.TrimSpace(line)
-
-		// Skip comments and empty lines
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			continue
-		}
-
-		// Count braces
-		openBraces += strings.Count(line, "{") - strings.Count(line, "}")
-		openParens += strings.Count(line, "(") - strings.Count(line, ")")
-
-		// Check for common errors
-		if strings.HasSuffix(trimmed, "|") && !strings.HasSuffix(trimmed, "||") {
-			errors = append(errors, fmt.Sprintf("Line %d: Pipeline operator '|' should not end a line", lineNum))
-		}
-	}
-
-	if openBraces != 0 {
-		errors = append(errors, fmt.Sprintf("Unmatched braces: %d unclosed '{'", openBraces))
-	}
-
-	if openParens != 0 {
-		errors = append(errors, fmt.Sprintf("Unmatched parentheses: %d unclosed '('", openParens))
-	}
-
-	return errors
-}
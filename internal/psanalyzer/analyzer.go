@@ -0,0 +1,150 @@
+// Package psanalyzer checks PowerShell scripts against style rules, in the
+// spirit of PSScriptAnalyzer but over pstoken tokens, so it runs in the
+// editor without a PowerShell process. It also lists a script's symbols for
+// the editor's outline and finds what can be completed at a position.
+package psanalyzer
+
+import (
+	"sort"
+	"strings"
+)
+
+// Rule IDs, named after the matching PSScriptAnalyzer rules
+const (
+	RuleAlias          = "PSAvoidUsingCmdletAliases"
+	RulePositional     = "PSAvoidUsingPositionalParameters"
+	RuleWriteHost      = "PSAvoidUsingWriteHost"
+	RuleApprovedVerbs  = "PSUseApprovedVerbs"
+	RuleCmdletBinding  = "PSUseCmdletBinding"
+	RuleUnusedVariable = "PSUseDeclaredVarsMoreThanAssignments"
+	RuleUnusedParam    = "PSReviewUnusedParameter"
+	RuleNullComparison = "PSPossibleIncorrectComparisonWithNull"
+)
+
+// Rule describes a rule for settings dialogs
+type Rule struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// Rules lists every rule in the order they are shown
+var Rules = []Rule{
+	{RuleAlias, "Cmdlet aliases", "Use the full command name instead of an alias such as gci, % or ?"},
+	{RulePositional, "Positional parameters", "Name the parameters of commands given more than one positional argument"},
+	{RuleWriteHost, "Write-Host in functions", "Functions should write output with Write-Output, Write-Verbose or Write-Information"},
+	{RuleApprovedVerbs, "Unapproved verbs", "Function names should start with a verb from Get-Verb"},
+	{RuleCmdletBinding, "Missing [CmdletBinding()]", "Functions with a param block should be advanced functions"},
+	{RuleUnusedVariable, "Unused variables", "Variables that are assigned but never used"},
+	{RuleUnusedParam, "Unused parameters", "Parameters that are never used in the function body"},
+	{RuleNullComparison, "$null on the right of comparisons", "Put $null on the left, so arrays are not filtered instead of compared"},
+}
+
+// Finding is a rule violation. Positions are 0-based lines and byte
+// offsets within the line, as in pstoken.
+type Finding struct {
+	RuleID  string
+	Line    int
+	Start   int
+	EndLine int
+	End     int
+	Message string
+	Fix     *Fix // Nil unless the fix is mechanical
+}
+
+// Fix is a quick fix for a finding
+type Fix struct {
+	Description string
+	Edits       []Edit
+}
+
+// Edit replaces Old, the text between two byte offsets of a line, with New
+type Edit struct {
+	Line  int
+	Start int
+	End   int
+	Old   string
+	New   string
+}
+
+// Analyzer holds the rule settings and the session data rules check against
+type Analyzer struct {
+	Aliases       map[string]string // Lower-case alias -> command name
+	ApprovedVerbs map[string]bool   // Lower-case verb
+	Disabled      map[string]bool   // Rule ID
+}
+
+// New returns an analyzer with the built-in alias and verb lists
+func New() *Analyzer {
+	a := &Analyzer{
+		Aliases:       make(map[string]string, len(defaultAliases)),
+		ApprovedVerbs: make(map[string]bool, len(defaultVerbs)),
+		Disabled:      make(map[string]bool),
+	}
+	for alias, command := range defaultAliases {
+		a.Aliases[alias] = command
+	}
+	for _, verb := range defaultVerbs {
+		a.ApprovedVerbs[strings.ToLower(verb)] = true
+	}
+	return a
+}
+
+// Analyze returns the findings of the enabled rules, in script order
+func (a *Analyzer) Analyze(source string) []Finding {
+	s := parseScript(source)
+	var findings []Finding
+	for _, check := range []struct {
+		id  string
+		run func(*script, *Analyzer) []Finding
+	}{
+		{RuleAlias, checkAliases},
+		{RulePositional, checkPositional},
+		{RuleWriteHost, checkWriteHost},
+		{RuleApprovedVerbs, checkApprovedVerbs},
+		{RuleCmdletBinding, checkCmdletBinding},
+		{RuleUnusedVariable, checkUnusedVariables},
+		{RuleUnusedParam, checkUnusedParameters},
+		{RuleNullComparison, checkNullComparison},
+	} {
+		if !a.Disabled[check.id] {
+			findings = append(findings, check.run(s, a)...)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Start < findings[j].Start
+	})
+	return findings
+}
+
+// finding returns a finding covering tokens first to last
+func (s *script) finding(rule string, first, last int, message string) Finding {
+	return Finding{
+		RuleID:  rule,
+		Line:    s.tokens[first].Line,
+		Start:   s.tokens[first].Start,
+		EndLine: s.tokens[last].Line,
+		End:     s.tokens[last].End,
+		Message: message,
+	}
+}
+
+// replaceFix returns a fix replacing the text of tokens first to last,
+// which must be on one line
+func (s *script) replaceFix(description string, first, last int, text string) *Fix {
+	line := s.tokens[first].Line
+	start, end := s.tokens[first].Start, s.tokens[last].End
+	return &Fix{
+		Description: description,
+		Edits: []Edit{{
+			Line:  line,
+			Start: start,
+			End:   end,
+			Old:   s.lines[line][start:end],
+			New:   text,
+		}},
+	}
+}
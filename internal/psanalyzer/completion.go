@@ -0,0 +1,250 @@
+package psanalyzer
+
+import (
+	"strings"
+
+	"github.com/laurie/ps-ide-go/internal/pstoken"
+)
+
+// CompletionKind is what can be completed at a position
+type CompletionKind int
+
+const (
+	CompleteNone      CompletionKind = iota
+	CompleteCommand                  // Command name, e.g. Get-Ch
+	CompleteParameter                // -Pa after a command
+	CompleteVariable                 // $na or $env:PA
+	CompletePath                     // Command argument or path in quotes, e.g. .\scr
+	CompleteType                     // Type name in brackets, e.g. [System.IO.Fi
+	CompleteMember                   // Property or method after . or ::
+)
+
+// Completion is what can be completed at a position of a script. A
+// completion replaces the text from Start, a byte offset within the line,
+// to the position; Prefix is that text.
+type Completion struct {
+	Kind      CompletionKind
+	Start     int
+	Prefix    string
+	Command   string           // Command whose parameters are completed
+	Static    bool             // The member follows ::
+	Variables []string         // Variables in scope, without $: the script's, then automatic ones
+	Functions []ScriptFunction // Functions the script defines
+}
+
+// ScriptFunction is a function defined in a script
+type ScriptFunction struct {
+	Name       string
+	Parameters []string // Without $
+}
+
+// CompletionAt returns what can be completed at a 0-based line and byte
+// offset of a script. Nothing is completed inside comments.
+func CompletionAt(source string, line, column int) Completion {
+	s := parseScript(source)
+	c := Completion{Start: column}
+	if line < 0 || line >= len(s.lines) || column < 0 || column > len(s.lines[line]) {
+		return c
+	}
+	for _, comment := range s.comments {
+		if comment.Line == line && comment.Start < column && column <= comment.End {
+			return c
+		}
+	}
+
+	// The token the position is in or at the end of, and the first token
+	// after the position
+	at, next := -1, len(s.tokens)
+	for i, token := range s.tokens {
+		if token.Line > line || token.Line == line && token.Start >= column {
+			next = i
+			break
+		}
+		if token.Line == line && column <= token.End {
+			at = i
+		}
+	}
+
+	if at < 0 {
+		c.Kind = s.positionKind(next, line)
+	} else {
+		s.completeToken(&c, at, column)
+	}
+	switch c.Kind {
+	case CompleteVariable:
+		c.Variables = s.variablesAt(max(at, next), at)
+	case CompleteCommand, CompleteParameter:
+		c.Functions = s.scriptFunctions()
+	}
+	return c
+}
+
+// completeToken fills in what completes token i, which the position at
+// column is in or at the end of
+func (s *script) completeToken(c *Completion, i, column int) {
+	token := s.tokens[i]
+	prefix := token.Text[:column-token.Start]
+	kind, start := CompleteNone, token.Start
+	switch {
+	case token.Kind == pstoken.Variable || strings.HasPrefix(prefix, "$") && (token.Kind == pstoken.Command || token.Kind == pstoken.Argument):
+		kind = CompleteVariable
+	case token.Kind == pstoken.Operator && token.Text == ":" && column == token.End && s.adjacent(i) && s.tokens[i-1].Kind == pstoken.Variable:
+		// $env: before a name is typed
+		kind, start, prefix = CompleteVariable, s.tokens[i-1].Start, s.tokens[i-1].Text+":"
+	case token.Kind == pstoken.Member && s.adjacent(i) && s.isMemberAccess(i-1):
+		kind = CompleteMember
+		c.Static = s.tokens[i-1].Text == "::"
+	case s.isMemberAccess(i) && column == token.End && s.adjacent(i) && s.isOperand(i-1):
+		kind, start, prefix = CompleteMember, column, ""
+		c.Static = token.Text == "::"
+	case token.Kind == pstoken.Type && strings.HasPrefix(prefix, "["):
+		kind, start, prefix = CompleteType, token.Start+1, prefix[1:]
+	case token.Kind == pstoken.Command && s.is(i-1, "[") && s.adjacent(i) && !(s.adjacent(i-1) && s.isOperand(i-2)):
+		// An unclosed type name, as opposed to an index
+		kind = CompleteType
+	case token.Kind == pstoken.Parameter || token.Kind == pstoken.Argument && strings.HasPrefix(prefix, "-"):
+		if command := s.commandOf(i, token.Line); command >= 0 {
+			kind = CompleteParameter
+			c.Command = functionName(s.tokens[command].Text)
+		}
+	case token.Kind == pstoken.Command && !strings.ContainsAny(prefix, `\/`):
+		kind = CompleteCommand
+	case token.Kind == pstoken.Command || token.Kind == pstoken.Argument:
+		kind = CompletePath
+	case token.Kind == pstoken.String && strings.ContainsAny(prefix, `\/`) && strings.ContainsAny(prefix[:1], `'"`):
+		kind, start, prefix = CompletePath, token.Start+1, prefix[1:]
+	case (token.Kind == pstoken.Punctuation || token.Kind == pstoken.Operator) && column == token.End:
+		kind, start, prefix = s.positionKind(i+1, token.Line), column, ""
+	}
+	c.Kind, c.Start, c.Prefix = kind, start, prefix
+}
+
+// positionKind returns what can be completed between tokens, before token
+// i: a command where a statement or pipeline element starts, including
+// after a param block, a path among a command's arguments
+func (s *script) positionKind(i, line int) CompletionKind {
+	if i == 0 {
+		return CompleteCommand
+	}
+	prev := s.tokens[i-1]
+	switch {
+	case prev.Line != line && !(prev.Kind == pstoken.Punctuation && prev.Text == "`"):
+		return CompleteCommand
+	case prev.Kind == pstoken.Punctuation && (prev.Text == "(" || prev.Text == "{" || prev.Text == ";" || prev.Text == "$(" || prev.Text == "@("):
+		return CompleteCommand
+	case prev.Kind == pstoken.Operator && (prev.Text == "|" || prev.Text == "||" || prev.Text == "&&" || strings.HasSuffix(prev.Text, "=")):
+		return CompleteCommand
+	case s.is(i-1, ")") && s.match[i-1] > 0 && s.tokens[s.match[i-1]-1].Kind == pstoken.Keyword &&
+		strings.EqualFold(s.tokens[s.match[i-1]-1].Text, "param"):
+		// A statement may follow a param block on its line
+		return CompleteCommand
+	case s.commandOf(i, line) >= 0:
+		return CompletePath
+	}
+	return CompleteNone
+}
+
+// commandOf returns the command whose arguments run up to just before
+// token i, on the given line, or -1
+func (s *script) commandOf(i, line int) int {
+	after := line
+	for j := i - 1; j >= 0; j-- {
+		token := s.tokens[j]
+		if token.Line != after && !(token.Kind == pstoken.Punctuation && token.Text == "`") {
+			return -1
+		}
+		after = token.Line
+		switch {
+		case s.match[j] >= 0 && s.match[j] < j:
+			j = s.match[j] // Skip a bracketed argument
+		case token.Kind == pstoken.Command:
+			return j
+		case token.Kind == pstoken.Punctuation && token.Text != "`" && token.Text != ",":
+			return -1
+		case token.Kind == pstoken.Operator && (token.Text == "|" || token.Text == "||" || token.Text == "&&" || strings.HasSuffix(token.Text, "=")):
+			return -1
+		case token.Kind == pstoken.Keyword:
+			return -1
+		}
+	}
+	return -1
+}
+
+// isMemberAccess reports whether token i is . or ::
+func (s *script) isMemberAccess(i int) bool {
+	return i >= 0 && s.tokens[i].Kind == pstoken.Operator && (s.tokens[i].Text == "." || s.tokens[i].Text == "::")
+}
+
+// isOperand reports whether token i ends something members can follow
+func (s *script) isOperand(i int) bool {
+	if i < 0 {
+		return false
+	}
+	switch token := s.tokens[i]; token.Kind {
+	case pstoken.Variable, pstoken.Member, pstoken.Type, pstoken.String, pstoken.Number:
+		return true
+	case pstoken.Punctuation:
+		return token.Text == ")" || token.Text == "]" || token.Text == "}"
+	}
+	return false
+}
+
+// variablesAt returns the variables visible at token i, leaving out token
+// skip, which is being typed. Variables of a function are visible inside
+// it; those outside functions everywhere.
+func (s *script) variablesAt(i, skip int) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if lower := strings.ToLower(name); name != "" && !seen[lower] {
+			seen[lower] = true
+			names = append(names, name)
+		}
+	}
+	for j, token := range s.tokens {
+		if token.Kind != pstoken.Variable || j == skip || !s.visibleAt(j, i) {
+			continue
+		}
+		if s.adjacent(j+1) && s.tokens[j+1].Kind == pstoken.Operator && s.tokens[j+1].Text == ":" {
+			continue // $env: being typed
+		}
+		name := strings.TrimPrefix(token.Text, "$")
+		if strings.HasPrefix(name, "{") || strings.HasPrefix(strings.ToLower(name), "env:") {
+			continue
+		}
+		add(name)
+	}
+	for _, name := range defaultVariables {
+		add(name)
+	}
+	return names
+}
+
+// visibleAt reports whether a variable at token j can be seen at token i:
+// every function around j must also be around i
+func (s *script) visibleAt(j, i int) bool {
+	for _, f := range s.functions {
+		if f.bodyClose < 0 {
+			continue
+		}
+		if f.keyword < j && j <= f.bodyClose && !(f.keyword < i && i <= f.bodyClose) {
+			return false
+		}
+	}
+	return true
+}
+
+// scriptFunctions returns the functions the script defines with their
+// parameters
+func (s *script) scriptFunctions() []ScriptFunction {
+	var functions []ScriptFunction
+	for k := range s.functions {
+		f := &s.functions[k]
+		function := ScriptFunction{Name: functionName(s.tokens[f.name].Text)}
+		for _, param := range s.parameters(f) {
+			function.Parameters = append(function.Parameters, strings.TrimPrefix(s.tokens[param].Text, "$"))
+		}
+		functions = append(functions, function)
+	}
+	return functions
+}
@@ -0,0 +1,81 @@
+package psanalyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// completionAt runs CompletionAt at the ^ in script, which is removed
+func completionAt(script string) Completion {
+	at := strings.Index(script, "^")
+	before := strings.Split(script[:at], "\n")
+	line := len(before) - 1
+	return CompletionAt(script[:at]+script[at+1:], line, len(before[line]))
+}
+
+func TestCompletionAt(t *testing.T) {
+	tests := []struct {
+		script  string
+		kind    CompletionKind
+		start   int
+		prefix  string
+		command string
+		static  bool
+	}{
+		{script: "Get-Ch^", kind: CompleteCommand, start: 0, prefix: "Get-Ch"},
+		{script: "Get-Item | ^", kind: CompleteCommand, start: 11},
+		{script: "$x = ^", kind: CompleteCommand, start: 5},
+		{script: "Get-Item -Path (Get-Ch^", kind: CompleteCommand, start: 16, prefix: "Get-Ch"},
+		{script: "function Get-Foo { param($A) Get-Ch^ }", kind: CompleteCommand, start: 29, prefix: "Get-Ch"},
+		{script: "function Get-Foo { param($A) ^", kind: CompleteCommand, start: 29},
+		{script: "Get-Item -Pa^", kind: CompleteParameter, start: 9, prefix: "-Pa", command: "Get-Item"},
+		{script: "function Get-Foo { param($Name) } ; Get-Foo -N^", kind: CompleteParameter, start: 44, prefix: "-N", command: "Get-Foo"},
+		{script: "$na^ = 1", kind: CompleteVariable, start: 0, prefix: "$na"},
+		{script: "$env:PA^", kind: CompleteVariable, start: 0, prefix: "$env:PA"},
+		{script: "Get-Item ^", kind: CompletePath, start: 9},
+		{script: `Get-Item .\scr^`, kind: CompletePath, start: 9, prefix: `.\scr`},
+		{script: `Get-Item 'C:\Us^`, kind: CompletePath, start: 10, prefix: `C:\Us`},
+		{script: "[System.IO.Fi^", kind: CompleteType, start: 1, prefix: "System.IO.Fi"},
+		{script: "$x.Len^", kind: CompleteMember, start: 3, prefix: "Len"},
+		{script: "[string]::Emp^", kind: CompleteMember, start: 10, prefix: "Emp", static: true},
+		{script: "$x -eq ^", kind: CompleteNone, start: 7},
+		{script: "# Get-Ch^", kind: CompleteNone, start: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			c := completionAt(tt.script)
+			if c.Kind != tt.kind || c.Start != tt.start || c.Prefix != tt.prefix || c.Command != tt.command || c.Static != tt.static {
+				t.Errorf("CompletionAt() = kind %d, start %d, prefix %q, command %q, static %v; want %d, %d, %q, %q, %v",
+					c.Kind, c.Start, c.Prefix, c.Command, c.Static, tt.kind, tt.start, tt.prefix, tt.command, tt.static)
+			}
+		})
+	}
+}
+
+func TestCompletionVariablesInScope(t *testing.T) {
+	script := "$outer = 2\nfunction f { $inner = 1; $^ }\nfunction g { $other = 3 }"
+	c := completionAt(script)
+	var own []string
+	for _, name := range c.Variables {
+		if name == "_" {
+			break // The automatic variables follow the script's
+		}
+		own = append(own, name)
+	}
+	if want := []string{"outer", "inner"}; !reflect.DeepEqual(own, want) {
+		t.Errorf("Variables = %q, want %q first", own, want)
+	}
+}
+
+func TestCompletionScriptFunctions(t *testing.T) {
+	c := completionAt("function Get-Foo { param($Name, [int]$Count) }\nfilter Skip-Odd { }\nGet-^")
+	want := []ScriptFunction{
+		{Name: "Get-Foo", Parameters: []string{"Name", "Count"}},
+		{Name: "Skip-Odd"},
+	}
+	if !reflect.DeepEqual(c.Functions, want) {
+		t.Errorf("Functions = %+v, want %+v", c.Functions, want)
+	}
+}
@@ -0,0 +1,141 @@
+package psanalyzer
+
+// defaultVerbs is the Get-Verb list of PowerShell 7, used until the list
+// is read from PowerShell itself
+var defaultVerbs = []string{
+	"Add", "Approve", "Assert", "Backup", "Block", "Build", "Checkpoint", "Clear", "Close",
+	"Compare", "Complete", "Compress", "Confirm", "Connect", "Convert", "ConvertFrom", "ConvertTo",
+	"Copy", "Debug", "Deny", "Deploy", "Disable", "Disconnect", "Dismount", "Edit", "Enable",
+	"Enter", "Exit", "Expand", "Export", "Find", "Format", "Get", "Grant", "Group", "Hide",
+	"Import", "Initialize", "Install", "Invoke", "Join", "Limit", "Lock", "Measure", "Merge",
+	"Mount", "Move", "New", "Open", "Optimize", "Out", "Ping", "Pop", "Protect", "Publish",
+	"Push", "Read", "Receive", "Redo", "Register", "Remove", "Rename", "Repair", "Request",
+	"Reset", "Resize", "Resolve", "Restart", "Restore", "Resume", "Revoke", "Save", "Search",
+	"Select", "Send", "Set", "Show", "Skip", "Split", "Start", "Step", "Stop", "Submit",
+	"Suspend", "Switch", "Sync", "Test", "Trace", "Unblock", "Undo", "Uninstall", "Unlock",
+	"Unprotect", "Unpublish", "Unregister", "Update", "Use", "Wait", "Watch", "Write",
+}
+
+// defaultAliases are the built-in aliases PowerShell 7 defines on every
+// platform, used until the list is read from PowerShell itself. Aliases
+// such as ls and cat that only exist on Windows are left out.
+var defaultAliases = map[string]string{
+	"?":       "Where-Object",
+	"%":       "ForEach-Object",
+	"cd":      "Set-Location",
+	"chdir":   "Set-Location",
+	"clc":     "Clear-Content",
+	"clear":   "Clear-Host",
+	"clhy":    "Clear-History",
+	"cli":     "Clear-Item",
+	"clp":     "Clear-ItemProperty",
+	"cls":     "Clear-Host",
+	"clv":     "Clear-Variable",
+	"copy":    "Copy-Item",
+	"cpi":     "Copy-Item",
+	"cvpa":    "Convert-Path",
+	"dbp":     "Disable-PSBreakpoint",
+	"del":     "Remove-Item",
+	"dir":     "Get-ChildItem",
+	"ebp":     "Enable-PSBreakpoint",
+	"echo":    "Write-Output",
+	"epal":    "Export-Alias",
+	"epcsv":   "Export-Csv",
+	"erase":   "Remove-Item",
+	"etsn":    "Enter-PSSession",
+	"exsn":    "Exit-PSSession",
+	"fc":      "Format-Custom",
+	"fhx":     "Format-Hex",
+	"fl":      "Format-List",
+	"foreach": "ForEach-Object",
+	"ft":      "Format-Table",
+	"fw":      "Format-Wide",
+	"gal":     "Get-Alias",
+	"gbp":     "Get-PSBreakpoint",
+	"gc":      "Get-Content",
+	"gci":     "Get-ChildItem",
+	"gcm":     "Get-Command",
+	"gcs":     "Get-PSCallStack",
+	"gdr":     "Get-PSDrive",
+	"gerr":    "Get-Error",
+	"ghy":     "Get-History",
+	"gi":      "Get-Item",
+	"gjb":     "Get-Job",
+	"gl":      "Get-Location",
+	"gm":      "Get-Member",
+	"gmo":     "Get-Module",
+	"gp":      "Get-ItemProperty",
+	"gps":     "Get-Process",
+	"gpv":     "Get-ItemPropertyValue",
+	"group":   "Group-Object",
+	"gsn":     "Get-PSSession",
+	"gtz":     "Get-TimeZone",
+	"gu":      "Get-Unique",
+	"gv":      "Get-Variable",
+	"h":       "Get-History",
+	"history": "Get-History",
+	"icm":     "Invoke-Command",
+	"iex":     "Invoke-Expression",
+	"ihy":     "Invoke-History",
+	"ii":      "Invoke-Item",
+	"ipal":    "Import-Alias",
+	"ipcsv":   "Import-Csv",
+	"ipmo":    "Import-Module",
+	"irm":     "Invoke-RestMethod",
+	"iwr":     "Invoke-WebRequest",
+	"measure": "Measure-Object",
+	"mi":      "Move-Item",
+	"move":    "Move-Item",
+	"mp":      "Move-ItemProperty",
+	"nal":     "New-Alias",
+	"ndr":     "New-PSDrive",
+	"ni":      "New-Item",
+	"nmo":     "New-Module",
+	"nsn":     "New-PSSession",
+	"nv":      "New-Variable",
+	"oh":      "Out-Host",
+	"popd":    "Pop-Location",
+	"pushd":   "Push-Location",
+	"pwd":     "Get-Location",
+	"r":       "Invoke-History",
+	"rbp":     "Remove-PSBreakpoint",
+	"rcjb":    "Receive-Job",
+	"rcsn":    "Receive-PSSession",
+	"rd":      "Remove-Item",
+	"rdr":     "Remove-PSDrive",
+	"ren":     "Rename-Item",
+	"ri":      "Remove-Item",
+	"rjb":     "Remove-Job",
+	"rmo":     "Remove-Module",
+	"rni":     "Rename-Item",
+	"rnp":     "Rename-ItemProperty",
+	"rp":      "Remove-ItemProperty",
+	"rsn":     "Remove-PSSession",
+	"rv":      "Remove-Variable",
+	"rvpa":    "Resolve-Path",
+	"sajb":    "Start-Job",
+	"sal":     "Set-Alias",
+	"saps":    "Start-Process",
+	"sbp":     "Set-PSBreakpoint",
+	"select":  "Select-Object",
+	"set":     "Set-Variable",
+	"si":      "Set-Item",
+	"sl":      "Set-Location",
+	"sls":     "Select-String",
+	"sp":      "Set-ItemProperty",
+	"spjb":    "Stop-Job",
+	"spps":    "Stop-Process",
+	"sv":      "Set-Variable",
+	"where":   "Where-Object",
+	"wjb":     "Wait-Job",
+}
+
+// defaultVariables are the automatic and preference variables offered by
+// variable completion in every scope
+var defaultVariables = []string{
+	"_", "args", "ConfirmPreference", "DebugPreference", "Error", "ErrorActionPreference",
+	"false", "HOME", "Host", "InformationPreference", "input", "IsLinux", "IsMacOS", "IsWindows",
+	"LASTEXITCODE", "Matches", "MyInvocation", "null", "OFS", "PID", "ProgressPreference",
+	"PSBoundParameters", "PSCmdlet", "PSCommandPath", "PSHOME", "PSItem", "PSScriptRoot",
+	"PSVersionTable", "PWD", "this", "true", "VerbosePreference", "WarningPreference",
+}
@@ -0,0 +1,92 @@
+package psanalyzer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/laurie/ps-ide-go/internal/pstoken"
+)
+
+// FoldKind is what a fold range spans
+type FoldKind int
+
+const (
+	FoldBlock   FoldKind = iota // Braces: script blocks and hashtables
+	FoldRegion                  // #region to #endregion
+	FoldComment                 // <# #> comment
+	FoldString                  // Here-string or other string spanning lines
+)
+
+// FoldRange is a foldable part of a script. Line stays visible when it is
+// folded and the lines after it up to EndLine are hidden. Lines are 0-based.
+type FoldRange struct {
+	Kind    FoldKind
+	Line    int
+	EndLine int
+}
+
+// FoldRanges returns the foldable ranges of a script ordered by line. When
+// several start on the same line, only the outermost is kept. The line
+// closing a block, comment or string stays visible, so } else { and
+// "@ | Out-File are not hidden with it; #endregion is hidden.
+func FoldRanges(source string) []FoldRange {
+	byLine := make(map[int]FoldRange)
+	add := func(kind FoldKind, line, endLine int) {
+		if endLine <= line {
+			return
+		}
+		if r, ok := byLine[line]; !ok || endLine > r.EndLine {
+			byLine[line] = FoldRange{Kind: kind, Line: line, EndLine: endLine}
+		}
+	}
+
+	var braces, regions []int
+	comment, str := -1, -1
+	var state pstoken.State
+	for i, line := range strings.Split(source, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		before := state
+		var tokens []pstoken.Token
+		tokens, state = pstoken.TokenizeLine(line, state)
+		for k, token := range tokens {
+			switch {
+			case token.Kind == pstoken.Punctuation && (token.Text == "{" || token.Text == "@{"):
+				braces = append(braces, i)
+			case token.Kind == pstoken.Punctuation && token.Text == "}":
+				if n := len(braces); n > 0 {
+					add(FoldBlock, braces[n-1], i-1)
+					braces = braces[:n-1]
+				}
+			case token.Kind == pstoken.Comment && k == 0 && !before.InComment() && isRegionMarker(token.Text):
+				if !strings.HasPrefix(strings.ToLower(token.Text), "#endregion") {
+					regions = append(regions, i)
+				} else if n := len(regions); n > 0 {
+					add(FoldRegion, regions[n-1], i)
+					regions = regions[:n-1]
+				}
+			}
+		}
+
+		switch {
+		case !before.InComment() && state.InComment():
+			comment = i
+		case before.InComment() && !state.InComment() && comment >= 0:
+			add(FoldComment, comment, i-1)
+			comment = -1
+		}
+		switch {
+		case !before.InString() && state.InString():
+			str = i
+		case before.InString() && !state.InString() && str >= 0:
+			add(FoldString, str, i-1)
+			str = -1
+		}
+	}
+
+	ranges := make([]FoldRange, 0, len(byLine))
+	for _, r := range byLine {
+		ranges = append(ranges, r)
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Line < ranges[j].Line })
+	return ranges
+}
@@ -0,0 +1,69 @@
+package psanalyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFoldRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []FoldRange
+	}{
+		{
+			name:   "one line",
+			script: "function f { 1 }",
+			want:   []FoldRange{},
+		},
+		{
+			name:   "block keeps its closing line visible",
+			script: "function f {\n  1\n}",
+			want:   []FoldRange{{Kind: FoldBlock, Line: 0, EndLine: 1}},
+		},
+		{
+			name:   "else blocks",
+			script: "if ($a) {\n  1\n} else {\n  2\n}",
+			want: []FoldRange{
+				{Kind: FoldBlock, Line: 0, EndLine: 1},
+				{Kind: FoldBlock, Line: 2, EndLine: 3},
+			},
+		},
+		{
+			name:   "region hides its #endregion",
+			script: "#region R\n$a = 1\n#endregion",
+			want:   []FoldRange{{Kind: FoldRegion, Line: 0, EndLine: 2}},
+		},
+		{
+			name:   "block comment",
+			script: "<#\n  help\n#>\nGet-Item",
+			want:   []FoldRange{{Kind: FoldComment, Line: 0, EndLine: 1}},
+		},
+		{
+			name:   "here-string",
+			script: "$s = @\"\nx\ny\n\"@",
+			want:   []FoldRange{{Kind: FoldString, Line: 0, EndLine: 2}},
+		},
+		{
+			name:   "outermost range on a line wins",
+			script: "#region R\n$h = @{\n  a = 1\n}\n#endregion",
+			want: []FoldRange{
+				{Kind: FoldRegion, Line: 0, EndLine: 4},
+				{Kind: FoldBlock, Line: 1, EndLine: 2},
+			},
+		},
+		{
+			name:   "region marker inside a block comment",
+			script: "<#\n#region not one\n#>",
+			want:   []FoldRange{{Kind: FoldComment, Line: 0, EndLine: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FoldRanges(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FoldRanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
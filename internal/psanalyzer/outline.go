@@ -0,0 +1,307 @@
+package psanalyzer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/laurie/ps-ide-go/internal/pstoken"
+)
+
+// SymbolKind is the kind of an outline symbol
+type SymbolKind int
+
+const (
+	SymbolFunction SymbolKind = iota
+	SymbolFilter
+	SymbolWorkflow
+	SymbolConfiguration
+	SymbolClass
+	SymbolConstructor
+	SymbolMethod
+	SymbolProperty
+	SymbolEnum
+	SymbolEnumValue
+	SymbolRegion
+	SymbolParamBlock
+)
+
+var symbolKindNames = []string{
+	"function", "filter", "workflow", "configuration", "class", "constructor",
+	"method", "property", "enum", "enum value", "region", "param block",
+}
+
+func (k SymbolKind) String() string {
+	if int(k) >= 0 && int(k) < len(symbolKindNames) {
+		return symbolKindNames[k]
+	}
+	return "symbol"
+}
+
+// Symbol is an entry of a script's outline. Positions are 0-based lines
+// and byte offsets within the line; the symbol spans from its start to
+// EndLine and EndColumn, and Line and Column are where its name is.
+type Symbol struct {
+	Kind        SymbolKind
+	Name        string
+	Detail      string // Parameters, base types or property type
+	Line        int
+	Column      int
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
+	Children    []*Symbol
+}
+
+// Outline returns the functions, classes, enums, regions and the script
+// param block of a script, nested as they are in the source
+func Outline(source string) []*Symbol {
+	s := parseScript(source)
+	var symbols []*Symbol
+	for k := range s.functions {
+		symbols = append(symbols, s.functionSymbol(&s.functions[k]))
+	}
+	for i, token := range s.tokens {
+		switch {
+		case token.Kind != pstoken.Keyword:
+		case strings.EqualFold(token.Text, "class"):
+			symbols = append(symbols, s.classSymbols(i)...)
+		case strings.EqualFold(token.Text, "enum"):
+			symbols = append(symbols, s.enumSymbols(i)...)
+		case strings.EqualFold(token.Text, "param") && s.depth[i] == 0 && s.is(i+1, "("):
+			symbol := s.symbol(SymbolParamBlock, "param", i, i, s.lastInside(i+1))
+			symbol.Detail = s.parameterList(&function{params: i + 1})
+			symbols = append(symbols, symbol)
+		}
+	}
+	symbols = append(symbols, s.regionSymbols()...)
+	return nest(symbols)
+}
+
+// symbol returns a symbol named by token name, spanning tokens first to last
+func (s *script) symbol(kind SymbolKind, name string, first, at, last int) *Symbol {
+	if last >= len(s.tokens) {
+		last = len(s.tokens) - 1
+	}
+	return &Symbol{
+		Kind:        kind,
+		Name:        name,
+		Line:        s.tokens[at].Line,
+		Column:      s.tokens[at].Start,
+		StartLine:   s.tokens[first].Line,
+		StartColumn: s.tokens[first].Start,
+		EndLine:     s.tokens[last].Line,
+		EndColumn:   s.tokens[last].End,
+	}
+}
+
+func (s *script) functionSymbol(f *function) *Symbol {
+	kind := SymbolFunction
+	switch strings.ToLower(s.tokens[f.keyword].Text) {
+	case "filter":
+		kind = SymbolFilter
+	case "workflow":
+		kind = SymbolWorkflow
+	case "configuration":
+		kind = SymbolConfiguration
+	}
+	last := f.name
+	if f.bodyClose >= 0 {
+		last = f.bodyClose
+	} else if f.params >= 0 {
+		last = s.lastInside(f.params)
+	}
+	symbol := s.symbol(kind, s.tokens[f.name].Text, f.keyword, f.name, last)
+	if kind != SymbolConfiguration {
+		symbol.Detail = s.parameterList(f)
+	}
+	return symbol
+}
+
+// parameterList formats the parameters of a function as ($a, [int]$b)
+func (s *script) parameterList(f *function) string {
+	var params []string
+	for _, param := range s.parameters(f) {
+		text := s.tokens[param].Text
+		if prev := s.tokens[param-1]; prev.Kind == pstoken.Type {
+			text = prev.Text + text
+		}
+		params = append(params, text)
+	}
+	return "(" + strings.Join(params, ", ") + ")"
+}
+
+// typeDeclaration returns the name token of a class or enum declared by
+// the keyword at token i, its base types and its opening brace, or -1s
+func (s *script) typeDeclaration(i int) (name int, bases string, open int) {
+	if i+1 >= len(s.tokens) || s.tokens[i+1].Kind != pstoken.Type {
+		return -1, "", -1
+	}
+	name = i + 1
+	j := i + 2
+	if j < len(s.tokens) && s.tokens[j].Kind == pstoken.Operator && s.tokens[j].Text == ":" {
+		var list []string
+		for j++; j < len(s.tokens) && (s.tokens[j].Kind == pstoken.Type || s.is(j, ",")); j++ {
+			if s.tokens[j].Kind == pstoken.Type {
+				list = append(list, s.tokens[j].Text)
+			}
+		}
+		bases = ": " + strings.Join(list, ", ")
+	}
+	if !s.is(j, "{") {
+		return name, bases, -1
+	}
+	return name, bases, j
+}
+
+// classSymbols returns a class and its constructors, methods and properties
+func (s *script) classSymbols(i int) []*Symbol {
+	name, bases, open := s.typeDeclaration(i)
+	if name < 0 {
+		return nil
+	}
+	close := name
+	if open >= 0 {
+		if close = s.match[open]; close < 0 {
+			close = len(s.tokens) - 1
+		}
+	}
+	class := s.symbol(SymbolClass, s.tokens[name].Text, i, name, close)
+	class.Detail = bases
+	symbols := []*Symbol{class}
+	if open < 0 {
+		return symbols
+	}
+
+	depth := s.depth[open] + 1
+	for j := open + 1; j < close; j++ {
+		if s.depth[j] != depth {
+			continue
+		}
+		token := s.tokens[j]
+		switch {
+		case token.Kind == pstoken.Member && s.is(j+1, "(") && s.adjacent(j+1):
+			kind := SymbolMethod
+			if strings.EqualFold(token.Text, class.Name) {
+				kind = SymbolConstructor
+			}
+			last := s.lastInside(j + 1)
+			if s.is(last+1, "{") {
+				last = s.lastInside(last + 1)
+			}
+			method := s.symbol(kind, token.Text, j, j, last)
+			method.Detail = s.parameterList(&function{params: j + 1})
+			if prev := s.tokens[j-1]; kind == SymbolMethod && prev.Kind == pstoken.Type {
+				method.Detail += " " + prev.Text
+			}
+			symbols = append(symbols, method)
+			j = last
+		case token.Kind == pstoken.Variable && s.startsMember(j):
+			property := s.symbol(SymbolProperty, token.Text, j, j, j)
+			if prev := s.tokens[j-1]; prev.Kind == pstoken.Type {
+				property.Detail = prev.Text
+			}
+			symbols = append(symbols, property)
+		}
+	}
+	return symbols
+}
+
+// startsMember reports whether variable token i declares a class property,
+// rather than being part of an initializer
+func (s *script) startsMember(i int) bool {
+	prev := s.tokens[i-1]
+	switch {
+	case prev.Line != s.tokens[i].Line:
+		return true
+	case prev.Kind == pstoken.Type || prev.Kind == pstoken.Attribute:
+		return true
+	case prev.Kind == pstoken.Keyword:
+		return strings.EqualFold(prev.Text, "hidden") || strings.EqualFold(prev.Text, "static")
+	}
+	return s.is(i-1, "{") || s.is(i-1, ";")
+}
+
+// enumSymbols returns an enum and its values
+func (s *script) enumSymbols(i int) []*Symbol {
+	name, bases, open := s.typeDeclaration(i)
+	if name < 0 {
+		return nil
+	}
+	close := name
+	if open >= 0 {
+		if close = s.match[open]; close < 0 {
+			close = len(s.tokens) - 1
+		}
+	}
+	enum := s.symbol(SymbolEnum, s.tokens[name].Text, i, name, close)
+	enum.Detail = bases
+	symbols := []*Symbol{enum}
+	for j := open + 1; open >= 0 && j < close; j++ {
+		if s.tokens[j].Kind == pstoken.Member && s.depth[j] == s.depth[open]+1 {
+			symbols = append(symbols, s.symbol(SymbolEnumValue, s.tokens[j].Text, j, j, j))
+		}
+	}
+	return symbols
+}
+
+// regionSymbols pairs #region and #endregion comments. A region left open
+// runs to the end of the script.
+func (s *script) regionSymbols() []*Symbol {
+	var symbols, open []*Symbol
+	for _, comment := range s.regions {
+		text := strings.ToLower(comment.Text)
+		if strings.HasPrefix(text, "#endregion") {
+			if n := len(open); n > 0 {
+				open[n-1].EndLine, open[n-1].EndColumn = comment.Line, comment.End
+				open = open[:n-1]
+			}
+			continue
+		}
+		name := strings.TrimSpace(comment.Text[len("#region"):])
+		if name == "" {
+			name = "#region"
+		}
+		region := &Symbol{
+			Kind: SymbolRegion, Name: name,
+			Line: comment.Line, Column: comment.Start,
+			StartLine: comment.Line, StartColumn: comment.Start,
+			EndLine: len(s.lines) - 1, EndColumn: len(s.lines[len(s.lines)-1]),
+		}
+		symbols = append(symbols, region)
+		open = append(open, region)
+	}
+	return symbols
+}
+
+// nest puts each symbol under the innermost symbol spanning its start
+func nest(symbols []*Symbol) []*Symbol {
+	before := func(line, column, otherLine, otherColumn int) bool {
+		return line < otherLine || line == otherLine && column < otherColumn
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i], symbols[j]
+		if a.StartLine != b.StartLine || a.StartColumn != b.StartColumn {
+			return before(a.StartLine, a.StartColumn, b.StartLine, b.StartColumn)
+		}
+		return before(b.EndLine, b.EndColumn, a.EndLine, a.EndColumn)
+	})
+
+	var roots, stack []*Symbol
+	for _, symbol := range symbols {
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if before(symbol.StartLine, symbol.StartColumn, top.EndLine, top.EndColumn) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+		if n := len(stack); n > 0 {
+			stack[n-1].Children = append(stack[n-1].Children, symbol)
+		} else {
+			roots = append(roots, symbol)
+		}
+		stack = append(stack, symbol)
+	}
+	return roots
+}
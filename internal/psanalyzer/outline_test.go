@@ -0,0 +1,94 @@
+package psanalyzer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// describeSymbols lists symbols as "kind name detail line:column", with
+// children indented under their parent
+func describeSymbols(symbols []*Symbol, indent string) []string {
+	var out []string
+	for _, s := range symbols {
+		text := fmt.Sprintf("%s%s %s", indent, s.Kind, s.Name)
+		if s.Detail != "" {
+			text += " " + s.Detail
+		}
+		out = append(out, fmt.Sprintf("%s %d:%d-%d:%d", text, s.Line, s.Column, s.EndLine, s.EndColumn))
+		out = append(out, describeSymbols(s.Children, indent+"  ")...)
+	}
+	return out
+}
+
+func TestOutline(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "empty",
+			script: "",
+			want:   nil,
+		},
+		{
+			name: "functions, regions and the param block",
+			script: `param([string]$Path)
+#region Helpers
+function Get-Thing {
+    param($Name, [int]$Count)
+    function Inner { }
+}
+filter Only-Even { $_ }
+#endregion`,
+			want: []string{
+				"param block param ([string]$Path) 0:0-0:20",
+				"region Helpers 1:0-7:10",
+				"  function Get-Thing ($Name, [int]$Count) 2:9-5:1",
+				"    function Inner () 4:13-4:22",
+				"  filter Only-Even () 6:7-6:23",
+			},
+		},
+		{
+			name: "class members",
+			script: `class Point : Base {
+    [int]$X
+    hidden $Y = 2
+    Point([int]$x) { $this.X = $x }
+    [string] ToString() { return "p" }
+}`,
+			want: []string{
+				"class Point : Base 0:6-5:1",
+				"  property $X [int] 1:9-1:11",
+				"  property $Y 2:11-2:13",
+				"  constructor Point ([int]$x) 3:4-3:35",
+				"  method ToString () [string] 4:13-4:38",
+			},
+		},
+		{
+			name:   "enum values",
+			script: `enum Color { Red; Green = 2 }`,
+			want: []string{
+				"enum Color 0:5-0:29",
+				"  enum value Red 0:13-0:16",
+				"  enum value Green 0:18-0:23",
+			},
+		},
+		{
+			name:   "unclosed region runs to the end",
+			script: "#region Open\n$a = 1\n$b = 2",
+			want:   []string{"region Open 0:0-2:6"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := describeSymbols(Outline(tt.script), "")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Outline()\n got %s\nwant %s", strings.Join(got, "\n     "), strings.Join(tt.want, "\n     "))
+			}
+		})
+	}
+}
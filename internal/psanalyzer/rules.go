@@ -0,0 +1,318 @@
+package psanalyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/laurie/ps-ide-go/internal/pstoken"
+)
+
+// positionalLimit is the number of positional arguments a command may take
+// before the positional parameter rule reports it, so Get-Item $path and
+// ForEach-Object { } stay quiet
+const positionalLimit = 1
+
+// automaticVariables are set by PowerShell; assigning them without reading
+// them back is normal
+var automaticVariables = map[string]bool{
+	"_": true, "psitem": true, "null": true, "true": true, "false": true, "args": true,
+	"input": true, "this": true, "matches": true, "lastexitcode": true, "error": true,
+	"host": true, "ofs": true, "psdefaultparametervalues": true, "formatenumerationlimit": true,
+	"psboundparameters": true, "pscmdlet": true, "myinvocation": true, "psstyle": true,
+}
+
+// nullComparisons are the operators PowerShell applies as a filter when the
+// left operand is a collection
+var nullComparisons = map[string]bool{
+	"-eq": true, "-ne": true, "-ieq": true, "-ine": true, "-ceq": true, "-cne": true,
+}
+
+// checkAliases reports commands invoked through an alias
+func checkAliases(s *script, a *Analyzer) []Finding {
+	var findings []Finding
+	for i, token := range s.tokens {
+		if token.Kind != pstoken.Command || s.isDefinition(i) {
+			continue
+		}
+		lower := strings.ToLower(token.Text)
+		command, ok := a.Aliases[lower]
+		if !ok || s.definitions[lower] {
+			continue
+		}
+		f := s.finding(RuleAlias, i, i, fmt.Sprintf("'%s' is an alias of '%s'", token.Text, command))
+		f.Fix = s.replaceFix(fmt.Sprintf("Replace '%s' with '%s'", token.Text, command), i, i, command)
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// checkPositional reports cmdlets and functions called with more than
+// positionalLimit positional arguments. Native commands are left alone.
+func checkPositional(s *script, a *Analyzer) []Finding {
+	var findings []Finding
+	for i, token := range s.tokens {
+		if token.Kind != pstoken.Command || s.isDefinition(i) {
+			continue
+		}
+		lower := strings.ToLower(token.Text)
+		_, isAlias := a.Aliases[lower]
+		isCmdlet := strings.Contains(token.Text, "-") && !strings.ContainsAny(token.Text, `./\`)
+		if !isAlias && !isCmdlet && !s.definitions[lower] {
+			continue
+		}
+		if count := s.positionalArguments(s.commandElements(i)); count > positionalLimit {
+			findings = append(findings, s.finding(RulePositional, i, i,
+				fmt.Sprintf("'%s' is called with %d positional arguments; name the parameters", token.Text, count)))
+		}
+	}
+	return findings
+}
+
+// checkWriteHost reports Write-Host inside functions
+func checkWriteHost(s *script, a *Analyzer) []Finding {
+	var findings []Finding
+	for i, token := range s.tokens {
+		if token.Kind != pstoken.Command || !strings.EqualFold(token.Text, "Write-Host") {
+			continue
+		}
+		if f := s.enclosingFunction(i); f != nil {
+			findings = append(findings, s.finding(RuleWriteHost, i, i,
+				fmt.Sprintf("Function '%s' uses Write-Host, which cannot be captured or redirected; use Write-Output, Write-Verbose or Write-Information",
+					s.tokens[f.name].Text)))
+		}
+	}
+	return findings
+}
+
+// checkApprovedVerbs reports Verb-Noun functions with an unapproved verb
+func checkApprovedVerbs(s *script, a *Analyzer) []Finding {
+	var findings []Finding
+	for _, f := range s.functions {
+		name := functionName(s.tokens[f.name].Text)
+		dash := strings.Index(name, "-")
+		if dash <= 0 {
+			continue
+		}
+		verb := name[:dash]
+		if !a.ApprovedVerbs[strings.ToLower(verb)] {
+			findings = append(findings, s.finding(RuleApprovedVerbs, f.name, f.name,
+				fmt.Sprintf("'%s' uses the unapproved verb '%s'; see Get-Verb for the approved verbs", name, verb)))
+		}
+	}
+	return findings
+}
+
+// checkCmdletBinding reports functions with a param block but without
+// [CmdletBinding()]. The fix adds the attribute above param.
+func checkCmdletBinding(s *script, a *Analyzer) []Finding {
+	var findings []Finding
+	for _, f := range s.functions {
+		if f.paramKeyword < 0 || !strings.EqualFold(s.tokens[f.keyword].Text, "function") {
+			continue
+		}
+		if containsString(f.attributes, "cmdletbinding") {
+			continue
+		}
+		finding := s.finding(RuleCmdletBinding, f.name, f.name,
+			fmt.Sprintf("Function '%s' has a param block but no [CmdletBinding()]", s.tokens[f.name].Text))
+
+		param := s.tokens[f.paramKeyword]
+		line := s.lines[param.Line]
+		insert := "[CmdletBinding()] "
+		if indent := line[:param.Start]; strings.TrimSpace(indent) == "" {
+			insert = "[CmdletBinding()]\n" + indent
+		}
+		finding.Fix = &Fix{
+			Description: "Add [CmdletBinding()]",
+			Edits:       []Edit{{Line: param.Line, Start: param.Start, End: param.Start, New: insert}},
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// checkUnusedVariables reports variables that are assigned but never read.
+// A variable assigned in a function is looked for in that function; one
+// assigned outside functions anywhere in the script.
+func checkUnusedVariables(s *script, a *Analyzer) []Finding {
+	declarations := make(map[int]bool)
+	for k := range s.functions {
+		for _, param := range s.parameters(&s.functions[k]) {
+			declarations[param] = true
+		}
+	}
+
+	var findings []Finding
+	reported := make(map[string]bool)
+	for i, token := range s.tokens {
+		if token.Kind != pstoken.Variable || declarations[i] || !s.isAssignment(i) {
+			continue
+		}
+		scope, name := variableName(token.Text)
+		if (scope != "" && scope != "local" && scope != "private") || automaticVariables[name] ||
+			strings.HasSuffix(name, "preference") {
+			continue
+		}
+
+		from, to := 0, len(s.tokens)
+		key := name
+		if f := s.enclosingFunction(i); f != nil {
+			from, to = f.bodyOpen, f.bodyClose
+			key = fmt.Sprintf("%d:%s", f.bodyOpen, name)
+		}
+		if reported[key] {
+			continue
+		}
+		if !s.variableUsed(name, from, to) {
+			reported[key] = true
+			findings = append(findings, s.finding(RuleUnusedVariable, i, i,
+				fmt.Sprintf("The variable '%s' is assigned but never used", token.Text)))
+		}
+	}
+	return findings
+}
+
+// checkUnusedParameters reports parameters that the function body never
+// uses. Functions using $PSBoundParameters are skipped.
+func checkUnusedParameters(s *script, a *Analyzer) []Finding {
+	var findings []Finding
+	for k := range s.functions {
+		f := &s.functions[k]
+		if f.bodyOpen < 0 || s.variableUsed("psboundparameters", f.bodyOpen, f.bodyClose) {
+			continue
+		}
+		from := f.bodyOpen
+		if f.paramKeyword >= 0 {
+			from = s.match[f.params]
+		}
+		for _, param := range s.parameters(f) {
+			_, name := variableName(s.tokens[param].Text)
+			if !s.variableUsed(name, from, f.bodyClose) {
+				findings = append(findings, s.finding(RuleUnusedParam, param, param,
+					fmt.Sprintf("The parameter '%s' of '%s' is never used", s.tokens[param].Text, s.tokens[f.name].Text)))
+			}
+		}
+	}
+	return findings
+}
+
+// checkNullComparison reports $null on the right of -eq and -ne. The fix
+// swaps the operands when the left one is a plain variable or property.
+func checkNullComparison(s *script, a *Analyzer) []Finding {
+	var findings []Finding
+	for i := 1; i+1 < len(s.tokens); i++ {
+		op := s.tokens[i]
+		if op.Kind != pstoken.Operator || !nullComparisons[strings.ToLower(op.Text)] {
+			continue
+		}
+		null := s.tokens[i+1]
+		if null.Kind != pstoken.Variable || !strings.EqualFold(null.Text, "$null") || s.accessed(i+1) {
+			continue
+		}
+		f := s.finding(RuleNullComparison, i+1, i+1, "$null should be on the left side of comparisons")
+		if left := s.simpleOperand(i - 1); left >= 0 && s.tokens[left].Line == null.Line && s.operandEnds(i+2) {
+			line := s.lines[null.Line]
+			leftText := line[s.tokens[left].Start:s.tokens[i-1].End]
+			middle := line[s.tokens[i-1].End:null.Start]
+			f.Fix = s.replaceFix("Move $null to the left", left, i+1, null.Text+middle+leftText)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// accessed reports whether token i is followed by a member access or index
+func (s *script) accessed(i int) bool {
+	next := i + 1
+	return s.adjacent(next) && (s.is(next, "[") ||
+		s.tokens[next].Kind == pstoken.Operator && (s.tokens[next].Text == "." || s.tokens[next].Text == "::"))
+}
+
+// isAssignment reports whether variable token i is assigned with =
+func (s *script) isAssignment(i int) bool {
+	next := i + 1
+	return next < len(s.tokens) && s.tokens[next].Kind == pstoken.Operator && s.tokens[next].Text == "=" &&
+		s.tokens[next].Line == s.tokens[i].Line
+}
+
+// variableUsed reports whether a variable is read, or splatted, between
+// tokens from and to
+func (s *script) variableUsed(name string, from, to int) bool {
+	for j := max(from, 0); j < to && j < len(s.tokens); j++ {
+		token := s.tokens[j]
+		if token.Kind != pstoken.Variable && token.Kind != pstoken.Splat {
+			continue
+		}
+		scope, other := variableName(token.Text)
+		if other == name && sameScope(scope) && !s.isAssignment(j) {
+			return true
+		}
+	}
+	return false
+}
+
+// simpleOperand returns the first token of a variable or property chain
+// such as $a.b.c ending at token last, or -1. The chain must not be part
+// of a larger expression that binds tighter than a comparison.
+func (s *script) simpleOperand(last int) int {
+	i := last
+	for i > 1 && s.tokens[i].Kind == pstoken.Member && s.adjacent(i) &&
+		s.tokens[i-1].Kind == pstoken.Operator && s.tokens[i-1].Text == "." && s.adjacent(i-1) {
+		i -= 2
+	}
+	if i < 0 || s.tokens[i].Kind != pstoken.Variable {
+		return -1
+	}
+	if i == 0 {
+		return i
+	}
+	prev := s.tokens[i-1]
+	switch {
+	case prev.Line != s.tokens[i].Line:
+		if prev.Kind == pstoken.Operator || s.is(i-1, "`") || s.is(i-1, ",") {
+			return -1
+		}
+	case prev.Kind == pstoken.Keyword:
+	case prev.Kind == pstoken.Punctuation:
+		if prev.Text != "(" && prev.Text != "$(" && prev.Text != "@(" && prev.Text != "{" && prev.Text != ";" {
+			return -1
+		}
+	case prev.Kind == pstoken.Operator:
+		switch strings.ToLower(prev.Text) {
+		case "=", "-and", "-or", "-xor":
+		default:
+			return -1
+		}
+	default:
+		return -1
+	}
+	return i
+}
+
+// operandEnds reports whether token i can follow a comparison's right
+// operand without binding to it more tightly
+func (s *script) operandEnds(i int) bool {
+	if i >= len(s.tokens) {
+		return true
+	}
+	token := s.tokens[i]
+	switch {
+	case token.Kind == pstoken.Operator:
+		switch strings.ToLower(token.Text) {
+		case "+", "-", "*", "/", "%", "..", "-f":
+			return false
+		}
+	case s.is(i, ","):
+		return false
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
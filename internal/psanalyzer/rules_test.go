@@ -0,0 +1,202 @@
+package psanalyzer
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// analyzeRule runs only the given rule over source
+func analyzeRule(rule, source string) []Finding {
+	a := New()
+	for _, r := range Rules {
+		a.Disabled[r.ID] = r.ID != rule
+	}
+	return a.Analyze(source)
+}
+
+// describeFindings lists findings as "line:start-end text"
+func describeFindings(source string, findings []Finding) []string {
+	lines := strings.Split(source, "\n")
+	var out []string
+	for _, f := range findings {
+		out = append(out, fmt.Sprintf("%d:%d-%d %s", f.Line, f.Start, f.End, lines[f.Line][f.Start:f.End]))
+	}
+	return out
+}
+
+// applyFixes returns source with the edits of every quick fix made
+func applyFixes(source string, findings []Finding) string {
+	var edits []Edit
+	for _, f := range findings {
+		if f.Fix != nil {
+			edits = append(edits, f.Fix.Edits...)
+		}
+	}
+	// Edit from the end, so earlier offsets stay valid
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].Line != edits[j].Line {
+			return edits[i].Line > edits[j].Line
+		}
+		return edits[i].Start > edits[j].Start
+	})
+	lines := strings.Split(source, "\n")
+	for _, e := range edits {
+		line := lines[e.Line]
+		lines[e.Line] = line[:e.Start] + e.New + line[e.End:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		script string
+		want   []string
+		fixed  string // The script with every quick fix made; empty for no fixes
+	}{
+		{
+			name:   "aliases",
+			rule:   RuleAlias,
+			script: "gci | % { $_ }",
+			want:   []string{"0:0-3 gci", "0:6-7 %"},
+			fixed:  "Get-ChildItem | ForEach-Object { $_ }",
+		},
+		{
+			name:   "alias after a param block",
+			rule:   RuleAlias,
+			script: "function Get-Foo { param($A) gci $A }",
+			want:   []string{"0:29-32 gci"},
+			fixed:  "function Get-Foo { param($A) Get-ChildItem $A }",
+		},
+		{
+			name:   "function named like an alias",
+			rule:   RuleAlias,
+			script: "function gci { }\ngci",
+			want:   nil,
+		},
+		{
+			name:   "positional arguments",
+			rule:   RulePositional,
+			script: "Copy-Item a b\nGet-Item $path\ngit commit -m x",
+			want:   []string{"0:0-9 Copy-Item"},
+		},
+		{
+			name:   "positional arguments after a param block",
+			rule:   RulePositional,
+			script: "function Copy-Both { param($A) Copy-Item $A b }",
+			want:   []string{"0:31-40 Copy-Item"},
+		},
+		{
+			name:   "Write-Host in a function",
+			rule:   RuleWriteHost,
+			script: "function Get-Foo { param($A) Write-Host $a }\nWrite-Host 'top'",
+			want:   []string{"0:29-39 Write-Host"},
+		},
+		{
+			name:   "unapproved verb",
+			rule:   RuleApprovedVerbs,
+			script: "function Fetch-Data { }\nfunction Get-Data { }\nfunction helper { }",
+			want:   []string{"0:9-19 Fetch-Data"},
+		},
+		{
+			name:   "missing CmdletBinding on its own line",
+			rule:   RuleCmdletBinding,
+			script: "function Get-Foo {\n    param($A)\n    $A\n}",
+			want:   []string{"0:9-16 Get-Foo"},
+			fixed:  "function Get-Foo {\n    [CmdletBinding()]\n    param($A)\n    $A\n}",
+		},
+		{
+			name:   "missing CmdletBinding within a line",
+			rule:   RuleCmdletBinding,
+			script: "function Get-Foo { param($A) $A }",
+			want:   []string{"0:9-16 Get-Foo"},
+			fixed:  "function Get-Foo { [CmdletBinding()] param($A) $A }",
+		},
+		{
+			name:   "CmdletBinding present",
+			rule:   RuleCmdletBinding,
+			script: "function Get-Foo {\n    [CmdletBinding()]\n    param($A)\n    $A\n}",
+			want:   nil,
+		},
+		{
+			name:   "unused variable",
+			rule:   RuleUnusedVariable,
+			script: "$unused = 1\n$used = 2\n$used\n$global:set = 3",
+			want:   []string{"0:0-7 $unused"},
+		},
+		{
+			name:   "unused parameter",
+			rule:   RuleUnusedParam,
+			script: "function Get-Foo { [CmdletBinding()] param($A, $B) $A }",
+			want:   []string{"0:47-49 $B"},
+		},
+		{
+			name:   "parameters used through PSBoundParameters",
+			rule:   RuleUnusedParam,
+			script: "function Get-Foo { param($A, $B) Get-Bar @PSBoundParameters }",
+			want:   nil,
+		},
+		{
+			name:   "null on the right",
+			rule:   RuleNullComparison,
+			script: "if ($x -eq $null) { }\n$a.b -ne $null\n$x + 1 -eq $null\n$null -eq $x",
+			want:   []string{"0:11-16 $null", "1:9-14 $null", "2:11-16 $null"},
+			fixed:  "if ($null -eq $x) { }\n$null -ne $a.b\n$x + 1 -eq $null\n$null -eq $x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := analyzeRule(tt.rule, tt.script)
+			if got := describeFindings(tt.script, findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings\n got %q\nwant %q", got, tt.want)
+			}
+			for _, f := range findings {
+				if f.RuleID != tt.rule {
+					t.Errorf("finding of %s, want only %s", f.RuleID, tt.rule)
+				}
+			}
+			want := tt.fixed
+			if want == "" {
+				want = tt.script
+			}
+			if got := applyFixes(tt.script, findings); got != want {
+				t.Errorf("fixed script\n got %q\nwant %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeOrderAndMessages(t *testing.T) {
+	script := "function Get-Foo { param($A) Write-Host $a }"
+	var got []string
+	for _, f := range New().Analyze(script) {
+		got = append(got, f.RuleID+": "+f.Message)
+	}
+	want := []string{
+		RuleCmdletBinding + ": Function 'Get-Foo' has a param block but no [CmdletBinding()]",
+		RuleWriteHost + ": Function 'Get-Foo' uses Write-Host, which cannot be captured or redirected; use Write-Output, Write-Verbose or Write-Information",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Analyze()\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFixEditsRecordOldText(t *testing.T) {
+	script := "gci\nif ($x -eq $null) { }"
+	for _, f := range New().Analyze(script) {
+		if f.Fix == nil {
+			continue
+		}
+		lines := strings.Split(script, "\n")
+		for _, e := range f.Fix.Edits {
+			if old := lines[e.Line][e.Start:e.End]; old != e.Old {
+				t.Errorf("%s: edit Old = %q, text is %q", f.RuleID, e.Old, old)
+			}
+		}
+	}
+}
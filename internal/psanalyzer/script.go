@@ -0,0 +1,318 @@
+package psanalyzer
+
+import (
+	"strings"
+
+	"github.com/laurie/ps-ide-go/internal/pstoken"
+)
+
+// script is a tokenized script with its brackets matched and its function
+// definitions found
+type script struct {
+	lines       []string
+	tokens      []pstoken.Token // Comments are left out
+	regions     []pstoken.Token // #region and #endregion comments
+	comments    []pstoken.Token // Line comments and lines of block comments
+	match       []int           // Index of the matching bracket, or -1
+	depth       []int           // Number of brackets around each token
+	functions   []function
+	definitions map[string]bool // Lower-case names of the functions defined
+}
+
+// function is a function, filter, workflow or configuration definition.
+// Fields are token indexes, -1 if missing.
+type function struct {
+	keyword      int
+	name         int
+	params       int      // ( of the signature or of the param block
+	paramKeyword int      // param, if the parameters are in a param block
+	attributes   []string // Lower-case names of the attributes before param
+	bodyOpen     int
+	bodyClose    int // len(tokens) if the body is not closed
+}
+
+var closers = map[string]string{"(": ")", "$(": ")", "@(": ")", "{": "}", "@{": "}", "[": "]"}
+
+func parseScript(source string) *script {
+	s := &script{definitions: make(map[string]bool)}
+	var state pstoken.State
+	for i, line := range strings.Split(source, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		s.lines = append(s.lines, line)
+		inComment := state.InComment()
+		var tokens []pstoken.Token
+		tokens, state = pstoken.TokenizeLine(line, state)
+		for k, token := range tokens {
+			token.Line = i
+			if token.Kind == pstoken.Comment {
+				s.comments = append(s.comments, token)
+			}
+			switch {
+			case token.Kind != pstoken.Comment:
+				s.tokens = append(s.tokens, token)
+			case k == 0 && !inComment && isRegionMarker(token.Text):
+				s.regions = append(s.regions, token)
+			}
+		}
+	}
+	s.matchBrackets()
+	s.findFunctions()
+	return s
+}
+
+func (s *script) matchBrackets() {
+	s.match = make([]int, len(s.tokens))
+	s.depth = make([]int, len(s.tokens))
+	var stack []int
+	for i, token := range s.tokens {
+		s.match[i] = -1
+		s.depth[i] = len(stack)
+		if token.Kind != pstoken.Punctuation {
+			continue
+		}
+		if _, ok := closers[token.Text]; ok {
+			stack = append(stack, i)
+			continue
+		}
+		if n := len(stack); n > 0 && closers[s.tokens[stack[n-1]].Text] == token.Text {
+			open := stack[n-1]
+			stack = stack[:n-1]
+			s.match[open], s.match[i] = i, open
+			s.depth[i] = len(stack)
+		}
+	}
+}
+
+func (s *script) findFunctions() {
+	for i := 0; i+1 < len(s.tokens); i++ {
+		if s.tokens[i].Kind != pstoken.Keyword || s.tokens[i+1].Kind != pstoken.Command {
+			continue
+		}
+		switch strings.ToLower(s.tokens[i].Text) {
+		case "function", "filter", "workflow", "configuration":
+		default:
+			continue
+		}
+		f := function{keyword: i, name: i + 1, params: -1, paramKeyword: -1, bodyOpen: -1, bodyClose: -1}
+		s.definitions[strings.ToLower(functionName(s.tokens[i+1].Text))] = true
+
+		j := i + 2
+		if s.is(j, "(") {
+			f.params = j
+			if j = s.match[j]; j < 0 {
+				s.functions = append(s.functions, f)
+				continue
+			}
+			j++
+		}
+		if s.is(j, "{") {
+			f.bodyOpen = j
+			f.bodyClose = s.match[j]
+			if f.bodyClose < 0 {
+				f.bodyClose = len(s.tokens)
+			}
+			if f.params < 0 {
+				s.findParamBlock(&f)
+			}
+		}
+		s.functions = append(s.functions, f)
+	}
+}
+
+// findParamBlock looks for attributes and a param block at the start of a
+// function body
+func (s *script) findParamBlock(f *function) {
+	for j := f.bodyOpen + 1; j < f.bodyClose; j++ {
+		token := s.tokens[j]
+		switch {
+		case token.Kind == pstoken.Attribute && strings.HasPrefix(token.Text, "["):
+			f.attributes = append(f.attributes, strings.ToLower(strings.TrimPrefix(token.Text, "[")))
+			if s.is(j+1, "(") && s.match[j+1] > 0 {
+				j = s.match[j+1]
+			}
+		case token.Kind == pstoken.Attribute:
+			// Closing ] of an attribute
+		case token.Kind == pstoken.Keyword && strings.EqualFold(token.Text, "param") && s.is(j+1, "("):
+			f.paramKeyword = j
+			f.params = j + 1
+			return
+		default:
+			return
+		}
+	}
+}
+
+// isRegionMarker reports whether a line comment is #region or #endregion
+func isRegionMarker(comment string) bool {
+	lower := strings.ToLower(comment)
+	for _, marker := range []string{"#region", "#endregion"} {
+		if strings.HasPrefix(lower, marker) && (len(lower) == len(marker) || lower[len(marker)] == ' ' || lower[len(marker)] == '\t') {
+			return true
+		}
+	}
+	return false
+}
+
+// is reports whether token i is the given punctuation
+func (s *script) is(i int, text string) bool {
+	return i >= 0 && i < len(s.tokens) && s.tokens[i].Kind == pstoken.Punctuation && s.tokens[i].Text == text
+}
+
+// adjacent reports whether token i directly follows token i-1
+func (s *script) adjacent(i int) bool {
+	return i > 0 && i < len(s.tokens) && s.tokens[i].Line == s.tokens[i-1].Line && s.tokens[i].Start == s.tokens[i-1].End
+}
+
+// isDefinition reports whether token i is the name of a function definition
+func (s *script) isDefinition(i int) bool {
+	for _, f := range s.functions {
+		if f.name == i {
+			return true
+		}
+	}
+	return false
+}
+
+// enclosingFunction returns the innermost function whose body holds token
+// i, or nil
+func (s *script) enclosingFunction(i int) *function {
+	var inner *function
+	for k := range s.functions {
+		f := &s.functions[k]
+		if f.bodyOpen >= 0 && f.bodyOpen < i && i < f.bodyClose && (inner == nil || f.bodyOpen > inner.bodyOpen) {
+			inner = f
+		}
+	}
+	return inner
+}
+
+// parameters returns the token indexes of the parameter variables declared
+// in a function's signature or param block
+func (s *script) parameters(f *function) []int {
+	if f.params < 0 || s.match[f.params] < 0 {
+		return nil
+	}
+	var params []int
+	depth := s.depth[f.params] + 1
+	for j := f.params + 1; j < s.match[f.params]; j++ {
+		if s.tokens[j].Kind != pstoken.Variable || s.depth[j] != depth {
+			continue
+		}
+		prev := s.tokens[j-1]
+		if j-1 == f.params || s.is(j-1, ",") || prev.Kind == pstoken.Type || prev.Kind == pstoken.Attribute {
+			params = append(params, j)
+		}
+	}
+	return params
+}
+
+// element is a command argument: tokens first to last
+type element struct {
+	first, last int
+}
+
+// commandElements returns the arguments of the command named by token i.
+// Elements are separated by spaces; brackets and the items of a
+// comma-separated list belong to the element they start in.
+func (s *script) commandElements(i int) []element {
+	var elements []element
+	depth := s.depth[i]
+	skipNext := false
+	for j := i + 1; j < len(s.tokens); j++ {
+		token := s.tokens[j]
+		if s.depth[j] > depth {
+			continue
+		}
+		if s.match[j] >= 0 && s.match[j] < j {
+			if s.match[j] < i {
+				break // Closes a bracket around the command
+			}
+			continue // Closes a bracket inside an argument
+		}
+		prev := s.tokens[j-1]
+		if token.Line != prev.Line && !(prev.Kind == pstoken.Punctuation && prev.Text == "`") {
+			break
+		}
+		switch {
+		case token.Kind == pstoken.Punctuation && (token.Text == ";" || token.Text == "`"):
+			if token.Text == ";" {
+				return elements
+			}
+			continue
+		case token.Kind == pstoken.Operator && (token.Text == "|" || token.Text == "||" || token.Text == "&&"):
+			return elements
+		case len(elements) > 0 && (s.adjacent(j) || s.is(j, ",") || s.is(j-1, ",")):
+			elements[len(elements)-1].last = s.lastInside(j)
+			continue
+		case token.Kind == pstoken.Operator:
+			// Redirection; its target, unless merged into it or another
+			// stream, is not an argument
+			skipNext = !strings.Contains(token.Text, "&") && !s.adjacent(j+1)
+			continue
+		}
+		if skipNext {
+			skipNext = false
+			continue
+		}
+		elements = append(elements, element{j, s.lastInside(j)})
+	}
+	return elements
+}
+
+// lastInside returns the closing bracket of an opening bracket at i, or i
+func (s *script) lastInside(i int) int {
+	if s.match[i] > i {
+		return s.match[i]
+	}
+	return i
+}
+
+// positionalArguments counts the arguments not bound by parameter name. An
+// argument after a parameter is taken as its value, so switches followed
+// by a positional argument are missed.
+func (s *script) positionalArguments(elements []element) int {
+	count := 0
+	for k := 0; k < len(elements); k++ {
+		first := s.tokens[elements[k].first]
+		if first.Kind != pstoken.Parameter {
+			count++
+			continue
+		}
+		if !strings.HasSuffix(first.Text, ":") && k+1 < len(elements) &&
+			s.tokens[elements[k+1].first].Kind != pstoken.Parameter {
+			k++
+		}
+	}
+	return count
+}
+
+// functionName strips a scope such as global: from a function name
+func functionName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// variableName splits a variable token into its lower-case scope and name
+func variableName(text string) (scope, name string) {
+	name = strings.TrimPrefix(strings.TrimPrefix(text, "$"), "@")
+	if strings.HasPrefix(name, "{") && strings.HasSuffix(name, "}") {
+		name = name[1 : len(name)-1]
+	}
+	name = strings.ToLower(name)
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// sameScope reports whether a scope modifier can refer to a variable of
+// the current scope
+func sameScope(scope string) bool {
+	switch scope {
+	case "", "local", "private", "script", "using":
+		return true
+	}
+	return false
+}
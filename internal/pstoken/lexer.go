@@ -0,0 +1,1060 @@
+package pstoken
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Constructs that stay open across tokens, and across lines
+const (
+	openParen      = '(' // ( or @(
+	openSubExpr    = '$' // $( in script or in an expandable string
+	openIndex      = '['
+	openBrace      = '{' // Script block
+	openHashtable  = 'h'
+	openClass      = 'c'
+	openEnum       = 'e'
+	openAttribute  = 'a' // Arguments of an attribute
+	openDouble     = '"'
+	openSingle     = '\''
+	openHereDouble = 'H'
+	openHereSingle = 'S'
+	openComment    = '#'
+)
+
+// Tokenizer modes, i.e. what a bare word means at the current position
+const (
+	modeStatement byte = 's' // Start of a statement: keywords, commands and expressions
+	modePipeline  byte = 'p' // After |: a command, or an expression
+	modeInvoke    byte = 'i' // After & or dot-sourcing: the command to run
+	modeArgs      byte = 'a' // Command arguments
+	modeExpr      byte = 'x' // Inside an expression
+	modeKey       byte = 'k' // Hashtable key, class or enum member, named attribute argument
+	modeName      byte = 'n' // Name of a function being defined
+	modeClassName byte = 'c' // Class name and base types, up to the class body
+	modeEnumName  byte = 'e' // Enum name and base type, up to the enum body
+)
+
+var keywords = map[string]bool{
+	"begin": true, "break": true, "catch": true, "class": true, "clean": true,
+	"configuration": true, "continue": true, "data": true, "default": true,
+	"define": true, "do": true, "dynamicparam": true, "else": true,
+	"elseif": true, "end": true, "enum": true, "exit": true, "filter": true,
+	"finally": true, "for": true, "foreach": true, "from": true,
+	"function": true, "hidden": true, "if": true, "in": true,
+	"inlinescript": true, "parallel": true, "param": true, "process": true,
+	"return": true, "sequence": true, "static": true, "switch": true,
+	"throw": true, "trap": true, "try": true, "until": true, "using": true,
+	"var": true, "while": true, "workflow": true,
+}
+
+// Operators that take a case-sensitive (c) or insensitive (i) prefix
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "gt": true, "ge": true, "lt": true, "le": true,
+	"like": true, "notlike": true, "match": true, "notmatch": true,
+	"replace": true, "contains": true, "notcontains": true, "in": true,
+	"notin": true, "split": true,
+}
+
+var otherOperators = map[string]bool{
+	"is": true, "isnot": true, "as": true, "and": true, "or": true,
+	"xor": true, "not": true, "band": true, "bor": true, "bxor": true,
+	"bnot": true, "shl": true, "shr": true, "join": true, "f": true,
+}
+
+// IsKeyword reports whether word is a PowerShell keyword
+func IsKeyword(word string) bool {
+	return keywords[strings.ToLower(word)]
+}
+
+// IsOperatorWord reports whether word, without its dash, is an operator
+// such as eq, cne or join
+func IsOperatorWord(word string) bool {
+	word = strings.ToLower(word)
+	if comparisonOperators[word] || otherOperators[word] {
+		return true
+	}
+	if len(word) > 1 && (word[0] == 'c' || word[0] == 'i') {
+		return comparisonOperators[word[1:]]
+	}
+	return false
+}
+
+type lexer struct {
+	line      string
+	pos       int
+	stack     []byte // Pairs of construct and resume mode
+	mode      byte
+	tokens    []Token
+	member    bool // The next word is a member name
+	attribute bool // The next ] closes an attribute
+	continued bool // The line ended with a line continuation
+}
+
+// TokenizeLine tokenizes one line, without its line ending, starting in
+// state. It returns the tokens and the state the next line starts in.
+func TokenizeLine(line string, state State) ([]Token, State) {
+	l := &lexer{line: line, stack: []byte(state.stack), mode: state.mode}
+	if l.mode == 0 {
+		l.mode = l.lineMode()
+	}
+
+	for l.pos < len(l.line) {
+		switch l.top() {
+		case openDouble, openHereDouble:
+			l.expandableString(l.pos)
+		case openSingle, openHereSingle:
+			l.verbatimString(l.pos)
+		case openComment:
+			l.blockComment(l.pos)
+		default:
+			l.script()
+		}
+	}
+
+	// Continue arguments after a line continuation, and a class or enum
+	// header whose body starts on a later line
+	next := State{stack: string(l.stack)}
+	if l.continued || l.mode == modeClassName || l.mode == modeEnumName {
+		next.mode = l.mode
+	}
+	return l.tokens, next
+}
+
+// lineMode is the mode a new line or statement starts in
+func (l *lexer) lineMode() byte {
+	switch l.top() {
+	case openHashtable, openClass, openEnum, openAttribute:
+		return modeKey
+	}
+	return modeStatement
+}
+
+func (l *lexer) top() byte {
+	if len(l.stack) < 2 {
+		return 0
+	}
+	return l.stack[len(l.stack)-2]
+}
+
+func (l *lexer) push(construct, resume byte) {
+	l.stack = append(l.stack, construct, resume)
+}
+
+// pop closes the innermost construct and resumes the mode before it
+func (l *lexer) pop() byte {
+	if len(l.stack) < 2 {
+		return 0
+	}
+	construct := l.stack[len(l.stack)-2]
+	l.mode = l.stack[len(l.stack)-1]
+	l.stack = l.stack[:len(l.stack)-2]
+	return construct
+}
+
+func (l *lexer) emit(kind Kind, start, end int) {
+	if end > start {
+		l.tokens = append(l.tokens, Token{Kind: kind, Start: start, End: end, Text: l.line[start:end]})
+	}
+	l.pos = end
+}
+
+// afterOperand is the mode after a value such as a variable or string
+func afterOperand(mode byte) byte {
+	switch mode {
+	case modeArgs, modeInvoke:
+		return modeArgs
+	case modeClassName, modeEnumName:
+		return mode
+	}
+	return modeExpr
+}
+
+// followsOperand reports whether the last token ends at pos and is a value
+// that . [ or :: can apply to
+func (l *lexer) followsOperand() bool {
+	if len(l.tokens) == 0 {
+		return false
+	}
+	last := l.tokens[len(l.tokens)-1]
+	if last.End != l.pos {
+		return false
+	}
+	switch last.Kind {
+	case Variable, Member, String, Type:
+		return true
+	case Punctuation:
+		return last.Text == ")" || last.Text == "]" || last.Text == "}"
+	}
+	return false
+}
+
+// lastKind returns the kind of the last token on the line, ignoring
+// comments, or Unknown at the start of the line
+func (l *lexer) lastKind() Kind {
+	for i := len(l.tokens) - 1; i >= 0; i-- {
+		if l.tokens[i].Kind != Comment {
+			return l.tokens[i].Kind
+		}
+	}
+	return Unknown
+}
+
+// statementBody reports whether a { opens the body of a statement such as
+// a function, try or if, which a statement may follow on the same line,
+// rather than a script block value
+func (l *lexer) statementBody() bool {
+	var prev []Token
+	for _, token := range l.tokens {
+		if token.Kind != Comment {
+			prev = append(prev, token)
+		}
+	}
+	if len(prev) == 0 {
+		return false
+	}
+	last := prev[len(prev)-1]
+	switch {
+	case last.Kind == Keyword:
+		return true
+	case last.Kind == Punctuation && last.Text == ")":
+		// Only the parentheses of a keyword resume in statement mode
+		return l.mode == modeStatement
+	case last.Kind == Command && l.mode == modeExpr && len(prev) > 1:
+		// A function name
+		return prev[len(prev)-2].Kind == Keyword
+	}
+	return false
+}
+
+func (l *lexer) at(i int) byte {
+	if i < len(l.line) {
+		return l.line[i]
+	}
+	return 0
+}
+
+// script tokenizes one token outside strings and block comments
+func (l *lexer) script() {
+	start := l.pos
+	c := l.line[start]
+	next := l.at(start + 1)
+
+	if l.member {
+		l.member = false
+		if end := l.nameEnd(start); end > start {
+			l.emit(Member, start, end)
+			return
+		}
+	}
+
+	switch {
+	case isSpace(c):
+		l.pos++
+		return
+	case c == '<' && next == '#':
+		l.push(openComment, l.mode)
+		l.blockComment(start + 2)
+		return
+	case c == '#':
+		l.emit(Comment, start, len(l.line))
+		return
+	case c == '`' && strings.TrimSpace(l.line[start+1:]) == "":
+		l.emit(Punctuation, start, start+1)
+		l.pos = len(l.line)
+		l.continued = true
+		return
+	}
+
+	if quote, size := quoteAt(l.line, start); quote != 0 {
+		l.pos = start + size
+		if quote == '"' {
+			l.push(openDouble, afterOperand(l.mode))
+			l.expandableString(start)
+		} else {
+			l.push(openSingle, afterOperand(l.mode))
+			l.verbatimString(start)
+		}
+		return
+	}
+
+	switch c {
+	case '$':
+		if next == '(' {
+			l.push(openSubExpr, afterOperand(l.mode))
+			l.emit(Punctuation, start, start+2)
+			l.mode = modeStatement
+			return
+		}
+		if end := variableEnd(l.line, start); end > start {
+			l.emit(Variable, start, end)
+			l.mode = afterOperand(l.mode)
+			return
+		}
+	case '@':
+		if l.hereStringStart(start) {
+			return
+		}
+		switch {
+		case next == '(':
+			l.push(openParen, afterOperand(l.mode))
+			l.emit(Punctuation, start, start+2)
+			l.mode = modeStatement
+			return
+		case next == '{':
+			l.push(openHashtable, afterOperand(l.mode))
+			l.emit(Punctuation, start, start+2)
+			l.mode = modeKey
+			return
+		}
+		if end := l.nameEnd(start + 1); end > start+1 {
+			l.emit(Splat, start, end)
+			l.mode = afterOperand(l.mode)
+			return
+		}
+		l.emit(Operator, start, start+1)
+		return
+	case '(':
+		resume := afterOperand(l.mode)
+		if l.mode == modeStatement && l.lastKind() == Keyword {
+			// param(...), if (...) and the like: a statement may follow
+			resume = modeStatement
+		}
+		l.push(openParen, resume)
+		l.emit(Punctuation, start, start+1)
+		l.mode = modeStatement
+		return
+	case '{':
+		switch l.mode {
+		case modeClassName:
+			l.push(openClass, modeExpr)
+			l.mode = modeKey
+		case modeEnumName:
+			l.push(openEnum, modeExpr)
+			l.mode = modeKey
+		default:
+			resume := afterOperand(l.mode)
+			if l.statementBody() {
+				resume = modeStatement
+			}
+			l.push(openBrace, resume)
+			l.mode = modeStatement
+		}
+		l.emit(Punctuation, start, start+1)
+		return
+	case ')':
+		l.emit(Punctuation, start, start+1)
+		switch l.top() {
+		case openParen, openSubExpr:
+			l.pop()
+		case openAttribute:
+			l.pop()
+			l.attribute = true
+		default:
+			l.mode = afterOperand(l.mode)
+		}
+		return
+	case '}':
+		l.emit(Punctuation, start, start+1)
+		switch l.top() {
+		case openBrace, openHashtable, openClass, openEnum:
+			l.pop()
+		default:
+			l.mode = modeExpr
+		}
+		return
+	case ']':
+		if l.attribute {
+			l.attribute = false
+			l.emit(Attribute, start, start+1)
+			return
+		}
+		l.emit(Punctuation, start, start+1)
+		if l.top() == openIndex {
+			l.pop()
+		}
+		return
+	case ';':
+		l.emit(Punctuation, start, start+1)
+		l.mode = l.lineMode()
+		return
+	case ',':
+		l.emit(Punctuation, start, start+1)
+		switch {
+		case l.top() == openAttribute:
+			l.mode = modeKey
+		case l.mode != modeArgs && l.mode != modeClassName && l.mode != modeEnumName:
+			l.mode = modeExpr
+		}
+		return
+	case '|':
+		if next == '|' {
+			l.emit(Operator, start, start+2)
+			l.mode = modeStatement
+			return
+		}
+		l.emit(Operator, start, start+1)
+		l.mode = modePipeline
+		return
+	case '&':
+		if next == '&' {
+			l.emit(Operator, start, start+2)
+			l.mode = modeStatement
+			return
+		}
+		l.emit(Operator, start, start+1)
+		if l.mode == modeArgs || l.mode == modeExpr {
+			l.mode = modeStatement // Background operator
+		} else {
+			l.mode = modeInvoke
+		}
+		return
+	}
+
+	if end := l.redirection(start); end > start {
+		l.emit(Operator, start, end)
+		l.mode = modeArgs
+		return
+	}
+
+	if l.mode == modeArgs {
+		l.argument(start)
+	} else {
+		l.expression(start)
+	}
+}
+
+// argument tokenizes a token in command arguments
+func (l *lexer) argument(start int) {
+	c := l.line[start]
+	switch {
+	case c == '-' && isNameStart(l.line, start+1):
+		end := l.nameEnd(start + 1)
+		if l.at(end) == ':' {
+			end++
+		}
+		l.emit(Parameter, start, end)
+		return
+	case c == '-' && isDigit(l.at(start+1)):
+		if end := numberEnd(l.line, start+1); end > 0 && isArgumentEnd(l.line, end) {
+			l.emit(Number, start, end)
+			return
+		}
+	case isDigit(c):
+		if end := numberEnd(l.line, start); end > 0 && isArgumentEnd(l.line, end) {
+			l.emit(Number, start, end)
+			return
+		}
+	case c == '.' && l.followsOperand() && isNameStart(l.line, start+1):
+		l.emit(Operator, start, start+1)
+		l.member = true
+		return
+	case c == '[' && l.followsOperand():
+		l.push(openIndex, modeArgs)
+		l.emit(Punctuation, start, start+1)
+		l.mode = modeStatement
+		return
+	}
+	end := genericEnd(l.line, start)
+	if end == start {
+		l.emit(Operator, start, start+1)
+		return
+	}
+	l.emit(Argument, start, end)
+}
+
+// expression tokenizes a token at the start of a statement or inside an
+// expression
+func (l *lexer) expression(start int) {
+	c := l.line[start]
+	next := l.at(start + 1)
+	commandPosition := l.mode == modeStatement || l.mode == modePipeline || l.mode == modeInvoke
+
+	switch c {
+	case '[':
+		if l.followsOperand() {
+			l.push(openIndex, afterOperand(l.mode))
+			l.emit(Punctuation, start, start+1)
+			l.mode = modeStatement
+			return
+		}
+		l.typeLiteral(start)
+		return
+	case '.':
+		switch {
+		case next == '.':
+			l.emit(Operator, start, start+2)
+			l.mode = modeExpr
+			return
+		case l.followsOperand():
+			l.emit(Operator, start, start+1)
+			l.member = true
+			return
+		case isDigit(next):
+			if end := numberEnd(l.line, start); end > 0 {
+				l.emit(Number, start, end)
+				l.mode = afterOperand(l.mode)
+				return
+			}
+		case commandPosition && (next == 0 || isSpace(next) || next == '$' || next == '{' || next == '('):
+			// Dot-sourcing
+			l.emit(Operator, start, start+1)
+			l.mode = modeInvoke
+			return
+		case !commandPosition:
+			l.emit(Operator, start, start+1)
+			return
+		}
+	case ':':
+		switch {
+		case next == ':':
+			l.emit(Operator, start, start+2)
+			l.member = true
+			return
+		case l.mode == modeStatement && isNameStart(l.line, start+1):
+			l.emit(Label, start, l.nameEnd(start+1))
+			return
+		}
+		l.emit(Operator, start, start+1)
+		return
+	case '-':
+		if isNameStart(l.line, start+1) {
+			end := l.nameEnd(start + 1)
+			if IsOperatorWord(l.line[start+1 : end]) {
+				l.emit(Operator, start, end)
+				l.mode = modeExpr
+				return
+			}
+			if l.at(end) == ':' {
+				end++
+			}
+			l.emit(Parameter, start, end)
+			return
+		}
+	}
+
+	if isDigit(c) {
+		if end := numberEnd(l.line, start); end > 0 {
+			l.emit(Number, start, end)
+			l.mode = afterOperand(l.mode)
+			return
+		}
+	}
+
+	if end := l.operatorEnd(start); end > start {
+		// After | or &, % and ? are the ForEach-Object and Where-Object aliases
+		alias := (l.mode == modePipeline || l.mode == modeInvoke) && (c == '%' || c == '?') && isArgumentEnd(l.line, end)
+		if !alias {
+			l.emit(Operator, start, end)
+			switch l.line[start:end] {
+			case "=", "+=", "-=", "*=", "/=", "%=", "??=":
+				l.mode = modeStatement
+			case "?.":
+				l.member = true
+			case "++", "--":
+			default:
+				l.mode = modeExpr
+			}
+			return
+		}
+	}
+
+	l.word(start)
+}
+
+// word tokenizes a bare word outside command arguments
+func (l *lexer) word(start int) {
+	switch l.mode {
+	case modeStatement, modePipeline, modeInvoke, modeName:
+		end := genericEnd(l.line, start)
+		if end == start {
+			l.emit(Unknown, start, start+runeSize(l.line, start))
+			return
+		}
+		word := strings.ToLower(l.line[start:end])
+		if l.mode == modeStatement && keywords[word] {
+			l.emit(Keyword, start, end)
+			l.keyword(word)
+			return
+		}
+		l.emit(Command, start, end)
+		if l.mode == modeName {
+			l.mode = modeExpr
+		} else {
+			l.mode = modeArgs
+		}
+		return
+	}
+
+	end := l.nameEnd(start)
+	if end == start {
+		l.emit(Unknown, start, start+runeSize(l.line, start))
+		return
+	}
+	word := strings.ToLower(l.line[start:end])
+	switch l.mode {
+	case modeClassName, modeEnumName:
+		l.emit(Type, start, end)
+	case modeKey:
+		if l.top() == openClass && (word == "hidden" || word == "static") {
+			l.emit(Keyword, start, end)
+			return
+		}
+		l.emit(Member, start, end)
+		l.mode = modeExpr
+	default:
+		if keywords[word] {
+			l.emit(Keyword, start, end)
+			l.keyword(word)
+			return
+		}
+		l.emit(Argument, start, end)
+	}
+}
+
+// keyword sets the mode for what follows a keyword
+func (l *lexer) keyword(word string) {
+	switch word {
+	case "function", "filter", "workflow", "configuration":
+		l.mode = modeName
+	case "class":
+		l.mode = modeClassName
+	case "enum":
+		l.mode = modeEnumName
+	case "switch", "break", "continue", "using":
+		l.mode = modeArgs
+	default:
+		l.mode = modeStatement
+	}
+}
+
+// typeLiteral tokenizes [ as a type literal, an attribute or an array index
+func (l *lexer) typeLiteral(start int) {
+	name := skipSpaces(l.line, start+1)
+	end := typeNameEnd(l.line, name)
+	if end > name {
+		after := skipSpaces(l.line, end)
+		switch l.at(after) {
+		case ']':
+			l.emit(Type, start, after+1)
+			if l.mode != modeKey && l.mode != modeClassName && l.mode != modeEnumName {
+				l.mode = modeExpr
+			}
+			return
+		case '(':
+			l.emit(Attribute, start, end)
+			l.push(openAttribute, l.mode)
+			l.emit(Punctuation, after, after+1)
+			l.mode = modeKey
+			return
+		}
+	}
+	l.push(openIndex, afterOperand(l.mode))
+	l.emit(Punctuation, start, start+1)
+	l.mode = modeStatement
+}
+
+// hereStringStart opens a here-string if @" or @' ends the line
+func (l *lexer) hereStringStart(start int) bool {
+	quote, size := quoteAt(l.line, start+1)
+	if quote == 0 || strings.TrimSpace(l.line[start+1+size:]) != "" {
+		return false
+	}
+	if quote == '"' {
+		l.push(openHereDouble, afterOperand(l.mode))
+	} else {
+		l.push(openHereSingle, afterOperand(l.mode))
+	}
+	l.emit(String, start, len(l.line))
+	return true
+}
+
+// expandableString tokenizes a double-quoted string or here-string from
+// l.pos, with its variables and sub-expressions. The string token starts
+// at start.
+func (l *lexer) expandableString(start int) {
+	here := l.top() == openHereDouble
+	if here && l.pos == 0 {
+		if quote, size := quoteAt(l.line, 0); quote == '"' && l.at(size) == '@' {
+			l.emit(String, 0, size+1)
+			l.pop()
+			return
+		}
+	}
+
+	i := l.pos
+	for i < len(l.line) {
+		c := l.line[i]
+		switch {
+		case c == '`':
+			i += 1 + runeSize(l.line, i+1)
+			continue
+		case c == '$' && l.at(i+1) == '(':
+			l.emit(String, start, i)
+			l.push(openSubExpr, modeExpr)
+			l.emit(Punctuation, i, i+2)
+			l.mode = modeStatement
+			return
+		case c == '$':
+			if end := variableEnd(l.line, i); end > i {
+				l.emit(String, start, i)
+				l.emit(Variable, i, end)
+				start, i = end, end
+				continue
+			}
+		case !here:
+			if quote, size := quoteAt(l.line, i); quote == '"' {
+				if next, nextSize := quoteAt(l.line, i+size); next == '"' {
+					i += size + nextSize // Escaped quote
+					continue
+				}
+				l.emit(String, start, i+size)
+				l.pop()
+				return
+			}
+		}
+		i += runeSize(l.line, i)
+	}
+	l.emit(String, start, len(l.line))
+}
+
+// verbatimString tokenizes a single-quoted string or here-string from l.pos
+func (l *lexer) verbatimString(start int) {
+	if l.top() == openHereSingle {
+		if quote, size := quoteAt(l.line, 0); l.pos == 0 && quote == '\'' && l.at(size) == '@' {
+			l.emit(String, 0, size+1)
+			l.pop()
+			return
+		}
+		l.emit(String, start, len(l.line))
+		return
+	}
+
+	i := l.pos
+	for i < len(l.line) {
+		if quote, size := quoteAt(l.line, i); quote == '\'' {
+			if next, nextSize := quoteAt(l.line, i+size); next == '\'' {
+				i += size + nextSize // Escaped quote
+				continue
+			}
+			l.emit(String, start, i+size)
+			l.pop()
+			return
+		}
+		i += runeSize(l.line, i)
+	}
+	l.emit(String, start, len(l.line))
+}
+
+// blockComment tokenizes a <# #> comment from l.pos, looking for its end
+// from from
+func (l *lexer) blockComment(from int) {
+	if end := strings.Index(l.line[from:], "#>"); end >= 0 {
+		l.emit(Comment, l.pos, from+end+2)
+		l.pop()
+		return
+	}
+	l.emit(Comment, l.pos, len(l.line))
+}
+
+// operatorEnd returns the end of the operator at start, or start
+func (l *lexer) operatorEnd(start int) int {
+	rest := l.line[start:]
+	for _, op := range []string{"??=", "+=", "-=", "*=", "/=", "%=", "++", "--", "??", "?.", "!"} {
+		if strings.HasPrefix(rest, op) {
+			return start + len(op)
+		}
+	}
+	switch rest[0] {
+	case '=', '+', '-', '*', '/', '%', '?', '<', '>':
+		return start + 1
+	}
+	return start
+}
+
+// redirection returns the end of a redirection operator such as > or 2>&1
+// at start, or start
+func (l *lexer) redirection(start int) int {
+	i := start
+	if c := l.line[i]; (c >= '1' && c <= '6') || c == '*' {
+		if l.at(i+1) != '>' || (l.mode != modeArgs && c != '*') {
+			return start
+		}
+		i++
+	}
+	if l.at(i) != '>' {
+		return start
+	}
+	i++
+	if l.at(i) == '>' {
+		i++
+	} else if l.at(i) == '&' && (l.at(i+1) == '1' || l.at(i+1) == '2') {
+		i += 2
+	}
+	return i
+}
+
+func (l *lexer) nameEnd(start int) int {
+	return nameEnd(l.line, start)
+}
+
+// variableEnd returns the end of the variable whose $ is at start, or start
+func variableEnd(line string, start int) int {
+	i := start + 1
+	if i >= len(line) {
+		return start
+	}
+	switch line[i] {
+	case '{':
+		for j := i + 1; j < len(line); j++ {
+			switch line[j] {
+			case '`':
+				j++
+			case '}':
+				return j + 1
+			}
+		}
+		return len(line)
+	case '$', '?', '^':
+		return i + 1
+	}
+
+	end := nameEnd(line, i)
+	if end == i {
+		return start
+	}
+	// A scope or drive qualifier such as $env: or $script:
+	if end < len(line) && line[end] == ':' && isNameStart(line, end+1) {
+		end = nameEnd(line, end+1)
+	}
+	return end
+}
+
+// typeNameEnd returns the end of the type name starting at start, including
+// generic arguments and array ranks, or start
+func typeNameEnd(line string, start int) int {
+	if !isNameStart(line, start) {
+		return start
+	}
+	i := start
+	for i < len(line) {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if !isNameRune(r) && r != '.' && r != '`' && r != '+' {
+			break
+		}
+		i += size
+	}
+
+	for i < len(line) && line[i] == '[' {
+		j := skipSpaces(line, i+1)
+		if j < len(line) && (line[j] == ']' || line[j] == ',') {
+			// Array rank
+			for j < len(line) && (line[j] == ',' || isSpace(line[j])) {
+				j++
+			}
+			if j >= len(line) || line[j] != ']' {
+				return i
+			}
+			i = j + 1
+			continue
+		}
+
+		// Generic arguments, each optionally in brackets
+		for {
+			bracketed := j < len(line) && line[j] == '['
+			if bracketed {
+				j = skipSpaces(line, j+1)
+			}
+			end := typeNameEnd(line, j)
+			if end == j {
+				return i
+			}
+			j = skipSpaces(line, end)
+			if bracketed {
+				if j >= len(line) || line[j] != ']' {
+					return i
+				}
+				j = skipSpaces(line, j+1)
+			}
+			if j < len(line) && line[j] == ',' {
+				j = skipSpaces(line, j+1)
+				continue
+			}
+			break
+		}
+		if j >= len(line) || line[j] != ']' {
+			return i
+		}
+		i = j + 1
+	}
+	return i
+}
+
+// numberEnd returns the end of the number literal at start, or 0
+func numberEnd(line string, start int) int {
+	i := start
+	lower := strings.ToLower(line[start:])
+	switch {
+	case strings.HasPrefix(lower, "0x"):
+		i += 2
+		for i < len(line) && isHexDigit(line[i]) {
+			i++
+		}
+		if i == start+2 {
+			return 0
+		}
+	case strings.HasPrefix(lower, "0b"):
+		i += 2
+		for i < len(line) && (line[i] == '0' || line[i] == '1') {
+			i++
+		}
+		if i == start+2 {
+			return 0
+		}
+	default:
+		for i < len(line) && isDigit(line[i]) {
+			i++
+		}
+		if i+1 < len(line) && line[i] == '.' && isDigit(line[i+1]) {
+			i++
+			for i < len(line) && isDigit(line[i]) {
+				i++
+			}
+		}
+		if i == start {
+			return 0
+		}
+		if i < len(line) && (line[i] == 'e' || line[i] == 'E') {
+			j := i + 1
+			if j < len(line) && (line[j] == '+' || line[j] == '-') {
+				j++
+			}
+			if j < len(line) && isDigit(line[j]) {
+				for j < len(line) && isDigit(line[j]) {
+					j++
+				}
+				i = j
+			}
+		}
+	}
+
+	// Type suffix, then multiplier
+	lower = strings.ToLower(line[i:])
+	for _, suffix := range []string{"ul", "us", "uy", "u", "l", "d", "n", "y", "s"} {
+		if strings.HasPrefix(lower, suffix) {
+			i += len(suffix)
+			lower = lower[len(suffix):]
+			break
+		}
+	}
+	for _, multiplier := range []string{"kb", "mb", "gb", "tb", "pb"} {
+		if strings.HasPrefix(lower, multiplier) {
+			i += len(multiplier)
+			break
+		}
+	}
+
+	if r, _ := utf8.DecodeRuneInString(line[i:]); i < len(line) && isNameRune(r) {
+		return 0
+	}
+	return i
+}
+
+// genericEnd returns the end of a bare word such as a command name or
+// argument starting at start
+func genericEnd(line string, start int) int {
+	i := start
+	for i < len(line) {
+		c := line[i]
+		if c == '`' {
+			if strings.TrimSpace(line[i+1:]) == "" {
+				break // Line continuation
+			}
+			i += 1 + runeSize(line, i+1)
+			continue
+		}
+		if isSpace(c) || strings.IndexByte(";|&(){},<>", c) >= 0 {
+			break
+		}
+		i += runeSize(line, i)
+	}
+	return i
+}
+
+// isArgumentEnd reports whether a token ending at i is a whole argument
+func isArgumentEnd(line string, i int) bool {
+	return i >= len(line) || isSpace(line[i]) || strings.IndexByte(";|&(){},", line[i]) >= 0
+}
+
+// quoteAt returns '"' or '\” and its size if a quote, including the
+// typographic quotes PowerShell accepts, is at i
+func quoteAt(line string, i int) (byte, int) {
+	if i >= len(line) {
+		return 0, 0
+	}
+	switch line[i] {
+	case '"', '\'':
+		return line[i], 1
+	}
+	r, size := utf8.DecodeRuneInString(line[i:])
+	switch r {
+	case '“', '”', '„':
+		return '"', size
+	case '‘', '’', '‚', '‛':
+		return '\'', size
+	}
+	return 0, 0
+}
+
+func skipSpaces(line string, i int) int {
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	return i
+}
+
+func runeSize(line string, i int) int {
+	if i >= len(line) {
+		return 0
+	}
+	_, size := utf8.DecodeRuneInString(line[i:])
+	return size
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// nameEnd returns the end of the name starting at start
+func nameEnd(line string, start int) int {
+	i := start
+	for i < len(line) {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if !isNameRune(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isNameStart(line string, i int) bool {
+	if i >= len(line) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(line[i:])
+	return r == '_' || unicode.IsLetter(r)
+}
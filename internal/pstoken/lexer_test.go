@@ -0,0 +1,340 @@
+package pstoken
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// describe lists tokens as "Kind text" for comparison
+func describe(tokens []Token) []string {
+	var out []string
+	for _, token := range tokens {
+		out = append(out, token.Kind.String()+" "+token.Text)
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "requires comment",
+			script: "#requires -Version 7.0\nGet-Item",
+			want:   []string{"Comment #requires -Version 7.0", "Command Get-Item"},
+		},
+		{
+			name: "block comment",
+			script: `<#
+    Multi-line comment block
+#>
+$simpleVar = "Hello World"`,
+			want: []string{
+				"Comment <#",
+				"Comment     Multi-line comment block",
+				"Comment #>",
+				"Variable $simpleVar", "Operator =", `String "Hello World"`,
+			},
+		},
+		{
+			name:   "block comment within a line",
+			script: `Get-Item <# inline #> -Path x`,
+			want:   []string{"Command Get-Item", "Comment <# inline #>", "Parameter -Path", "Argument x"},
+		},
+		{
+			name: "expandable here-string",
+			script: `$hereString = @"
+This is a here-string
+and can contain $variables
+"@`,
+			want: []string{
+				"Variable $hereString", "Operator =", `String @"`,
+				"String This is a here-string",
+				"String and can contain ", "Variable $variables",
+				`String "@`,
+			},
+		},
+		{
+			name: "verbatim here-string",
+			script: `$s = @'
+literal $x "@
+'@`,
+			want: []string{
+				"Variable $s", "Operator =", "String @'",
+				`String literal $x "@`,
+				"String '@",
+			},
+		},
+		{
+			name:   "subexpressions in an expandable string",
+			script: `"$($this.FirstName) $($this.LastName)"`,
+			want: []string{
+				`String "`, "Punctuation $(", "Variable $this", "Operator .", "Member FirstName", "Punctuation )",
+				"String  ", "Punctuation $(", "Variable $this", "Operator .", "Member LastName", "Punctuation )",
+				`String "`,
+			},
+		},
+		{
+			name:   "nested subexpressions and strings",
+			script: `"a $(if ($x) { "in $($b[0])" }) z"`,
+			want: []string{
+				`String "a `, "Punctuation $(", "Keyword if", "Punctuation (", "Variable $x", "Punctuation )",
+				"Punctuation {", `String "in `, "Punctuation $(", "Variable $b", "Punctuation [", "Number 0",
+				"Punctuation ]", "Punctuation )", `String "`, "Punctuation }", "Punctuation )", `String  z"`,
+			},
+		},
+		{
+			name: "subexpression spanning here-string lines",
+			script: `@"
+Value: $(Get-Date -Format "yyyy $($y)")
+"@`,
+			want: []string{
+				`String @"`,
+				"String Value: ", "Punctuation $(", "Command Get-Date", "Parameter -Format", `String "yyyy `,
+				"Punctuation $(", "Variable $y", "Punctuation )", `String "`, "Punctuation )",
+				`String "@`,
+			},
+		},
+		{
+			name:   "static members",
+			script: `$person = [Person]::new("John", "Doe")`,
+			want: []string{
+				"Variable $person", "Operator =", "Type [Person]", "Operator ::", "Member new",
+				"Punctuation (", `String "John"`, "Punctuation ,", `String "Doe"`, "Punctuation )",
+			},
+		},
+		{
+			name:   "static members of generic types",
+			script: `[System.Collections.Generic.List[string]]::new().Count`,
+			want: []string{
+				"Type [System.Collections.Generic.List[string]]", "Operator ::", "Member new",
+				"Punctuation (", "Punctuation )", "Operator .", "Member Count",
+			},
+		},
+		{
+			name: "splatting",
+			script: `$params = @{
+    Path = "C:\Temp"
+}
+Get-ChildItem @params -Force`,
+			want: []string{
+				"Variable $params", "Operator =", "Punctuation @{",
+				"Member Path", "Operator =", `String "C:\Temp"`,
+				"Punctuation }",
+				"Command Get-ChildItem", "Splat @params", "Parameter -Force",
+			},
+		},
+		{
+			name:   "type literals",
+			script: `[System.Collections.ArrayList]$list = @()`,
+			want:   []string{"Type [System.Collections.ArrayList]", "Variable $list", "Operator =", "Punctuation @(", "Punctuation )"},
+		},
+		{
+			name:   "array type literal",
+			script: `$arr = [int[]]@(1, 2)`,
+			want: []string{
+				"Variable $arr", "Operator =", "Type [int[]]", "Punctuation @(",
+				"Number 1", "Punctuation ,", "Number 2", "Punctuation )",
+			},
+		},
+		{
+			name:   "catch type",
+			script: `} catch [System.DivideByZeroException] {`,
+			want:   []string{"Punctuation }", "Keyword catch", "Type [System.DivideByZeroException]", "Punctuation {"},
+		},
+		{
+			name:   "attributes",
+			script: `[CmdletBinding(DefaultParameterSetName='Default')]`,
+			want: []string{
+				"Attribute [CmdletBinding", "Punctuation (", "Member DefaultParameterSetName",
+				"Operator =", "String 'Default'", "Punctuation )", "Attribute ]",
+			},
+		},
+		{
+			name:   "pipeline",
+			script: `Get-Process | Where-Object { $_.CPU -gt 100 } | Select-Object Name, CPU`,
+			want: []string{
+				"Command Get-Process", "Operator |", "Command Where-Object", "Punctuation {",
+				"Variable $_", "Operator .", "Member CPU", "Operator -gt", "Number 100", "Punctuation }",
+				"Operator |", "Command Select-Object", "Argument Name", "Punctuation ,", "Argument CPU",
+			},
+		},
+		{
+			name:   "operators",
+			script: `$condition = -not ($a -eq $b)`,
+			want: []string{
+				"Variable $condition", "Operator =", "Operator -not", "Punctuation (",
+				"Variable $a", "Operator -eq", "Variable $b", "Punctuation )",
+			},
+		},
+		{
+			name:   "function definition",
+			script: `function Get-CustomData {`,
+			want:   []string{"Keyword function", "Command Get-CustomData", "Punctuation {"},
+		},
+		{
+			name:   "loop label",
+			script: `:outer foreach ($i in 1..3) { break outer }`,
+			want: []string{
+				"Label :outer", "Keyword foreach", "Punctuation (", "Variable $i", "Keyword in",
+				"Number 1", "Operator ..", "Number 3", "Punctuation )", "Punctuation {",
+				"Keyword break", "Argument outer", "Punctuation }",
+			},
+		},
+		{
+			name:   "numbers",
+			script: `0x1F + 1.5e3 + 10kb + 7L`,
+			want: []string{
+				"Number 0x1F", "Operator +", "Number 1.5e3", "Operator +",
+				"Number 10kb", "Operator +", "Number 7L",
+			},
+		},
+		{
+			name:   "variables",
+			script: `${weird name} = $env:USERPROFILE`,
+			want:   []string{"Variable ${weird name}", "Operator =", "Variable $env:USERPROFILE"},
+		},
+		{
+			name:   "redirection",
+			script: `Get-ChildItem > out.txt 2>&1`,
+			want:   []string{"Command Get-ChildItem", "Operator >", "Argument out.txt", "Operator 2>&1"},
+		},
+		{
+			name:   "command after a param block",
+			script: `function Get-Foo { param($A) Write-Host $a }`,
+			want: []string{
+				"Keyword function", "Command Get-Foo", "Punctuation {", "Keyword param",
+				"Punctuation (", "Variable $A", "Punctuation )",
+				"Command Write-Host", "Variable $a", "Punctuation }",
+			},
+		},
+		{
+			name:   "keyword after a statement block",
+			script: `if ($x) { Get-Item } else { Get-Date }`,
+			want: []string{
+				"Keyword if", "Punctuation (", "Variable $x", "Punctuation )",
+				"Punctuation {", "Command Get-Item", "Punctuation }",
+				"Keyword else", "Punctuation {", "Command Get-Date", "Punctuation }",
+			},
+		},
+		{
+			name:   "operator after a parenthesized expression",
+			script: `(Get-Item x).Name -eq 'x'`,
+			want: []string{
+				"Punctuation (", "Command Get-Item", "Argument x", "Punctuation )",
+				"Operator .", "Member Name", "Operator -eq", "String 'x'",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := describe(Tokenize(tt.script))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q)\n got %q\nwant %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeLineState(t *testing.T) {
+	tests := []struct {
+		name        string
+		lines       []string
+		wantString  []bool // After each line
+		wantComment []bool
+	}{
+		{
+			name:        "block comment",
+			lines:       []string{"<#", "text", "#>", "$x"},
+			wantString:  []bool{false, false, false, false},
+			wantComment: []bool{true, true, false, false},
+		},
+		{
+			name:        "expandable here-string",
+			lines:       []string{`$h = @"`, "text $x", `"@`},
+			wantString:  []bool{true, true, false},
+			wantComment: []bool{false, false, false},
+		},
+		{
+			name:        "verbatim here-string ends only at the start of a line",
+			lines:       []string{"@'", " '@", "'@"},
+			wantString:  []bool{true, true, false},
+			wantComment: []bool{false, false, false},
+		},
+		{
+			name:        "multi-line string",
+			lines:       []string{`"one`, `two"`},
+			wantString:  []bool{true, false},
+			wantComment: []bool{false, false},
+		},
+		{
+			name:        "comment in a subexpression of a here-string",
+			lines:       []string{`@"`, `$(<#`, `#>)`, `"@`},
+			wantString:  []bool{true, false, true, false},
+			wantComment: []bool{false, true, false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var state State
+			for i, line := range tt.lines {
+				_, state = TokenizeLine(line, state)
+				if state.InString() != tt.wantString[i] {
+					t.Errorf("line %d: InString() = %v, want %v", i, state.InString(), tt.wantString[i])
+				}
+				if state.InComment() != tt.wantComment[i] {
+					t.Errorf("line %d: InComment() = %v, want %v", i, state.InComment(), tt.wantComment[i])
+				}
+			}
+		})
+	}
+}
+
+// TestTokenizeTestScript checks the sample script is tokenized completely:
+// tokens are in order, match their text and cover everything but spaces
+func TestTokenizeTestScript(t *testing.T) {
+	data, err := os.ReadFile("../../test/test-syntax.ps1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	tokens := Tokenize(string(data))
+
+	covered := make([][]bool, len(lines))
+	for i, line := range lines {
+		covered[i] = make([]bool, len(line))
+	}
+	for i, token := range tokens {
+		if token.Kind == Unknown {
+			t.Errorf("line %d: unknown token %q", token.Line+1, token.Text)
+		}
+		if i > 0 {
+			prev := tokens[i-1]
+			if token.Line < prev.Line || (token.Line == prev.Line && token.Start < prev.End) {
+				t.Errorf("line %d: token %q overlaps or precedes %q", token.Line+1, token.Text, prev.Text)
+			}
+		}
+		line := lines[token.Line]
+		if token.Start < 0 || token.End > len(line) || line[token.Start:token.End] != token.Text {
+			t.Errorf("line %d: token %q does not match [%d:%d]", token.Line+1, token.Text, token.Start, token.End)
+			continue
+		}
+		for j := token.Start; j < token.End; j++ {
+			covered[token.Line][j] = true
+		}
+	}
+	for i, line := range lines {
+		for j := 0; j < len(line); j++ {
+			if !covered[i][j] && !isSpace(line[j]) && line[j] != '\r' {
+				t.Errorf("line %d: %q at column %d is not in a token", i+1, line[j], j+1)
+				break
+			}
+		}
+	}
+}
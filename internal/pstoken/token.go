@@ -0,0 +1,112 @@
+// Package pstoken tokenizes PowerShell scripts following the tokenizer
+// rules of the PowerShell language specification, closely enough to tell
+// commands, parameters, arguments, types, attributes and members apart.
+//
+// Scripts are tokenized a line at a time. The State at the end of each
+// line records the open strings, comments and brackets, so an editor can
+// resume tokenizing at any line after an edit.
+package pstoken
+
+import "strings"
+
+// Kind classifies a token
+type Kind int
+
+const (
+	Unknown     Kind = iota
+	Comment          // # line comment or part of a <# #> block comment
+	Keyword          // Language keyword such as if, function or param
+	String           // Literal text of a string, including its quotes
+	Variable         // $name, $scope:name or ${name}
+	Splat            // @name
+	Number           // Integer or real literal, with any suffix and multiplier
+	Operator         // Operators, including -operator words
+	Punctuation      // Brackets, separators, $( and @( and line continuations
+	Command          // Command name, or the name of a function being defined
+	Parameter        // -Name in command arguments
+	Argument         // Bare word command argument
+	Type             // Type literal such as [System.IO.File]
+	Attribute        // [Name( and the closing ] of an attribute
+	Member           // Property, method, hashtable key or enum value
+	Label            // :name before a loop
+)
+
+var kindNames = [...]string{
+	Unknown:     "Unknown",
+	Comment:     "Comment",
+	Keyword:     "Keyword",
+	String:      "String",
+	Variable:    "Variable",
+	Splat:       "Splat",
+	Number:      "Number",
+	Operator:    "Operator",
+	Punctuation: "Punctuation",
+	Command:     "Command",
+	Parameter:   "Parameter",
+	Argument:    "Argument",
+	Type:        "Type",
+	Attribute:   "Attribute",
+	Member:      "Member",
+	Label:       "Label",
+}
+
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "Unknown"
+}
+
+// Token is a token on one line. Tokens spanning lines, such as here-strings
+// and block comments, are split into one token per line.
+type Token struct {
+	Kind  Kind
+	Line  int
+	Start int // Byte offset within the line
+	End   int
+	Text  string
+}
+
+// State is the tokenizer state between lines. The zero State is the start
+// of a script. States are comparable: if a line starts in the same state
+// as before, it tokenizes the same way.
+type State struct {
+	stack string // Pairs of an open construct and the mode to resume after it
+	mode  byte   // Mode of the next line after a line continuation, or 0
+}
+
+// InString reports whether the next line starts inside a string
+func (s State) InString() bool {
+	switch s.top() {
+	case openDouble, openSingle, openHereDouble, openHereSingle:
+		return true
+	}
+	return false
+}
+
+// InComment reports whether the next line starts inside a block comment
+func (s State) InComment() bool {
+	return s.top() == openComment
+}
+
+func (s State) top() byte {
+	if len(s.stack) < 2 {
+		return 0
+	}
+	return s.stack[len(s.stack)-2]
+}
+
+// Tokenize splits a script into tokens
+func Tokenize(script string) []Token {
+	var tokens []Token
+	var state State
+	for line, text := range strings.Split(script, "\n") {
+		var lineTokens []Token
+		lineTokens, state = TokenizeLine(strings.TrimSuffix(text, "\r"), state)
+		for _, token := range lineTokens {
+			token.Line = line
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
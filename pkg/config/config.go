@@ -23,6 +23,9 @@ type Config struct {
 	ExecutionTimeout int    `json:"executionTimeout"` // in seconds
 	PowerShellPath   string `json:"powerShellPath"`
 
+	// Script analyzer rules turned off, by rule ID
+	DisabledAnalyzerRules []string `json:"disabledAnalyzerRules"`
+
 	// Recent files
 	RecentFiles []string `json:"recentFiles"`
 }